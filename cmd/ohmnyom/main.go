@@ -4,28 +4,63 @@ import (
 	"context"
 	"log"
 	"net"
+	"net/http"
 	"os"
 	"path/filepath"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/aiceru/protonyom/gonyom"
+	"google.golang.org/api/option"
+	htransport "google.golang.org/api/transport/http"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/encoding"
 	"google.golang.org/grpc/encoding/gzip"
 	"ohmnyom/cmd/ohmnyom/servers"
+	"ohmnyom/domain/idempotency"
+	"ohmnyom/domain/pet"
+	"ohmnyom/domain/user"
+	"ohmnyom/internal/auditlog"
+	"ohmnyom/internal/authz"
+	"ohmnyom/internal/cascade"
+	"ohmnyom/internal/config"
 	"ohmnyom/internal/firestore"
+	auditstore "ohmnyom/internal/firestore/audit"
+	deletionstore "ohmnyom/internal/firestore/deletion"
+	devicestore "ohmnyom/internal/firestore/device"
 	feedstore "ohmnyom/internal/firestore/feed"
+	foodstore "ohmnyom/internal/firestore/food"
+	healthstore "ohmnyom/internal/firestore/health"
+	householdstore "ohmnyom/internal/firestore/household"
+	idempotencystore "ohmnyom/internal/firestore/idempotency"
+	invitestore "ohmnyom/internal/firestore/invite"
 	petstore "ohmnyom/internal/firestore/pet"
+	reminderstore "ohmnyom/internal/firestore/reminder"
+	schedulestore "ohmnyom/internal/firestore/schedule"
+	tokenstore "ohmnyom/internal/firestore/token"
+	uowstore "ohmnyom/internal/firestore/uow"
 	userstore "ohmnyom/internal/firestore/user"
+	weightstore "ohmnyom/internal/firestore/weight"
 	"ohmnyom/internal/interceptor"
 	"ohmnyom/internal/jwt"
+	"ohmnyom/internal/notify"
+	"ohmnyom/internal/oauth"
 	"ohmnyom/internal/path"
+	"ohmnyom/internal/reminder"
+	"ohmnyom/internal/revocation"
+	"ohmnyom/internal/sitter"
+	"ohmnyom/internal/storage"
 	"ohmnyom/internal/storage/googleStorage"
+	"ohmnyom/internal/storage/localStorage"
 )
 
-func printAddress() {
-	nif, _ := net.InterfaceByName("en0")
+func printAddress(name string) {
+	nif, err := net.InterfaceByName(name)
+	if err != nil {
+		log.Printf("cannot find interface %v: %v", name, err)
+		return
+	}
 	addrs, _ := nif.Addrs()
 	for _, addr := range addrs {
 		re, _ := regexp.Compile(`^(((25[0-5]|2[0-4][0-9]|[01]?[0-9][0-9]?)(\.|$)){4})`)
@@ -35,22 +70,82 @@ func printAddress() {
 	}
 }
 
+// newStorage creates the configured storage backend. Local storage is served
+// on LocalAddr when it is set.
+func newStorage(ctx context.Context, cfg config.Storage) (storage.Storage, error) {
+	if cfg.Backend != config.StorageLocal {
+		return googleStorage.New(ctx, cfg.CredentialFile, cfg.OwnerEntity)
+	}
+
+	dir := cfg.LocalDir
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(path.Root(), dir)
+	}
+	baseUrl := cfg.LocalUrl
+	if baseUrl == "" && cfg.LocalAddr != "" {
+		baseUrl = "http://" + cfg.LocalAddr
+	}
+	local, err := localStorage.New(dir, baseUrl)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.LocalAddr != "" {
+		go func() {
+			log.Printf("local storage serving %v at %v", dir, cfg.LocalAddr)
+			log.Fatal(http.ListenAndServe(cfg.LocalAddr, local.Handler()))
+		}()
+	}
+	return local, nil
+}
+
+func newVerifiers(cfg config.OAuth) map[string]oauth.Verifier {
+	verifiers := make(map[string]oauth.Verifier)
+	if len(cfg.GoogleClientIds) > 0 {
+		verifiers[user.OAuthProviderGoogle] = oauth.NewGoogleVerifier(cfg.GoogleCertsUrl, cfg.GoogleClientIds, nil)
+	}
+	if cfg.KakaoAppId != 0 {
+		verifiers[user.OAuthProviderKakao] = oauth.NewKakaoVerifier(
+			cfg.KakaoTokenInfoUrl, cfg.KakaoUserUrl, cfg.KakaoAppId, nil)
+	}
+	return verifiers
+}
+
+func newNotifier(ctx context.Context, cfg config.Notify) (notify.Notifier, error) {
+	if cfg.Backend != config.NotifyFCM {
+		return notify.NewLogNotifier(), nil
+	}
+	opts := []option.ClientOption{option.WithScopes(notify.FCMScope)}
+	if cfg.CredentialFile != "" {
+		opts = append(opts, option.WithCredentialsFile(cfg.CredentialFile))
+	}
+	client, _, err := htransport.NewClient(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return notify.NewFCMNotifier("", cfg.FcmProjectId, client), nil
+}
+
 func main() {
 	ctx := context.Background()
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	cfg, err := config.Load(os.Args[1:])
+	if err != nil {
+		log.Fatal(err)
+	}
+	if cfg.NetworkInterface != "" {
+		printAddress(cfg.NetworkInterface)
 	}
 
-	gcpCredentialJsonPath := filepath.Join(path.Root(), "assets", "ohmnyom-77df675cb827.json")
-	firestoreClient, err := firestore.NewClient(ctx, "ohmnyom", filepath.Join(path.Root(), "assets", "ohmnyom-77df675cb827.json"))
+	firestoreClient, err := firestore.NewClient(ctx, cfg.Firestore.ProjectId, cfg.Firestore.CredentialFile)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	jwtManager := jwt.NewManager([]byte("temp-test-secret"))
+	jwtManager := jwt.NewManager([]byte(cfg.Jwt.Secret))
+	tokenStore := tokenstore.New(ctx, firestoreClient)
+	checker := revocation.NewChecker(tokenStore, revocation.DefaultCacheTTL)
 	authInterceptor := interceptor.NewAuthInterceptor(
 		jwtManager,
+		checker,
 		"/protonyom.SignApi/SignUp",
 		"/protonyom.SignApi/SignIn",
 		"/protonyom.PetApi/GetFamilies",
@@ -58,22 +153,93 @@ func main() {
 	userStore := userstore.New(ctx, firestoreClient)
 	petStore := petstore.New(ctx, firestoreClient)
 	feedStore := feedstore.New(ctx, firestoreClient)
-	storage := googleStorage.New(ctx, gcpCredentialJsonPath)
+	inviteStore := invitestore.New(ctx, firestoreClient)
+	unitOfWork := uowstore.New(ctx, firestoreClient)
+	storage, err := newStorage(ctx, cfg.Storage)
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	userServer := servers.NewUserServer(userStore, petStore, storage, jwtManager)
-	petServer := servers.NewPetServer(petStore, userStore, storage)
-	feedServer := servers.NewFeedServer(feedStore, userStore)
+	scheduleStore := schedulestore.New(ctx, firestoreClient)
+	deviceStore := devicestore.New(ctx, firestoreClient)
+	foodStore := foodstore.New(ctx, firestoreClient)
+	weightStore := weightstore.New(ctx, firestoreClient)
+	auditStore := auditstore.New(ctx, firestoreClient)
+	deleter := cascade.New(deletionstore.New(ctx, firestoreClient), feedStore, scheduleStore, foodStore,
+		weightStore, healthstore.New(ctx, firestoreClient), inviteStore, deviceStore, auditStore, storage,
+		pet.StorageRoot, cascade.DefaultBatchSize)
+	go deleter.ResumeEvery(ctx, cascade.DefaultResumeInterval)
+	go func() {
+		migrated, err := petstore.MigrateRoles(ctx, firestoreClient)
+		if err != nil {
+			log.Printf("cannot migrate pet roles: %v", err)
+			return
+		}
+		log.Printf("pet roles migrated: %v pets", migrated)
+	}()
+
+	householdStore := householdstore.New(ctx, firestoreClient)
+	authorizer := authz.New(petStore, householdStore)
+	go sitter.New(petStore, unitOfWork, sitter.DefaultInterval).Run(ctx)
+
+	if cfg.Notify.ReminderIntervalSeconds > 0 {
+		notifier, err := newNotifier(ctx, cfg.Notify)
+		if err != nil {
+			log.Fatal(err)
+		}
+		reminders := reminder.New(scheduleStore, feedStore, petStore, householdStore, deviceStore,
+			reminderstore.New(ctx, firestoreClient), notifier,
+			time.Duration(cfg.Notify.ReminderIntervalSeconds)*time.Second, reminder.DefaultLookback)
+		go reminders.Run(ctx)
+	}
+
+	// the servers change users, pets and feeds through the audit log
+	auditedUsers := auditlog.UserStore(userStore, auditStore)
+	auditedPets := auditlog.PetStore(petStore, auditStore)
+	auditedUnitOfWork := auditlog.UnitOfWork(unitOfWork)
+
+	userServer := servers.NewUserServer(auditedUsers, petStore, inviteStore, householdStore, tokenStore, deviceStore,
+		auditedUnitOfWork, storage, deleter, jwtManager, checker, authorizer, newVerifiers(cfg.OAuth), false)
+	petServer := servers.NewPetServer(auditedPets, userStore, auditedUnitOfWork, storage, deleter, authorizer)
+	feedServer := servers.NewFeedServer(auditlog.FeedStore(feedStore, auditStore), userStore, foodStore, weightStore,
+		authorizer)
+
+	idempotencyInterceptor := interceptor.NewIdempotencyInterceptor(
+		idempotencystore.New(ctx, firestoreClient),
+		idempotency.DefaultTTL,
+		"/protonyom.FeedApi/AddFeed",
+		"/protonyom.PetApi/AddPet",
+	)
 
 	grpcServer := grpc.NewServer(
-		grpc.UnaryInterceptor(authInterceptor.Unary()),
+		grpc.ChainUnaryInterceptor(authInterceptor.Unary(), idempotencyInterceptor.Unary()),
 		grpc.ForceServerCodec(encoding.GetCodec(gzip.Name)),
 	)
 	gonyom.RegisterSignApiServer(grpcServer, userServer)
 	gonyom.RegisterAccountApiServer(grpcServer, userServer)
 	gonyom.RegisterPetApiServer(grpcServer, petServer)
 	gonyom.RegisterFeedApiServer(grpcServer, feedServer)
+	// protonyom v1.0.3 defines only the APIs registered above. The RPCs below
+	// are written, but are not served, nor their servers constructed, until it
+	// defines them:
+	//   - SignApi: RefreshToken, and NewUserServer's refreshTokens stays off
+	//     until then
+	//   - SignApi: SignOutEverywhere
+	//   - FeedApi: GetFeedStats
+	//   - ScheduleApi: ScheduleServer
+	//   - PetApi: SetFeedWindow
+	//   - FoodApi: FoodServer, and FeedApi: AddTypedFeed
+	//   - PetApi: SetPreferredUnit
+	//   - WeightApi: WeightServer
+	//   - PetApi: SetNutritionProfile, and FeedApi: GetFeedingTarget
+	//   - HealthApi: HealthServer
+	//   - PetApi: SetMemberRole, TransferOwnership
+	//   - AccountApi: CreateSitterInvite
+	//   - PetApi: RemoveFeeder
+	//   - HouseholdApi: HouseholdServer
+	//   - AuditApi: AuditServer
 
-	lis, err := net.Listen("tcp", ":"+port)
+	lis, err := net.Listen("tcp", ":"+cfg.Port)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -0,0 +1,61 @@
+package servers
+
+import (
+	"context"
+
+	"ohmnyom/domain/audit"
+	"ohmnyom/domain/pet"
+	"ohmnyom/internal/authz"
+	"ohmnyom/internal/errors"
+)
+
+// AuditServer lists the audit log the auditlog decorators write. protonyom
+// does not define an AuditApi yet, so the server is not registered.
+type AuditServer struct {
+	auditStore audit.Store
+	authorizer *authz.Authorizer
+}
+
+func NewAuditServer(store audit.Store, authorizer *authz.Authorizer) *AuditServer {
+	return &AuditServer{
+		auditStore: store,
+		authorizer: authorizer,
+	}
+}
+
+// ListAuditEvents returns a page of up to limit changes to the pet and its
+// feeds after startAfter, latest first. Pass the Cursor of the last event of a
+// page for the next one, and the zero Cursor for the first. Every member sees
+// them.
+func (s *AuditServer) ListAuditEvents(ctx context.Context, petId string, startAfter audit.Cursor,
+	limit int) ([]*audit.Event, error) {
+	if limit <= 0 || limit > audit.MaxPageSize {
+		return nil, errors.GrpcError(errors.NewInvalidParamError("limit: %v", limit))
+	}
+	if _, err := s.authorizer.Pet(ctx, petId, pet.RoleViewer); err != nil {
+		return nil, errors.GrpcError(err)
+	}
+	events, err := s.auditStore.GetListOfPet(ctx, petId, startAfter, limit)
+	if err != nil {
+		return nil, errors.GrpcError(err)
+	}
+	return events, nil
+}
+
+// ListAccountAuditEvents returns a page of the changes to the account of the
+// caller, as ListAuditEvents does for a pet.
+func (s *AuditServer) ListAccountAuditEvents(ctx context.Context, startAfter audit.Cursor,
+	limit int) ([]*audit.Event, error) {
+	uid, err := authz.Uid(ctx)
+	if err != nil {
+		return nil, errors.GrpcError(err)
+	}
+	if limit <= 0 || limit > audit.MaxPageSize {
+		return nil, errors.GrpcError(errors.NewInvalidParamError("limit: %v", limit))
+	}
+	events, err := s.auditStore.GetListOfUser(ctx, uid, startAfter, limit)
+	if err != nil {
+		return nil, errors.GrpcError(err)
+	}
+	return events, nil
+}
@@ -0,0 +1,110 @@
+package servers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aiceru/protonyom/gonyom"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"ohmnyom/domain/audit"
+	"ohmnyom/domain/pet"
+	"ohmnyom/internal/auditlog"
+	"ohmnyom/internal/authz"
+)
+
+func TestAuditServer(t *testing.T) {
+	stores := newTestStores(t)
+	authorizer := authz.New(stores.pets, stores.households)
+	s := NewAuditServer(stores.audits, authorizer)
+	feeds := NewFeedServer(auditlog.FeedStore(stores.feeds, stores.audits), stores.users, stores.foods, stores.weights,
+		authorizer)
+	addTestMember(t, stores, "viewer", pet.RoleViewer)
+
+	added, err := feeds.AddFeed(ctxOf("feeder"), &gonyom.AddFeedRequest{
+		Feed: &gonyom.Feed{PetId: "pet1", FeederId: "feeder", Timestamp: time.Now().Unix(), Amount: 10, Unit: "g"},
+	})
+	assert.NoError(t, err)
+	stream := &testStream{method: "/protonyom.FeedApi/DeleteFeed"}
+	_, err = feeds.DeleteFeed(grpc.NewContextWithServerTransportStream(ctxOf("owner"), stream),
+		&gonyom.DeleteFeedRequest{PetId: "pet1", FeedId: added.Feed.Id})
+	assert.NoError(t, err)
+
+	_, err = s.ListAuditEvents(ctxOf("stranger"), "pet1", audit.Cursor{}, 10)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	_, err = s.ListAuditEvents(ctxOf("viewer"), "pet1", audit.Cursor{}, 0)
+	assert.Equal(t, codes.Internal, status.Code(err))
+
+	// who deleted the feed is known to every member
+	events, err := s.ListAuditEvents(ctxOf("viewer"), "pet1", audit.Cursor{}, 10)
+	assert.NoError(t, err)
+	if assert.Len(t, events, 2) {
+		assert.Equal(t, "owner", events[0].Actor)
+		assert.Equal(t, stream.method, events[0].Method)
+		assert.Contains(t, events[0].Before, added.Feed.Id)
+		assert.Empty(t, events[0].After)
+		assert.Equal(t, "feeder", events[1].Actor)
+		assert.Empty(t, events[1].Before)
+	}
+	page, err := s.ListAuditEvents(ctxOf("viewer"), "pet1", audit.Cursor{}, 1)
+	assert.NoError(t, err)
+	if assert.Len(t, page, 1) {
+		page, err = s.ListAuditEvents(ctxOf("viewer"), "pet1", page[0].Cursor(), 1)
+		assert.NoError(t, err)
+		assert.Len(t, page, 1)
+	}
+}
+
+func TestAuditServer_SameTimestamp(t *testing.T) {
+	stores := newTestStores(t)
+	s := NewAuditServer(stores.audits, authz.New(stores.pets, stores.households))
+	now := time.Now().UTC()
+	for i := 0; i < 5; i++ {
+		e, err := audit.NewEvent("owner", "/protonyom.PetApi/UpdatePet", nil, nil)
+		assert.NoError(t, err)
+		e.PetId = "pet1"
+		e.Timestamp = now
+		assert.NoError(t, stores.audits.Put(context.TODO(), e))
+	}
+
+	// pages of two split the events of the same time without losing any
+	seen := make(map[string]bool)
+	var cursor audit.Cursor
+	for {
+		page, err := s.ListAuditEvents(ctxOf("owner"), "pet1", cursor, 2)
+		assert.NoError(t, err)
+		if len(page) == 0 {
+			break
+		}
+		for _, e := range page {
+			assert.False(t, seen[e.Id], e.Id)
+			seen[e.Id] = true
+		}
+		cursor = page[len(page)-1].Cursor()
+	}
+	assert.Len(t, seen, 5)
+}
+
+func TestAuditServer_Account(t *testing.T) {
+	stores := newTestStores(t)
+	s := NewAuditServer(stores.audits, authz.New(stores.pets, stores.households))
+	users := newTestUserServer(stores)
+
+	_, err := users.Update(ctxOf("feeder"), &gonyom.UpdateAccountRequest{Path: "password", Value: "new-password"})
+	assert.NoError(t, err)
+
+	events, err := s.ListAccountAuditEvents(ctxOf("feeder"), audit.Cursor{}, 10)
+	assert.NoError(t, err)
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, "feeder", events[0].Actor)
+		assert.Contains(t, events[0].After, `"Password":"[redacted]"`)
+	}
+	events, err = s.ListAccountAuditEvents(ctxOf("owner"), audit.Cursor{}, 10)
+	assert.NoError(t, err)
+	assert.Empty(t, events)
+	_, err = s.ListAccountAuditEvents(context.TODO(), audit.Cursor{}, 10)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
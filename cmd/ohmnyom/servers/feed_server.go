@@ -5,38 +5,108 @@ import (
 	"time"
 
 	"github.com/aiceru/protonyom/gonyom"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 	"ohmnyom/domain/feed"
+	"ohmnyom/domain/food"
+	"ohmnyom/domain/nutrition"
+	"ohmnyom/domain/pet"
+	"ohmnyom/domain/units"
 	"ohmnyom/domain/user"
+	"ohmnyom/domain/weight"
+	"ohmnyom/internal/authz"
 	"ohmnyom/internal/errors"
 )
 
+// feedOverrideKey is the request metadata that, set to "true", adds a feed even
+// though the pet was fed within its feed window.
+const feedOverrideKey = "feed-override"
+
 type FeedServer struct {
-	feedStore feed.Store
-	userStore user.Store
+	feedStore   feed.Store
+	userStore   user.Store
+	foodStore   food.Store
+	weightStore weight.Store
+	authorizer  *authz.Authorizer
 	gonyom.UnimplementedFeedApiServer
 }
 
-func NewFeedServer(store feed.Store, userStore user.Store) *FeedServer {
+func NewFeedServer(store feed.Store, userStore user.Store, foodStore food.Store, weightStore weight.Store,
+	authorizer *authz.Authorizer) *FeedServer {
 	return &FeedServer{
-		feedStore: store,
-		userStore: userStore,
+		feedStore:   store,
+		userStore:   userStore,
+		foodStore:   foodStore,
+		weightStore: weightStore,
+		authorizer:  authorizer,
 	}
 }
 
 func (s *FeedServer) AddFeed(ctx context.Context, request *gonyom.AddFeedRequest) (*gonyom.AddFeedReply, error) {
-	// uid := ctx.Value(user.CtxKeyUid).(string)
-	// TODO user check??
-	newFeed, err := feed.NewFromProto(request.GetFeed())
+	added, err := s.addFeed(ctx, request.GetFeed(), "", "")
+	if err != nil {
+		return nil, err
+	}
+	return &gonyom.AddFeedReply{
+		Feed: added,
+	}, nil
+}
+
+// AddTypedFeed adds a feed of a food in the catalog of the pet, or of
+// feedType, or both. The feed takes the type of the food unless feedType is
+// given, and its calories. protonyom does not define the RPC yet.
+func (s *FeedServer) AddTypedFeed(ctx context.Context, in *gonyom.Feed, foodId string, feedType feed.Type) (*gonyom.Feed, error) {
+	if feedType != "" {
+		if _, err := feed.ParseType(string(feedType)); err != nil {
+			return nil, errors.GrpcError(err)
+		}
+	}
+	return s.addFeed(ctx, in, foodId, feedType)
+}
+
+func (s *FeedServer) addFeed(ctx context.Context, in *gonyom.Feed, foodId string, feedType feed.Type) (*gonyom.Feed, error) {
+	newFeed, err := feed.NewFromProto(in)
+	if err != nil {
+		return nil, errors.GrpcError(err)
+	}
+	if newFeed.FeederId == "" {
+		newFeed.FeederId, _ = authz.Uid(ctx)
+	}
+	p, err := s.authorizer.Feed(ctx, newFeed)
+	if err != nil {
+		return nil, errors.GrpcError(err)
+	}
+	feederRole, err := s.authorizer.RoleOf(ctx, p, newFeed.FeederId)
 	if err != nil {
 		return nil, errors.GrpcError(err)
 	}
+	if feederRole < pet.RoleCaregiver {
+		return nil, errors.GrpcError(errors.NewInvalidParamError("feeder %v does not feed Pet{Id: %v}",
+			newFeed.FeederId, p.Id))
+	}
+
+	newFeed.Type = feedType
+	if foodId != "" {
+		fd, err := s.foodStore.Get(ctx, newFeed.PetId, foodId)
+		if err != nil {
+			return nil, errors.GrpcError(err)
+		}
+		if err := fd.Apply(newFeed); err != nil {
+			return nil, errors.GrpcError(err)
+		}
+	}
 
 	feeder, err := s.userStore.Get(ctx, newFeed.FeederId)
 	if err != nil {
 		return nil, errors.GrpcError(err)
 	}
 
-	if err := s.feedStore.Put(ctx, newFeed); err != nil {
+	if window := p.FeedWindow(); window > 0 && !isOverride(ctx) {
+		if err := s.feedStore.PutUnlessConflict(ctx, newFeed, window); err != nil {
+			return nil, s.conflictError(ctx, err)
+		}
+	} else if err := s.feedStore.Put(ctx, newFeed); err != nil {
 		return nil, errors.GrpcError(err)
 	}
 
@@ -44,10 +114,31 @@ func (s *FeedServer) AddFeed(ctx context.Context, request *gonyom.AddFeedRequest
 	if err != nil {
 		return nil, errors.GrpcError(err)
 	}
+	return check.ToProto(feeder.Name), nil
+}
 
-	return &gonyom.AddFeedReply{
-		Feed: check.ToProto(feeder.Name),
-	}, nil
+func isOverride(ctx context.Context) bool {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get(feedOverrideKey)
+	return len(values) > 0 && values[0] == "true"
+}
+
+// conflictError returns a FailedPrecondition status carrying the conflicting
+// feed as its detail, so the client can tell who fed the pet and when.
+func (s *FeedServer) conflictError(ctx context.Context, err error) error {
+	var conflict *feed.ConflictError
+	if !errors.As(err, &conflict) {
+		return errors.GrpcError(err)
+	}
+	name := ""
+	if u, err := s.userStore.Get(ctx, conflict.Feed.FeederId); err == nil {
+		name = u.Name
+	}
+	st, detailErr := status.New(codes.FailedPrecondition, conflict.Error()).WithDetails(conflict.Feed.ToProto(name))
+	if detailErr != nil {
+		return status.Error(codes.FailedPrecondition, conflict.Error())
+	}
+	return st.Err()
 }
 
 func (s *FeedServer) GetFeeds(ctx context.Context, request *gonyom.GetFeedsRequest) (*gonyom.GetFeedsReply, error) {
@@ -55,18 +146,26 @@ func (s *FeedServer) GetFeeds(ctx context.Context, request *gonyom.GetFeedsReque
 	startAfter := time.Unix(request.GetStartAfter(), 0)
 	limit := request.GetLimit()
 
+	if _, err := s.authorizer.Pet(ctx, petId, pet.RoleViewer); err != nil {
+		return nil, errors.GrpcError(err)
+	}
+
 	feeds, err := s.feedStore.GetFeedsOfPet(ctx, petId, startAfter, int(limit))
 	if err != nil {
 		return nil, errors.GrpcError(err)
 	}
 
+	names := make(map[string]string)
 	ret := make([]*gonyom.Feed, len(feeds))
 	for i, f := range feeds {
-		feeder, err := s.userStore.Get(ctx, f.FeederId)
-		if err != nil {
-			return nil, err
+		name, ok := names[f.FeederId]
+		if !ok {
+			if name, err = s.feederName(ctx, f.FeederId); err != nil {
+				return nil, errors.GrpcError(err)
+			}
+			names[f.FeederId] = name
 		}
-		ret[i] = f.ToProto(feeder.Name)
+		ret[i] = f.ToProto(name)
 	}
 	return &gonyom.GetFeedsReply{
 		Feeds: ret,
@@ -77,8 +176,16 @@ func (s *FeedServer) DeleteFeed(ctx context.Context, request *gonyom.DeleteFeedR
 	petId := request.GetPetId()
 	feedId := request.GetFeedId()
 
+	stored, err := s.feedStore.Get(ctx, petId, feedId)
+	if err != nil {
+		return nil, errors.GrpcError(err)
+	}
+	if _, err := s.authorizer.Feed(ctx, stored); err != nil {
+		return nil, errors.GrpcError(err)
+	}
+
 	if err := s.feedStore.Delete(ctx, petId, feedId); err != nil {
-		return nil, err
+		return nil, errors.GrpcError(err)
 	}
 	return &gonyom.DeleteFeedReply{}, nil
 }
@@ -88,8 +195,20 @@ func (s *FeedServer) UpdateFeed(ctx context.Context, request *gonyom.UpdateFeedR
 	if err != nil {
 		return nil, errors.GrpcError(err)
 	}
+	if newFeed.Unit, err = units.Normalize(newFeed.Unit); err != nil {
+		return nil, errors.GrpcError(err)
+	}
 
-	feeder, err := s.userStore.Get(ctx, newFeed.FeederId)
+	stored, err := s.feedStore.Get(ctx, newFeed.PetId, newFeed.Id)
+	if err != nil {
+		return nil, errors.GrpcError(err)
+	}
+	p, err := s.authorizer.Feed(ctx, stored)
+	if err != nil {
+		return nil, errors.GrpcError(err)
+	}
+
+	feederName, err := s.feederName(ctx, stored.FeederId)
 	if err != nil {
 		return nil, errors.GrpcError(err)
 	}
@@ -99,8 +218,21 @@ func (s *FeedServer) UpdateFeed(ctx context.Context, request *gonyom.UpdateFeedR
 		"amount":    newFeed.Amount,
 		"unit":      newFeed.Unit,
 	}
-	if err := s.feedStore.Update(ctx, newFeed.PetId, newFeed.Id, updates); err != nil {
-		return nil, errors.GrpcError(err)
+	if stored.FoodId != "" {
+		kcal, err := s.kcalOf(ctx, stored, newFeed.Amount, newFeed.Unit)
+		if err != nil {
+			return nil, errors.GrpcError(err)
+		}
+		updates["kcal"] = kcal
+	}
+	window := p.FeedWindow()
+	if window > 0 && !isOverride(ctx) && !newFeed.Timestamp.Equal(stored.Timestamp) {
+		err = s.feedStore.UpdateUnlessConflict(ctx, newFeed.PetId, newFeed.Id, updates, window)
+	} else {
+		err = s.feedStore.Update(ctx, newFeed.PetId, newFeed.Id, updates)
+	}
+	if err != nil {
+		return nil, s.conflictError(ctx, err)
 	}
 
 	check, err := s.feedStore.Get(ctx, newFeed.PetId, newFeed.Id)
@@ -109,6 +241,129 @@ func (s *FeedServer) UpdateFeed(ctx context.Context, request *gonyom.UpdateFeedR
 	}
 
 	return &gonyom.UpdateFeedReply{
-		Feed: check.ToProto(feeder.Name),
+		Feed: check.ToProto(feederName),
 	}, nil
 }
+
+// feederName returns the name of the feeder, empty for one who deleted the
+// account.
+func (s *FeedServer) feederName(ctx context.Context, uid string) (string, error) {
+	u, err := s.userStore.Get(ctx, uid)
+	var notfound *errors.NotFoundError
+	if errors.As(err, &notfound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return u.Name, nil
+}
+
+// kcalOf returns the calories of stored with amount in unit instead. A food
+// deleted from the catalog leaves the calories of the feed to scale.
+func (s *FeedServer) kcalOf(ctx context.Context, stored *feed.Feed, amount float64, unit string) (float64, error) {
+	fd, err := s.foodStore.Get(ctx, stored.PetId, stored.FoodId)
+	var notfound *errors.NotFoundError
+	if errors.As(err, &notfound) {
+		converted, err := units.Convert(amount, unit, stored.Unit, 0)
+		if err != nil {
+			return 0, err
+		}
+		return stored.ScaleKcal(converted), nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return fd.Kcal(amount, unit)
+}
+
+// GetFeedStats aggregates the feeds of petId in [from, to) by period, with days
+// starting at midnight in timeZone, an IANA name defaulting to UTC. Amounts are
+// in the preferred unit of the pet where they convert. protonyom does not
+// define the RPC yet.
+func (s *FeedServer) GetFeedStats(ctx context.Context, petId string, from, to time.Time, period feed.Period,
+	timeZone string) (*feed.Stats, error) {
+	loc, err := time.LoadLocation(timeZone)
+	if err != nil {
+		return nil, errors.GrpcError(errors.NewInvalidParamError("timeZone: %v", timeZone))
+	}
+	p, err := s.authorizer.Pet(ctx, petId, pet.RoleViewer)
+	if err != nil {
+		return nil, errors.GrpcError(err)
+	}
+
+	feeds, err := s.feedStore.GetFeedsOfPetInRange(ctx, petId, from, to)
+	if err != nil {
+		return nil, errors.GrpcError(err)
+	}
+	foods, err := s.foodStore.GetListOfPet(ctx, petId)
+	if err != nil {
+		return nil, errors.GrpcError(err)
+	}
+	densities := make(map[string]float64)
+	for _, fd := range foods {
+		densities[fd.Id] = fd.Density
+	}
+	feeds = feed.InUnit(feeds, p.PreferredUnit, func(foodId string) float64 {
+		return densities[foodId]
+	})
+	stats, err := feed.Aggregate(feeds, period, from, to, loc)
+	if err != nil {
+		return nil, errors.GrpcError(err)
+	}
+
+	names := make(map[string]string)
+	for _, fs := range stats.Total.Feeders {
+		// feeders who deleted their account keep an empty name
+		if u, err := s.userStore.Get(ctx, fs.FeederId); err == nil {
+			names[fs.FeederId] = u.Name
+		}
+	}
+	for _, b := range append(stats.Buckets, stats.Total) {
+		for _, fs := range b.Feeders {
+			fs.FeederName = names[fs.FeederId]
+		}
+	}
+	return stats, nil
+}
+
+// GetFeedingTarget works out the daily calorie target of the pet from its
+// family, latest weight, life stage and neuter status, and compares it against
+// the feeds of today, a day starting at midnight in timeZone. protonyom does not
+// define the RPC yet.
+func (s *FeedServer) GetFeedingTarget(ctx context.Context, petId, timeZone string) (*nutrition.Intake, error) {
+	loc, err := time.LoadLocation(timeZone)
+	if err != nil {
+		return nil, errors.GrpcError(errors.NewInvalidParamError("timeZone: %v", timeZone))
+	}
+	p, err := s.authorizer.Pet(ctx, petId, pet.RoleViewer)
+	if err != nil {
+		return nil, errors.GrpcError(err)
+	}
+
+	latest, err := s.weightStore.GetLatest(ctx, petId)
+	var notfound *errors.NotFoundError
+	if errors.As(err, &notfound) {
+		return nil, errors.GrpcError(errors.NewFailedPreconditionError("no weight of Pet{Id: %v}", petId))
+	}
+	if err != nil {
+		return nil, errors.GrpcError(err)
+	}
+	kg, err := latest.In(units.Kilogram)
+	if err != nil {
+		return nil, errors.GrpcError(err)
+	}
+	now := time.Now().In(loc)
+	target, err := nutrition.NewTarget(p.Family, kg, p.Born, p.Neutered, now)
+	if err != nil {
+		return nil, errors.GrpcError(err)
+	}
+
+	y, m, d := now.Date()
+	today := time.Date(y, m, d, 0, 0, 0, 0, loc)
+	feeds, err := s.feedStore.GetFeedsOfPetInRange(ctx, petId, today, today.AddDate(0, 0, 1))
+	if err != nil {
+		return nil, errors.GrpcError(err)
+	}
+	return nutrition.NewIntake(target, feeds), nil
+}
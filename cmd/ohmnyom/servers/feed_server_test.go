@@ -0,0 +1,406 @@
+package servers
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aiceru/protonyom/gonyom"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"ohmnyom/domain/audit"
+	"ohmnyom/domain/deletion"
+	"ohmnyom/domain/device"
+	"ohmnyom/domain/feed"
+	"ohmnyom/domain/food"
+	"ohmnyom/domain/health"
+	"ohmnyom/domain/household"
+	"ohmnyom/domain/invite"
+	"ohmnyom/domain/pet"
+	"ohmnyom/domain/schedule"
+	"ohmnyom/domain/uow"
+	"ohmnyom/domain/user"
+	"ohmnyom/domain/weight"
+	"ohmnyom/internal/authz"
+	"ohmnyom/internal/cascade"
+	"ohmnyom/internal/memstore"
+	"ohmnyom/internal/storage"
+	"ohmnyom/internal/storage/localStorage"
+)
+
+type testStores struct {
+	users      user.Store
+	pets       pet.Store
+	feeds      feed.Store
+	schedules  schedule.Store
+	foods      food.Store
+	weights    weight.Store
+	health     health.Store
+	invites    invite.Store
+	devices    device.Store
+	deletions  deletion.Store
+	audits     audit.Store
+	households household.Store
+	uow        uow.UnitOfWork
+	storage    storage.Storage
+	// storageDir is where storage keeps its files
+	storageDir string
+	deleter    *cascade.Deleter
+}
+
+// newTestStores creates owner, feeder and stranger, with owner and feeder
+// sharing pet1.
+func newTestStores(t *testing.T) *testStores {
+	ctx := context.TODO()
+	s := &testStores{
+		users:      memstore.NewUserStore(),
+		pets:       memstore.NewPetStore(),
+		feeds:      memstore.NewFeedStore(),
+		schedules:  memstore.NewScheduleStore(),
+		foods:      memstore.NewFoodStore(),
+		weights:    memstore.NewWeightStore(),
+		health:     memstore.NewHealthStore(),
+		invites:    memstore.NewInviteStore(),
+		devices:    memstore.NewDeviceStore(),
+		deletions:  memstore.NewDeletionStore(),
+		audits:     memstore.NewAuditStore(),
+		households: memstore.NewHouseholdStore(),
+	}
+	s.uow = memstore.NewUnitOfWork(s.users, s.pets, s.invites, s.deletions, s.audits, s.households)
+	s.storageDir = t.TempDir()
+	local, err := localStorage.New(s.storageDir, "http://localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.storage = local
+	s.deleter = cascade.New(s.deletions, s.feeds, s.schedules, s.foods, s.weights, s.health, s.invites, s.devices,
+		s.audits, s.storage, pet.StorageRoot, 2)
+	t.Cleanup(s.deleter.Wait)
+	for _, id := range []string{"owner", "feeder", "stranger"} {
+		if err := s.users.Put(ctx, &user.User{Id: id, Name: "name-" + id, Email: id + "@test.com"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.pets.Put(ctx, &pet.Pet{Id: "pet1", Name: "nyom", Feeders: []string{"owner", "feeder"}}); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"owner", "feeder"} {
+		if err := s.users.AddPet(ctx, id, "pet1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return s
+}
+
+func ctxOf(uid string) context.Context {
+	return context.WithValue(context.TODO(), user.CtxKeyUid, uid)
+}
+
+func TestFeedServer_Authorization(t *testing.T) {
+	stores := newTestStores(t)
+	s := NewFeedServer(stores.feeds, stores.users, stores.foods, stores.weights, authz.New(stores.pets, stores.households))
+	now := time.Now().Unix()
+	if err := stores.pets.AddMember(context.TODO(), "pet1", "viewer", pet.RoleViewer); err != nil {
+		t.Fatal(err)
+	}
+
+	added, err := s.AddFeed(ctxOf("feeder"), &gonyom.AddFeedRequest{
+		Feed: &gonyom.Feed{PetId: "pet1", FeederId: "feeder", Timestamp: now, Amount: 10, Unit: "g"},
+	})
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name string
+		uid  string
+		call func(ctx context.Context) error
+		want codes.Code
+	}{
+		{"stranger adds feed", "stranger", func(ctx context.Context) error {
+			_, err := s.AddFeed(ctx, &gonyom.AddFeedRequest{
+				Feed: &gonyom.Feed{PetId: "pet1", FeederId: "stranger", Timestamp: now},
+			})
+			return err
+		}, codes.PermissionDenied},
+		{"feeder adds feed of other", "feeder", func(ctx context.Context) error {
+			_, err := s.AddFeed(ctx, &gonyom.AddFeedRequest{
+				Feed: &gonyom.Feed{PetId: "pet1", FeederId: "owner", Timestamp: now},
+			})
+			return err
+		}, codes.PermissionDenied},
+		{"owner adds feed of feeder", "owner", func(ctx context.Context) error {
+			// an hour apart, out of the feed window of the first feed
+			_, err := s.AddFeed(ctx, &gonyom.AddFeedRequest{
+				Feed: &gonyom.Feed{PetId: "pet1", FeederId: "feeder", Timestamp: now - 3600},
+			})
+			return err
+		}, codes.OK},
+		{"viewer adds feed", "viewer", func(ctx context.Context) error {
+			_, err := s.AddFeed(ctx, &gonyom.AddFeedRequest{
+				Feed: &gonyom.Feed{PetId: "pet1", FeederId: "viewer", Timestamp: now},
+			})
+			return err
+		}, codes.PermissionDenied},
+		{"owner adds feed of viewer", "owner", func(ctx context.Context) error {
+			_, err := s.AddFeed(ctx, &gonyom.AddFeedRequest{
+				Feed: &gonyom.Feed{PetId: "pet1", FeederId: "viewer", Timestamp: now - 7200},
+			})
+			return err
+		}, codes.Internal},
+		{"viewer gets feeds", "viewer", func(ctx context.Context) error {
+			_, err := s.GetFeeds(ctx, &gonyom.GetFeedsRequest{PetId: "pet1", StartAfter: now + 1, Limit: 10})
+			return err
+		}, codes.OK},
+		{"stranger gets feeds", "stranger", func(ctx context.Context) error {
+			_, err := s.GetFeeds(ctx, &gonyom.GetFeedsRequest{PetId: "pet1", StartAfter: now + 1, Limit: 10})
+			return err
+		}, codes.PermissionDenied},
+		{"stranger updates feed", "stranger", func(ctx context.Context) error {
+			f := proto.Clone(added.Feed).(*gonyom.Feed)
+			f.Amount = 20
+			_, err := s.UpdateFeed(ctx, &gonyom.UpdateFeedRequest{Feed: f})
+			return err
+		}, codes.PermissionDenied},
+		{"stranger deletes feed", "stranger", func(ctx context.Context) error {
+			_, err := s.DeleteFeed(ctx, &gonyom.DeleteFeedRequest{PetId: "pet1", FeedId: added.Feed.Id})
+			return err
+		}, codes.PermissionDenied},
+		{"owner deletes feed of feeder", "owner", func(ctx context.Context) error {
+			_, err := s.DeleteFeed(ctx, &gonyom.DeleteFeedRequest{PetId: "pet1", FeedId: added.Feed.Id})
+			return err
+		}, codes.OK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, status.Code(tt.call(ctxOf(tt.uid))))
+		})
+	}
+}
+
+func TestFeedServer_FeedsOfFormerMember(t *testing.T) {
+	stores := newTestStores(t)
+	s := NewFeedServer(stores.feeds, stores.users, stores.foods, stores.weights, authz.New(stores.pets, stores.households))
+	now := time.Now().Unix()
+	add := func(timestamp int64) *gonyom.Feed {
+		reply, err := s.AddFeed(ctxOf("feeder"), &gonyom.AddFeedRequest{
+			Feed: &gonyom.Feed{PetId: "pet1", Timestamp: timestamp, Amount: 10, Unit: "g"},
+		})
+		assert.NoError(t, err)
+		return reply.GetFeed()
+	}
+	updated, deleted := add(now), add(now-3600)
+	_, err := newTestPetServer(stores).RemoveFeeder(ctxOf("owner"), "pet1", "feeder")
+	assert.NoError(t, err)
+
+	// the owner keeps control of the feeds the feeder left behind
+	updated.Amount = 20
+	reply, err := s.UpdateFeed(ctxOf("owner"), &gonyom.UpdateFeedRequest{Feed: updated})
+	assert.NoError(t, err)
+	assert.Equal(t, 20.0, reply.GetFeed().GetAmount())
+	assert.Equal(t, "name-feeder", reply.GetFeed().GetFeederName())
+	_, err = s.DeleteFeed(ctxOf("owner"), &gonyom.DeleteFeedRequest{PetId: "pet1", FeedId: deleted.Id})
+	assert.NoError(t, err)
+
+	// but nobody adds new feeds of the feeder
+	_, err = s.AddFeed(ctxOf("owner"), &gonyom.AddFeedRequest{
+		Feed: &gonyom.Feed{PetId: "pet1", FeederId: "feeder", Timestamp: now - 7200, Amount: 10, Unit: "g"},
+	})
+	assert.Equal(t, codes.Internal, status.Code(err))
+
+	// the feeds of a deleted account are listed without a name
+	assert.NoError(t, stores.users.Delete(context.TODO(), "feeder"))
+	feeds, err := s.GetFeeds(ctxOf("owner"), &gonyom.GetFeedsRequest{PetId: "pet1", StartAfter: now + 60, Limit: 10})
+	assert.NoError(t, err)
+	if assert.Len(t, feeds.GetFeeds(), 1) {
+		assert.Equal(t, updated.Id, feeds.GetFeeds()[0].GetId())
+		assert.Empty(t, feeds.GetFeeds()[0].GetFeederName())
+	}
+}
+
+func TestFeedServer_AddFeedConflict(t *testing.T) {
+	stores := newTestStores(t)
+	s := NewFeedServer(stores.feeds, stores.users, stores.foods, stores.weights, authz.New(stores.pets, stores.households))
+	now := time.Now().Unix()
+	add := func(ctx context.Context, timestamp int64) (*gonyom.AddFeedReply, error) {
+		return s.AddFeed(ctx, &gonyom.AddFeedRequest{
+			Feed: &gonyom.Feed{PetId: "pet1", Timestamp: timestamp, Amount: 10, Unit: "g"},
+		})
+	}
+
+	first, err := add(ctxOf("owner"), now)
+	assert.NoError(t, err)
+
+	// ten minutes earlier, still within the default window
+	_, err = add(ctxOf("feeder"), now-600)
+	st := status.Convert(err)
+	assert.Equal(t, codes.FailedPrecondition, st.Code())
+	if assert.Len(t, st.Details(), 1) {
+		conflicting := st.Details()[0].(*gonyom.Feed)
+		assert.Equal(t, first.Feed.Id, conflicting.Id)
+		assert.Equal(t, "name-owner", conflicting.FeederName)
+	}
+
+	override := metadata.NewIncomingContext(ctxOf("feeder"), metadata.Pairs(feedOverrideKey, "true"))
+	_, err = add(override, now-600)
+	assert.NoError(t, err)
+	_, err = add(ctxOf("feeder"), now-3600)
+	assert.NoError(t, err)
+
+	// the window is per pet
+	petServer := NewPetServer(stores.pets, stores.users, stores.uow, stores.storage, stores.deleter,
+		authz.New(stores.pets, stores.households))
+	_, err = petServer.SetFeedWindow(ctxOf("feeder"), "pet1", 5)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	p, err := petServer.SetFeedWindow(ctxOf("owner"), "pet1", 5)
+	assert.NoError(t, err)
+	assert.Equal(t, 5*time.Minute, p.FeedWindow())
+	_, err = add(ctxOf("feeder"), now+400)
+	assert.NoError(t, err)
+
+	_, err = petServer.SetFeedWindow(ctxOf("owner"), "pet1", -1)
+	assert.NoError(t, err)
+	_, err = add(ctxOf("feeder"), now+400)
+	assert.NoError(t, err)
+}
+
+func TestFeedServer_AddFeedConflictConcurrent(t *testing.T) {
+	stores := newTestStores(t)
+	s := NewFeedServer(stores.feeds, stores.users, stores.foods, stores.weights, authz.New(stores.pets, stores.households))
+	now := time.Now().Unix()
+
+	var wg sync.WaitGroup
+	errs := make([]error, 10)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = s.AddFeed(ctxOf("feeder"), &gonyom.AddFeedRequest{
+				Feed: &gonyom.Feed{PetId: "pet1", Timestamp: now + int64(i), Amount: 10, Unit: "g"},
+			})
+		}(i)
+	}
+	wg.Wait()
+
+	added := 0
+	for _, err := range errs {
+		if err == nil {
+			added++
+		} else {
+			assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+		}
+	}
+	assert.Equal(t, 1, added)
+}
+
+func TestFeedServer_UpdateFeedConflict(t *testing.T) {
+	stores := newTestStores(t)
+	s := NewFeedServer(stores.feeds, stores.users, stores.foods, stores.weights, authz.New(stores.pets, stores.households))
+	now := time.Now().Unix()
+	add := func(timestamp int64) *gonyom.Feed {
+		reply, err := s.AddFeed(ctxOf("owner"), &gonyom.AddFeedRequest{
+			Feed: &gonyom.Feed{PetId: "pet1", Timestamp: timestamp, Amount: 10, Unit: "g"},
+		})
+		assert.NoError(t, err)
+		return reply.GetFeed()
+	}
+	update := func(ctx context.Context, f *gonyom.Feed) error {
+		_, err := s.UpdateFeed(ctx, &gonyom.UpdateFeedRequest{Feed: f})
+		return err
+	}
+
+	first := add(now)
+	second := add(now - 3600)
+
+	// the amount alone changes without a check
+	second.Amount = 20
+	assert.NoError(t, update(ctxOf("owner"), second))
+
+	second.Timestamp = now - 600
+	st := status.Convert(update(ctxOf("owner"), second))
+	assert.Equal(t, codes.FailedPrecondition, st.Code())
+	if assert.Len(t, st.Details(), 1) {
+		assert.Equal(t, first.Id, st.Details()[0].(*gonyom.Feed).Id)
+	}
+	stored, err := stores.feeds.Get(ctxOf("owner"), "pet1", second.Id)
+	assert.NoError(t, err)
+	assert.Equal(t, now-3600, stored.Timestamp.Unix())
+
+	// moving a feed closer to where it was conflicts with nothing but itself
+	first.Timestamp = now - 60
+	assert.NoError(t, update(ctxOf("owner"), first))
+
+	override := metadata.NewIncomingContext(ctxOf("owner"), metadata.Pairs(feedOverrideKey, "true"))
+	assert.NoError(t, update(override, second))
+}
+
+func TestFeedServer_GetFeedStats(t *testing.T) {
+	stores := newTestStores(t)
+	s := NewFeedServer(stores.feeds, stores.users, stores.foods, stores.weights, authz.New(stores.pets, stores.households))
+	ctx := context.TODO()
+	to := time.Date(2022, 3, 3, 0, 0, 0, 0, time.UTC)
+	from := to.AddDate(0, 0, -2)
+	for i, feederId := range []string{"owner", "feeder", "feeder"} {
+		f := &feed.Feed{Id: fmt.Sprint("feed", i), PetId: "pet1", FeederId: feederId,
+			Timestamp: from.Add(time.Duration(i*12) * time.Hour), Amount: 10, Unit: "g"}
+		if err := stores.feeds.Put(ctx, f); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, err := s.GetFeedStats(ctxOf("stranger"), "pet1", from, to, feed.PeriodDay, "")
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	_, err = s.GetFeedStats(ctxOf("owner"), "pet1", from, to, feed.PeriodDay, "Nowhere/City")
+	assert.Error(t, err)
+
+	stats, err := s.GetFeedStats(ctxOf("owner"), "pet1", from, to, feed.PeriodDay, "UTC")
+	assert.NoError(t, err)
+	assert.Len(t, stats.Buckets, 2)
+	assert.Equal(t, 2, stats.Buckets[0].Count)
+	assert.Equal(t, 1, stats.Buckets[1].Count)
+	assert.Equal(t, map[string]float64{"g": 30}, stats.Total.Amounts)
+	assert.Equal(t, 12*time.Hour, stats.Total.AverageInterval)
+	if assert.Len(t, stats.Total.Feeders, 2) {
+		assert.Equal(t, "name-feeder", stats.Total.Feeders[0].FeederName)
+		assert.Equal(t, 2, stats.Total.Feeders[0].Count)
+		assert.Equal(t, "name-owner", stats.Buckets[0].Feeders[1].FeederName)
+	}
+
+	petServer := NewPetServer(stores.pets, stores.users, stores.uow, stores.storage, stores.deleter,
+		authz.New(stores.pets, stores.households))
+	_, err = petServer.SetPreferredUnit(ctxOf("owner"), "pet1", "handful")
+	assert.Error(t, err)
+	_, err = petServer.SetPreferredUnit(ctxOf("owner"), "pet1", "Kilograms")
+	assert.NoError(t, err)
+	stats, err = s.GetFeedStats(ctxOf("owner"), "pet1", from, to, feed.PeriodDay, "UTC")
+	assert.NoError(t, err)
+	assert.InDelta(t, 0.03, stats.Total.Amounts["kg"], 1e-9)
+}
+
+func TestFeedServer_Units(t *testing.T) {
+	stores := newTestStores(t)
+	s := NewFeedServer(stores.feeds, stores.users, stores.foods, stores.weights, authz.New(stores.pets, stores.households))
+	now := time.Now().Unix()
+
+	_, err := s.AddFeed(ctxOf("owner"), &gonyom.AddFeedRequest{
+		Feed: &gonyom.Feed{PetId: "pet1", Timestamp: now, Amount: 1, Unit: "handful"},
+	})
+	assert.Error(t, err)
+	added, err := s.AddFeed(ctxOf("owner"), &gonyom.AddFeedRequest{
+		Feed: &gonyom.Feed{PetId: "pet1", Timestamp: now, Amount: 1, Unit: "컵"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "cup", added.Feed.Unit)
+
+	f := proto.Clone(added.Feed).(*gonyom.Feed)
+	f.Unit = "scoopful"
+	_, err = s.UpdateFeed(ctxOf("owner"), &gonyom.UpdateFeedRequest{Feed: f})
+	assert.Error(t, err)
+	f.Unit = "Grams"
+	updated, err := s.UpdateFeed(ctxOf("owner"), &gonyom.UpdateFeedRequest{Feed: f})
+	assert.NoError(t, err)
+	assert.Equal(t, "g", updated.Feed.Unit)
+}
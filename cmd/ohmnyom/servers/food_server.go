@@ -0,0 +1,101 @@
+package servers
+
+import (
+	"context"
+	"time"
+
+	"ohmnyom/domain/food"
+	"ohmnyom/domain/pet"
+	"ohmnyom/internal/authz"
+	"ohmnyom/internal/errors"
+)
+
+// FoodServer manages the food catalogs of pets. protonyom does not define a
+// FoodApi yet, so the server is not registered.
+type FoodServer struct {
+	foodStore  food.Store
+	authorizer *authz.Authorizer
+}
+
+func NewFoodServer(store food.Store, authorizer *authz.Authorizer) *FoodServer {
+	return &FoodServer{
+		foodStore:  store,
+		authorizer: authorizer,
+	}
+}
+
+// AddFood adds a food to the catalog of the pet. Any feeder may add one, as
+// whoever buys a new bag of treats is the one to register it.
+func (s *FoodServer) AddFood(ctx context.Context, in *food.Food) (*food.Food, error) {
+	if in == nil {
+		return nil, errors.GrpcError(errors.NewInvalidParamError("food: %v", in))
+	}
+	if _, err := s.authorizer.Pet(ctx, in.PetId, pet.RoleCaregiver); err != nil {
+		return nil, errors.GrpcError(err)
+	}
+	uid, _ := authz.Uid(ctx)
+
+	newFood := *in
+	newFood.Id = food.NewFoodId()
+	newFood.CreatedBy = uid
+	newFood.Created = time.Now().UTC()
+	if err := newFood.Validate(); err != nil {
+		return nil, errors.GrpcError(err)
+	}
+	if err := s.foodStore.Put(ctx, &newFood); err != nil {
+		return nil, errors.GrpcError(err)
+	}
+	return &newFood, nil
+}
+
+func (s *FoodServer) GetFoods(ctx context.Context, petId string) ([]*food.Food, error) {
+	if _, err := s.authorizer.Pet(ctx, petId, pet.RoleViewer); err != nil {
+		return nil, errors.GrpcError(err)
+	}
+	foods, err := s.foodStore.GetListOfPet(ctx, petId)
+	if err != nil {
+		return nil, errors.GrpcError(err)
+	}
+	return foods, nil
+}
+
+// UpdateFood replaces the name, brand, type, unit and calories of a food. Feeds
+// already recorded keep the calories they were recorded with.
+func (s *FoodServer) UpdateFood(ctx context.Context, in *food.Food) (*food.Food, error) {
+	if in == nil {
+		return nil, errors.GrpcError(errors.NewInvalidParamError("food: %v", in))
+	}
+	if _, err := s.authorizer.Pet(ctx, in.PetId, pet.RoleOwner); err != nil {
+		return nil, errors.GrpcError(err)
+	}
+	stored, err := s.foodStore.Get(ctx, in.PetId, in.Id)
+	if err != nil {
+		return nil, errors.GrpcError(err)
+	}
+
+	updated := *in
+	updated.CreatedBy = stored.CreatedBy
+	updated.Created = stored.Created
+	if err := updated.Validate(); err != nil {
+		return nil, errors.GrpcError(err)
+	}
+	if err := s.foodStore.Update(ctx, &updated); err != nil {
+		return nil, errors.GrpcError(err)
+	}
+	return &updated, nil
+}
+
+// DeleteFood removes a food from the catalog. Feeds of it keep their type and
+// calories.
+func (s *FoodServer) DeleteFood(ctx context.Context, petId, id string) error {
+	if _, err := s.authorizer.Pet(ctx, petId, pet.RoleOwner); err != nil {
+		return errors.GrpcError(err)
+	}
+	if _, err := s.foodStore.Get(ctx, petId, id); err != nil {
+		return errors.GrpcError(err)
+	}
+	if err := s.foodStore.Delete(ctx, petId, id); err != nil {
+		return errors.GrpcError(err)
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package servers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aiceru/protonyom/gonyom"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"ohmnyom/domain/feed"
+	"ohmnyom/domain/food"
+	"ohmnyom/domain/pet"
+	"ohmnyom/domain/weight"
+	"ohmnyom/internal/authz"
+)
+
+func TestFoodServer(t *testing.T) {
+	stores := newTestStores(t)
+	s := NewFoodServer(stores.foods, authz.New(stores.pets, stores.households))
+	in := &food.Food{PetId: "pet1", Name: "kibble", Brand: "nyom", Type: food.TypeKibble, KcalPerUnit: 3.6, Unit: "g"}
+
+	_, err := s.AddFood(ctxOf("stranger"), in)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	added, err := s.AddFood(ctxOf("feeder"), in)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, added.Id)
+	assert.Equal(t, "feeder", added.CreatedBy)
+
+	list, err := s.GetFoods(ctxOf("owner"), "pet1")
+	assert.NoError(t, err)
+	assert.Equal(t, []*food.Food{added}, list)
+
+	update := *added
+	update.KcalPerUnit = 4
+	_, err = s.UpdateFood(ctxOf("feeder"), &update)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	updated, err := s.UpdateFood(ctxOf("owner"), &update)
+	assert.NoError(t, err)
+	assert.Equal(t, 4.0, updated.KcalPerUnit)
+	assert.Equal(t, "feeder", updated.CreatedBy)
+
+	assert.Equal(t, codes.PermissionDenied, status.Code(s.DeleteFood(ctxOf("feeder"), "pet1", added.Id)))
+	assert.NoError(t, s.DeleteFood(ctxOf("owner"), "pet1", added.Id))
+	assert.Equal(t, codes.NotFound, status.Code(s.DeleteFood(ctxOf("owner"), "pet1", added.Id)))
+}
+
+func TestFeedServer_AddTypedFeed(t *testing.T) {
+	stores := newTestStores(t)
+	s := NewFeedServer(stores.feeds, stores.users, stores.foods, stores.weights, authz.New(stores.pets, stores.households))
+	foods := NewFoodServer(stores.foods, authz.New(stores.pets, stores.households))
+	kibble, err := foods.AddFood(ctxOf("owner"), &food.Food{PetId: "pet1", Name: "kibble", Type: food.TypeKibble,
+		KcalPerUnit: 3.6, Unit: "g"})
+	assert.NoError(t, err)
+	now := time.Now().Unix()
+
+	_, err = s.AddTypedFeed(ctxOf("feeder"), &gonyom.Feed{PetId: "pet1", Timestamp: now, Amount: 10},
+		"unknown", "")
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	_, err = s.AddTypedFeed(ctxOf("feeder"), &gonyom.Feed{PetId: "pet1", Timestamp: now, Amount: 10},
+		"", "snack")
+	assert.Error(t, err)
+
+	added, err := s.AddTypedFeed(ctxOf("feeder"), &gonyom.Feed{PetId: "pet1", Timestamp: now - 7200, Amount: 50},
+		kibble.Id, "")
+	assert.NoError(t, err)
+	assert.Equal(t, "g", added.Unit)
+	stored, err := stores.feeds.Get(context.TODO(), "pet1", added.Id)
+	assert.NoError(t, err)
+	assert.Equal(t, kibble.Id, stored.FoodId)
+	assert.Equal(t, feed.TypeMeal, stored.Type)
+	assert.Equal(t, 180.0, stored.Kcal)
+
+	_, err = s.AddTypedFeed(ctxOf("feeder"), &gonyom.Feed{PetId: "pet1", Timestamp: now, Amount: 1, Unit: "piece"},
+		"", feed.TypeTreat)
+	assert.NoError(t, err)
+
+	// the calories follow the amount, even after the food is gone
+	added.Amount = 25
+	_, err = s.UpdateFeed(ctxOf("feeder"), &gonyom.UpdateFeedRequest{Feed: added})
+	assert.NoError(t, err)
+	stored, _ = stores.feeds.Get(context.TODO(), "pet1", added.Id)
+	assert.Equal(t, 90.0, stored.Kcal)
+	assert.NoError(t, foods.DeleteFood(ctxOf("owner"), "pet1", kibble.Id))
+	added.Amount = 50
+	_, err = s.UpdateFeed(ctxOf("feeder"), &gonyom.UpdateFeedRequest{Feed: added})
+	assert.NoError(t, err)
+	stored, _ = stores.feeds.Get(context.TODO(), "pet1", added.Id)
+	assert.Equal(t, 180.0, stored.Kcal)
+
+	stats, err := s.GetFeedStats(ctxOf("owner"), "pet1", time.Unix(now-86400, 0), time.Unix(now+1, 0),
+		feed.PeriodDay, "UTC")
+	assert.NoError(t, err)
+	assert.Equal(t, 180.0, stats.Total.Kcal)
+	if assert.Len(t, stats.Total.Types, 2) {
+		assert.Equal(t, 1, stats.Total.Types[feed.TypeMeal].Count)
+		assert.Equal(t, 180.0, stats.Total.Types[feed.TypeMeal].Kcal)
+		assert.Equal(t, map[string]float64{"piece": 1}, stats.Total.Types[feed.TypeTreat].Amounts)
+	}
+}
+
+func TestFeedServer_GetFeedingTarget(t *testing.T) {
+	stores := newTestStores(t)
+	s := NewFeedServer(stores.feeds, stores.users, stores.foods, stores.weights, authz.New(stores.pets, stores.households))
+	ctx := context.TODO()
+
+	_, err := s.GetFeedingTarget(ctxOf("stranger"), "pet1", "UTC")
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	_, err = s.GetFeedingTarget(ctxOf("feeder"), "pet1", "UTC")
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+
+	if err := stores.weights.Put(ctx, &weight.Weight{Id: "w1", PetId: "pet1", Timestamp: time.Now().Add(-time.Hour),
+		Value: 10, Unit: "kg"}); err != nil {
+		t.Fatal(err)
+	}
+	// a pet of no family has no target
+	_, err = s.GetFeedingTarget(ctxOf("feeder"), "pet1", "UTC")
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+
+	petServer := NewPetServer(stores.pets, stores.users, stores.uow, stores.storage, stores.deleter,
+		authz.New(stores.pets, stores.households))
+	assert.NoError(t, stores.pets.Update(ctx, "pet1", map[string]interface{}{pet.FamilyField: "dog"}))
+	_, err = petServer.SetNutritionProfile(ctxOf("feeder"), "pet1", time.Time{}, true)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	_, err = petServer.SetNutritionProfile(ctxOf("owner"), "pet1", time.Now().AddDate(-3, 0, 0), true)
+	assert.NoError(t, err)
+
+	now := time.Now().UTC()
+	feeds := []*feed.Feed{
+		{Id: "today", PetId: "pet1", FeederId: "feeder", Timestamp: now, Kcal: 300},
+		{Id: "yesterday", PetId: "pet1", FeederId: "feeder", Timestamp: now.AddDate(0, 0, -1), Kcal: 300},
+	}
+	for _, f := range feeds {
+		if err := stores.feeds.Put(ctx, f); err != nil {
+			t.Fatal(err)
+		}
+	}
+	intake, err := s.GetFeedingTarget(ctxOf("feeder"), "pet1", "UTC")
+	assert.NoError(t, err)
+	assert.InDelta(t, 629.8, intake.Target.Kcal, 0.1)
+	assert.Equal(t, 300.0, intake.Kcal)
+	assert.InDelta(t, 329.8, intake.Remaining, 0.1)
+}
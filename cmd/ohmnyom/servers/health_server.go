@@ -0,0 +1,227 @@
+package servers
+
+import (
+	"context"
+	"time"
+
+	"ohmnyom/domain/health"
+	"ohmnyom/domain/pet"
+	"ohmnyom/internal/authz"
+	"ohmnyom/internal/errors"
+	"ohmnyom/internal/storage"
+)
+
+// MaxDueHorizon limits how far ahead GetUpcomingDue looks.
+const MaxDueHorizon = 366 * 24 * time.Hour
+
+// HealthServer keeps the health records of pets. protonyom does not define a
+// HealthApi yet, so the server is not registered.
+type HealthServer struct {
+	healthStore health.Store
+	storage     storage.Storage
+	authorizer  *authz.Authorizer
+}
+
+func NewHealthServer(store health.Store, storage storage.Storage, authorizer *authz.Authorizer) *HealthServer {
+	return &HealthServer{
+		healthStore: store,
+		storage:     storage,
+		authorizer:  authorizer,
+	}
+}
+
+// AddHealthRecord adds a record to the pet. Files are attached to vet visits
+// with AddAttachment afterwards.
+func (s *HealthServer) AddHealthRecord(ctx context.Context, in *health.Record) (*health.Record, error) {
+	if in == nil {
+		return nil, errors.GrpcError(errors.NewInvalidParamError("record: %v", in))
+	}
+	if _, err := s.authorizer.Pet(ctx, in.PetId, pet.RoleCaregiver); err != nil {
+		return nil, errors.GrpcError(err)
+	}
+	uid, _ := authz.Uid(ctx)
+
+	newRecord := *in
+	newRecord.Id = health.NewRecordId()
+	newRecord.RecordedBy = uid
+	newRecord.Created = time.Now().UTC()
+	if newRecord.Visit != nil {
+		visit := *newRecord.Visit
+		visit.Attachments = nil
+		newRecord.Visit = &visit
+	}
+	if err := newRecord.Validate(); err != nil {
+		return nil, errors.GrpcError(err)
+	}
+	if err := s.healthStore.Put(ctx, &newRecord); err != nil {
+		return nil, errors.GrpcError(err)
+	}
+	return &newRecord, nil
+}
+
+// GetHealthRecords returns the records of the pet of kind, or of every kind
+// when kind is empty, latest first.
+func (s *HealthServer) GetHealthRecords(ctx context.Context, petId string, kind health.Kind) ([]*health.Record, error) {
+	if kind != "" {
+		if _, err := health.ParseKind(string(kind)); err != nil {
+			return nil, errors.GrpcError(err)
+		}
+	}
+	if _, err := s.authorizer.Pet(ctx, petId, pet.RoleViewer); err != nil {
+		return nil, errors.GrpcError(err)
+	}
+	records, err := s.healthStore.GetListOfPet(ctx, petId, kind)
+	if err != nil {
+		return nil, errors.GrpcError(err)
+	}
+	return records, nil
+}
+
+// UpdateHealthRecord replaces the title, date, notes and detail of a record.
+// The kind and the attachments stay. Only whoever recorded it or the owner may
+// change it.
+func (s *HealthServer) UpdateHealthRecord(ctx context.Context, in *health.Record) (*health.Record, error) {
+	if in == nil {
+		return nil, errors.GrpcError(errors.NewInvalidParamError("record: %v", in))
+	}
+	stored, err := s.healthStore.Get(ctx, in.PetId, in.Id)
+	if err != nil {
+		return nil, errors.GrpcError(err)
+	}
+	if _, err := s.authorizer.Author(ctx, stored.PetId, stored.RecordedBy); err != nil {
+		return nil, errors.GrpcError(err)
+	}
+	if in.Kind != stored.Kind {
+		return nil, errors.GrpcError(errors.NewInvalidParamError("kind [%v] of a %v record", in.Kind, stored.Kind))
+	}
+
+	updated := *stored
+	updated.Title = in.Title
+	updated.Date = in.Date
+	updated.Notes = in.Notes
+	updated.Vaccination = in.Vaccination
+	updated.Medication = in.Medication
+	if in.Visit != nil {
+		visit := *in.Visit
+		visit.Attachments = stored.Visit.Attachments
+		updated.Visit = &visit
+	}
+	if err := updated.Validate(); err != nil {
+		return nil, errors.GrpcError(err)
+	}
+	if err := s.healthStore.Update(ctx, &updated); err != nil {
+		return nil, errors.GrpcError(err)
+	}
+	return &updated, nil
+}
+
+// DeleteHealthRecord deletes a record with its attachments.
+func (s *HealthServer) DeleteHealthRecord(ctx context.Context, petId, id string) error {
+	stored, err := s.healthStore.Get(ctx, petId, id)
+	if err != nil {
+		return errors.GrpcError(err)
+	}
+	if _, err := s.authorizer.Author(ctx, stored.PetId, stored.RecordedBy); err != nil {
+		return errors.GrpcError(err)
+	}
+	if stored.Visit != nil && len(stored.Visit.Attachments) > 0 {
+		if err := s.storage.DeleteDir(ctx, pet.StorageRoot, pet.HealthRecordDir(petId, id)); err != nil {
+			return errors.GrpcError(errors.NewInternalError("%v", err))
+		}
+	}
+	if err := s.healthStore.Delete(ctx, petId, id); err != nil {
+		return errors.GrpcError(err)
+	}
+	return nil
+}
+
+// AddAttachment uploads a file, a lab result or an invoice, to a vet visit.
+func (s *HealthServer) AddAttachment(ctx context.Context, petId, recordId, name, contentType string,
+	content []byte) (*health.Record, error) {
+	if name == "" || len(content) == 0 || len(content) > health.MaxAttachmentSize {
+		return nil, errors.GrpcError(errors.NewInvalidParamError("name: %v, size: %v", name, len(content)))
+	}
+	stored, err := s.healthStore.Get(ctx, petId, recordId)
+	if err != nil {
+		return nil, errors.GrpcError(err)
+	}
+	if _, err := s.authorizer.Author(ctx, stored.PetId, stored.RecordedBy); err != nil {
+		return nil, errors.GrpcError(err)
+	}
+	if stored.Visit == nil {
+		return nil, errors.GrpcError(errors.NewInvalidParamError("attachment to a %v record", stored.Kind))
+	}
+	if len(stored.Visit.Attachments) >= health.MaxAttachments {
+		return nil, errors.GrpcError(errors.NewFailedPreconditionError("Record{Id: %v} has %v attachments",
+			recordId, len(stored.Visit.Attachments)))
+	}
+
+	attachment := &health.Attachment{
+		Id:          health.NewAttachmentId(),
+		Name:        name,
+		ContentType: contentType,
+		Uploaded:    time.Now().UTC(),
+	}
+	attachment.Path = pet.HealthRecordDir(petId, recordId) + attachment.Id
+	attachment.Url, err = s.storage.Upload(ctx, &storage.Object{
+		Root:        pet.StorageRoot,
+		Path:        attachment.Path,
+		ContentType: contentType,
+		Bytes:       content,
+	})
+	if err != nil {
+		return nil, errors.GrpcError(errors.NewInternalError("%v", err))
+	}
+	stored.Visit.Attachments = append(stored.Visit.Attachments, attachment)
+	if err := s.healthStore.Update(ctx, stored); err != nil {
+		_ = s.storage.Delete(ctx, pet.StorageRoot, attachment.Path)
+		return nil, errors.GrpcError(err)
+	}
+	return stored, nil
+}
+
+func (s *HealthServer) DeleteAttachment(ctx context.Context, petId, recordId, attachmentId string) (*health.Record, error) {
+	stored, err := s.healthStore.Get(ctx, petId, recordId)
+	if err != nil {
+		return nil, errors.GrpcError(err)
+	}
+	if _, err := s.authorizer.Author(ctx, stored.PetId, stored.RecordedBy); err != nil {
+		return nil, errors.GrpcError(err)
+	}
+	attachment := stored.Attachment(attachmentId)
+	if attachment == nil {
+		return nil, errors.GrpcError(errors.NewNotFoundError("Attachment{Id: %v} of Record{Id: %v}",
+			attachmentId, recordId))
+	}
+	if err := s.storage.Delete(ctx, pet.StorageRoot, attachment.Path); err != nil {
+		return nil, errors.GrpcError(errors.NewInternalError("%v", err))
+	}
+	kept := make([]*health.Attachment, 0, len(stored.Visit.Attachments)-1)
+	for _, a := range stored.Visit.Attachments {
+		if a.Id != attachmentId {
+			kept = append(kept, a)
+		}
+	}
+	stored.Visit.Attachments = kept
+	if err := s.healthStore.Update(ctx, stored); err != nil {
+		return nil, errors.GrpcError(err)
+	}
+	return stored, nil
+}
+
+// GetUpcomingDue returns the vaccinations and medication doses of the pet due
+// within horizon from now, and the vaccinations already overdue.
+func (s *HealthServer) GetUpcomingDue(ctx context.Context, petId string, horizon time.Duration) ([]*health.DueItem, error) {
+	if horizon <= 0 || horizon > MaxDueHorizon {
+		return nil, errors.GrpcError(errors.NewInvalidParamError("horizon: %v", horizon))
+	}
+	if _, err := s.authorizer.Pet(ctx, petId, pet.RoleViewer); err != nil {
+		return nil, errors.GrpcError(err)
+	}
+	records, err := s.healthStore.GetListOfPet(ctx, petId, "")
+	if err != nil {
+		return nil, errors.GrpcError(err)
+	}
+	now := time.Now().UTC()
+	return health.Upcoming(records, now, now.Add(horizon)), nil
+}
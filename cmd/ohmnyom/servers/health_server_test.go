@@ -0,0 +1,79 @@
+package servers
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"ohmnyom/domain/health"
+	"ohmnyom/domain/pet"
+	"ohmnyom/internal/authz"
+)
+
+func TestHealthServer(t *testing.T) {
+	stores := newTestStores(t)
+	s := NewHealthServer(stores.health, stores.storage, authz.New(stores.pets, stores.households))
+	now := time.Now().UTC()
+
+	visit := &health.Record{PetId: "pet1", Kind: health.KindVisit, Date: now, Visit: &health.Visit{Reason: "checkup"}}
+	_, err := s.AddHealthRecord(ctxOf("stranger"), visit)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	added, err := s.AddHealthRecord(ctxOf("feeder"), visit)
+	assert.NoError(t, err)
+	assert.Equal(t, "feeder", added.RecordedBy)
+	vaccination, err := s.AddHealthRecord(ctxOf("owner"), &health.Record{PetId: "pet1", Kind: health.KindVaccination,
+		Date: now.AddDate(0, -11, 0), Vaccination: &health.Vaccination{Vaccine: "rabies", NextDue: now.AddDate(0, 1, 0)}})
+	assert.NoError(t, err)
+
+	_, err = s.AddAttachment(ctxOf("owner"), "pet1", vaccination.Id, "card.jpg", "image/jpeg", []byte("card"))
+	assert.Error(t, err)
+	var file string
+	withFile, err := s.AddAttachment(ctxOf("feeder"), "pet1", added.Id, "blood.pdf", "application/pdf", []byte("pdf"))
+	assert.NoError(t, err)
+	if assert.Len(t, withFile.Visit.Attachments, 1) {
+		attachment := withFile.Visit.Attachments[0]
+		assert.Equal(t, pet.HealthRecordDir("pet1", added.Id)+attachment.Id, attachment.Path)
+		file = filepath.Join(stores.storageDir, pet.StorageRoot, attachment.Path)
+		_, err := os.Stat(file)
+		assert.NoError(t, err)
+	}
+
+	// an update keeps the attachments
+	update := *added
+	update.Visit = &health.Visit{Reason: "vaccination"}
+	_, err = s.UpdateHealthRecord(ctxOf("stranger"), &update)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	updated, err := s.UpdateHealthRecord(ctxOf("owner"), &update)
+	assert.NoError(t, err)
+	assert.Equal(t, "vaccination", updated.Visit.Reason)
+	assert.Len(t, updated.Visit.Attachments, 1)
+	update.Kind = health.KindMedication
+	_, err = s.UpdateHealthRecord(ctxOf("owner"), &update)
+	assert.Error(t, err)
+
+	list, err := s.GetHealthRecords(ctxOf("feeder"), "pet1", health.KindVaccination)
+	assert.NoError(t, err)
+	assert.Equal(t, []*health.Record{vaccination}, list)
+	list, err = s.GetHealthRecords(ctxOf("feeder"), "pet1", "")
+	assert.NoError(t, err)
+	assert.Len(t, list, 2)
+
+	due, err := s.GetUpcomingDue(ctxOf("feeder"), "pet1", 60*24*time.Hour)
+	assert.NoError(t, err)
+	if assert.Len(t, due, 1) {
+		assert.Equal(t, "rabies", due[0].Name)
+	}
+	due, err = s.GetUpcomingDue(ctxOf("feeder"), "pet1", 7*24*time.Hour)
+	assert.NoError(t, err)
+	assert.Empty(t, due)
+
+	// feeders delete only what they recorded
+	assert.Equal(t, codes.PermissionDenied, status.Code(s.DeleteHealthRecord(ctxOf("feeder"), "pet1", vaccination.Id)))
+	assert.NoError(t, s.DeleteHealthRecord(ctxOf("feeder"), "pet1", added.Id))
+	_, err = os.Stat(file)
+	assert.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,266 @@
+package servers
+
+import (
+	"context"
+	"time"
+
+	"ohmnyom/domain/household"
+	"ohmnyom/domain/invite"
+	"ohmnyom/domain/pet"
+	"ohmnyom/domain/uow"
+	"ohmnyom/internal/authz"
+	"ohmnyom/internal/errors"
+)
+
+// HouseholdServer manages households and the pets in them. protonyom does not
+// define a HouseholdApi yet, so the server is not registered.
+type HouseholdServer struct {
+	householdStore household.Store
+	petStore       pet.Store
+	inviteStore    invite.Store
+	unitOfWork     uow.UnitOfWork
+	authorizer     *authz.Authorizer
+}
+
+func NewHouseholdServer(store household.Store, petStore pet.Store, inviteStore invite.Store, unitOfWork uow.UnitOfWork,
+	authorizer *authz.Authorizer) *HouseholdServer {
+	return &HouseholdServer{
+		householdStore: store,
+		petStore:       petStore,
+		inviteStore:    inviteStore,
+		unitOfWork:     unitOfWork,
+		authorizer:     authorizer,
+	}
+}
+
+// CreateHousehold creates a household of the caller alone.
+func (s *HouseholdServer) CreateHousehold(ctx context.Context, name string) (*household.Household, error) {
+	uid, err := authz.Uid(ctx)
+	if err != nil {
+		return nil, errors.GrpcError(err)
+	}
+	h, err := household.New(name, uid)
+	if err != nil {
+		return nil, errors.GrpcError(err)
+	}
+	err = s.unitOfWork.Run(ctx, func(ctx context.Context, tx uow.Tx) error {
+		if _, err := tx.GetUser(uid); err != nil {
+			return err
+		}
+		return tx.CreateHousehold(h)
+	})
+	if err != nil {
+		return nil, errors.GrpcError(err)
+	}
+	return h, nil
+}
+
+// GetHouseholds returns the households of the caller.
+func (s *HouseholdServer) GetHouseholds(ctx context.Context) ([]*household.Household, error) {
+	uid, err := authz.Uid(ctx)
+	if err != nil {
+		return nil, errors.GrpcError(err)
+	}
+	households, err := s.householdStore.GetListOfUser(ctx, uid)
+	if err != nil {
+		return nil, errors.GrpcError(err)
+	}
+	return households, nil
+}
+
+// CreateHouseholdInvite lets a member invite up to maxUses users to the
+// household until ttl passes. Zero values take invite.DefaultMaxUses and
+// invite.DefaultTTL.
+func (s *HouseholdServer) CreateHouseholdInvite(ctx context.Context, householdId string, maxUses int,
+	ttl time.Duration) (*invite.Invite, error) {
+	if _, err := s.authorizer.Household(ctx, householdId); err != nil {
+		return nil, errors.GrpcError(err)
+	}
+	uid, _ := authz.Uid(ctx)
+	i, err := invite.NewToHousehold(householdId, uid, maxUses, ttl)
+	if err != nil {
+		return nil, errors.GrpcError(err)
+	}
+	if err := s.inviteStore.Put(ctx, i); err != nil {
+		return nil, errors.GrpcError(err)
+	}
+	return i, nil
+}
+
+// JoinHousehold redeems a household invite and makes the caller a member of
+// the household, and so a caregiver of every pet in it.
+func (s *HouseholdServer) JoinHousehold(ctx context.Context, code string) (*household.Household, error) {
+	uid, err := authz.Uid(ctx)
+	if err != nil {
+		return nil, errors.GrpcError(err)
+	}
+	if code == "" {
+		return nil, errors.GrpcError(errors.NewInvalidParamError("code: %v", code))
+	}
+	now := time.Now().UTC()
+	var joined *household.Household
+	err = s.unitOfWork.Run(ctx, func(ctx context.Context, tx uow.Tx) error {
+		if _, err := tx.GetUser(uid); err != nil {
+			return err
+		}
+		i, err := tx.GetInvite(code)
+		if err != nil {
+			return err
+		}
+		if i.HouseholdId == "" {
+			return errors.NewFailedPreconditionError("Invite{Code: %v} is to a pet", code)
+		}
+		h, err := tx.GetHousehold(i.HouseholdId)
+		if err != nil {
+			return err
+		}
+		if h.IsMember(uid) {
+			return errors.NewAlreadyExistsError("member %v of Household{Id: %v}", uid, h.Id)
+		}
+		if len(h.Members) >= household.MaxMembers {
+			return errors.NewFailedPreconditionError("Household{Id: %v} has %v members", h.Id, len(h.Members))
+		}
+		if err := i.CheckRedeemable(uid, now); err != nil {
+			return err
+		}
+
+		if err := tx.UseInvite(code, uid); err != nil {
+			return err
+		}
+		h.Members = append(h.Members, uid)
+		joined = h
+		return tx.AddHouseholdMember(h.Id, uid)
+	})
+	if err != nil {
+		return nil, errors.GrpcError(err)
+	}
+	return joined, nil
+}
+
+// LeaveHousehold removes the caller from the household. The pets of the
+// household keep their own members, and belong to none once the last member
+// leaves.
+func (s *HouseholdServer) LeaveHousehold(ctx context.Context, householdId string) error {
+	if _, err := s.authorizer.Household(ctx, householdId); err != nil {
+		return errors.GrpcError(err)
+	}
+	uid, _ := authz.Uid(ctx)
+	pets, err := s.petStore.GetListOfHousehold(ctx, householdId)
+	if err != nil {
+		return errors.GrpcError(err)
+	}
+	err = s.unitOfWork.Run(ctx, func(ctx context.Context, tx uow.Tx) error {
+		h, err := tx.GetHousehold(householdId)
+		if err != nil {
+			return err
+		}
+		read, err := readPets(tx, pets)
+		if err != nil {
+			return err
+		}
+		return uow.LeaveHousehold(tx, h, uid, read)
+	})
+	if err != nil {
+		return errors.GrpcError(err)
+	}
+	return nil
+}
+
+// MovePet moves a pet of the caller to a household of the caller, or out of
+// any with an empty householdId.
+func (s *HouseholdServer) MovePet(ctx context.Context, petId, householdId string) (*pet.Pet, error) {
+	if _, err := s.authorizer.Pet(ctx, petId, pet.RoleOwner); err != nil {
+		return nil, errors.GrpcError(err)
+	}
+	if householdId != "" {
+		if _, err := s.authorizer.Household(ctx, householdId); err != nil {
+			return nil, errors.GrpcError(err)
+		}
+	}
+	uid, _ := authz.Uid(ctx)
+	err := s.unitOfWork.Run(ctx, func(ctx context.Context, tx uow.Tx) error {
+		p, err := tx.GetPet(petId)
+		if err != nil {
+			return err
+		}
+		if p.RoleOf(uid) < pet.RoleOwner {
+			return errors.NewPermissionDeniedError("user %v on Pet{Id: %v}", uid, petId)
+		}
+		if householdId != "" {
+			h, err := tx.GetHousehold(householdId)
+			if err != nil {
+				return err
+			}
+			if !h.IsMember(uid) {
+				return errors.NewPermissionDeniedError("user %v on Household{Id: %v}", uid, householdId)
+			}
+		}
+		return tx.SetPetHousehold(petId, householdId)
+	})
+	if err != nil {
+		return nil, errors.GrpcError(err)
+	}
+	p, err := s.petStore.Get(ctx, petId)
+	if err != nil {
+		return nil, errors.GrpcError(err)
+	}
+	return p, nil
+}
+
+// readPets reads pets again in tx, leaving out those deleted since.
+func readPets(tx uow.Tx, pets pet.List) (pet.List, error) {
+	ret := make(pet.List, 0, len(pets))
+	for _, p := range pets {
+		read, err := tx.GetPet(p.Id)
+		var notfound *errors.NotFoundError
+		if errors.As(err, &notfound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, read)
+	}
+	return ret, nil
+}
+
+// readHousehold reads the household of a pet in tx, nil when it has none or
+// it was deleted.
+func readHousehold(tx uow.Tx, id string) (*household.Household, error) {
+	if id == "" {
+		return nil, nil
+	}
+	h, err := tx.GetHousehold(id)
+	var notfound *errors.NotFoundError
+	if errors.As(err, &notfound) {
+		return nil, nil
+	}
+	return h, err
+}
+
+// leavePet takes uid off the members of p, read in tx along with h, its
+// household if it has one. The last owner hands p to its successor, or with no
+// other member left to another member of the household, who joins it. Only
+// when there is none either is p deleted, and leavePet tells so.
+func leavePet(tx uow.Tx, p *pet.Pet, h *household.Household, uid string) (bool, error) {
+	successor := p.Successor(uid)
+	heir := ""
+	if h != nil {
+		heir = h.MemberOtherThan(uid)
+	}
+	switch {
+	case successor != "":
+		if p.IsLastOwner(uid) {
+			if err := uow.MakeOwner(tx, p, successor); err != nil {
+				return false, err
+			}
+		}
+	case heir != "":
+		if err := uow.Join(tx, heir, p.Id, pet.RoleOwner); err != nil {
+			return false, err
+		}
+	default:
+		return true, tx.DeletePet(p.Id)
+	}
+	return false, tx.DeleteMember(p.Id, uid)
+}
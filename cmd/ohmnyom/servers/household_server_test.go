@@ -0,0 +1,186 @@
+package servers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aiceru/protonyom/gonyom"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"ohmnyom/internal/authz"
+	"ohmnyom/internal/errors"
+)
+
+func TestHouseholdServer(t *testing.T) {
+	stores := newTestStores(t)
+	authorizer := authz.New(stores.pets, stores.households)
+	s := NewHouseholdServer(stores.households, stores.pets, stores.invites, stores.uow, authorizer)
+	pets := newTestPetServer(stores)
+	feeds := NewFeedServer(stores.feeds, stores.users, stores.foods, stores.weights, authorizer)
+	users := newTestUserServer(stores)
+
+	_, err := s.CreateHousehold(ctxOf("owner"), "")
+	assert.Equal(t, codes.Internal, status.Code(err))
+	h, err := s.CreateHousehold(ctxOf("owner"), "home")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"owner"}, h.Members)
+
+	_, err = s.MovePet(ctxOf("feeder"), "pet1", h.Id)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err), "only the owner moves a pet")
+	p, err := s.MovePet(ctxOf("owner"), "pet1", h.Id)
+	assert.NoError(t, err)
+	assert.Equal(t, h.Id, p.HouseholdId)
+
+	_, err = s.CreateHouseholdInvite(ctxOf("stranger"), h.Id, 1, time.Hour)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	i, err := s.CreateHouseholdInvite(ctxOf("owner"), h.Id, 1, time.Hour)
+	assert.NoError(t, err)
+	_, err = users.AcceptInvite(ctxOf("stranger"), &gonyom.AcceptInviteRequest{PetId: i.Code})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err), "a household invite is not to a pet")
+
+	byHousehold := metadata.NewIncomingContext(ctxOf("stranger"), metadata.Pairs(householdKey, h.Id))
+	_, err = pets.GetPetList(byHousehold, &gonyom.GetPetListRequest{})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	joined, err := s.JoinHousehold(ctxOf("stranger"), i.Code)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"owner", "stranger"}, joined.Members)
+	_, err = s.JoinHousehold(ctxOf("stranger"), i.Code)
+	assert.Equal(t, codes.AlreadyExists, status.Code(err))
+
+	// members of the household inherit access to its pets
+	list, err := pets.GetPetList(byHousehold, &gonyom.GetPetListRequest{})
+	assert.NoError(t, err)
+	assert.Len(t, list.Pets, 1)
+	_, err = pets.GetPet(grpc.NewContextWithServerTransportStream(ctxOf("stranger"), &testStream{}),
+		&gonyom.GetPetRequest{PetId: "pet1"})
+	assert.NoError(t, err)
+	_, err = feeds.AddFeed(ctxOf("stranger"), &gonyom.AddFeedRequest{
+		Feed: &gonyom.Feed{PetId: "pet1", FeederId: "stranger", Timestamp: time.Now().Unix(), Amount: 10, Unit: "g"},
+	})
+	assert.NoError(t, err)
+	_, err = pets.UpdatePet(ctxOf("stranger"), &gonyom.UpdatePetRequest{Pet: &gonyom.Pet{Id: "pet1", Name: "ohm"}})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err), "household members are caregivers")
+
+	households, err := s.GetHouseholds(ctxOf("stranger"))
+	assert.NoError(t, err)
+	assert.Len(t, households, 1)
+
+	assert.NoError(t, s.LeaveHousehold(ctxOf("stranger"), h.Id))
+	_, err = feeds.AddFeed(ctxOf("stranger"), &gonyom.AddFeedRequest{
+		Feed: &gonyom.Feed{PetId: "pet1", FeederId: "stranger", Timestamp: time.Now().Unix(), Amount: 10, Unit: "g"},
+	})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	// the last member to leave deletes the household
+	assert.NoError(t, s.LeaveHousehold(ctxOf("owner"), h.Id))
+	_, err = stores.households.Get(ctxOf("owner"), h.Id)
+	var notfound *errors.NotFoundError
+	assert.ErrorAs(t, err, &notfound)
+	p, err = stores.pets.Get(ctxOf("owner"), "pet1")
+	assert.NoError(t, err)
+	assert.Empty(t, p.HouseholdId)
+}
+
+func TestHouseholdServer_DeleteAccount(t *testing.T) {
+	stores := newTestStores(t)
+	s := NewHouseholdServer(stores.households, stores.pets, stores.invites, stores.uow,
+		authz.New(stores.pets, stores.households))
+	users := newTestUserServer(stores)
+
+	h, err := s.CreateHousehold(ctxOf("feeder"), "home")
+	assert.NoError(t, err)
+	i, err := s.CreateHouseholdInvite(ctxOf("feeder"), h.Id, 1, time.Hour)
+	assert.NoError(t, err)
+	_, err = s.JoinHousehold(ctxOf("stranger"), i.Code)
+	assert.NoError(t, err)
+
+	_, err = users.Delete(ctxOf("stranger"), &gonyom.DeleteAccountRequest{Id: "stranger"})
+	assert.NoError(t, err)
+	stored, err := stores.households.Get(ctxOf("feeder"), h.Id)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"feeder"}, stored.Members)
+}
+
+func TestHouseholdServer_FormerMemberLeavesPet(t *testing.T) {
+	// pet1 stays in the household of stranger alone after owner, its only
+	// member, left the household
+	stores := newTestStores(t)
+	s := NewHouseholdServer(stores.households, stores.pets, stores.invites, stores.uow,
+		authz.New(stores.pets, stores.households))
+	h, err := s.CreateHousehold(ctxOf("stranger"), "home")
+	assert.NoError(t, err)
+	i, err := s.CreateHouseholdInvite(ctxOf("stranger"), h.Id, 1, time.Hour)
+	assert.NoError(t, err)
+	_, err = s.JoinHousehold(ctxOf("owner"), i.Code)
+	assert.NoError(t, err)
+	_, err = s.MovePet(ctxOf("owner"), "pet1", h.Id)
+	assert.NoError(t, err)
+	assert.NoError(t, s.LeaveHousehold(ctxOf("owner"), h.Id))
+	_, err = newTestPetServer(stores).RemoveFeeder(ctxOf("owner"), "pet1", "feeder")
+	assert.NoError(t, err)
+
+	_, err = newTestPetServer(stores).DeletePet(ctxOf("owner"), &gonyom.DeletePetRequest{PetId: "pet1"})
+	assert.NoError(t, err)
+	p, err := stores.pets.Get(context.TODO(), "pet1")
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"stranger"}, p.Owners())
+		assert.Equal(t, h.Id, p.HouseholdId)
+	}
+}
+
+func TestHouseholdServer_LastMemberLeaves(t *testing.T) {
+	// pet1 is in the household of owner and stranger, and owner is its only
+	// member
+	newStores := func(t *testing.T) *testStores {
+		stores := newTestStores(t)
+		s := NewHouseholdServer(stores.households, stores.pets, stores.invites, stores.uow,
+			authz.New(stores.pets, stores.households))
+		h, err := s.CreateHousehold(ctxOf("owner"), "home")
+		assert.NoError(t, err)
+		_, err = s.MovePet(ctxOf("owner"), "pet1", h.Id)
+		assert.NoError(t, err)
+		i, err := s.CreateHouseholdInvite(ctxOf("owner"), h.Id, 1, time.Hour)
+		assert.NoError(t, err)
+		_, err = s.JoinHousehold(ctxOf("stranger"), i.Code)
+		assert.NoError(t, err)
+		_, err = newTestPetServer(stores).RemoveFeeder(ctxOf("owner"), "pet1", "feeder")
+		assert.NoError(t, err)
+		return stores
+	}
+	// the household keeps the pet, with stranger as its owner
+	assertKept := func(t *testing.T, stores *testStores) {
+		p, err := stores.pets.Get(context.TODO(), "pet1")
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, []string{"stranger"}, p.Feeders)
+		assert.Equal(t, []string{"stranger"}, p.Owners())
+		assert.NotEmpty(t, p.HouseholdId)
+		u, err := stores.users.Get(context.TODO(), "stranger")
+		assert.NoError(t, err)
+		assert.True(t, u.HasPet("pet1"))
+	}
+
+	t.Run("DeletePet", func(t *testing.T) {
+		stores := newStores(t)
+		_, err := newTestPetServer(stores).DeletePet(ctxOf("owner"), &gonyom.DeletePetRequest{PetId: "pet1"})
+		assert.NoError(t, err)
+		assertKept(t, stores)
+	})
+	t.Run("Delete", func(t *testing.T) {
+		stores := newStores(t)
+		_, err := newTestUserServer(stores).Delete(ctxOf("owner"), &gonyom.DeleteAccountRequest{Id: "owner"})
+		assert.NoError(t, err)
+		assertKept(t, stores)
+		households, err := stores.households.GetListOfUser(context.TODO(), "stranger")
+		assert.NoError(t, err)
+		if assert.Len(t, households, 1) {
+			assert.Equal(t, []string{"stranger"}, households[0].Members)
+		}
+	})
+}
@@ -2,28 +2,53 @@ package servers
 
 import (
 	"context"
+	"fmt"
+	"sort"
+	"time"
 
 	"github.com/aiceru/protonyom/gonyom"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"ohmnyom/domain/deletion"
 	"ohmnyom/domain/pet"
+	"ohmnyom/domain/units"
+	"ohmnyom/domain/uow"
 	"ohmnyom/domain/user"
 	"ohmnyom/i18n"
+	"ohmnyom/internal/authz"
+	"ohmnyom/internal/cascade"
 	"ohmnyom/internal/errors"
 	"ohmnyom/internal/storage"
-	"ohmnyom/internal/util"
+)
+
+const (
+	// grantsKey is the response header GetPet lists the grants of pet sitters
+	// in.
+	grantsKey = "pet-grants"
+	// householdKey is the request metadata GetPetList lists the pets of a
+	// household by, in place of the pet ids of the request.
+	householdKey = "household-id"
 )
 
 type PetServer struct {
-	petStore  pet.Store
-	userStore user.Store
-	storage   storage.Storage
+	petStore   pet.Store
+	userStore  user.Store
+	unitOfWork uow.UnitOfWork
+	storage    storage.Storage
+	deleter    *cascade.Deleter
+	authorizer *authz.Authorizer
 	gonyom.UnimplementedPetApiServer
 }
 
-func NewPetServer(store pet.Store, userStore user.Store, storage storage.Storage) *PetServer {
+func NewPetServer(store pet.Store, userStore user.Store, unitOfWork uow.UnitOfWork, storage storage.Storage,
+	deleter *cascade.Deleter, authorizer *authz.Authorizer) *PetServer {
 	return &PetServer{
-		petStore:  store,
-		userStore: userStore,
-		storage:   storage,
+		petStore:   store,
+		userStore:  userStore,
+		unitOfWork: unitOfWork,
+		storage:    storage,
+		deleter:    deleter,
+		authorizer: authorizer,
 	}
 }
 
@@ -45,6 +70,7 @@ func (s *PetServer) AddPet(ctx context.Context, request *gonyom.AddPetRequest) (
 	newPet := pet.FromProto(request.GetPet())
 	newPet.Id = pet.NewPetId()
 	newPet.Feeders = []string{u.Id}
+	newPet.Roles = map[string]string{u.Id: pet.RoleOwner.String()}
 
 	contentType := request.GetProfileContentType()
 	profileImageBytes := request.GetProfilePhoto()
@@ -57,10 +83,19 @@ func (s *PetServer) AddPet(ctx context.Context, request *gonyom.AddPetRequest) (
 		newPet.Photourl = link
 	}
 
-	if err := s.petStore.Put(ctx, newPet); err != nil {
-		return nil, errors.GrpcError(err)
-	}
-	if err := s.userStore.AddPet(ctx, u.Id, newPet.Id); err != nil {
+	err = s.unitOfWork.Run(ctx, func(ctx context.Context, tx uow.Tx) error {
+		if _, err := tx.GetUser(u.Id); err != nil {
+			return err
+		}
+		if err := tx.CreatePet(newPet); err != nil {
+			return err
+		}
+		return tx.AddUserPet(u.Id, newPet.Id)
+	})
+	if err != nil {
+		if newPet.Photourl != "" {
+			_ = s.storage.DeleteDir(ctx, pet.StorageRoot, newPet.ProfileDir())
+		}
 		return nil, errors.GrpcError(err)
 	}
 
@@ -86,6 +121,9 @@ func (s *PetServer) UpdatePet(ctx context.Context, request *gonyom.UpdatePetRequ
 	}
 
 	newPet := pet.FromProto(request.GetPet())
+	if _, err := s.authorizer.Pet(ctx, newPet.Id, pet.RoleOwner); err != nil {
+		return nil, errors.GrpcError(err)
+	}
 	contentType := request.GetProfileContentType()
 	profileImageBytes := request.GetProfilePhoto()
 
@@ -120,43 +158,115 @@ func (s *PetServer) UpdatePet(ctx context.Context, request *gonyom.UpdatePetRequ
 	}, nil
 }
 
-func (s *PetServer) DeletePet(ctx context.Context, request *gonyom.DeletePetRequest) (*gonyom.DeletePetReply, error) {
-	uid := ctx.Value(user.CtxKeyUid).(string)
-	u, err := s.userStore.Get(ctx, uid)
+// SetFeedWindow sets how close two feeds of the pet may be before AddFeed
+// rejects the second one. Zero minutes restores the default, and a negative
+// value turns the check off. protonyom does not define the RPC yet.
+func (s *PetServer) SetFeedWindow(ctx context.Context, petId string, minutes int) (*pet.Pet, error) {
+	if time.Duration(minutes)*time.Minute > pet.MaxFeedWindow {
+		return nil, errors.GrpcError(errors.NewInvalidParamError("minutes: %v", minutes))
+	}
+	if _, err := s.authorizer.Pet(ctx, petId, pet.RoleOwner); err != nil {
+		return nil, errors.GrpcError(err)
+	}
+	if err := s.petStore.Update(ctx, petId, map[string]interface{}{
+		pet.FeedWindowField: minutes,
+	}); err != nil {
+		return nil, errors.GrpcError(err)
+	}
+	p, err := s.petStore.Get(ctx, petId)
 	if err != nil {
 		return nil, errors.GrpcError(err)
 	}
+	return p, nil
+}
 
-	petId := request.GetPetId()
-	if !u.HasPet(petId) {
-		return nil, errors.GrpcError(errors.NewNotFoundError("pet id %s from pet list of user %s"))
+// SetPreferredUnit sets the unit feed stats of the pet report amounts in. An
+// empty unit keeps every feed in its own. protonyom does not define the RPC yet.
+func (s *PetServer) SetPreferredUnit(ctx context.Context, petId, unit string) (*pet.Pet, error) {
+	unit, err := units.Normalize(unit)
+	if err != nil {
+		return nil, errors.GrpcError(err)
+	}
+	if _, err := s.authorizer.Pet(ctx, petId, pet.RoleOwner); err != nil {
+		return nil, errors.GrpcError(err)
+	}
+	if err := s.petStore.Update(ctx, petId, map[string]interface{}{
+		pet.PreferredUnitField: unit,
+	}); err != nil {
+		return nil, errors.GrpcError(err)
 	}
-
 	p, err := s.petStore.Get(ctx, petId)
 	if err != nil {
 		return nil, errors.GrpcError(err)
 	}
+	return p, nil
+}
 
-	// delete pet id from user doc
-	if err := s.userStore.DeletePet(ctx, uid, petId); err != nil {
+// SetNutritionProfile sets the birth date and neuter status the daily calorie
+// target of the pet depends on. A zero born leaves the pet an adult. protonyom
+// does not define the RPC yet.
+func (s *PetServer) SetNutritionProfile(ctx context.Context, petId string, born time.Time, neutered bool) (*pet.Pet, error) {
+	if born.After(time.Now()) {
+		return nil, errors.GrpcError(errors.NewInvalidParamError("born: %v", born))
+	}
+	if _, err := s.authorizer.Pet(ctx, petId, pet.RoleOwner); err != nil {
+		return nil, errors.GrpcError(err)
+	}
+	if err := s.petStore.Update(ctx, petId, map[string]interface{}{
+		pet.BornField:     born.UTC(),
+		pet.NeuteredField: neutered,
+	}); err != nil {
 		return nil, errors.GrpcError(err)
 	}
+	p, err := s.petStore.Get(ctx, petId)
+	if err != nil {
+		return nil, errors.GrpcError(err)
+	}
+	return p, nil
+}
 
-	p.Feeders = util.Remove(p.Feeders, u.Id)
-	if len(p.Feeders) == 0 {
-		// delete from firestore
-		if err := s.petStore.Delete(ctx, petId); err != nil {
-			return nil, errors.GrpcError(err)
+// DeletePet takes the pet off the caller. The last owner hands it to the
+// successor first, and the pet is deleted only when no other member of it or
+// of its household is left.
+func (s *PetServer) DeletePet(ctx context.Context, request *gonyom.DeletePetRequest) (*gonyom.DeletePetReply, error) {
+	uid := ctx.Value(user.CtxKeyUid).(string)
+	petId := request.GetPetId()
+	var job *deletion.Job
+	err := s.unitOfWork.Run(ctx, func(ctx context.Context, tx uow.Tx) error {
+		job = nil
+		u, err := tx.GetUser(uid)
+		if err != nil {
+			return err
 		}
-		// delete profile
-		if err := s.storage.DeleteDir(ctx, pet.StorageRoot, p.ProfileDir()); err != nil {
-			return nil, errors.GrpcError(err)
+		if !u.HasPet(petId) {
+			return errors.NewNotFoundError("pet id %s from pet list of user %s", petId, uid)
 		}
-	} else {
-		// update pet doc
-		if err := s.petStore.DeleteFeeder(ctx, petId, uid); err != nil {
-			return nil, errors.GrpcError(err)
+		p, err := tx.GetPet(petId)
+		if err != nil {
+			return err
+		}
+
+		h, err := readHousehold(tx, p.HouseholdId)
+		if err != nil {
+			return err
+		}
+
+		if err := tx.DeleteUserPet(uid, petId); err != nil {
+			return err
 		}
+		deleted, err := leavePet(tx, p, h, uid)
+		if err != nil || !deleted {
+			return err
+		}
+		job = deletion.NewPetJob(petId, p.StorageDir())
+		return tx.SaveDeletionJob(job)
+	})
+	if err != nil {
+		return nil, errors.GrpcError(err)
+	}
+
+	if job != nil {
+		s.deleter.Start(job)
 	}
 
 	account, err := s.userStore.Get(ctx, uid)
@@ -173,31 +283,197 @@ func (s *PetServer) DeletePet(ctx context.Context, request *gonyom.DeletePetRequ
 	}, nil
 }
 
+// SetMemberRole changes the role of a member of the pet. Making a member an
+// owner shares the ownership, and the last owner cannot step down. protonyom
+// does not define the RPC yet.
+func (s *PetServer) SetMemberRole(ctx context.Context, petId, uid string, role pet.Role) (*pet.Pet, error) {
+	if role < pet.RoleViewer || role > pet.RoleOwner {
+		return nil, errors.GrpcError(errors.NewInvalidParamError("role: %v", role))
+	}
+	if _, err := s.authorizer.Pet(ctx, petId, pet.RoleOwner); err != nil {
+		return nil, errors.GrpcError(err)
+	}
+	err := s.unitOfWork.Run(ctx, func(ctx context.Context, tx uow.Tx) error {
+		p, err := tx.GetPet(petId)
+		if err != nil {
+			return err
+		}
+		if p.RoleOf(uid) == pet.RoleNone {
+			return errors.NewNotFoundError("member %v of Pet{Id: %v}", uid, petId)
+		}
+		if role != pet.RoleOwner && p.IsLastOwner(uid) {
+			return errors.NewFailedPreconditionError("%v is the last owner of Pet{Id: %v}", uid, petId)
+		}
+		if role == pet.RoleOwner {
+			return uow.MakeOwner(tx, p, uid)
+		}
+		return tx.AddMember(petId, uid, role)
+	})
+	if err != nil {
+		return nil, errors.GrpcError(err)
+	}
+	p, err := s.petStore.Get(ctx, petId)
+	if err != nil {
+		return nil, errors.GrpcError(err)
+	}
+	return p, nil
+}
+
+// TransferOwnership hands the pet of the caller to another member, and the
+// caller stays on as a caregiver. protonyom does not define the RPC yet.
+func (s *PetServer) TransferOwnership(ctx context.Context, petId, toUid string) (*pet.Pet, error) {
+	if _, err := s.authorizer.Pet(ctx, petId, pet.RoleOwner); err != nil {
+		return nil, errors.GrpcError(err)
+	}
+	uid, _ := authz.Uid(ctx)
+	if toUid == uid {
+		return nil, errors.GrpcError(errors.NewInvalidParamError("transfer of Pet{Id: %v} to its owner", petId))
+	}
+	err := s.unitOfWork.Run(ctx, func(ctx context.Context, tx uow.Tx) error {
+		p, err := tx.GetPet(petId)
+		if err != nil {
+			return err
+		}
+		if p.RoleOf(uid) < pet.RoleOwner {
+			return errors.NewPermissionDeniedError("user %v on Pet{Id: %v}", uid, petId)
+		}
+		if p.RoleOf(toUid) == pet.RoleNone {
+			return errors.NewNotFoundError("member %v of Pet{Id: %v}", toUid, petId)
+		}
+		if err := uow.MakeOwner(tx, p, toUid); err != nil {
+			return err
+		}
+		return tx.AddMember(petId, uid, pet.RoleCaregiver)
+	})
+	if err != nil {
+		return nil, errors.GrpcError(err)
+	}
+	p, err := s.petStore.Get(ctx, petId)
+	if err != nil {
+		return nil, errors.GrpcError(err)
+	}
+	return p, nil
+}
+
+// RemoveFeeder lets an owner remove another member, a former partner or a pet
+// sitter, from the pet. The pet and the account of the member change together.
+// The last owner is never removed. protonyom does not define the RPC yet.
+func (s *PetServer) RemoveFeeder(ctx context.Context, petId, uid string) (*pet.Pet, error) {
+	if uid == "" {
+		return nil, errors.GrpcError(errors.NewInvalidParamError("uid: %v", uid))
+	}
+	if _, err := s.authorizer.Pet(ctx, petId, pet.RoleOwner); err != nil {
+		return nil, errors.GrpcError(err)
+	}
+	actor, _ := authz.Uid(ctx)
+	err := s.unitOfWork.Run(ctx, func(ctx context.Context, tx uow.Tx) error {
+		p, err := tx.GetPet(petId)
+		if err != nil {
+			return err
+		}
+		if p.RoleOf(actor) < pet.RoleOwner {
+			return errors.NewPermissionDeniedError("user %v on Pet{Id: %v}", actor, petId)
+		}
+		if !p.IsMember(uid) {
+			return errors.NewNotFoundError("member %v of Pet{Id: %v}", uid, petId)
+		}
+		if p.IsLastOwner(uid) {
+			return errors.NewFailedPreconditionError("%v is the last owner of Pet{Id: %v}", uid, petId)
+		}
+		return uow.Leave(tx, uid, petId)
+	})
+	if err != nil {
+		return nil, errors.GrpcError(err)
+	}
+	p, err := s.petStore.Get(ctx, petId)
+	if err != nil {
+		return nil, errors.GrpcError(err)
+	}
+	return p, nil
+}
+
 func (s *PetServer) GetPetList(ctx context.Context, request *gonyom.GetPetListRequest) (*gonyom.GetPetListReply, error) {
+	if householdId := metadataValue(ctx, householdKey); householdId != "" {
+		return s.getPetListOfHousehold(ctx, householdId)
+	}
 	petIds := request.GetPetIds()
 
 	pets, err := s.petStore.GetList(ctx, petIds)
 	if err != nil {
 		return nil, errors.GrpcError(err)
 	}
+	pets, err = s.authorizer.Pets(ctx, pets)
+	if err != nil {
+		return nil, errors.GrpcError(err)
+	}
 
 	return &gonyom.GetPetListReply{
 		Pets: pets.ToProto(),
 	}, nil
 }
 
+func (s *PetServer) getPetListOfHousehold(ctx context.Context, householdId string) (*gonyom.GetPetListReply, error) {
+	if _, err := s.authorizer.Household(ctx, householdId); err != nil {
+		return nil, errors.GrpcError(err)
+	}
+	pets, err := s.petStore.GetListOfHousehold(ctx, householdId)
+	if err != nil {
+		return nil, errors.GrpcError(err)
+	}
+
+	return &gonyom.GetPetListReply{
+		Pets: pets.ToProto(),
+	}, nil
+}
+
+func metadataValue(ctx context.Context, key string) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get(key)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+// GetPet returns the pet. protonyom's Pet has no field for the grants of pet
+// sitters, so they are sent in the grantsKey response header.
 func (s *PetServer) GetPet(ctx context.Context, request *gonyom.GetPetRequest) (*gonyom.GetPetReply, error) {
 	petId := request.GetPetId()
-	p, err := s.petStore.Get(ctx, petId)
+	p, err := s.authorizer.Pet(ctx, petId, pet.RoleViewer)
 	if err != nil {
 		return nil, errors.GrpcError(err)
 	}
+	if err := sendGrants(ctx, p.Grants); err != nil {
+		return nil, errors.GrpcError(err)
+	}
 
 	return &gonyom.GetPetReply{
 		Pet: p.ToProto(),
 	}, nil
 }
 
+// sendGrants sets one grantsKey header value per grant, "<uid> <start> <end>"
+// with the times in epoch seconds like the Adopted of a pet.
+func sendGrants(ctx context.Context, grants map[string]*pet.Grant) error {
+	if len(grants) == 0 {
+		return nil
+	}
+	uids := make([]string, 0, len(grants))
+	for uid := range grants {
+		uids = append(uids, uid)
+	}
+	sort.Strings(uids)
+	md := metadata.MD{}
+	for _, uid := range uids {
+		g := grants[uid]
+		md.Append(grantsKey, fmt.Sprintf("%v %v %v", uid, g.Start.Unix(), g.End.Unix()))
+	}
+	if err := grpc.SetHeader(ctx, md); err != nil {
+		return errors.NewInternalError("%v", err)
+	}
+	return nil
+}
+
 func (s *PetServer) uploadStorage(ctx context.Context, path string, content []byte, contentType string) (string, error) {
 	mediaLink, err := s.storage.Upload(ctx, &storage.Object{
 		Root:        pet.StorageRoot,
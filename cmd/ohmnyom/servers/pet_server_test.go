@@ -0,0 +1,243 @@
+package servers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aiceru/protonyom/gonyom"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"ohmnyom/domain/audit"
+	"ohmnyom/domain/deletion"
+	"ohmnyom/domain/pet"
+	"ohmnyom/domain/uow"
+	"ohmnyom/domain/user"
+	"ohmnyom/internal/auditlog"
+	"ohmnyom/internal/authz"
+)
+
+func newTestPetServer(stores *testStores) *PetServer {
+	return NewPetServer(auditlog.PetStore(stores.pets, stores.audits), stores.users, auditlog.UnitOfWork(stores.uow),
+		stores.storage, stores.deleter, authz.New(stores.pets, stores.households))
+}
+
+// addTestMember makes uid a member of pet1 with role.
+func addTestMember(t *testing.T, stores *testStores, uid string, role pet.Role) {
+	ctx := context.TODO()
+	if err := stores.users.Put(ctx, &user.User{Id: uid, Name: "name-" + uid}); err != nil {
+		t.Fatal(err)
+	}
+	if err := stores.users.AddPet(ctx, uid, "pet1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := stores.pets.AddMember(ctx, "pet1", uid, role); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPetServer_Roles(t *testing.T) {
+	stores := newTestStores(t)
+	s := newTestPetServer(stores)
+	addTestMember(t, stores, "viewer", pet.RoleViewer)
+
+	_, err := s.GetPet(ctxOf("viewer"), &gonyom.GetPetRequest{PetId: "pet1"})
+	assert.NoError(t, err)
+	_, err = s.GetPet(ctxOf("stranger"), &gonyom.GetPetRequest{PetId: "pet1"})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	rename := &gonyom.UpdatePetRequest{Pet: &gonyom.Pet{Id: "pet1", Name: "ohm"}}
+	_, err = s.UpdatePet(ctxOf("feeder"), rename)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	_, err = s.UpdatePet(ctxOf("owner"), rename)
+	assert.NoError(t, err)
+
+	_, err = s.SetMemberRole(ctxOf("feeder"), "pet1", "viewer", pet.RoleCaregiver)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	_, err = s.SetMemberRole(ctxOf("owner"), "pet1", "stranger", pet.RoleCaregiver)
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	_, err = s.SetMemberRole(ctxOf("owner"), "pet1", "owner", pet.RoleViewer)
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err), "the last owner steps down")
+	p, err := s.SetMemberRole(ctxOf("owner"), "pet1", "viewer", pet.RoleCaregiver)
+	assert.NoError(t, err)
+	assert.Equal(t, pet.RoleCaregiver, p.RoleOf("viewer"))
+	// the owner of a legacy pet keeps owning it
+	assert.Equal(t, pet.RoleOwner, p.RoleOf("owner"))
+}
+
+func TestPetServer_TransferOwnership(t *testing.T) {
+	stores := newTestStores(t)
+	s := newTestPetServer(stores)
+
+	_, err := s.TransferOwnership(ctxOf("feeder"), "pet1", "feeder")
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	_, err = s.TransferOwnership(ctxOf("owner"), "pet1", "stranger")
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	p, err := s.TransferOwnership(ctxOf("owner"), "pet1", "feeder")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"feeder"}, p.Owners())
+	assert.Equal(t, pet.RoleCaregiver, p.RoleOf("owner"))
+
+	_, err = s.UpdatePet(ctxOf("owner"), &gonyom.UpdatePetRequest{Pet: &gonyom.Pet{Id: "pet1", Name: "ohm"}})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+}
+
+func TestPetServer_PromoteSitter(t *testing.T) {
+	now := time.Now()
+	newStores := func(t *testing.T) *testStores {
+		stores := newTestStores(t)
+		addTestMember(t, stores, "sitter", pet.RoleCaregiver)
+		err := stores.uow.Run(context.TODO(), func(ctx context.Context, tx uow.Tx) error {
+			return tx.SetGrants("pet1", map[string]*pet.Grant{
+				"sitter": {Start: now.Add(-time.Minute), End: now.Add(time.Hour), GrantedBy: "owner"},
+			})
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return stores
+	}
+	// the sitter owns the pet after the grant would have ended
+	assertOwner := func(t *testing.T, p *pet.Pet) {
+		assert.NotContains(t, p.Grants, "sitter")
+		assert.Equal(t, pet.RoleOwner, p.RoleAt("sitter", now.Add(2*time.Hour)))
+	}
+
+	t.Run("SetMemberRole", func(t *testing.T) {
+		stores := newStores(t)
+		p, err := newTestPetServer(stores).SetMemberRole(ctxOf("owner"), "pet1", "sitter", pet.RoleOwner)
+		assert.NoError(t, err)
+		assertOwner(t, p)
+	})
+	t.Run("TransferOwnership", func(t *testing.T) {
+		stores := newStores(t)
+		p, err := newTestPetServer(stores).TransferOwnership(ctxOf("owner"), "pet1", "sitter")
+		assert.NoError(t, err)
+		assertOwner(t, p)
+		assert.Equal(t, []string{"sitter"}, p.Owners())
+	})
+	t.Run("DeletePet", func(t *testing.T) {
+		stores := newStores(t)
+		s := newTestPetServer(stores)
+		_, err := s.RemoveFeeder(ctxOf("owner"), "pet1", "feeder")
+		assert.NoError(t, err)
+		_, err = s.DeletePet(ctxOf("owner"), &gonyom.DeletePetRequest{PetId: "pet1"})
+		assert.NoError(t, err)
+		p, err := stores.pets.Get(context.TODO(), "pet1")
+		assert.NoError(t, err)
+		assertOwner(t, p)
+	})
+}
+
+func TestPetServer_GetPetList(t *testing.T) {
+	stores := newTestStores(t)
+	s := newTestPetServer(stores)
+	ctx := context.TODO()
+	if err := stores.pets.Put(ctx, &pet.Pet{Id: "pet2", Feeders: []string{"sitter"}}); err != nil {
+		t.Fatal(err)
+	}
+	// the grant has ended, but the sweep has not removed the sitter yet
+	addTestMember(t, stores, "sitter", pet.RoleCaregiver)
+	err := stores.uow.Run(ctx, func(ctx context.Context, tx uow.Tx) error {
+		return tx.SetGrants("pet1", map[string]*pet.Grant{
+			"sitter": {Start: time.Now().Add(-2 * time.Hour), End: time.Now().Add(-time.Hour), GrantedBy: "owner"},
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reply, err := s.GetPetList(ctxOf("sitter"), &gonyom.GetPetListRequest{PetIds: []string{"pet1", "pet2"}})
+	assert.NoError(t, err)
+	if assert.Len(t, reply.Pets, 1) {
+		assert.Equal(t, "pet2", reply.Pets[0].Id)
+	}
+	reply, err = s.GetPetList(ctxOf("owner"), &gonyom.GetPetListRequest{PetIds: []string{"pet1", "pet2"}})
+	assert.NoError(t, err)
+	if assert.Len(t, reply.Pets, 1) {
+		assert.Equal(t, "pet1", reply.Pets[0].Id)
+	}
+}
+
+func TestPetServer_DeletePet(t *testing.T) {
+	stores := newTestStores(t)
+	s := newTestPetServer(stores)
+	ctx := context.TODO()
+	addTestMember(t, stores, "viewer", pet.RoleViewer)
+
+	// a caregiver only leaves
+	_, err := s.DeletePet(ctxOf("feeder"), &gonyom.DeletePetRequest{PetId: "pet1"})
+	assert.NoError(t, err)
+	p, err := stores.pets.Get(ctx, "pet1")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"owner", "viewer"}, p.Feeders)
+
+	// the last owner hands the pet to the successor
+	_, err = s.DeletePet(ctxOf("owner"), &gonyom.DeletePetRequest{PetId: "pet1"})
+	assert.NoError(t, err)
+	p, err = stores.pets.Get(ctx, "pet1")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"viewer"}, p.Feeders)
+	assert.Equal(t, []string{"viewer"}, p.Owners())
+	u, err := stores.users.Get(ctx, "owner")
+	assert.NoError(t, err)
+	assert.False(t, u.HasPet("pet1"))
+
+	// the last member deletes it
+	_, err = s.DeletePet(ctxOf("viewer"), &gonyom.DeletePetRequest{PetId: "pet1"})
+	assert.NoError(t, err)
+	_, err = stores.pets.Get(ctx, "pet1")
+	assert.Error(t, err)
+	u, err = stores.users.Get(ctx, "viewer")
+	assert.NoError(t, err)
+	assert.False(t, u.HasPet("pet1"))
+	stores.deleter.Wait()
+	job, err := stores.deletions.Get(ctx, deletion.NewPetJob("pet1", p.StorageDir()).Id)
+	assert.NoError(t, err)
+	assert.True(t, job.Done)
+}
+
+func TestPetServer_RemoveFeeder(t *testing.T) {
+	stores := newTestStores(t)
+	s := newTestPetServer(stores)
+	ctx := context.TODO()
+	addTestMember(t, stores, "viewer", pet.RoleViewer)
+
+	_, err := s.RemoveFeeder(ctxOf("feeder"), "pet1", "viewer")
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	_, err = s.RemoveFeeder(ctxOf("owner"), "pet1", "stranger")
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	_, err = s.RemoveFeeder(ctxOf("owner"), "pet1", "owner")
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err), "the last owner")
+
+	stream := &testStream{method: "/protonyom.PetApi/RemoveFeeder"}
+	p, err := s.RemoveFeeder(grpc.NewContextWithServerTransportStream(ctxOf("owner"), stream), "pet1", "feeder")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"owner", "viewer"}, p.Feeders)
+	u, err := stores.users.Get(ctx, "feeder")
+	assert.NoError(t, err)
+	assert.False(t, u.HasPet("pet1"))
+
+	events, err := stores.audits.GetListOfPet(ctx, "pet1", audit.Cursor{}, 10)
+	assert.NoError(t, err)
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, "owner", events[0].Actor)
+		assert.Equal(t, stream.method, events[0].Method)
+		assert.Contains(t, events[0].Before, `"feeder"`)
+		assert.NotContains(t, events[0].After, `"feeder"`)
+	}
+	events, err = stores.audits.GetListOfUser(ctx, "feeder", audit.Cursor{}, 10)
+	assert.NoError(t, err)
+	assert.Len(t, events, 1)
+
+	// a co-owner may be removed, but not the one left
+	_, err = s.SetMemberRole(ctxOf("owner"), "pet1", "viewer", pet.RoleOwner)
+	assert.NoError(t, err)
+	_, err = s.RemoveFeeder(ctxOf("viewer"), "pet1", "owner")
+	assert.NoError(t, err)
+	_, err = s.RemoveFeeder(ctxOf("viewer"), "pet1", "viewer")
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+}
@@ -0,0 +1,130 @@
+package servers
+
+import (
+	"context"
+	"time"
+
+	"ohmnyom/domain/feed"
+	"ohmnyom/domain/pet"
+	"ohmnyom/domain/schedule"
+	"ohmnyom/internal/authz"
+	"ohmnyom/internal/errors"
+)
+
+// MaxStatusRange limits how many days GetScheduleStatus reports at once.
+const MaxStatusRange = 31 * 24 * time.Hour
+
+// ScheduleServer manages the feeding schedules of pets. protonyom does not
+// define a ScheduleApi yet, so the server is not registered.
+type ScheduleServer struct {
+	scheduleStore schedule.Store
+	feedStore     feed.Store
+	authorizer    *authz.Authorizer
+}
+
+func NewScheduleServer(store schedule.Store, feedStore feed.Store, authorizer *authz.Authorizer) *ScheduleServer {
+	return &ScheduleServer{
+		scheduleStore: store,
+		feedStore:     feedStore,
+		authorizer:    authorizer,
+	}
+}
+
+func (s *ScheduleServer) AddSchedule(ctx context.Context, in *schedule.Schedule) (*schedule.Schedule, error) {
+	if in == nil {
+		return nil, errors.GrpcError(errors.NewInvalidParamError("schedule: %v", in))
+	}
+	if _, err := s.authorizer.Pet(ctx, in.PetId, pet.RoleOwner); err != nil {
+		return nil, errors.GrpcError(err)
+	}
+	uid, _ := authz.Uid(ctx)
+
+	newSchedule := *in
+	newSchedule.Id = schedule.NewScheduleId()
+	newSchedule.CreatedBy = uid
+	newSchedule.Created = time.Now().UTC()
+	if err := newSchedule.Validate(); err != nil {
+		return nil, errors.GrpcError(err)
+	}
+	if err := s.scheduleStore.Put(ctx, &newSchedule); err != nil {
+		return nil, errors.GrpcError(err)
+	}
+	return &newSchedule, nil
+}
+
+func (s *ScheduleServer) GetSchedules(ctx context.Context, petId string) ([]*schedule.Schedule, error) {
+	if _, err := s.authorizer.Pet(ctx, petId, pet.RoleViewer); err != nil {
+		return nil, errors.GrpcError(err)
+	}
+	schedules, err := s.scheduleStore.GetListOfPet(ctx, petId)
+	if err != nil {
+		return nil, errors.GrpcError(err)
+	}
+	return schedules, nil
+}
+
+// UpdateSchedule replaces the slots, weekdays, time zone, name and tolerance of
+// a schedule.
+func (s *ScheduleServer) UpdateSchedule(ctx context.Context, in *schedule.Schedule) (*schedule.Schedule, error) {
+	if in == nil {
+		return nil, errors.GrpcError(errors.NewInvalidParamError("schedule: %v", in))
+	}
+	if _, err := s.authorizer.Pet(ctx, in.PetId, pet.RoleOwner); err != nil {
+		return nil, errors.GrpcError(err)
+	}
+	stored, err := s.scheduleStore.Get(ctx, in.PetId, in.Id)
+	if err != nil {
+		return nil, errors.GrpcError(err)
+	}
+
+	updated := *in
+	updated.CreatedBy = stored.CreatedBy
+	updated.Created = stored.Created
+	if err := updated.Validate(); err != nil {
+		return nil, errors.GrpcError(err)
+	}
+	if err := s.scheduleStore.Update(ctx, &updated); err != nil {
+		return nil, errors.GrpcError(err)
+	}
+	return &updated, nil
+}
+
+func (s *ScheduleServer) DeleteSchedule(ctx context.Context, petId, id string) error {
+	if _, err := s.authorizer.Pet(ctx, petId, pet.RoleOwner); err != nil {
+		return errors.GrpcError(err)
+	}
+	if _, err := s.scheduleStore.Get(ctx, petId, id); err != nil {
+		return errors.GrpcError(err)
+	}
+	if err := s.scheduleStore.Delete(ctx, petId, id); err != nil {
+		return errors.GrpcError(err)
+	}
+	return nil
+}
+
+// GetScheduleStatus reports each slot of the schedule in [from, to) as fed,
+// missed or upcoming.
+func (s *ScheduleServer) GetScheduleStatus(ctx context.Context, petId, id string, from, to time.Time) ([]*schedule.SlotStatus, error) {
+	if !from.Before(to) || to.Sub(from) > MaxStatusRange {
+		return nil, errors.GrpcError(errors.NewInvalidParamError("from: %v, to: %v", from, to))
+	}
+	if _, err := s.authorizer.Pet(ctx, petId, pet.RoleViewer); err != nil {
+		return nil, errors.GrpcError(err)
+	}
+	sc, err := s.scheduleStore.Get(ctx, petId, id)
+	if err != nil {
+		return nil, errors.GrpcError(err)
+	}
+
+	// feeds just outside the range may still count for its first or last slot
+	tolerance := sc.Tolerance()
+	feeds, err := s.feedStore.GetFeedsOfPetInRange(ctx, petId, from.Add(-tolerance), to.Add(tolerance))
+	if err != nil {
+		return nil, errors.GrpcError(err)
+	}
+	statuses, err := sc.Check(feeds, from, to, time.Now())
+	if err != nil {
+		return nil, errors.GrpcError(err)
+	}
+	return statuses, nil
+}
@@ -0,0 +1,59 @@
+package servers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"ohmnyom/domain/feed"
+	"ohmnyom/domain/schedule"
+	"ohmnyom/internal/authz"
+)
+
+func TestScheduleServer(t *testing.T) {
+	stores := newTestStores(t)
+	s := NewScheduleServer(stores.schedules, stores.feeds, authz.New(stores.pets, stores.households))
+	in := &schedule.Schedule{PetId: "pet1", TimeZone: "UTC", Slots: []schedule.Slot{{Hour: 8}, {Hour: 19}}}
+
+	_, err := s.AddSchedule(ctxOf("feeder"), in)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	added, err := s.AddSchedule(ctxOf("owner"), in)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, added.Id)
+	assert.Equal(t, "owner", added.CreatedBy)
+
+	_, err = s.GetSchedules(ctxOf("stranger"), "pet1")
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	list, err := s.GetSchedules(ctxOf("feeder"), "pet1")
+	assert.NoError(t, err)
+	assert.Equal(t, []*schedule.Schedule{added}, list)
+
+	update := *added
+	update.Slots = []schedule.Slot{{Hour: 9}}
+	update.CreatedBy = "feeder"
+	updated, err := s.UpdateSchedule(ctxOf("owner"), &update)
+	assert.NoError(t, err)
+	assert.Equal(t, "owner", updated.CreatedBy)
+	assert.Equal(t, []schedule.Slot{{Hour: 9}}, updated.Slots)
+
+	day := time.Date(2022, 3, 1, 0, 0, 0, 0, time.UTC)
+	f := &feed.Feed{Id: "feed1", PetId: "pet1", FeederId: "feeder", Timestamp: day.Add(9*time.Hour + 5*time.Minute)}
+	if err := stores.feeds.Put(context.TODO(), f); err != nil {
+		t.Fatal(err)
+	}
+	statuses, err := s.GetScheduleStatus(ctxOf("feeder"), "pet1", added.Id, day, day.AddDate(0, 0, 2))
+	assert.NoError(t, err)
+	if assert.Len(t, statuses, 2) {
+		assert.Equal(t, schedule.StatusFed, statuses[0].Status)
+		assert.Equal(t, schedule.StatusMissed, statuses[1].Status)
+	}
+	_, err = s.GetScheduleStatus(ctxOf("feeder"), "pet1", added.Id, day, day.AddDate(1, 0, 0))
+	assert.Error(t, err)
+
+	assert.NoError(t, s.DeleteSchedule(ctxOf("owner"), "pet1", added.Id))
+	err = s.DeleteSchedule(ctxOf("owner"), "pet1", added.Id)
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
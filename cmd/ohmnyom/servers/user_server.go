@@ -2,30 +2,97 @@ package servers
 
 import (
 	"context"
+	"fmt"
+	"time"
 
 	"github.com/aiceru/protonyom/gonyom"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"ohmnyom/domain/deletion"
+	"ohmnyom/domain/device"
+	"ohmnyom/domain/household"
+	"ohmnyom/domain/invite"
 	"ohmnyom/domain/pet"
+	"ohmnyom/domain/token"
+	"ohmnyom/domain/uow"
 	"ohmnyom/domain/user"
+	"ohmnyom/internal/authz"
+	"ohmnyom/internal/cascade"
 	"ohmnyom/internal/errors"
 	"ohmnyom/internal/jwt"
+	"ohmnyom/internal/oauth"
+	"ohmnyom/internal/revocation"
 	"ohmnyom/internal/storage"
 )
 
+const (
+	refreshTokenKey = "user-refresh"
+	oauthTokenKey   = "oauth-token"
+
+	// inviteActionKey is the request metadata that makes AcceptInvite run one
+	// of the invite RPCs protonyom does not define yet, inviteCreate, inviteList
+	// or inviteRevoke, on the petId field of the request instead.
+	inviteActionKey = "invite-action"
+	// inviteOptionsKey is the request metadata of inviteCreate, "<maxUses> <ttl>"
+	// with the ttl in seconds. Zero values take the defaults.
+	inviteOptionsKey = "invite-options"
+	// inviteKey is the response header of inviteCreate and inviteList, one value
+	// per invite, "<code> <uses> <maxUses> <expires>" with expires in epoch
+	// seconds.
+	inviteKey = "pet-invite"
+
+	inviteCreate = "create"
+	inviteList   = "list"
+	inviteRevoke = "revoke"
+
+	// registerDevicePath and unregisterDevicePath are the Update paths that
+	// register and unregister the device whose push token is the value, since
+	// protonyom defines no device RPCs yet. devicePlatformKey is the request
+	// metadata carrying the platform of the device registered.
+	registerDevicePath   = "register-device"
+	unregisterDevicePath = "unregister-device"
+	devicePlatformKey    = "device-platform"
+)
+
 type UserServer struct {
-	userStore  user.Store
-	petStore   pet.Store
-	storage    storage.Storage
-	jwtManager *jwt.Manager
+	userStore      user.Store
+	petStore       pet.Store
+	inviteStore    invite.Store
+	householdStore household.Store
+	tokenStore     token.Store
+	deviceStore    device.Store
+	unitOfWork     uow.UnitOfWork
+	storage        storage.Storage
+	deleter        *cascade.Deleter
+	jwtManager     *jwt.Manager
+	checker        *revocation.Checker
+	authorizer     *authz.Authorizer
+	verifiers      map[string]oauth.Verifier
+	// refreshTokens makes SignIn and SignUp start token families. It stays off
+	// while RefreshToken is not served, since nothing could redeem them.
+	refreshTokens bool
 	gonyom.UnimplementedSignApiServer
 	gonyom.UnimplementedAccountApiServer
 }
 
-func NewUserServer(store user.Store, petStore pet.Store, storage storage.Storage, jwtManager *jwt.Manager) *UserServer {
+func NewUserServer(store user.Store, petStore pet.Store, inviteStore invite.Store, householdStore household.Store,
+	tokenStore token.Store, deviceStore device.Store, unitOfWork uow.UnitOfWork, storage storage.Storage, deleter *cascade.Deleter, jwtManager *jwt.Manager,
+	checker *revocation.Checker, authorizer *authz.Authorizer, verifiers map[string]oauth.Verifier, refreshTokens bool) *UserServer {
 	return &UserServer{
-		userStore:  store,
-		petStore:   petStore,
-		storage:    storage,
-		jwtManager: jwtManager,
+		userStore:      store,
+		petStore:       petStore,
+		inviteStore:    inviteStore,
+		householdStore: householdStore,
+		tokenStore:     tokenStore,
+		deviceStore:    deviceStore,
+		unitOfWork:     unitOfWork,
+		storage:        storage,
+		deleter:        deleter,
+		jwtManager:     jwtManager,
+		checker:        checker,
+		authorizer:     authorizer,
+		verifiers:      verifiers,
+		refreshTokens:  refreshTokens,
 	}
 }
 
@@ -63,11 +130,17 @@ func (s *UserServer) signUpWithEmail(
 	return u, nil
 }
 
+// signUpWithOAuthInfo signs up with the verified email of the OAuth account,
+// not one the client claims.
 func (s *UserServer) signUpWithOAuthInfo(
-	ctx context.Context, name, email string, info *user.OAuthInfo, provider, photourl string) (
+	ctx context.Context, name string, info *user.OAuthInfo, provider, photourl string) (
 	*user.User, error) {
-	if name == "" || email == "" || info == nil {
-		return nil, errors.NewInvalidParamError("name [%v], email [%v], info [%v]")
+	if name == "" || info == nil {
+		return nil, errors.NewInvalidParamError("name [%v], info [%v]", name, info)
+	}
+	email := info.Email
+	if email == "" {
+		return nil, errors.NewFailedPreconditionError("%v account %v has no verified email", provider, info.Id)
 	}
 
 	_, err := s.userStore.GetByEmail(ctx, email)
@@ -128,6 +201,22 @@ func (s *UserServer) signInWithOAuthInfo(ctx context.Context, info *user.OAuthIn
 	return u, nil
 }
 
+// verifyOAuth verifies the token the provider issued to the client, sent in
+// the oauthTokenKey metadata: an ID token for google, an access token for kakao.
+// The OAuthInfo in the request is not trusted.
+func (s *UserServer) verifyOAuth(ctx context.Context, provider string) (*user.OAuthInfo, error) {
+	verifier, ok := s.verifiers[provider]
+	if !ok {
+		return nil, errors.NewUnimplementedError("oauth provider %v", provider)
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get(oauthTokenKey)
+	if len(values) == 0 {
+		return nil, errors.NewAuthenticationError("oauth token is not provided")
+	}
+	return verifier.Verify(ctx, values[0])
+}
+
 func (s *UserServer) SignUp(ctx context.Context, in *gonyom.SignUpRequest) (*gonyom.SignReply, error) {
 	var u *user.User
 	var err error
@@ -141,7 +230,10 @@ func (s *UserServer) SignUp(ctx context.Context, in *gonyom.SignUpRequest) (*gon
 		u, err = s.signUpWithEmail(ctx, name, email, cred.Password, photourl)
 	case *gonyom.SignUpRequest_Oauthinfo:
 		provider := in.GetOauthprovider()
-		u, err = s.signUpWithOAuthInfo(ctx, name, email, user.OAuthInfoFromProto(cred.Oauthinfo), provider, photourl)
+		var info *user.OAuthInfo
+		if info, err = s.verifyOAuth(ctx, provider); err == nil {
+			u, err = s.signUpWithOAuthInfo(ctx, name, info, provider, photourl)
+		}
 	default:
 		err = errors.NewUnimplementedError("type %v", cred)
 	}
@@ -149,12 +241,12 @@ func (s *UserServer) SignUp(ctx context.Context, in *gonyom.SignUpRequest) (*gon
 		return nil, errors.GrpcError(err)
 	}
 
-	token, err := s.jwtManager.NewAuthToken(u.Id)
+	authToken, err := s.issueTokens(ctx, u.Id)
 	if err != nil {
 		return nil, errors.GrpcError(err)
 	}
 
-	return &gonyom.SignReply{Account: u.ToProto(), Token: token}, nil
+	return &gonyom.SignReply{Account: u.ToProto(), Token: authToken}, nil
 }
 
 func (s *UserServer) SignIn(ctx context.Context, in *gonyom.SignInRequest) (*gonyom.SignReply, error) {
@@ -166,7 +258,10 @@ func (s *UserServer) SignIn(ctx context.Context, in *gonyom.SignInRequest) (*gon
 		u, err = s.signInWithEmail(ctx, cred.Emailcred.Email, cred.Emailcred.Password)
 	case *gonyom.SignInRequest_Oauthinfo:
 		provider := in.GetOauthprovider()
-		u, err = s.signInWithOAuthInfo(ctx, user.OAuthInfoFromProto(cred.Oauthinfo), provider)
+		var info *user.OAuthInfo
+		if info, err = s.verifyOAuth(ctx, provider); err == nil {
+			u, err = s.signInWithOAuthInfo(ctx, info, provider)
+		}
 	default:
 		err = errors.NewUnimplementedError("type %v", cred)
 	}
@@ -174,14 +269,131 @@ func (s *UserServer) SignIn(ctx context.Context, in *gonyom.SignInRequest) (*gon
 		return nil, errors.GrpcError(err)
 	}
 
-	token, err := s.jwtManager.NewAuthToken(u.Id)
+	authToken, err := s.issueTokens(ctx, u.Id)
+	if err != nil {
+		return nil, errors.GrpcError(err)
+	}
+	return &gonyom.SignReply{Account: u.ToProto(), Token: authToken}, nil
+}
+
+// issueTokens returns a new auth token for uid. When refreshTokens is on, it
+// also starts a new token family and sends its first refresh token in the
+// refreshTokenKey response header.
+func (s *UserServer) issueTokens(ctx context.Context, uid string) (string, error) {
+	generation, err := s.checker.Generation(ctx, uid)
+	if err != nil {
+		return "", err
+	}
+	authToken, err := s.jwtManager.NewAuthToken(uid, generation)
+	if err != nil {
+		return "", err
+	}
+	if !s.refreshTokens {
+		return authToken, nil
+	}
+
+	id := jwt.NewTokenId()
+	family := token.NewFamily(uid, id, jwt.RefreshTokenTTL())
+	refreshToken, err := s.jwtManager.NewRefreshToken(uid, id, family.Id, generation)
+	if err != nil {
+		return "", err
+	}
+	if err := s.tokenStore.Put(ctx, family); err != nil {
+		return "", err
+	}
+	if err := sendRefreshToken(ctx, refreshToken); err != nil {
+		return "", err
+	}
+	return authToken, nil
+}
+
+func sendRefreshToken(ctx context.Context, refreshToken string) error {
+	if err := grpc.SetHeader(ctx, metadata.Pairs(refreshTokenKey, refreshToken)); err != nil {
+		return errors.NewInternalError("%v", err)
+	}
+	return nil
+}
+
+// RefreshToken exchanges the refresh token in the refreshTokenKey request
+// metadata for a new auth token, and rotates the refresh token. It is meant to
+// be served as SignApi/RefreshToken, which protonyom does not define yet. The
+// AuthInterceptor has to let the method through without an auth token, and
+// refreshTokens has to be turned on, once it is registered.
+func (s *UserServer) RefreshToken(ctx context.Context, in *gonyom.EmptyParams) (*gonyom.SignReply, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get(refreshTokenKey)
+	if len(values) == 0 {
+		return nil, errors.GrpcError(errors.NewAuthenticationError("refresh token is not provided"))
+	}
+	claims, err := s.jwtManager.VerifyRefresh(values[0])
+	if err != nil {
+		return nil, errors.GrpcError(err)
+	}
+	generation, err := s.checker.Generation(ctx, claims.Uid)
+	if err != nil {
+		return nil, errors.GrpcError(err)
+	}
+	if claims.Generation < generation {
+		return nil, errors.GrpcError(errors.NewAuthenticationError("token generation %v is signed out", claims.Generation))
+	}
+
+	next := jwt.NewTokenId()
+	expires := time.Now().Add(jwt.RefreshTokenTTL()).UTC()
+	if err := s.tokenStore.Rotate(ctx, claims.Family, claims.Id, next, expires); err != nil {
+		return nil, errors.GrpcError(err)
+	}
+
+	u, err := s.userStore.Get(ctx, claims.Uid)
+	if err != nil {
+		return nil, errors.GrpcError(err)
+	}
+	authToken, err := s.jwtManager.NewAuthToken(u.Id, generation)
+	if err != nil {
+		return nil, errors.GrpcError(err)
+	}
+	refreshToken, err := s.jwtManager.NewRefreshToken(u.Id, next, claims.Family, generation)
 	if err != nil {
 		return nil, errors.GrpcError(err)
 	}
-	return &gonyom.SignReply{Account: u.ToProto(), Token: token}, nil
+	if err := sendRefreshToken(ctx, refreshToken); err != nil {
+		return nil, errors.GrpcError(err)
+	}
+	return &gonyom.SignReply{Account: u.ToProto(), Token: authToken}, nil
 }
 
+// SignOut revokes the auth token of the call. If the refresh token is sent in
+// the refreshTokenKey metadata as well, its token family is revoked too.
 func (s *UserServer) SignOut(ctx context.Context, in *gonyom.EmptyParams) (*gonyom.EmptyParams, error) {
+	claims, ok := ctx.Value(jwt.CtxKeyClaims).(*jwt.Claims)
+	if !ok {
+		return nil, errors.GrpcError(errors.NewAuthenticationError("token not provided"))
+	}
+	if err := s.checker.Revoke(ctx, claims); err != nil {
+		return nil, errors.GrpcError(err)
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	if values := md.Get(refreshTokenKey); len(values) > 0 {
+		refresh, err := s.jwtManager.VerifyRefresh(values[0])
+		if err == nil && refresh.Uid == claims.Uid {
+			if err := s.tokenStore.Revoke(ctx, refresh.Family); err != nil {
+				return nil, errors.GrpcError(err)
+			}
+		}
+	}
+	return &gonyom.EmptyParams{}, nil
+}
+
+// SignOutEverywhere invalidates every auth and refresh token issued to the
+// caller so far. protonyom does not define the RPC yet.
+func (s *UserServer) SignOutEverywhere(ctx context.Context, in *gonyom.EmptyParams) (*gonyom.EmptyParams, error) {
+	uid, err := authz.Uid(ctx)
+	if err != nil {
+		return nil, errors.GrpcError(err)
+	}
+	if err := s.checker.RevokeAll(ctx, uid); err != nil {
+		return nil, errors.GrpcError(err)
+	}
 	return &gonyom.EmptyParams{}, nil
 }
 
@@ -197,6 +409,8 @@ func (s *UserServer) Get(ctx context.Context, request *gonyom.GetAccountRequest)
 	return &gonyom.GetAccountReply{Account: u.ToProto()}, nil
 }
 
+// Update sets the account field at the path of the request, or registers or
+// unregisters a device on registerDevicePath and unregisterDevicePath.
 func (s *UserServer) Update(ctx context.Context, request *gonyom.UpdateAccountRequest) (*gonyom.UpdateAccountReply, error) {
 	uid := ctx.Value(user.CtxKeyUid).(string)
 	if uid == "" {
@@ -209,16 +423,22 @@ func (s *UserServer) Update(ctx context.Context, request *gonyom.UpdateAccountRe
 	path := request.GetPath()
 	value := request.GetValue()
 
-	if path == "password" {
-		value, err = user.HashPassword(value)
-		if err != nil {
-			return nil, errors.GrpcError(err)
+	switch path {
+	case registerDevicePath:
+		err = s.RegisterDevice(ctx, value, metadataValue(ctx, devicePlatformKey))
+	case unregisterDevicePath:
+		err = s.UnregisterDevice(ctx, value)
+	default:
+		if path == "password" {
+			value, err = user.HashPassword(value)
+			if err != nil {
+				return nil, errors.GrpcError(err)
+			}
 		}
+		err = errors.GrpcError(s.userStore.Update(ctx, u, path, value))
 	}
-
-	err = s.userStore.Update(ctx, u, path, value)
 	if err != nil {
-		return nil, errors.GrpcError(err)
+		return nil, err
 	}
 
 	u, err = s.userStore.Get(ctx, uid)
@@ -229,31 +449,252 @@ func (s *UserServer) Update(ctx context.Context, request *gonyom.UpdateAccountRe
 	return &gonyom.UpdateAccountReply{Account: u.ToProto()}, nil
 }
 
+// AcceptInvite redeems the invite code carried in the petId field of the request
+// and makes the caller a caregiver of the invited pet, until the grant of the
+// invite ends if it has one. With the inviteActionKey request metadata it
+// creates, lists or revokes invites instead, see runInviteAction.
 func (s *UserServer) AcceptInvite(ctx context.Context, request *gonyom.AcceptInviteRequest) (*gonyom.AcceptInviteReply, error) {
 	uid := ctx.Value(user.CtxKeyUid).(string)
-	pid := request.GetPetId()
-	if uid == "" || pid == "" {
-		return nil, errors.GrpcError(errors.NewAuthenticationError("UID or PID not provided"))
+	code := request.GetPetId()
+	if uid == "" || code == "" {
+		return nil, errors.GrpcError(errors.NewAuthenticationError("UID or invite code not provided"))
+	}
+	if action := metadataValue(ctx, inviteActionKey); action != "" {
+		if err := s.runInviteAction(ctx, action, code); err != nil {
+			return nil, err
+		}
+		u, err := s.userStore.Get(ctx, uid)
+		if err != nil {
+			return nil, errors.GrpcError(err)
+		}
+		return &gonyom.AcceptInviteReply{Account: u.ToProto()}, nil
+	}
+	now := time.Now().UTC()
+	err := s.unitOfWork.Run(ctx, func(ctx context.Context, tx uow.Tx) error {
+		u, err := tx.GetUser(uid)
+		if err != nil {
+			return err
+		}
+		i, err := tx.GetInvite(code)
+		if err != nil {
+			return err
+		}
+		if i.HouseholdId != "" {
+			return errors.NewFailedPreconditionError("Invite{Code: %v} is to a household", code)
+		}
+		if u.HasPet(i.PetId) {
+			return errors.NewAlreadyExistsError("pet %v of user %v", i.PetId, uid)
+		}
+		p, err := tx.GetPet(i.PetId)
+		if err != nil {
+			return err
+		}
+		if err := i.CheckRedeemable(uid, now); err != nil {
+			return err
+		}
+
+		if err := tx.UseInvite(code, uid); err != nil {
+			return err
+		}
+		if err := uow.Join(tx, uid, i.PetId, pet.RoleCaregiver); err != nil {
+			return err
+		}
+		if i.Grant == nil {
+			return nil
+		}
+		grants := make(map[string]*pet.Grant, len(p.Grants)+1)
+		for member, g := range p.Grants {
+			grants[member] = g
+		}
+		grants[uid] = i.Grant
+		return tx.SetGrants(i.PetId, grants)
+	})
+	if err != nil {
+		return nil, errors.GrpcError(err)
 	}
-	_, err := s.userStore.Get(ctx, uid)
+
+	u, err := s.userStore.Get(ctx, uid)
+	if err != nil {
+		return nil, errors.GrpcError(err)
+	}
+	return &gonyom.AcceptInviteReply{Account: u.ToProto()}, nil
+}
+
+// runInviteAction runs the invite RPC named by action on arg, a petId for
+// inviteCreate and inviteList or an invite code for inviteRevoke, and sends the
+// invites it returns in the inviteKey response header.
+func (s *UserServer) runInviteAction(ctx context.Context, action, arg string) error {
+	var invites []*invite.Invite
+	switch action {
+	case inviteCreate:
+		maxUses, ttl, err := inviteOptions(ctx)
+		if err != nil {
+			return errors.GrpcError(err)
+		}
+		i, err := s.CreateInvite(ctx, arg, maxUses, ttl)
+		if err != nil {
+			return err
+		}
+		invites = []*invite.Invite{i}
+	case inviteList:
+		var err error
+		if invites, err = s.ListInvites(ctx, arg); err != nil {
+			return err
+		}
+	case inviteRevoke:
+		return s.RevokeInvite(ctx, arg)
+	default:
+		return errors.GrpcError(errors.NewInvalidParamError("%v [%v]", inviteActionKey, action))
+	}
+	if err := sendInvites(ctx, invites); err != nil {
+		return errors.GrpcError(err)
+	}
+	return nil
+}
+
+// inviteOptions parses the inviteOptionsKey request metadata, zero values when
+// it is not set.
+func inviteOptions(ctx context.Context) (int, time.Duration, error) {
+	options := metadataValue(ctx, inviteOptionsKey)
+	if options == "" {
+		return 0, 0, nil
+	}
+	var maxUses, ttl int
+	if n, err := fmt.Sscanf(options, "%d %d", &maxUses, &ttl); err != nil || n != 2 {
+		return 0, 0, errors.NewInvalidParamError("%v [%v]", inviteOptionsKey, options)
+	}
+	return maxUses, time.Duration(ttl) * time.Second, nil
+}
+
+func sendInvites(ctx context.Context, invites []*invite.Invite) error {
+	if len(invites) == 0 {
+		return nil
+	}
+	md := metadata.MD{}
+	for _, i := range invites {
+		md.Append(inviteKey, fmt.Sprintf("%v %v %v %v", i.Code, i.Uses, i.MaxUses, i.Expires.Unix()))
+	}
+	if err := grpc.SetHeader(ctx, md); err != nil {
+		return errors.NewInternalError("%v", err)
+	}
+	return nil
+}
+
+// CreateInvite lets a caregiver of petId invite up to maxUses users until ttl passes.
+// Zero values take invite.DefaultMaxUses and invite.DefaultTTL.
+func (s *UserServer) CreateInvite(ctx context.Context, petId string, maxUses int, ttl time.Duration) (*invite.Invite, error) {
+	if _, err := s.authorizer.Pet(ctx, petId, pet.RoleCaregiver); err != nil {
+		return nil, errors.GrpcError(err)
+	}
+	uid, _ := authz.Uid(ctx)
+	i, err := invite.New(petId, uid, maxUses, ttl)
 	if err != nil {
 		return nil, errors.GrpcError(err)
 	}
+	if err := s.inviteStore.Put(ctx, i); err != nil {
+		return nil, errors.GrpcError(err)
+	}
+	return i, nil
+}
 
-	err = s.userStore.AddPet(ctx, uid, pid)
+// CreateSitterInvite lets the owner of petId invite a pet sitter who takes
+// care of the pet from start until end only. The invite is used once, and
+// expires with the grant if that ends within invite.DefaultTTL. protonyom does
+// not define the RPC yet.
+func (s *UserServer) CreateSitterInvite(ctx context.Context, petId string, start, end time.Time) (*invite.Invite, error) {
+	if _, err := s.authorizer.Pet(ctx, petId, pet.RoleOwner); err != nil {
+		return nil, errors.GrpcError(err)
+	}
+	uid, _ := authz.Uid(ctx)
+	now := time.Now().UTC()
+	grant, err := pet.NewGrant(start, end, uid, now)
 	if err != nil {
 		return nil, errors.GrpcError(err)
 	}
-	err = s.petStore.AddFeeder(ctx, pid, uid)
+	ttl := invite.DefaultTTL
+	if untilEnd := end.Sub(now); untilEnd < ttl {
+		ttl = untilEnd
+	}
+	i, err := invite.New(petId, uid, 1, ttl)
 	if err != nil {
 		return nil, errors.GrpcError(err)
 	}
+	i.Grant = grant
+	if err := s.inviteStore.Put(ctx, i); err != nil {
+		return nil, errors.GrpcError(err)
+	}
+	return i, nil
+}
 
-	u, err := s.userStore.Get(ctx, uid)
+// ListInvites returns the invites of petId that can still be redeemed.
+func (s *UserServer) ListInvites(ctx context.Context, petId string) ([]*invite.Invite, error) {
+	if _, err := s.authorizer.Pet(ctx, petId, pet.RoleCaregiver); err != nil {
+		return nil, errors.GrpcError(err)
+	}
+	invites, err := s.inviteStore.GetListOfPet(ctx, petId)
 	if err != nil {
 		return nil, errors.GrpcError(err)
 	}
-	return &gonyom.AcceptInviteReply{Account: u.ToProto()}, nil
+	now := time.Now().UTC()
+	ret := make([]*invite.Invite, 0, len(invites))
+	for _, i := range invites {
+		if i.IsOutstanding(now) {
+			ret = append(ret, i)
+		}
+	}
+	return ret, nil
+}
+
+func (s *UserServer) RevokeInvite(ctx context.Context, code string) error {
+	i, err := s.inviteStore.Get(ctx, code)
+	if err != nil {
+		return errors.GrpcError(err)
+	}
+	if _, err := s.authorizer.Pet(ctx, i.PetId, pet.RoleCaregiver); err != nil {
+		return errors.GrpcError(err)
+	}
+	if err := s.inviteStore.Delete(ctx, code); err != nil {
+		return errors.GrpcError(err)
+	}
+	return nil
+}
+
+// RegisterDevice lets the caller receive push notifications on the device with
+// the token. A token registered by another user before moves to the caller.
+// protonyom does not define the RPC yet, so clients reach it through Update.
+func (s *UserServer) RegisterDevice(ctx context.Context, token, platform string) error {
+	uid, err := authz.Uid(ctx)
+	if err != nil {
+		return errors.GrpcError(err)
+	}
+	d, err := device.New(token, uid, platform)
+	if err != nil {
+		return errors.GrpcError(err)
+	}
+	if err := s.deviceStore.Put(ctx, d); err != nil {
+		return errors.GrpcError(err)
+	}
+	return nil
+}
+
+// UnregisterDevice stops push notifications to the device, for example on
+// sign out.
+func (s *UserServer) UnregisterDevice(ctx context.Context, token string) error {
+	uid, err := authz.Uid(ctx)
+	if err != nil {
+		return errors.GrpcError(err)
+	}
+	d, err := s.deviceStore.Get(ctx, token)
+	if err != nil {
+		return errors.GrpcError(err)
+	}
+	if d.Uid != uid {
+		return errors.GrpcError(errors.NewPermissionDeniedError("device of another user"))
+	}
+	if err := s.deviceStore.Delete(ctx, token); err != nil {
+		return errors.GrpcError(err)
+	}
+	return nil
 }
 
 func (s *UserServer) UploadProfile(ctx context.Context, request *gonyom.UploadProfileRequest) (*gonyom.UploadProfileResponse, error) {
@@ -297,35 +738,101 @@ func (s *UserServer) Delete(ctx context.Context, request *gonyom.DeleteAccountRe
 	if uid != request.GetId() {
 		return nil, errors.GrpcError(errors.New("cannot delete other user, %s / %s", uid, request.GetId()))
 	}
-	u, err := s.userStore.Get(ctx, uid)
-	if err != nil {
-		return nil, errors.GrpcError(err)
-	}
-
-	pets, err := s.petStore.GetList(ctx, u.Pets)
+	households, err := s.householdStore.GetListOfUser(ctx, uid)
 	if err != nil {
 		return nil, errors.GrpcError(err)
 	}
-	for _, p := range pets {
-		if err := s.petStore.DeleteFeeder(ctx, p.Id, u.Id); err != nil {
+	householdPets := make(map[string]pet.List, len(households))
+	for _, h := range households {
+		if householdPets[h.Id], err = s.petStore.GetListOfHousehold(ctx, h.Id); err != nil {
 			return nil, errors.GrpcError(err)
 		}
 	}
+	var jobs []*deletion.Job
+	err = s.unitOfWork.Run(ctx, func(ctx context.Context, tx uow.Tx) error {
+		jobs = nil
+		u, err := tx.GetUser(uid)
+		if err != nil {
+			return err
+		}
+		// households are read before anything is written
+		left := make([]*household.Household, 0, len(households))
+		leftPets := make(map[string]pet.List, len(households))
+		for _, h := range households {
+			read, err := tx.GetHousehold(h.Id)
+			var notfound *errors.NotFoundError
+			if errors.As(err, &notfound) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			if leftPets[h.Id], err = readPets(tx, householdPets[h.Id]); err != nil {
+				return err
+			}
+			left = append(left, read)
+		}
+		pets := make([]*pet.Pet, 0, len(u.Pets))
+		householdOf := make(map[string]*household.Household, len(left))
+		for _, h := range left {
+			householdOf[h.Id] = h
+		}
+		for _, petId := range u.Pets {
+			p, err := tx.GetPet(petId)
+			var notfound *errors.NotFoundError
+			if errors.As(err, &notfound) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			pets = append(pets, p)
+			if _, ok := householdOf[p.HouseholdId]; !ok && p.HouseholdId != "" {
+				if householdOf[p.HouseholdId], err = readHousehold(tx, p.HouseholdId); err != nil {
+					return err
+				}
+			}
+		}
 
-	check, err := s.petStore.GetList(ctx, u.Pets)
-	if err != nil {
-		return nil, errors.GrpcError(err)
-	}
-	for _, p := range check {
-		if len(p.Feeders) == 0 {
-			if err := s.petStore.Delete(ctx, p.Id); err != nil {
-				return nil, errors.GrpcError(err)
+		deleted := make(map[string]bool)
+		for _, p := range pets {
+			petDeleted, err := leavePet(tx, p, householdOf[p.HouseholdId], uid)
+			if err != nil {
+				return err
+			}
+			if petDeleted {
+				deleted[p.Id] = true
+				jobs = append(jobs, deletion.NewPetJob(p.Id, p.StorageDir()))
+			}
+		}
+		for _, h := range left {
+			kept := make([]*pet.Pet, 0, len(leftPets[h.Id]))
+			for _, p := range leftPets[h.Id] {
+				if !deleted[p.Id] {
+					kept = append(kept, p)
+				}
+			}
+			if err := uow.LeaveHousehold(tx, h, uid, kept); err != nil {
+				return err
 			}
 		}
+		if err := tx.DeleteUser(uid); err != nil {
+			return err
+		}
+		jobs = append(jobs, deletion.NewUserJob(uid, u.StorageDir()))
+		for _, job := range jobs {
+			if err := tx.SaveDeletionJob(job); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.GrpcError(err)
 	}
 
-	if err := s.userStore.Delete(ctx, u.Id); err != nil {
-		return nil, errors.GrpcError(err)
+	for _, job := range jobs {
+		s.deleter.Start(job)
 	}
 
 	return &gonyom.DeleteAccountReply{}, nil
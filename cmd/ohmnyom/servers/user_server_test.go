@@ -0,0 +1,472 @@
+package servers
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aiceru/protonyom/gonyom"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"ohmnyom/domain/audit"
+	"ohmnyom/domain/deletion"
+	"ohmnyom/domain/device"
+	"ohmnyom/domain/feed"
+	"ohmnyom/domain/invite"
+	"ohmnyom/domain/pet"
+	"ohmnyom/domain/user"
+	"ohmnyom/internal/auditlog"
+	"ohmnyom/internal/authz"
+	"ohmnyom/internal/errors"
+	"ohmnyom/internal/jwt"
+	"ohmnyom/internal/memstore"
+	"ohmnyom/internal/oauth"
+	"ohmnyom/internal/revocation"
+	"ohmnyom/internal/storage"
+)
+
+func newTestUserServer(stores *testStores) *UserServer {
+	return newTestUserServerRefreshing(stores, false)
+}
+
+// newTestUserServerRefreshing returns a UserServer that issues refresh tokens
+// if refreshTokens, as once SignApi/RefreshToken is served.
+func newTestUserServerRefreshing(stores *testStores, refreshTokens bool) *UserServer {
+	tokenStore := memstore.NewTokenStore()
+	return NewUserServer(auditlog.UserStore(stores.users, stores.audits), stores.pets, stores.invites, stores.households,
+		tokenStore, stores.devices, auditlog.UnitOfWork(stores.uow), stores.storage, stores.deleter,
+		jwt.NewManager([]byte("test-secret")), revocation.NewChecker(tokenStore, time.Minute), authz.New(stores.pets, stores.households),
+		map[string]oauth.Verifier{user.OAuthProviderGoogle: fakeVerifier{}}, refreshTokens)
+}
+
+func TestUserServer_Invite(t *testing.T) {
+	stores := newTestStores(t)
+	s := newTestUserServer(stores)
+
+	_, err := s.CreateInvite(ctxOf("stranger"), "pet1", 1, time.Hour)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	_, err = s.CreateInvite(ctxOf("owner"), "pet1", 1, 30*24*time.Hour)
+	assert.Error(t, err)
+
+	i, err := s.CreateInvite(ctxOf("owner"), "pet1", 1, time.Hour)
+	assert.NoError(t, err)
+	invites, err := s.ListInvites(ctxOf("feeder"), "pet1")
+	assert.NoError(t, err)
+	assert.Len(t, invites, 1)
+
+	_, err = s.AcceptInvite(ctxOf("stranger"), &gonyom.AcceptInviteRequest{PetId: "pet1"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	reply, err := s.AcceptInvite(ctxOf("stranger"), &gonyom.AcceptInviteRequest{PetId: i.Code})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"pet1"}, reply.Account.Pets)
+	p, err := stores.pets.Get(ctxOf("stranger"), "pet1")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"owner", "feeder", "stranger"}, p.Feeders)
+
+	invites, err = s.ListInvites(ctxOf("feeder"), "pet1")
+	assert.NoError(t, err)
+	assert.Len(t, invites, 0)
+
+	revoked, err := s.CreateInvite(ctxOf("owner"), "pet1", 2, time.Hour)
+	assert.NoError(t, err)
+	assert.NoError(t, s.RevokeInvite(ctxOf("feeder"), revoked.Code))
+	_, err = s.AcceptInvite(ctxOf("stranger"), &gonyom.AcceptInviteRequest{PetId: revoked.Code})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+// inviteAction runs action through AcceptInvite, the way clients reach the
+// invite RPCs protonyom does not define, and returns the inviteKey header.
+func inviteAction(s *UserServer, uid, action, arg, options string) ([]string, error) {
+	stream := &testStream{}
+	md := metadata.Pairs(inviteActionKey, action)
+	if options != "" {
+		md.Append(inviteOptionsKey, options)
+	}
+	ctx := metadata.NewIncomingContext(grpc.NewContextWithServerTransportStream(ctxOf(uid), stream), md)
+	if _, err := s.AcceptInvite(ctx, &gonyom.AcceptInviteRequest{PetId: arg}); err != nil {
+		return nil, err
+	}
+	return stream.header.Get(inviteKey), nil
+}
+
+func TestUserServer_InviteAction(t *testing.T) {
+	stores := newTestStores(t)
+	s := newTestUserServer(stores)
+
+	_, err := inviteAction(s, "stranger", inviteCreate, "pet1", "")
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	_, err = inviteAction(s, "owner", inviteCreate, "pet1", "two hours")
+	assert.Equal(t, codes.Internal, status.Code(err))
+	_, err = inviteAction(s, "owner", "accept", "pet1", "")
+	assert.Equal(t, codes.Internal, status.Code(err))
+
+	created, err := inviteAction(s, "owner", inviteCreate, "pet1", "2 3600")
+	assert.NoError(t, err)
+	if !assert.Len(t, created, 1) {
+		return
+	}
+	fields := strings.Fields(created[0])
+	assert.Equal(t, []string{"0", "2"}, fields[1:3])
+	listed, err := inviteAction(s, "feeder", inviteList, "pet1", "")
+	assert.NoError(t, err)
+	assert.Equal(t, created, listed)
+
+	reply, err := s.AcceptInvite(ctxOf("stranger"), &gonyom.AcceptInviteRequest{PetId: fields[0]})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"pet1"}, reply.Account.Pets)
+
+	_, err = inviteAction(s, "feeder", inviteRevoke, fields[0], "")
+	assert.NoError(t, err)
+	listed, err = inviteAction(s, "feeder", inviteList, "pet1", "")
+	assert.NoError(t, err)
+	assert.Empty(t, listed)
+}
+
+func TestUserServer_SitterInvite(t *testing.T) {
+	stores := newTestStores(t)
+	s := newTestUserServer(stores)
+	petServer := newTestPetServer(stores)
+	feedServer := NewFeedServer(stores.feeds, stores.users, stores.foods, stores.weights, authz.New(stores.pets, stores.households))
+	now := time.Now()
+
+	_, err := s.CreateSitterInvite(ctxOf("feeder"), "pet1", now, now.Add(time.Hour))
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	_, err = s.CreateSitterInvite(ctxOf("owner"), "pet1", now, now.Add(pet.MaxGrant+time.Hour))
+	assert.Error(t, err)
+
+	i, err := s.CreateSitterInvite(ctxOf("owner"), "pet1", now.Add(-time.Minute), now.Add(time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, i.MaxUses)
+	_, err = s.AcceptInvite(ctxOf("stranger"), &gonyom.AcceptInviteRequest{PetId: i.Code})
+	assert.NoError(t, err)
+
+	addFeed := func(at time.Time) error {
+		_, err := feedServer.AddFeed(ctxOf("stranger"), &gonyom.AddFeedRequest{
+			Feed: &gonyom.Feed{PetId: "pet1", FeederId: "stranger", Timestamp: at.Unix(), Amount: 10, Unit: "g"},
+		})
+		return err
+	}
+	assert.NoError(t, addFeed(now))
+
+	stream := &testStream{}
+	reply, err := petServer.GetPet(grpc.NewContextWithServerTransportStream(ctxOf("owner"), stream),
+		&gonyom.GetPetRequest{PetId: "pet1"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"owner", "feeder", "stranger"}, reply.Pet.Feeders)
+	assert.Equal(t, []string{fmt.Sprintf("stranger %v %v", now.Add(-time.Minute).Unix(), now.Add(time.Hour).Unix())},
+		stream.header.Get(grantsKey))
+
+	// the grant ends before the sweep removes the sitter
+	p, err := stores.pets.Get(context.TODO(), "pet1")
+	assert.NoError(t, err)
+	p.Grants["stranger"].End = now.Add(-time.Second)
+	assert.NoError(t, stores.pets.Delete(context.TODO(), "pet1"))
+	assert.NoError(t, stores.pets.Put(context.TODO(), p))
+	assert.Equal(t, codes.PermissionDenied, status.Code(addFeed(now.Add(-time.Hour))))
+	reply, err = petServer.GetPet(grpc.NewContextWithServerTransportStream(ctxOf("owner"), &testStream{}),
+		&gonyom.GetPetRequest{PetId: "pet1"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"owner", "feeder"}, reply.Pet.Feeders)
+}
+
+// testStream records the headers a handler sets.
+type testStream struct {
+	method string
+	header metadata.MD
+}
+
+func (s *testStream) Method() string { return s.method }
+func (s *testStream) SetHeader(md metadata.MD) error {
+	s.header = metadata.Join(s.header, md)
+	return nil
+}
+func (s *testStream) SendHeader(md metadata.MD) error { return s.SetHeader(md) }
+func (s *testStream) SetTrailer(md metadata.MD) error { return nil }
+
+func refresh(s *UserServer, refreshToken string) (*gonyom.SignReply, string, error) {
+	stream := &testStream{}
+	ctx := grpc.NewContextWithServerTransportStream(context.TODO(), stream)
+	ctx = metadata.NewIncomingContext(ctx, metadata.Pairs(refreshTokenKey, refreshToken))
+	reply, err := s.RefreshToken(ctx, &gonyom.EmptyParams{})
+	if err != nil {
+		return nil, "", err
+	}
+	return reply, stream.header.Get(refreshTokenKey)[0], nil
+}
+
+func TestUserServer_RefreshToken(t *testing.T) {
+	stores := newTestStores(t)
+	stream := &testStream{}
+	ctx := grpc.NewContextWithServerTransportStream(context.TODO(), stream)
+	_, err := newTestUserServer(stores).SignUp(ctx, &gonyom.SignUpRequest{
+		Name:       "name-unserved",
+		Email:      "unserved@test.com",
+		Credential: &gonyom.SignUpRequest_Password{Password: "password"},
+	})
+	assert.NoError(t, err)
+	assert.Empty(t, stream.header.Get(refreshTokenKey), "no refresh token while RefreshToken is not served")
+
+	s := newTestUserServerRefreshing(stores, true)
+	jwtManager := s.jwtManager
+	stream = &testStream{}
+	ctx = grpc.NewContextWithServerTransportStream(context.TODO(), stream)
+	signed, err := s.SignUp(ctx, &gonyom.SignUpRequest{
+		Name:       "name-new",
+		Email:      "new@test.com",
+		Credential: &gonyom.SignUpRequest_Password{Password: "password"},
+	})
+	assert.NoError(t, err)
+	first := stream.header.Get(refreshTokenKey)
+	assert.Len(t, first, 1)
+
+	_, err = jwtManager.Verify(first[0])
+	assert.Error(t, err, "refresh token must not be accepted as auth token")
+	_, _, err = refresh(s, signed.Token)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err), "auth token must not be accepted as refresh token")
+
+	reply, second, err := refresh(s, first[0])
+	assert.NoError(t, err)
+	uid, err := jwtManager.Verify(reply.Token)
+	assert.NoError(t, err)
+	assert.Equal(t, signed.Account.Id, uid)
+
+	third := ""
+	_, third, err = refresh(s, second)
+	assert.NoError(t, err)
+
+	// reusing a rotated token revokes the family, including the latest token
+	_, _, err = refresh(s, first[0])
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	_, _, err = refresh(s, third)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
+
+func TestUserServer_SignOut(t *testing.T) {
+	stores := newTestStores(t)
+	s := newTestUserServerRefreshing(stores, true)
+	ctx := context.TODO()
+
+	n := 0
+	signIn := func() (*jwt.Claims, string) {
+		n++
+		stream := &testStream{}
+		signed, err := s.SignUp(grpc.NewContextWithServerTransportStream(ctx, stream), &gonyom.SignUpRequest{
+			Name:       "name-signout",
+			Email:      fmt.Sprintf("signout%d@test.com", n),
+			Credential: &gonyom.SignUpRequest_Password{Password: "password"},
+		})
+		assert.NoError(t, err)
+		claims, err := s.jwtManager.VerifyAuth(signed.Token)
+		assert.NoError(t, err)
+		return claims, stream.header.Get(refreshTokenKey)[0]
+	}
+
+	claims, refreshToken := signIn()
+	assert.NoError(t, s.checker.Check(ctx, claims))
+	signedIn := context.WithValue(ctxOf(claims.Uid), jwt.CtxKeyClaims, claims)
+	signedIn = metadata.NewIncomingContext(signedIn, metadata.Pairs(refreshTokenKey, refreshToken))
+	_, err := s.SignOut(signedIn, &gonyom.EmptyParams{})
+	assert.NoError(t, err)
+	assert.Error(t, s.checker.Check(ctx, claims))
+	_, _, err = refresh(s, refreshToken)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	other, otherRefresh := signIn()
+	_, err = s.SignOutEverywhere(ctxOf(other.Uid), &gonyom.EmptyParams{})
+	assert.NoError(t, err)
+	assert.Error(t, s.checker.Check(ctx, other))
+	_, _, err = refresh(s, otherRefresh)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
+
+// fakeVerifier accepts tokens of the form "id:email".
+type fakeVerifier struct{}
+
+func (fakeVerifier) Verify(ctx context.Context, token string) (*user.OAuthInfo, error) {
+	parts := strings.SplitN(token, ":", 2)
+	if len(parts) != 2 {
+		return nil, errors.NewAuthenticationError("token %v", token)
+	}
+	return &user.OAuthInfo{Id: parts[0], Email: parts[1]}, nil
+}
+
+func TestUserServer_SignInWithOAuth(t *testing.T) {
+	stores := newTestStores(t)
+	s := newTestUserServer(stores)
+
+	signIn := func(token, claimedId string) (*gonyom.SignReply, error) {
+		ctx := grpc.NewContextWithServerTransportStream(context.TODO(), &testStream{})
+		ctx = metadata.NewIncomingContext(ctx, metadata.Pairs(oauthTokenKey, token))
+		return s.SignIn(ctx, &gonyom.SignInRequest{
+			Credential:    &gonyom.SignInRequest_Oauthinfo{Oauthinfo: &gonyom.OAuthInfo{Id: claimedId}},
+			Oauthprovider: user.OAuthProviderGoogle,
+		})
+	}
+
+	ctx := grpc.NewContextWithServerTransportStream(context.TODO(), &testStream{})
+	ctx = metadata.NewIncomingContext(ctx, metadata.Pairs(oauthTokenKey, "google-victim:victim@gmail.com"))
+	victim, err := s.SignUp(ctx, &gonyom.SignUpRequest{
+		Name:          "victim",
+		Email:         "victim@gmail.com",
+		Credential:    &gonyom.SignUpRequest_Oauthinfo{Oauthinfo: &gonyom.OAuthInfo{Id: "google-victim"}},
+		Oauthprovider: user.OAuthProviderGoogle,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "google-victim", victim.Account.Oauthinfo[user.OAuthProviderGoogle].Id)
+
+	// the email is the verified one of the token, not the one claimed
+	ctx = grpc.NewContextWithServerTransportStream(context.TODO(), &testStream{})
+	ctx = metadata.NewIncomingContext(ctx, metadata.Pairs(oauthTokenKey, "google-other:other@gmail.com"))
+	other, err := s.SignUp(ctx, &gonyom.SignUpRequest{
+		Name:          "other",
+		Email:         "someone@gmail.com",
+		Credential:    &gonyom.SignUpRequest_Oauthinfo{Oauthinfo: &gonyom.OAuthInfo{}},
+		Oauthprovider: user.OAuthProviderGoogle,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "other@gmail.com", other.Account.Email)
+	ctx = grpc.NewContextWithServerTransportStream(context.TODO(), &testStream{})
+	ctx = metadata.NewIncomingContext(ctx, metadata.Pairs(oauthTokenKey, "kakao-unverified:"))
+	_, err = s.SignUp(ctx, &gonyom.SignUpRequest{
+		Name:          "unverified",
+		Email:         "someone@gmail.com",
+		Credential:    &gonyom.SignUpRequest_Oauthinfo{Oauthinfo: &gonyom.OAuthInfo{}},
+		Oauthprovider: user.OAuthProviderGoogle,
+	})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+
+	reply, err := signIn("google-victim:victim@gmail.com", "")
+	assert.NoError(t, err)
+	assert.Equal(t, victim.Account.Id, reply.Account.Id)
+
+	_, err = signIn("google-attacker:attacker@gmail.com", "google-victim")
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	_, err = signIn("", "google-victim")
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
+
+func TestUserServer_Delete(t *testing.T) {
+	stores := newTestStores(t)
+	s := newTestUserServer(stores)
+	ctx := context.TODO()
+	if err := stores.pets.Put(ctx, &pet.Pet{Id: "pet2", Feeders: []string{"feeder"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := stores.users.AddPet(ctx, "feeder", "pet2"); err != nil {
+		t.Fatal(err)
+	}
+	for i, petId := range []string{"pet1", "pet2", "pet2", "pet2"} {
+		f := &feed.Feed{Id: fmt.Sprint("feed", i), PetId: petId, FeederId: "feeder", Timestamp: time.Now()}
+		if err := stores.feeds.Put(ctx, f); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := stores.invites.Put(ctx, &invite.Invite{Code: "code", PetId: "pet2"}); err != nil {
+		t.Fatal(err)
+	}
+	assert.NoError(t, s.RegisterDevice(ctxOf("feeder"), "feeder-token", device.PlatformAndroid))
+	pet2 := &pet.Pet{Id: "pet2"}
+	feeder := &user.User{Id: "feeder"}
+	for _, path := range []string{pet2.NewProfilePath(), feeder.NewProfilePath()} {
+		if _, err := stores.storage.Upload(ctx, &storage.Object{Root: pet.StorageRoot, Path: path, Bytes: []byte("photo")}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, err := s.Delete(ctxOf("feeder"), &gonyom.DeleteAccountRequest{Id: "owner"})
+	assert.Error(t, err)
+	_, err = s.Delete(ctxOf("feeder"), &gonyom.DeleteAccountRequest{Id: "feeder"})
+	assert.NoError(t, err)
+
+	_, err = stores.users.Get(ctx, "feeder")
+	assert.Error(t, err)
+	_, err = stores.pets.Get(ctx, "pet2")
+	assert.Error(t, err)
+	p, err := stores.pets.Get(ctx, "pet1")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"owner"}, p.Feeders)
+
+	// the last owner of a shared pet hands it over
+	addTestMember(t, stores, "viewer", pet.RoleViewer)
+	_, err = s.Delete(ctxOf("owner"), &gonyom.DeleteAccountRequest{Id: "owner"})
+	assert.NoError(t, err)
+	p, err = stores.pets.Get(ctx, "pet1")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"viewer"}, p.Owners())
+
+	// the cleanup runs after the RPC returns
+	stores.deleter.Wait()
+	feeds, err := stores.feeds.GetFeedsOfPet(ctx, "pet2", time.Now().Add(time.Hour), 0)
+	assert.NoError(t, err)
+	assert.Empty(t, feeds)
+	feeds, err = stores.feeds.GetFeedsOfPet(ctx, "pet1", time.Now().Add(time.Hour), 0)
+	assert.NoError(t, err)
+	assert.Len(t, feeds, 1)
+	_, err = stores.invites.Get(ctx, "code")
+	assert.Error(t, err)
+
+	job, err := stores.deletions.Get(ctx, deletion.NewPetJob("pet2", pet2.StorageDir()).Id)
+	assert.NoError(t, err)
+	assert.True(t, job.Done)
+	// the log of pet2 goes with it, the event of its deletion included
+	assert.Equal(t, deletion.Report{Feeds: 3, Invites: 1, AuditEvents: 1, StorageDirs: []string{pet2.StorageDir()}}, job.Report)
+	job, err = stores.deletions.Get(ctx, deletion.NewUserJob("feeder", feeder.StorageDir()).Id)
+	assert.NoError(t, err)
+	assert.True(t, job.Done)
+	assert.Equal(t, 1, job.Report.Devices)
+	// and so does the log of the account, with its name and email
+	events, err := stores.audits.GetListOfUser(ctx, "feeder", audit.Cursor{}, 0)
+	assert.NoError(t, err)
+	assert.Empty(t, events)
+	events, err = stores.audits.GetListOfPet(ctx, "pet2", audit.Cursor{}, 0)
+	assert.NoError(t, err)
+	assert.Empty(t, events)
+
+	// the storage dir is empty once both jobs ran
+	err = filepath.WalkDir(stores.storageDir, func(name string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			t.Errorf("file %v is left", name)
+		}
+		return err
+	})
+	assert.NoError(t, err)
+}
+
+// updateDevice registers or unregisters the device through Update, the way
+// clients reach the device RPCs protonyom does not define.
+func updateDevice(s *UserServer, uid, path, token, platform string) error {
+	ctx := metadata.NewIncomingContext(ctxOf(uid), metadata.Pairs(devicePlatformKey, platform))
+	_, err := s.Update(ctx, &gonyom.UpdateAccountRequest{Path: path, Value: token})
+	return err
+}
+
+func TestUserServer_RegisterDevice(t *testing.T) {
+	stores := newTestStores(t)
+	s := newTestUserServer(stores)
+	ctx := context.TODO()
+
+	assert.Error(t, updateDevice(s, "owner", registerDevicePath, "token", "fridge"))
+	assert.NoError(t, updateDevice(s, "owner", registerDevicePath, "token", device.PlatformIos))
+	devices, err := stores.devices.GetListOfUser(ctx, "owner")
+	assert.NoError(t, err)
+	assert.Len(t, devices, 1)
+	// the same phone signed in with another account
+	assert.NoError(t, updateDevice(s, "feeder", registerDevicePath, "token", device.PlatformIos))
+	devices, err = stores.devices.GetListOfUser(ctx, "owner")
+	assert.NoError(t, err)
+	assert.Empty(t, devices)
+
+	err = updateDevice(s, "owner", unregisterDevicePath, "token", "")
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	assert.NoError(t, updateDevice(s, "feeder", unregisterDevicePath, "token", ""))
+	_, err = stores.devices.Get(ctx, "token")
+	assert.Error(t, err)
+}
@@ -0,0 +1,146 @@
+package servers
+
+import (
+	"context"
+	"time"
+
+	"ohmnyom/domain/pet"
+	"ohmnyom/domain/units"
+	"ohmnyom/domain/weight"
+	"ohmnyom/internal/authz"
+	"ohmnyom/internal/errors"
+)
+
+const (
+	// MaxTrendRange limits how far back GetWeightTrend reaches at once.
+	MaxTrendRange = 2 * 366 * 24 * time.Hour
+	// MaxTrendWindow limits the moving average window of GetWeightTrend.
+	MaxTrendWindow = 90 * 24 * time.Hour
+)
+
+// WeightServer keeps the weight history of pets. protonyom does not define a
+// WeightApi yet, so the server is not registered.
+type WeightServer struct {
+	weightStore weight.Store
+	authorizer  *authz.Authorizer
+}
+
+func NewWeightServer(store weight.Store, authorizer *authz.Authorizer) *WeightServer {
+	return &WeightServer{
+		weightStore: store,
+		authorizer:  authorizer,
+	}
+}
+
+// AddWeight records a weight of the pet, measured now unless in has a
+// timestamp.
+func (s *WeightServer) AddWeight(ctx context.Context, in *weight.Weight) (*weight.Weight, error) {
+	if in == nil {
+		return nil, errors.GrpcError(errors.NewInvalidParamError("weight: %v", in))
+	}
+	if _, err := s.authorizer.Pet(ctx, in.PetId, pet.RoleCaregiver); err != nil {
+		return nil, errors.GrpcError(err)
+	}
+	uid, _ := authz.Uid(ctx)
+
+	newWeight := *in
+	newWeight.Id = weight.NewWeightId()
+	newWeight.RecordedBy = uid
+	newWeight.Created = time.Now().UTC()
+	if newWeight.Timestamp.IsZero() {
+		newWeight.Timestamp = newWeight.Created
+	}
+	if err := newWeight.Validate(); err != nil {
+		return nil, errors.GrpcError(err)
+	}
+	if err := s.weightStore.Put(ctx, &newWeight); err != nil {
+		return nil, errors.GrpcError(err)
+	}
+	return &newWeight, nil
+}
+
+// GetWeights returns the weights of the pet in [from, to), oldest first.
+func (s *WeightServer) GetWeights(ctx context.Context, petId string, from, to time.Time) ([]*weight.Weight, error) {
+	if _, err := s.authorizer.Pet(ctx, petId, pet.RoleViewer); err != nil {
+		return nil, errors.GrpcError(err)
+	}
+	weights, err := s.weightStore.GetListOfPet(ctx, petId, from, to)
+	if err != nil {
+		return nil, errors.GrpcError(err)
+	}
+	return weights, nil
+}
+
+// UpdateWeight replaces the timestamp, value, unit and note of a weight. Only
+// whoever recorded it or the owner may change it.
+func (s *WeightServer) UpdateWeight(ctx context.Context, in *weight.Weight) (*weight.Weight, error) {
+	if in == nil {
+		return nil, errors.GrpcError(errors.NewInvalidParamError("weight: %v", in))
+	}
+	stored, err := s.weightStore.Get(ctx, in.PetId, in.Id)
+	if err != nil {
+		return nil, errors.GrpcError(err)
+	}
+	if _, err := s.authorizer.Author(ctx, stored.PetId, stored.RecordedBy); err != nil {
+		return nil, errors.GrpcError(err)
+	}
+
+	updated := *stored
+	updated.Timestamp = in.Timestamp
+	updated.Value = in.Value
+	updated.Unit = in.Unit
+	updated.Note = in.Note
+	if err := updated.Validate(); err != nil {
+		return nil, errors.GrpcError(err)
+	}
+	if err := s.weightStore.Update(ctx, &updated); err != nil {
+		return nil, errors.GrpcError(err)
+	}
+	return &updated, nil
+}
+
+func (s *WeightServer) DeleteWeight(ctx context.Context, petId, id string) error {
+	stored, err := s.weightStore.Get(ctx, petId, id)
+	if err != nil {
+		return errors.GrpcError(err)
+	}
+	if _, err := s.authorizer.Author(ctx, stored.PetId, stored.RecordedBy); err != nil {
+		return errors.GrpcError(err)
+	}
+	if err := s.weightStore.Delete(ctx, petId, id); err != nil {
+		return errors.GrpcError(err)
+	}
+	return nil
+}
+
+// GetWeightTrend returns the weights of the pet in [from, to) with their moving
+// average over window, and how much the average changed. Weights are in the
+// preferred unit of the pet if it is a mass, in kilograms otherwise.
+func (s *WeightServer) GetWeightTrend(ctx context.Context, petId string, from, to time.Time,
+	window time.Duration) (*weight.Trend, error) {
+	if !from.Before(to) || to.Sub(from) > MaxTrendRange || window > MaxTrendWindow {
+		return nil, errors.GrpcError(errors.NewInvalidParamError("from: %v, to: %v, window: %v", from, to, window))
+	}
+	if window <= 0 {
+		window = weight.DefaultWindow
+	}
+	p, err := s.authorizer.Pet(ctx, petId, pet.RoleViewer)
+	if err != nil {
+		return nil, errors.GrpcError(err)
+	}
+
+	// weights in the window before from start the average of the first points
+	weights, err := s.weightStore.GetListOfPet(ctx, petId, from.Add(-window), to)
+	if err != nil {
+		return nil, errors.GrpcError(err)
+	}
+	unit := units.Kilogram
+	if u, err := units.Lookup(p.PreferredUnit); err == nil && u.Dimension == units.DimensionMass {
+		unit = u.Symbol
+	}
+	trend, err := weight.NewTrend(weights, from, to, unit, window)
+	if err != nil {
+		return nil, errors.GrpcError(err)
+	}
+	return trend, nil
+}
@@ -0,0 +1,53 @@
+package servers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"ohmnyom/domain/weight"
+	"ohmnyom/internal/authz"
+)
+
+func TestWeightServer(t *testing.T) {
+	stores := newTestStores(t)
+	s := NewWeightServer(stores.weights, authz.New(stores.pets, stores.households))
+	day := time.Date(2022, 3, 1, 9, 0, 0, 0, time.UTC)
+
+	_, err := s.AddWeight(ctxOf("stranger"), &weight.Weight{PetId: "pet1", Timestamp: day, Value: 4, Unit: "kg"})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	first, err := s.AddWeight(ctxOf("feeder"), &weight.Weight{PetId: "pet1", Timestamp: day, Value: 4, Unit: "kg"})
+	assert.NoError(t, err)
+	assert.Equal(t, "feeder", first.RecordedBy)
+	second, err := s.AddWeight(ctxOf("owner"), &weight.Weight{PetId: "pet1", Timestamp: day.AddDate(0, 0, 14),
+		Value: 4400, Unit: "grams"})
+	assert.NoError(t, err)
+	assert.Equal(t, "g", second.Unit)
+
+	list, err := s.GetWeights(ctxOf("feeder"), "pet1", day, day.AddDate(1, 0, 0))
+	assert.NoError(t, err)
+	assert.Equal(t, []*weight.Weight{first, second}, list)
+
+	// feeders change their own records, owners any
+	update := *second
+	update.Value = 4200
+	_, err = s.UpdateWeight(ctxOf("feeder"), &update)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	updated, err := s.UpdateWeight(ctxOf("owner"), &update)
+	assert.NoError(t, err)
+	assert.Equal(t, 4200.0, updated.Value)
+
+	trend, err := s.GetWeightTrend(ctxOf("feeder"), "pet1", day, day.AddDate(0, 1, 0), 0)
+	assert.NoError(t, err)
+	assert.Equal(t, "kg", trend.Unit)
+	assert.Len(t, trend.Points, 2)
+	assert.InDelta(t, 5.0, trend.ChangePercent, 1e-9)
+	_, err = s.GetWeightTrend(ctxOf("feeder"), "pet1", day, day.AddDate(5, 0, 0), 0)
+	assert.Error(t, err)
+
+	assert.Equal(t, codes.PermissionDenied, status.Code(s.DeleteWeight(ctxOf("feeder"), "pet1", second.Id)))
+	assert.NoError(t, s.DeleteWeight(ctxOf("feeder"), "pet1", first.Id))
+	assert.Equal(t, codes.NotFound, status.Code(s.DeleteWeight(ctxOf("owner"), "pet1", first.Id)))
+}
@@ -0,0 +1,108 @@
+// Package audit is an append-only log of who changed what, kept per pet and
+// per account. The log of a pet or an account is deleted with it.
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/rs/xid"
+	"ohmnyom/internal/errors"
+)
+
+// MaxPageSize limits the events listed at once.
+const MaxPageSize = 100
+
+// Event records one change. Before and After are JSON snapshots of what the
+// change touched, empty for what did not exist before or after it.
+type Event struct {
+	Id string `firestore:"id"`
+	// Actor is the uid of who made the change.
+	Actor string `firestore:"actor"`
+	// Method is the full name of the RPC, as in grpc.UnaryServerInfo.
+	Method string `firestore:"method"`
+	// PetId and UserId are the pet and the account the change belongs to.
+	PetId     string    `firestore:"petId,omitempty"`
+	UserId    string    `firestore:"userId,omitempty"`
+	Before    string    `firestore:"before,omitempty"`
+	After     string    `firestore:"after,omitempty"`
+	Timestamp time.Time `firestore:"timestamp"`
+}
+
+// NewEvent returns an event of actor calling method now, with snapshots of
+// before and after. A nil snapshot stays empty.
+func NewEvent(actor, method string, before, after interface{}) (*Event, error) {
+	if actor == "" || method == "" {
+		return nil, errors.NewInvalidParamError("actor [%v], method [%v]", actor, method)
+	}
+	e := &Event{
+		Id:        xid.New().String(),
+		Actor:     actor,
+		Method:    method,
+		Timestamp: time.Now().UTC(),
+	}
+	var err error
+	if e.Before, err = snapshot(before); err != nil {
+		return nil, err
+	}
+	if e.After, err = snapshot(after); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+func snapshot(v interface{}) (string, error) {
+	if v == nil {
+		return "", nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", errors.NewInternalError("snapshot: %v", err)
+	}
+	if string(b) == "null" {
+		return "", nil
+	}
+	return string(b), nil
+}
+
+// Cursor is the position of an event in a list, where the next page starts.
+// Events are listed latest first, and by descending Id among those of the same
+// Timestamp, so that a page never splits them. The zero Cursor is before the
+// latest event.
+type Cursor struct {
+	Timestamp time.Time
+	Id        string
+}
+
+func (e *Event) Cursor() Cursor {
+	return Cursor{Timestamp: e.Timestamp, Id: e.Id}
+}
+
+// Before tells whether e is listed after c.
+func (c Cursor) Before(e *Event) bool {
+	switch {
+	case c.Timestamp.IsZero():
+		return true
+	case e.Timestamp.Equal(c.Timestamp):
+		return c.Id != "" && e.Id < c.Id
+	}
+	return e.Timestamp.Before(c.Timestamp)
+}
+
+// Store only adds events, there is no changing them, nor deleting them but
+// with the pet or the account they belong to.
+type Store interface {
+	Put(ctx context.Context, event *Event) error
+	// GetListOfPet returns up to limit events of the pet after startAfter.
+	GetListOfPet(ctx context.Context, petId string, startAfter Cursor, limit int) ([]*Event, error)
+	// GetListOfUser returns up to limit events of the account after
+	// startAfter.
+	GetListOfUser(ctx context.Context, userId string, startAfter Cursor, limit int) ([]*Event, error)
+	// DeleteBatchOfPet deletes up to limit events of the pet and returns how
+	// many it deleted. Fewer than limit means none are left.
+	DeleteBatchOfPet(ctx context.Context, petId string, limit int) (int, error)
+	// DeleteBatchOfUser deletes up to limit events of the account and returns
+	// how many it deleted. Fewer than limit means none are left.
+	DeleteBatchOfUser(ctx context.Context, userId string, limit int) (int, error)
+}
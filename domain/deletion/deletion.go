@@ -0,0 +1,107 @@
+// Package deletion describes the cleanup left over once a pet or a user
+// document is deleted. A Job is written in the same transaction as the delete,
+// so cleanup that is interrupted can be resumed later.
+package deletion
+
+import (
+	"context"
+	"time"
+)
+
+type Kind string
+
+const (
+	KindPet  Kind = "pet"
+	KindUser Kind = "user"
+)
+
+// Report counts what a job deleted so far.
+type Report struct {
+	Feeds         int      `firestore:"feeds"`
+	Schedules     int      `firestore:"schedules"`
+	Foods         int      `firestore:"foods"`
+	Weights       int      `firestore:"weights"`
+	HealthRecords int      `firestore:"healthRecords"`
+	Invites       int      `firestore:"invites"`
+	Devices       int      `firestore:"devices"`
+	AuditEvents   int      `firestore:"auditEvents"`
+	StorageDirs   []string `firestore:"storageDirs"`
+}
+
+type Job struct {
+	Id       string `firestore:"id"`
+	Kind     Kind   `firestore:"kind"`
+	TargetId string `firestore:"targetId"`
+	// StorageDirs are the directories to delete under StorageRoot.
+	StorageDirs []string  `firestore:"storageDirs"`
+	Created     time.Time `firestore:"created"`
+	// FeedsDone, SchedulesDone, FoodsDone, WeightsDone, HealthDone,
+	// InvitesDone, DevicesDone and AuditDone mark finished steps, so a resumed
+	// job skips them.
+	FeedsDone     bool   `firestore:"feedsDone"`
+	SchedulesDone bool   `firestore:"schedulesDone"`
+	FoodsDone     bool   `firestore:"foodsDone"`
+	WeightsDone   bool   `firestore:"weightsDone"`
+	HealthDone    bool   `firestore:"healthDone"`
+	InvitesDone   bool   `firestore:"invitesDone"`
+	DevicesDone   bool   `firestore:"devicesDone"`
+	AuditDone     bool   `firestore:"auditDone"`
+	Done          bool   `firestore:"done"`
+	Report        Report `firestore:"report"`
+}
+
+func jobId(kind Kind, targetId string) string {
+	return string(kind) + "-" + targetId
+}
+
+// NewPetJob cleans up the feeds, schedules, food catalog, weights, health
+// records, invites, audit log and stored files of a deleted pet.
+func NewPetJob(petId, storageDir string) *Job {
+	return &Job{
+		Id:          jobId(KindPet, petId),
+		Kind:        KindPet,
+		TargetId:    petId,
+		StorageDirs: []string{storageDir},
+		Created:     time.Now().UTC(),
+		// pets have no devices
+		DevicesDone: true,
+	}
+}
+
+// NewUserJob cleans up the devices, audit log and stored files of a deleted
+// user.
+func NewUserJob(uid, storageDir string) *Job {
+	return &Job{
+		Id:          jobId(KindUser, uid),
+		Kind:        KindUser,
+		TargetId:    uid,
+		StorageDirs: []string{storageDir},
+		Created:     time.Now().UTC(),
+		// users have no feeds, schedules, foods, weights, health records or
+		// invites of their own
+		FeedsDone:     true,
+		SchedulesDone: true,
+		FoodsDone:     true,
+		WeightsDone:   true,
+		HealthDone:    true,
+		InvitesDone:   true,
+	}
+}
+
+// IsDirDeleted reports whether dir is already in the report.
+func (j *Job) IsDirDeleted(dir string) bool {
+	for _, d := range j.Report.StorageDirs {
+		if d == dir {
+			return true
+		}
+	}
+	return false
+}
+
+type Store interface {
+	Get(ctx context.Context, id string) (*Job, error)
+	// Save creates or overwrites the job.
+	Save(ctx context.Context, job *Job) error
+	// GetPending returns jobs that are not done yet, oldest first.
+	GetPending(ctx context.Context) ([]*Job, error)
+}
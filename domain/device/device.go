@@ -0,0 +1,60 @@
+// Package device keeps the push notification tokens of the devices users
+// signed in on.
+package device
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"ohmnyom/internal/errors"
+)
+
+const (
+	PlatformAndroid = "android"
+	PlatformIos     = "ios"
+	PlatformWeb     = "web"
+)
+
+type Device struct {
+	Token      string    `firestore:"token"`
+	Uid        string    `firestore:"uid"`
+	Platform   string    `firestore:"platform"`
+	Registered time.Time `firestore:"registered"`
+}
+
+func New(token, uid, platform string) (*Device, error) {
+	if token == "" || uid == "" {
+		return nil, errors.NewInvalidParamError("token [%v], uid [%v]", token, uid)
+	}
+	switch platform {
+	case PlatformAndroid, PlatformIos, PlatformWeb:
+	default:
+		return nil, errors.NewInvalidParamError("platform [%v]", platform)
+	}
+	return &Device{
+		Token:      token,
+		Uid:        uid,
+		Platform:   platform,
+		Registered: time.Now().UTC(),
+	}, nil
+}
+
+// Id is the document id of the token, which may hold characters a document id
+// cannot.
+func Id(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+type Store interface {
+	Get(ctx context.Context, token string) (*Device, error)
+	GetListOfUser(ctx context.Context, uid string) ([]*Device, error)
+	// Put registers the device, replacing an earlier registration of the token
+	// by any user.
+	Put(ctx context.Context, device *Device) error
+	Delete(ctx context.Context, token string) error
+	// DeleteAllOfUser deletes every device of uid and returns how many there were.
+	DeleteAllOfUser(ctx context.Context, uid string) (int, error)
+}
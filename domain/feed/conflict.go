@@ -0,0 +1,49 @@
+package feed
+
+import (
+	"time"
+
+	"ohmnyom/internal/errors"
+)
+
+// ConflictError tells that the pet was already fed at Feed, close to the feed
+// being added.
+type ConflictError struct {
+	Feed *Feed
+	Err  error
+}
+
+func (e *ConflictError) Unwrap() error { return e.Err }
+func (e *ConflictError) Error() string { return e.Err.Error() }
+
+// CheckConflict returns a ConflictError for the feed among others closest to
+// f, if one is within window of it. Only feeds of the same type conflict, a
+// treat given just after a meal is not a second meal. A zero window never
+// conflicts.
+func CheckConflict(others []*Feed, f *Feed, window time.Duration) error {
+	if window <= 0 {
+		return nil
+	}
+	var closest *Feed
+	var distance time.Duration
+	for _, other := range others {
+		if other.Id == f.Id || other.TypeOrMeal() != f.TypeOrMeal() {
+			continue
+		}
+		d := other.Timestamp.Sub(f.Timestamp)
+		if d < 0 {
+			d = -d
+		}
+		if d < window && (closest == nil || d < distance) {
+			closest, distance = other, d
+		}
+	}
+	if closest == nil {
+		return nil
+	}
+	return &ConflictError{
+		Feed: closest,
+		Err: errors.NewFailedPreconditionError("pet %v was already fed at %v by %v",
+			f.PetId, closest.Timestamp.UTC().Format(time.RFC3339), closest.FeederId),
+	}
+}
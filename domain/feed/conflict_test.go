@@ -0,0 +1,39 @@
+package feed
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckConflict(t *testing.T) {
+	now := time.Now()
+	others := []*Feed{
+		{Id: "meal", Timestamp: now.Add(-20 * time.Minute)},
+		{Id: "typed meal", Timestamp: now.Add(10 * time.Minute), Type: TypeMeal},
+		{Id: "treat", Timestamp: now.Add(-time.Minute), Type: TypeTreat},
+		{Id: "water", Timestamp: now, Type: TypeWater},
+	}
+	conflictOf := func(f *Feed, window time.Duration) string {
+		err := CheckConflict(others, f, window)
+		if err == nil {
+			return ""
+		}
+		conflict, ok := err.(*ConflictError)
+		if !assert.True(t, ok) {
+			return ""
+		}
+		return conflict.Feed.Id
+	}
+
+	// feeds without a type are meals, and meals conflict with meals only
+	assert.Equal(t, "typed meal", conflictOf(&Feed{Id: "new", Timestamp: now}, time.Hour))
+	assert.Equal(t, "typed meal", conflictOf(&Feed{Id: "new", Timestamp: now, Type: TypeMeal}, time.Hour))
+	assert.Equal(t, "treat", conflictOf(&Feed{Id: "new", Timestamp: now, Type: TypeTreat}, time.Hour))
+	assert.Equal(t, "", conflictOf(&Feed{Id: "new", Timestamp: now, Type: TypeMedication}, time.Hour))
+	assert.Equal(t, "", conflictOf(&Feed{Id: "new", Timestamp: now}, 5*time.Minute))
+	assert.Equal(t, "", conflictOf(&Feed{Id: "new", Timestamp: now}, 0))
+	// a feed does not conflict with itself
+	assert.Equal(t, "meal", conflictOf(&Feed{Id: "typed meal", Timestamp: now}, time.Hour))
+}
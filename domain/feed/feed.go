@@ -6,10 +6,11 @@ import (
 
 	"github.com/aiceru/protonyom/gonyom"
 	"github.com/rs/xid"
+	"ohmnyom/domain/units"
 	"ohmnyom/internal/errors"
 )
 
-var updatableFields = []string{"timestamp", "amount", "unit"}
+var updatableFields = []string{"timestamp", "amount", "unit", "kcal"}
 
 func IsUpdatableField(field string) bool {
 	for _, f := range updatableFields {
@@ -27,24 +28,71 @@ type Feed struct {
 	FeederId  string    `firestore:"feederId,omitempty"`
 	Amount    float64   `firestore:"amount,omitempty"`
 	Unit      string    `firestore:"unit,omitempty"`
+	// FoodId refers to the food catalog of the pet, if the feeder picked a food.
+	FoodId string `firestore:"foodId,omitempty"`
+	Type   Type   `firestore:"type,omitempty"`
+	// Kcal is worked out from the food when the feed is added, so that later
+	// changes to the catalog leave the history alone.
+	Kcal float64 `firestore:"kcal,omitempty"`
+}
+
+// Type tells meals from the rest. Feeds without a type are meals.
+type Type string
+
+const (
+	TypeMeal       Type = "meal"
+	TypeTreat      Type = "treat"
+	TypeWater      Type = "water"
+	TypeMedication Type = "medication"
+)
+
+func ParseType(s string) (Type, error) {
+	switch t := Type(s); t {
+	case "":
+		return TypeMeal, nil
+	case TypeMeal, TypeTreat, TypeWater, TypeMedication:
+		return t, nil
+	}
+	return "", errors.NewInvalidParamError("feed type: %v", s)
+}
+
+// TypeOrMeal returns the type of the feed, TypeMeal when it has none.
+func (f *Feed) TypeOrMeal() Type {
+	if f.Type == "" {
+		return TypeMeal
+	}
+	return f.Type
+}
+
+// ScaleKcal returns the calories of the feed if it had amount instead.
+func (f *Feed) ScaleKcal(amount float64) float64 {
+	if f.Amount == 0 {
+		return 0
+	}
+	return f.Kcal * amount / f.Amount
 }
 
 func newFeedId() string {
 	return xid.NewWithTime(time.Now().UTC()).String()
 }
 
-// NewFromProto : returns with nil Feeder, have to manually fill it
+// NewFromProto : returns with nil Feeder, have to manually fill it. The unit
+// is normalized, and rejected unless it is in the units registry.
 func NewFromProto(feed *gonyom.Feed) (*Feed, error) {
 	if feed.Id != "" {
 		return nil, errors.NewInvalidParamError("feed already has ID, %v", feed.Id)
 	}
+	unit, err := units.Normalize(feed.Unit)
+	if err != nil {
+		return nil, err
+	}
 	return &Feed{
 		Id:        newFeedId(),
 		PetId:     feed.PetId,
 		Timestamp: time.Unix(feed.Timestamp, 0),
 		FeederId:  feed.FeederId,
 		Amount:    feed.Amount,
-		Unit:      feed.Unit,
+		Unit:      unit,
 	}, nil
 }
 
@@ -80,7 +128,20 @@ func (f *Feed) ToProto(feederName string) *gonyom.Feed {
 type Store interface {
 	Get(ctx context.Context, petId, feedId string) (*Feed, error)
 	GetFeedsOfPet(ctx context.Context, petId string, startAfter time.Time, limit int) ([]*Feed, error)
+	// GetFeedsOfPetInRange returns the feeds in [from, to), oldest first.
+	GetFeedsOfPetInRange(ctx context.Context, petId string, from, to time.Time) ([]*Feed, error)
 	Put(ctx context.Context, feed *Feed) error
 	Update(ctx context.Context, petId, feedId string, pathValues map[string]interface{}) error
+	// PutUnlessConflict puts the feed unless CheckConflict finds another feed of
+	// the pet within window of it. The check and the write are one atomic
+	// operation, so of two feeds added at once only one gets in.
+	PutUnlessConflict(ctx context.Context, feed *Feed, window time.Duration) error
+	// UpdateUnlessConflict updates the feed as Update does, unless the feed as
+	// updated conflicts with another one, atomically as PutUnlessConflict.
+	UpdateUnlessConflict(ctx context.Context, petId, feedId string, pathValues map[string]interface{},
+		window time.Duration) error
 	Delete(ctx context.Context, petId, feedId string) error
+	// DeleteBatchOfPet deletes up to limit feeds of the pet and returns how many
+	// it deleted. Fewer than limit means none are left.
+	DeleteBatchOfPet(ctx context.Context, petId string, limit int) (int, error)
 }
@@ -0,0 +1,207 @@
+package feed
+
+import (
+	"sort"
+	"time"
+
+	"ohmnyom/domain/units"
+	"ohmnyom/internal/errors"
+)
+
+type Period string
+
+const (
+	PeriodDay   Period = "day"
+	PeriodWeek  Period = "week"
+	PeriodMonth Period = "month"
+
+	// MaxBuckets keeps a stats query from spanning years of days.
+	MaxBuckets = 400
+)
+
+func ParsePeriod(s string) (Period, error) {
+	switch p := Period(s); p {
+	case PeriodDay, PeriodWeek, PeriodMonth:
+		return p, nil
+	}
+	return "", errors.NewInvalidParamError("period: %v", s)
+}
+
+// start returns the beginning of the period containing t, at midnight in loc.
+// Weeks start on Monday.
+func (p Period) start(t time.Time, loc *time.Location) time.Time {
+	t = t.In(loc)
+	y, m, d := t.Date()
+	switch p {
+	case PeriodWeek:
+		d -= (int(t.Weekday()) + 6) % 7
+	case PeriodMonth:
+		d = 1
+	}
+	return time.Date(y, m, d, 0, 0, 0, 0, loc)
+}
+
+func (p Period) next(t time.Time) time.Time {
+	switch p {
+	case PeriodWeek:
+		return t.AddDate(0, 0, 7)
+	case PeriodMonth:
+		return t.AddDate(0, 1, 0)
+	}
+	return t.AddDate(0, 0, 1)
+}
+
+type FeederStats struct {
+	FeederId   string
+	FeederName string
+	Count      int
+	Amounts    map[string]float64
+	Kcal       float64
+}
+
+// TypeStats aggregates the feeds of a type, so treats are told from meals.
+type TypeStats struct {
+	Count   int
+	Amounts map[string]float64
+	Kcal    float64
+}
+
+// Bucket aggregates the feeds in [Start, End).
+type Bucket struct {
+	Start time.Time
+	End   time.Time
+	Count int
+	// Amounts is the total amount per unit.
+	Amounts map[string]float64
+	// Kcal is the energy of the feeds of catalog foods with known calories.
+	Kcal float64
+	// Types has an entry for each type fed, feeds without one counting as meals.
+	Types map[Type]*TypeStats
+	// Feeders is sorted by FeederId.
+	Feeders []*FeederStats
+	// AverageInterval is the mean time between consecutive feeds, zero for
+	// fewer than two feeds.
+	AverageInterval time.Duration
+}
+
+type Stats struct {
+	Period  Period
+	Total   *Bucket
+	Buckets []*Bucket
+}
+
+func newBucket(start, end time.Time, feeds []*Feed) *Bucket {
+	b := &Bucket{
+		Start:   start,
+		End:     end,
+		Count:   len(feeds),
+		Amounts: make(map[string]float64),
+		Types:   make(map[Type]*TypeStats),
+		Feeders: make([]*FeederStats, 0),
+	}
+	feeders := make(map[string]*FeederStats)
+	for _, f := range feeds {
+		b.Amounts[f.Unit] += f.Amount
+		b.Kcal += f.Kcal
+		ts, ok := b.Types[f.TypeOrMeal()]
+		if !ok {
+			ts = &TypeStats{Amounts: make(map[string]float64)}
+			b.Types[f.TypeOrMeal()] = ts
+		}
+		ts.Count++
+		ts.Amounts[f.Unit] += f.Amount
+		ts.Kcal += f.Kcal
+		fs, ok := feeders[f.FeederId]
+		if !ok {
+			fs = &FeederStats{FeederId: f.FeederId, Amounts: make(map[string]float64)}
+			feeders[f.FeederId] = fs
+			b.Feeders = append(b.Feeders, fs)
+		}
+		fs.Count++
+		fs.Amounts[f.Unit] += f.Amount
+		fs.Kcal += f.Kcal
+	}
+	sort.Slice(b.Feeders, func(i, j int) bool {
+		return b.Feeders[i].FeederId < b.Feeders[j].FeederId
+	})
+	if len(feeds) > 1 {
+		span := feeds[len(feeds)-1].Timestamp.Sub(feeds[0].Timestamp)
+		b.AverageInterval = span / time.Duration(len(feeds)-1)
+	}
+	return b
+}
+
+// Aggregate buckets the feeds in [from, to) by period, with periods starting at
+// midnight in loc. Every period overlapping the range gets a bucket, empty or
+// not; the first and last buckets are clipped to the range.
+func Aggregate(feeds []*Feed, period Period, from, to time.Time, loc *time.Location) (*Stats, error) {
+	if _, err := ParsePeriod(string(period)); err != nil {
+		return nil, err
+	}
+	if !from.Before(to) {
+		return nil, errors.NewInvalidParamError("from: %v, to: %v", from, to)
+	}
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	inRange := make([]*Feed, 0, len(feeds))
+	for _, f := range feeds {
+		if !f.Timestamp.Before(from) && f.Timestamp.Before(to) {
+			inRange = append(inRange, f)
+		}
+	}
+	sort.Slice(inRange, func(i, j int) bool {
+		return inRange[i].Timestamp.Before(inRange[j].Timestamp)
+	})
+
+	stats := &Stats{
+		Period:  period,
+		Total:   newBucket(from, to, inRange),
+		Buckets: make([]*Bucket, 0),
+	}
+	rest := inRange
+	for start := period.start(from, loc); start.Before(to); start = period.next(start) {
+		if len(stats.Buckets) == MaxBuckets {
+			return nil, errors.NewInvalidParamError("range %v - %v has more than %v %vs",
+				from, to, MaxBuckets, period)
+		}
+		end := period.next(start)
+		n := 0
+		for n < len(rest) && rest[n].Timestamp.Before(end) {
+			n++
+		}
+		bucketStart, bucketEnd := start, end
+		if bucketStart.Before(from) {
+			bucketStart = from.In(loc)
+		}
+		if bucketEnd.After(to) {
+			bucketEnd = to.In(loc)
+		}
+		stats.Buckets = append(stats.Buckets, newBucket(bucketStart, bucketEnd, rest[:n]))
+		rest = rest[n:]
+	}
+	return stats, nil
+}
+
+// InUnit returns copies of feeds with their amounts in unit where they convert,
+// and in the canonical symbol of their own unit otherwise. density returns the
+// grams per millilitre of a food of the catalog, zero when unknown. Feeds
+// recorded before units were checked may have units out of the registry; they
+// are kept as they are.
+func InUnit(feeds []*Feed, unit string, density func(foodId string) float64) []*Feed {
+	ret := make([]*Feed, len(feeds))
+	for i, f := range feeds {
+		c := *f
+		if symbol, err := units.Normalize(c.Unit); err == nil {
+			c.Unit = symbol
+		}
+		if unit != "" && c.Unit != "" {
+			if amount, err := units.Convert(c.Amount, c.Unit, unit, density(c.FoodId)); err == nil {
+				c.Amount, c.Unit = amount, unit
+			}
+		}
+		ret[i] = &c
+	}
+	return ret
+}
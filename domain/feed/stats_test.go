@@ -0,0 +1,108 @@
+package feed
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAggregate(t *testing.T) {
+	seoul, err := time.LoadLocation("Asia/Seoul")
+	if err != nil {
+		t.Skip(err)
+	}
+	at := func(s string) time.Time {
+		ts, err := time.ParseInLocation("2006-01-02 15:04", s, seoul)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ts
+	}
+	feeds := []*Feed{
+		{FeederId: "b", Timestamp: at("2022-03-01 23:30"), Amount: 10, Unit: "g"},
+		{FeederId: "a", Timestamp: at("2022-03-01 07:30"), Amount: 20, Unit: "g"},
+		{FeederId: "a", Timestamp: at("2022-03-02 00:30"), Amount: 1, Unit: "cup"},
+		{FeederId: "a", Timestamp: at("2022-03-07 08:00"), Amount: 5, Unit: "g"},
+		{FeederId: "a", Timestamp: at("2022-04-01 08:00"), Amount: 5, Unit: "g"},
+	}
+	from, to := at("2022-03-01 00:00"), at("2022-03-08 00:00")
+
+	stats, err := Aggregate(feeds, PeriodDay, from, to, seoul)
+	assert.NoError(t, err)
+	assert.Len(t, stats.Buckets, 7)
+	first := stats.Buckets[0]
+	assert.Equal(t, from, first.Start)
+	assert.Equal(t, 2, first.Count)
+	assert.Equal(t, map[string]float64{"g": 30}, first.Amounts)
+	assert.Equal(t, 16*time.Hour, first.AverageInterval)
+	assert.Equal(t, []*FeederStats{
+		{FeederId: "a", Count: 1, Amounts: map[string]float64{"g": 20}},
+		{FeederId: "b", Count: 1, Amounts: map[string]float64{"g": 10}},
+	}, first.Feeders)
+	assert.Equal(t, 1, stats.Buckets[1].Count)
+	assert.Equal(t, 0, stats.Buckets[2].Count)
+	assert.Equal(t, 1, stats.Buckets[6].Count)
+
+	assert.Equal(t, 4, stats.Total.Count)
+	assert.Equal(t, map[string]float64{"g": 35, "cup": 1}, stats.Total.Amounts)
+
+	// in UTC the morning feed falls on Feb 28, and the feeds around Seoul
+	// midnight share Mar 1
+	stats, err = Aggregate(feeds, PeriodDay, from, to, time.UTC)
+	assert.NoError(t, err)
+	assert.Len(t, stats.Buckets, 8)
+	assert.Equal(t, 1, stats.Buckets[0].Count)
+	assert.Equal(t, 2, stats.Buckets[1].Count)
+
+	// 2022-03-01 is a Tuesday, so the first week is clipped to Tue-Sun
+	stats, err = Aggregate(feeds, PeriodWeek, from, to, seoul)
+	assert.NoError(t, err)
+	assert.Len(t, stats.Buckets, 2)
+	assert.Equal(t, from, stats.Buckets[0].Start)
+	assert.Equal(t, at("2022-03-07 00:00"), stats.Buckets[0].End)
+	assert.Equal(t, 3, stats.Buckets[0].Count)
+	assert.Equal(t, 1, stats.Buckets[1].Count)
+
+	stats, err = Aggregate(feeds, PeriodMonth, from, at("2022-05-01 00:00"), seoul)
+	assert.NoError(t, err)
+	assert.Len(t, stats.Buckets, 2)
+	assert.Equal(t, 4, stats.Buckets[0].Count)
+	assert.Equal(t, 1, stats.Buckets[1].Count)
+
+	_, err = Aggregate(feeds, "year", from, to, seoul)
+	assert.Error(t, err)
+	_, err = Aggregate(feeds, PeriodDay, to, from, seoul)
+	assert.Error(t, err)
+	_, err = Aggregate(feeds, PeriodDay, from, from.AddDate(2, 0, 0), seoul)
+	assert.Error(t, err)
+}
+
+func TestInUnit(t *testing.T) {
+	feeds := []*Feed{
+		{Id: "a", Amount: 1, Unit: "kg"},
+		{Id: "b", Amount: 2, Unit: "cup", FoodId: "kibble"},
+		{Id: "c", Amount: 2, Unit: "cup"},
+		{Id: "d", Amount: 1, Unit: "Pieces"},
+		{Id: "e", Amount: 3, Unit: "handful"},
+	}
+	density := func(foodId string) float64 {
+		if foodId == "kibble" {
+			return 0.5
+		}
+		return 0
+	}
+
+	got := InUnit(feeds, "g", density)
+	assert.Equal(t, 1000.0, got[0].Amount)
+	assert.Equal(t, "g", got[1].Unit)
+	assert.InDelta(t, 236.5882365, got[1].Amount, 1e-9)
+	assert.Equal(t, "cup", got[2].Unit)
+	assert.Equal(t, "piece", got[3].Unit)
+	assert.Equal(t, "handful", got[4].Unit)
+	assert.Equal(t, "kg", feeds[0].Unit)
+
+	got = InUnit(feeds, "", density)
+	assert.Equal(t, "kg", got[0].Unit)
+	assert.Equal(t, 1.0, got[0].Amount)
+}
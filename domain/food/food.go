@@ -0,0 +1,126 @@
+// Package food is the catalog of foods a pet is fed, so that feeds can tell
+// kibble from treats and count calories.
+package food
+
+import (
+	"context"
+	"time"
+
+	"github.com/rs/xid"
+	"ohmnyom/domain/feed"
+	"ohmnyom/domain/units"
+	"ohmnyom/internal/errors"
+)
+
+type Type string
+
+const (
+	TypeKibble     Type = "kibble"
+	TypeWet        Type = "wet"
+	TypeTreat      Type = "treat"
+	TypeWater      Type = "water"
+	TypeMedication Type = "medication"
+	TypeOther      Type = "other"
+)
+
+// Food is an item of the catalog of a pet. KcalPerUnit is the energy of one
+// Unit of it, and Density its grams per millilitre, so that feeds measured in
+// cups count the calories of food labelled per gram.
+type Food struct {
+	Id          string    `firestore:"id"`
+	PetId       string    `firestore:"petId"`
+	Name        string    `firestore:"name"`
+	Brand       string    `firestore:"brand,omitempty"`
+	Type        Type      `firestore:"type"`
+	KcalPerUnit float64   `firestore:"kcalPerUnit,omitempty"`
+	Unit        string    `firestore:"unit,omitempty"`
+	Density     float64   `firestore:"density,omitempty"`
+	CreatedBy   string    `firestore:"createdBy"`
+	Created     time.Time `firestore:"created"`
+}
+
+func NewFoodId() string {
+	return xid.New().String()
+}
+
+func (f *Food) Validate() error {
+	if f.PetId == "" || f.Name == "" {
+		return errors.NewInvalidParamError("petId [%v], name [%v]", f.PetId, f.Name)
+	}
+	switch f.Type {
+	case TypeKibble, TypeWet, TypeTreat, TypeWater, TypeMedication, TypeOther:
+	default:
+		return errors.NewInvalidParamError("food type [%v]", f.Type)
+	}
+	if f.KcalPerUnit < 0 || (f.KcalPerUnit > 0 && f.Unit == "") {
+		return errors.NewInvalidParamError("kcalPerUnit [%v], unit [%v]", f.KcalPerUnit, f.Unit)
+	}
+	if f.Density < 0 {
+		return errors.NewInvalidParamError("density [%v]", f.Density)
+	}
+	unit, err := units.Normalize(f.Unit)
+	if err != nil {
+		return err
+	}
+	f.Unit = unit
+	return nil
+}
+
+// FeedType is the type of feeds of the food.
+func (f *Food) FeedType() feed.Type {
+	switch f.Type {
+	case TypeTreat:
+		return feed.TypeTreat
+	case TypeWater:
+		return feed.TypeWater
+	case TypeMedication:
+		return feed.TypeMedication
+	}
+	return feed.TypeMeal
+}
+
+// Apply makes fd a feed of the food: it refers to the food, takes its type
+// unless it has one, and gets its calories.
+func (f *Food) Apply(fd *feed.Feed) error {
+	if fd.PetId != f.PetId {
+		return errors.NewInvalidParamError("Food{Id: %v} is not in the catalog of Pet{Id: %v}", f.Id, fd.PetId)
+	}
+	if fd.Unit == "" {
+		fd.Unit = f.Unit
+	}
+	kcal, err := f.Kcal(fd.Amount, fd.Unit)
+	if err != nil {
+		return err
+	}
+	fd.FoodId = f.Id
+	if fd.Type == "" {
+		fd.Type = f.FeedType()
+	}
+	fd.Kcal = kcal
+	return nil
+}
+
+// Kcal returns the energy of amount of the food in unit, converted to the unit
+// of the food.
+func (f *Food) Kcal(amount float64, unit string) (float64, error) {
+	if f.KcalPerUnit == 0 {
+		return 0, nil
+	}
+	converted, err := units.Convert(amount, unit, f.Unit, f.Density)
+	if err != nil {
+		return 0, err
+	}
+	return converted * f.KcalPerUnit, nil
+}
+
+type Store interface {
+	Get(ctx context.Context, petId, id string) (*Food, error)
+	GetListOfPet(ctx context.Context, petId string) ([]*Food, error)
+	Put(ctx context.Context, food *Food) error
+	// Update replaces the stored food with the same pet and id.
+	Update(ctx context.Context, food *Food) error
+	Delete(ctx context.Context, petId, id string) error
+	// DeleteAllOfPet deletes the catalog of the pet and returns how many foods
+	// it had.
+	DeleteAllOfPet(ctx context.Context, petId string) (int, error)
+}
@@ -0,0 +1,64 @@
+package food
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"ohmnyom/domain/feed"
+)
+
+func TestFood_Apply(t *testing.T) {
+	treat := &Food{Id: "food1", PetId: "pet1", Name: "chicken jerky", Type: TypeTreat, KcalPerUnit: 3.5, Unit: "g"}
+	assert.NoError(t, treat.Validate())
+
+	f := &feed.Feed{PetId: "pet1", Amount: 10}
+	assert.NoError(t, treat.Apply(f))
+	assert.Equal(t, "food1", f.FoodId)
+	assert.Equal(t, "g", f.Unit)
+	assert.Equal(t, feed.TypeTreat, f.Type)
+	assert.Equal(t, 35.0, f.Kcal)
+
+	// a type given by the feeder wins over the type of the food
+	f = &feed.Feed{PetId: "pet1", Amount: 10, Unit: "g", Type: feed.TypeMeal}
+	assert.NoError(t, treat.Apply(f))
+	assert.Equal(t, feed.TypeMeal, f.Type)
+
+	assert.Error(t, treat.Apply(&feed.Feed{PetId: "pet1", Amount: 1, Unit: "cup"}))
+
+	// with a density, cups count the calories of food labelled per gram
+	kibble := &Food{Id: "food3", PetId: "pet1", Name: "kibble", Type: TypeKibble, KcalPerUnit: 3.6, Unit: "grams",
+		Density: 0.5}
+	assert.NoError(t, kibble.Validate())
+	assert.Equal(t, "g", kibble.Unit)
+	f = &feed.Feed{PetId: "pet1", Amount: 1, Unit: "cup"}
+	assert.NoError(t, kibble.Apply(f))
+	assert.InDelta(t, 425.86, f.Kcal, 0.01)
+	assert.Error(t, treat.Apply(&feed.Feed{PetId: "pet2", Amount: 1, Unit: "g"}))
+
+	water := &Food{Id: "food2", PetId: "pet1", Name: "water", Type: TypeWater}
+	assert.NoError(t, water.Validate())
+	f = &feed.Feed{PetId: "pet1", Amount: 100, Unit: "ml"}
+	assert.NoError(t, water.Apply(f))
+	assert.Equal(t, feed.TypeWater, f.Type)
+	assert.Zero(t, f.Kcal)
+}
+
+func TestFood_Validate(t *testing.T) {
+	tests := []struct {
+		name string
+		food *Food
+	}{
+		{"no pet", &Food{Name: "kibble", Type: TypeKibble}},
+		{"no name", &Food{PetId: "pet1", Type: TypeKibble}},
+		{"unknown type", &Food{PetId: "pet1", Name: "kibble", Type: "snack"}},
+		{"negative kcal", &Food{PetId: "pet1", Name: "kibble", Type: TypeKibble, KcalPerUnit: -1, Unit: "g"}},
+		{"kcal without unit", &Food{PetId: "pet1", Name: "kibble", Type: TypeKibble, KcalPerUnit: 3.6}},
+		{"unknown unit", &Food{PetId: "pet1", Name: "kibble", Type: TypeKibble, KcalPerUnit: 3.6, Unit: "bag"}},
+		{"negative density", &Food{PetId: "pet1", Name: "kibble", Type: TypeKibble, Density: -1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Error(t, tt.food.Validate())
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package health
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// MaxDueItems keeps a short dosing interval from flooding a due query.
+const MaxDueItems = 500
+
+// DueItem is a vaccination or a dose of medication that is due.
+type DueItem struct {
+	RecordId string
+	Kind     Kind
+	// Name is the vaccine or the drug.
+	Name string
+	Due  time.Time
+	// Overdue is set for vaccinations due before the queried range that were
+	// not given again.
+	Overdue bool
+}
+
+// Upcoming returns what is due in [from, to), oldest first: the next shot of
+// every vaccine, overdue ones included, and the doses of medication courses.
+// A vaccination is superseded by a later one of the same vaccine.
+func Upcoming(records []*Record, from, to time.Time) []*DueItem {
+	ret := make([]*DueItem, 0)
+	latest := make(map[string]*Record)
+	for _, r := range records {
+		switch {
+		case r.Kind == KindVaccination && r.Vaccination != nil:
+			vaccine := strings.ToLower(strings.TrimSpace(r.Vaccination.Vaccine))
+			if l, ok := latest[vaccine]; !ok || r.Date.After(l.Date) {
+				latest[vaccine] = r
+			}
+		case r.Kind == KindMedication && r.Medication != nil:
+			ret = append(ret, doses(r, from, to)...)
+		}
+	}
+	for _, r := range latest {
+		due := r.Vaccination.NextDue
+		if due.IsZero() || !due.Before(to) {
+			continue
+		}
+		ret = append(ret, &DueItem{
+			RecordId: r.Id,
+			Kind:     r.Kind,
+			Name:     r.Vaccination.Vaccine,
+			Due:      due,
+			Overdue:  due.Before(from),
+		})
+	}
+	sort.SliceStable(ret, func(i, j int) bool {
+		return ret[i].Due.Before(ret[j].Due)
+	})
+	if len(ret) > MaxDueItems {
+		ret = ret[:MaxDueItems]
+	}
+	return ret
+}
+
+// doses returns the doses of the course of r in [from, to).
+func doses(r *Record, from, to time.Time) []*DueItem {
+	interval := time.Duration(r.Medication.IntervalHours) * time.Hour
+	end := to
+	if courseEnd := r.Medication.End; !courseEnd.IsZero() && courseEnd.Before(end) {
+		// a dose right at the end of the course is still given
+		end = courseEnd.Add(time.Nanosecond)
+	}
+	at := r.Date
+	if at.Before(from) {
+		// the first dose at or after from
+		n := (from.Sub(at) + interval - 1) / interval
+		at = at.Add(n * interval)
+	}
+	ret := make([]*DueItem, 0)
+	for ; at.Before(end) && len(ret) < MaxDueItems; at = at.Add(interval) {
+		ret = append(ret, &DueItem{
+			RecordId: r.Id,
+			Kind:     r.Kind,
+			Name:     r.Medication.Drug,
+			Due:      at,
+		})
+	}
+	return ret
+}
@@ -0,0 +1,72 @@
+package health
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpcoming(t *testing.T) {
+	now := time.Date(2022, 3, 1, 9, 0, 0, 0, time.UTC)
+	records := []*Record{
+		// superseded by the booster of the same vaccine
+		{Id: "rabies-1", Kind: KindVaccination, Date: now.AddDate(-1, 0, 0),
+			Vaccination: &Vaccination{Vaccine: "Rabies", NextDue: now.AddDate(0, -1, 0)}},
+		{Id: "rabies-2", Kind: KindVaccination, Date: now.AddDate(0, -1, 0),
+			Vaccination: &Vaccination{Vaccine: "rabies", NextDue: now.AddDate(0, 0, 10)}},
+		{Id: "dhpp", Kind: KindVaccination, Date: now.AddDate(-2, 0, 0),
+			Vaccination: &Vaccination{Vaccine: "DHPP", NextDue: now.AddDate(-1, 0, 0)}},
+		{Id: "lepto", Kind: KindVaccination, Date: now.AddDate(0, -1, 0),
+			Vaccination: &Vaccination{Vaccine: "Lepto", NextDue: now.AddDate(1, 0, 0)}},
+		{Id: "antibiotic", Kind: KindMedication, Date: now.Add(-13 * time.Hour),
+			Medication: &Medication{Drug: "amoxicillin", IntervalHours: 12, End: now.Add(35 * time.Hour)}},
+		{Id: "visit", Kind: KindVisit, Date: now, Visit: &Visit{}},
+	}
+
+	due := Upcoming(records, now, now.AddDate(0, 0, 30))
+	if assert.Len(t, due, 5) {
+		assert.Equal(t, "dhpp", due[0].RecordId)
+		assert.True(t, due[0].Overdue)
+		// doses 11h, 23h and 35h from now, the last one at the end of the course
+		for i, hours := range []int{11, 23, 35} {
+			assert.Equal(t, "antibiotic", due[i+1].RecordId)
+			assert.Equal(t, now.Add(time.Duration(hours)*time.Hour), due[i+1].Due)
+			assert.False(t, due[i+1].Overdue)
+		}
+		assert.Equal(t, "rabies-2", due[4].RecordId)
+	}
+}
+
+func TestRecord_Validate(t *testing.T) {
+	now := time.Now()
+	valid := []*Record{
+		{PetId: "pet1", Kind: KindVaccination, Date: now, Vaccination: &Vaccination{Vaccine: "rabies"}},
+		{PetId: "pet1", Kind: KindVisit, Date: now, Visit: &Visit{Reason: "limping"}},
+		{PetId: "pet1", Kind: KindMedication, Date: now, Medication: &Medication{Drug: "x", IntervalHours: 24}},
+	}
+	for _, r := range valid {
+		assert.NoError(t, r.Validate())
+	}
+
+	tests := []struct {
+		name   string
+		record *Record
+	}{
+		{"no pet", &Record{Kind: KindVisit, Date: now, Visit: &Visit{}}},
+		{"unknown kind", &Record{PetId: "pet1", Kind: "surgery", Date: now, Visit: &Visit{}}},
+		{"detail of another kind", &Record{PetId: "pet1", Kind: KindVisit, Date: now,
+			Medication: &Medication{Drug: "x", IntervalHours: 24}}},
+		{"two details", &Record{PetId: "pet1", Kind: KindVisit, Date: now, Visit: &Visit{},
+			Medication: &Medication{Drug: "x", IntervalHours: 24}}},
+		{"next due before the shot", &Record{PetId: "pet1", Kind: KindVaccination, Date: now,
+			Vaccination: &Vaccination{Vaccine: "rabies", NextDue: now.AddDate(0, 0, -1)}}},
+		{"no interval", &Record{PetId: "pet1", Kind: KindMedication, Date: now,
+			Medication: &Medication{Drug: "x"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Error(t, tt.record.Validate())
+		})
+	}
+}
@@ -0,0 +1,154 @@
+// Package health keeps the health records of pets: vaccinations, vet visits
+// and medication courses.
+package health
+
+import (
+	"context"
+	"time"
+
+	"github.com/rs/xid"
+	"ohmnyom/internal/errors"
+)
+
+type Kind string
+
+const (
+	KindVaccination Kind = "vaccination"
+	KindVisit       Kind = "visit"
+	KindMedication  Kind = "medication"
+
+	// MaxAttachmentSize limits a file attached to a vet visit.
+	MaxAttachmentSize = 10 << 20
+	// MaxAttachments limits the files of a vet visit.
+	MaxAttachments = 20
+)
+
+func ParseKind(s string) (Kind, error) {
+	switch k := Kind(s); k {
+	case KindVaccination, KindVisit, KindMedication:
+		return k, nil
+	}
+	return "", errors.NewInvalidParamError("health record kind: %v", s)
+}
+
+type Vaccination struct {
+	Vaccine string `firestore:"vaccine"`
+	// NextDue is when the next shot is due, zero when none is.
+	NextDue time.Time `firestore:"nextDue,omitempty"`
+}
+
+type Visit struct {
+	Clinic      string        `firestore:"clinic,omitempty"`
+	Vet         string        `firestore:"vet,omitempty"`
+	Reason      string        `firestore:"reason,omitempty"`
+	Attachments []*Attachment `firestore:"attachments,omitempty"`
+}
+
+// Attachment is a file of a vet visit kept in storage under Path.
+type Attachment struct {
+	Id          string    `firestore:"id"`
+	Name        string    `firestore:"name"`
+	ContentType string    `firestore:"contentType"`
+	Url         string    `firestore:"url"`
+	Path        string    `firestore:"path"`
+	Uploaded    time.Time `firestore:"uploaded"`
+}
+
+// Medication is a course of a drug given every IntervalHours from the date of
+// the record until End, or until the record is changed when End is zero.
+type Medication struct {
+	Drug          string    `firestore:"drug"`
+	Dosage        string    `firestore:"dosage,omitempty"`
+	IntervalHours int       `firestore:"intervalHours"`
+	End           time.Time `firestore:"end,omitempty"`
+}
+
+// Record is a health record of a pet. Date is when the shot was given, the
+// visit took place or the course starts. Exactly the detail of Kind is set.
+type Record struct {
+	Id          string       `firestore:"id"`
+	PetId       string       `firestore:"petId"`
+	Kind        Kind         `firestore:"kind"`
+	Title       string       `firestore:"title,omitempty"`
+	Date        time.Time    `firestore:"date"`
+	Notes       string       `firestore:"notes,omitempty"`
+	Vaccination *Vaccination `firestore:"vaccination,omitempty"`
+	Visit       *Visit       `firestore:"visit,omitempty"`
+	Medication  *Medication  `firestore:"medication,omitempty"`
+	RecordedBy  string       `firestore:"recordedBy"`
+	Created     time.Time    `firestore:"created"`
+}
+
+func NewRecordId() string {
+	return xid.New().String()
+}
+
+func NewAttachmentId() string {
+	return xid.New().String()
+}
+
+func (r *Record) Validate() error {
+	if r.PetId == "" || r.Date.IsZero() {
+		return errors.NewInvalidParamError("petId [%v], date [%v]", r.PetId, r.Date)
+	}
+	if _, err := ParseKind(string(r.Kind)); err != nil {
+		return err
+	}
+	details := 0
+	for _, set := range []bool{r.Vaccination != nil, r.Visit != nil, r.Medication != nil} {
+		if set {
+			details++
+		}
+	}
+	if details != 1 {
+		return errors.NewInvalidParamError("%v record needs exactly its own detail", r.Kind)
+	}
+	switch r.Kind {
+	case KindVaccination:
+		if r.Vaccination == nil || r.Vaccination.Vaccine == "" {
+			return errors.NewInvalidParamError("vaccination: %+v", r.Vaccination)
+		}
+		if !r.Vaccination.NextDue.IsZero() && !r.Vaccination.NextDue.After(r.Date) {
+			return errors.NewInvalidParamError("next due [%v] is not after [%v]", r.Vaccination.NextDue, r.Date)
+		}
+	case KindVisit:
+		if r.Visit == nil || len(r.Visit.Attachments) > MaxAttachments {
+			return errors.NewInvalidParamError("visit: %+v", r.Visit)
+		}
+	case KindMedication:
+		if r.Medication == nil || r.Medication.Drug == "" || r.Medication.IntervalHours <= 0 {
+			return errors.NewInvalidParamError("medication: %+v", r.Medication)
+		}
+		if !r.Medication.End.IsZero() && r.Medication.End.Before(r.Date) {
+			return errors.NewInvalidParamError("course end [%v] is before [%v]", r.Medication.End, r.Date)
+		}
+	}
+	return nil
+}
+
+// Attachment returns the attachment with the id, nil if the record has none.
+func (r *Record) Attachment(id string) *Attachment {
+	if r.Visit == nil {
+		return nil
+	}
+	for _, a := range r.Visit.Attachments {
+		if a.Id == id {
+			return a
+		}
+	}
+	return nil
+}
+
+type Store interface {
+	Get(ctx context.Context, petId, id string) (*Record, error)
+	// GetListOfPet returns the records of kind, or of every kind when kind is
+	// empty, latest first.
+	GetListOfPet(ctx context.Context, petId string, kind Kind) ([]*Record, error)
+	Put(ctx context.Context, record *Record) error
+	// Update replaces the stored record with the same pet and id.
+	Update(ctx context.Context, record *Record) error
+	Delete(ctx context.Context, petId, id string) error
+	// DeleteAllOfPet deletes the records of the pet and returns how many it
+	// had.
+	DeleteAllOfPet(ctx context.Context, petId string) (int, error)
+}
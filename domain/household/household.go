@@ -0,0 +1,65 @@
+// Package household groups users who share all of their pets. A member of a
+// household takes care of every pet in it without joining the pets one by one.
+package household
+
+import (
+	"context"
+	"time"
+
+	"github.com/rs/xid"
+	"ohmnyom/internal/errors"
+)
+
+const (
+	MaxNameLength = 50
+	MaxMembers    = 20
+)
+
+type Household struct {
+	Id        string    `firestore:"id"`
+	Name      string    `firestore:"name"`
+	Members   []string  `firestore:"members"`
+	CreatedBy string    `firestore:"createdBy"`
+	Created   time.Time `firestore:"created"`
+}
+
+// New returns a household of createdBy alone.
+func New(name, createdBy string) (*Household, error) {
+	if name == "" || len([]rune(name)) > MaxNameLength || createdBy == "" {
+		return nil, errors.NewInvalidParamError("name [%v], createdBy [%v]", name, createdBy)
+	}
+	return &Household{
+		Id:        xid.New().String(),
+		Name:      name,
+		Members:   []string{createdBy},
+		CreatedBy: createdBy,
+		Created:   time.Now().UTC(),
+	}, nil
+}
+
+func (h *Household) IsMember(uid string) bool {
+	for _, member := range h.Members {
+		if member == uid {
+			return true
+		}
+	}
+	return false
+}
+
+// MemberOtherThan returns the first member but uid, empty if there is none.
+func (h *Household) MemberOtherThan(uid string) string {
+	for _, member := range h.Members {
+		if member != uid {
+			return member
+		}
+	}
+	return ""
+}
+
+type Store interface {
+	Get(ctx context.Context, id string) (*Household, error)
+	// GetListOfUser returns the households uid is a member of.
+	GetListOfUser(ctx context.Context, uid string) ([]*Household, error)
+	Put(ctx context.Context, household *Household) error
+	Delete(ctx context.Context, id string) error
+}
@@ -0,0 +1,78 @@
+// Package idempotency remembers the replies to requests sent with an
+// idempotency key, so that a client retrying a request gets the original reply
+// instead of running it twice.
+package idempotency
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+)
+
+const (
+	// DefaultTTL is how long the reply to a request is kept for its retries.
+	DefaultTTL = 24 * time.Hour
+	// PendingLease is how long a pending record holds its key. A request that
+	// neither replies nor fails by then, as one whose server went down, leaves
+	// the key to the next retry.
+	PendingLease = 2 * time.Minute
+	MaxKeyLength = 255
+)
+
+type State string
+
+const (
+	// StatePending records run by the first request, which has not replied yet.
+	StatePending State = "pending"
+	StateDone    State = "done"
+)
+
+type Record struct {
+	Id     string `firestore:"id"`
+	Uid    string `firestore:"uid"`
+	Method string `firestore:"method"`
+	// RequestHash tells a retry from another request reusing the key.
+	RequestHash string `firestore:"requestHash"`
+	State       State  `firestore:"state"`
+	// Response is the reply, marshalled as a google.protobuf.Any.
+	Response []byte    `firestore:"response,omitempty"`
+	Created  time.Time `firestore:"created"`
+	Expires  time.Time `firestore:"expires"`
+}
+
+// Id scopes key to the user, so that users cannot see replies to each other.
+func Id(uid, key string) string {
+	sum := sha256.Sum256([]byte(uid + "\x00" + key))
+	return hex.EncodeToString(sum[:])
+}
+
+// NewPending returns a pending record that expires after PendingLease.
+func NewPending(uid, key, method, requestHash string) *Record {
+	now := time.Now().UTC()
+	return &Record{
+		Id:          Id(uid, key),
+		Uid:         uid,
+		Method:      method,
+		RequestHash: requestHash,
+		State:       StatePending,
+		Created:     now,
+		Expires:     now.Add(PendingLease),
+	}
+}
+
+// Matches reports whether other is a retry of the request of r.
+func (r *Record) Matches(other *Record) bool {
+	return r.Method == other.Method && r.RequestHash == other.RequestHash
+}
+
+type Store interface {
+	// Begin stores the pending record and returns nil, unless an unexpired
+	// record with its id exists, which it returns instead.
+	Begin(ctx context.Context, record *Record) (*Record, error)
+	// Complete stores the reply of a pending record, which then expires at
+	// expires instead.
+	Complete(ctx context.Context, id string, response []byte, expires time.Time) error
+	// Delete forgets a record, so a failed request can be retried.
+	Delete(ctx context.Context, id string) error
+}
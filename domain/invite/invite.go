@@ -0,0 +1,130 @@
+package invite
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"time"
+
+	"ohmnyom/domain/pet"
+	"ohmnyom/internal/errors"
+)
+
+const (
+	DefaultTTL     = time.Hour * 48
+	MaxTTL         = time.Hour * 24 * 7
+	DefaultMaxUses = 1
+	MaxMaxUses     = 10
+
+	codeBytes = 15
+)
+
+// Invite lets up to MaxUses users join a pet, or a household when HouseholdId
+// is set instead of PetId, until it expires.
+type Invite struct {
+	Code        string    `firestore:"code"`
+	PetId       string    `firestore:"petId,omitempty"`
+	HouseholdId string    `firestore:"householdId,omitempty"`
+	CreatedBy   string    `firestore:"createdBy"`
+	Created     time.Time `firestore:"created"`
+	Expires     time.Time `firestore:"expires"`
+	MaxUses     int       `firestore:"maxUses"`
+	Uses        int       `firestore:"uses"`
+	UsedBy      []string  `firestore:"usedBy,omitempty"`
+	// Grant, when set, makes whoever redeems the invite a pet sitter for its
+	// time instead of a member for good.
+	Grant *pet.Grant `firestore:"grant,omitempty"`
+}
+
+func newCode() (string, error) {
+	b := make([]byte, codeBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", errors.NewInternalError("%v", err)
+	}
+	return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b), nil
+}
+
+// New creates an invite to petId. Zero maxUses or ttl take the defaults.
+func New(petId, createdBy string, maxUses int, ttl time.Duration) (*Invite, error) {
+	if petId == "" {
+		return nil, errors.NewInvalidParamError("petId [%v]", petId)
+	}
+	i, err := newInvite(createdBy, maxUses, ttl)
+	if err != nil {
+		return nil, err
+	}
+	i.PetId = petId
+	return i, nil
+}
+
+// NewToHousehold creates an invite to householdId. Zero maxUses or ttl take
+// the defaults.
+func NewToHousehold(householdId, createdBy string, maxUses int, ttl time.Duration) (*Invite, error) {
+	if householdId == "" {
+		return nil, errors.NewInvalidParamError("householdId [%v]", householdId)
+	}
+	i, err := newInvite(createdBy, maxUses, ttl)
+	if err != nil {
+		return nil, err
+	}
+	i.HouseholdId = householdId
+	return i, nil
+}
+
+func newInvite(createdBy string, maxUses int, ttl time.Duration) (*Invite, error) {
+	if createdBy == "" {
+		return nil, errors.NewInvalidParamError("createdBy [%v]", createdBy)
+	}
+	if maxUses == 0 {
+		maxUses = DefaultMaxUses
+	}
+	if ttl == 0 {
+		ttl = DefaultTTL
+	}
+	if maxUses < 0 || maxUses > MaxMaxUses || ttl < 0 || ttl > MaxTTL {
+		return nil, errors.NewInvalidParamError("maxUses [%v], ttl [%v]", maxUses, ttl)
+	}
+
+	code, err := newCode()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	return &Invite{
+		Code:      code,
+		CreatedBy: createdBy,
+		Created:   now,
+		Expires:   now.Add(ttl),
+		MaxUses:   maxUses,
+	}, nil
+}
+
+func (i *Invite) IsOutstanding(now time.Time) bool {
+	return now.Before(i.Expires) && i.Uses < i.MaxUses
+}
+
+// CheckRedeemable returns an error if uid cannot redeem the invite at now.
+func (i *Invite) CheckRedeemable(uid string, now time.Time) error {
+	if !now.Before(i.Expires) {
+		return errors.NewFailedPreconditionError("Invite{Code: %v} expired at %v", i.Code, i.Expires)
+	}
+	if i.Grant != nil && i.Grant.Expired(now) {
+		return errors.NewFailedPreconditionError("grant of Invite{Code: %v} ended at %v", i.Code, i.Grant.End)
+	}
+	if i.Uses >= i.MaxUses {
+		return errors.NewFailedPreconditionError("Invite{Code: %v} used up", i.Code)
+	}
+	for _, used := range i.UsedBy {
+		if used == uid {
+			return errors.NewAlreadyExistsError("Invite{Code: %v} redeemed by %v", i.Code, uid)
+		}
+	}
+	return nil
+}
+
+type Store interface {
+	Get(ctx context.Context, code string) (*Invite, error)
+	GetListOfPet(ctx context.Context, petId string) ([]*Invite, error)
+	Put(ctx context.Context, invite *Invite) error
+	Delete(ctx context.Context, code string) error
+}
@@ -0,0 +1,167 @@
+// Package nutrition works out the daily energy requirement of dogs and cats.
+//
+// The resting energy requirement (RER) is 70 * kg^0.75 kcal a day. The
+// maintenance energy requirement (MER) multiplies it by a factor for the
+// family, life stage and neuter status of the pet.
+package nutrition
+
+import (
+	"math"
+	"time"
+
+	"ohmnyom/domain/feed"
+	"ohmnyom/internal/errors"
+)
+
+const (
+	FamilyDog = "dog"
+	FamilyCat = "cat"
+
+	// MaxTreatShare is the part of the daily calories treats should stay under.
+	MaxTreatShare = 0.1
+)
+
+type LifeStage string
+
+const (
+	// LifeStageYoung is a puppy under four months; kittens skip it.
+	LifeStageYoung   LifeStage = "young"
+	LifeStageGrowing LifeStage = "growing"
+	LifeStageAdult   LifeStage = "adult"
+	LifeStageSenior  LifeStage = "senior"
+)
+
+// LifeStageOf returns the life stage of a pet of family born at born. Pets
+// without a birth date are taken for adults.
+func LifeStageOf(family string, born, now time.Time) LifeStage {
+	if born.IsZero() {
+		return LifeStageAdult
+	}
+	seniorYears := 7
+	if family == FamilyCat {
+		seniorYears = 11
+	}
+	switch {
+	case family == FamilyDog && now.Before(born.AddDate(0, 4, 0)):
+		return LifeStageYoung
+	case now.Before(born.AddDate(1, 0, 0)):
+		return LifeStageGrowing
+	case !now.Before(born.AddDate(seniorYears, 0, 0)):
+		return LifeStageSenior
+	}
+	return LifeStageAdult
+}
+
+// RER returns the resting energy requirement of a pet of weightKg in kcal a
+// day.
+func RER(weightKg float64) float64 {
+	return 70 * math.Pow(weightKg, 0.75)
+}
+
+// Multiplier returns the factor of the RER a pet needs a day. Neuter status
+// only matters for adults.
+func Multiplier(family string, stage LifeStage, neutered bool) (float64, error) {
+	switch family {
+	case FamilyDog:
+		switch stage {
+		case LifeStageYoung:
+			return 3.0, nil
+		case LifeStageGrowing:
+			return 2.0, nil
+		case LifeStageSenior:
+			return 1.4, nil
+		case LifeStageAdult:
+			if neutered {
+				return 1.6, nil
+			}
+			return 1.8, nil
+		}
+	case FamilyCat:
+		switch stage {
+		case LifeStageYoung, LifeStageGrowing:
+			return 2.5, nil
+		case LifeStageSenior:
+			return 1.1, nil
+		case LifeStageAdult:
+			if neutered {
+				return 1.2, nil
+			}
+			return 1.4, nil
+		}
+	default:
+		return 0, errors.NewFailedPreconditionError("no energy requirement for family [%v]", family)
+	}
+	return 0, errors.NewInvalidParamError("life stage [%v]", stage)
+}
+
+// Target is the daily energy requirement of a pet and how it was worked out.
+type Target struct {
+	Family     string
+	LifeStage  LifeStage
+	Neutered   bool
+	WeightKg   float64
+	RER        float64
+	Multiplier float64
+	// Kcal is the MER, the calories a day the pet should get.
+	Kcal float64
+}
+
+func NewTarget(family string, weightKg float64, born time.Time, neutered bool, now time.Time) (*Target, error) {
+	if weightKg <= 0 {
+		return nil, errors.NewInvalidParamError("weightKg [%v]", weightKg)
+	}
+	stage := LifeStageOf(family, born, now)
+	multiplier, err := Multiplier(family, stage, neutered)
+	if err != nil {
+		return nil, err
+	}
+	rer := RER(weightKg)
+	return &Target{
+		Family:     family,
+		LifeStage:  stage,
+		Neutered:   neutered,
+		WeightKg:   weightKg,
+		RER:        rer,
+		Multiplier: multiplier,
+		Kcal:       rer * multiplier,
+	}, nil
+}
+
+// Intake compares the feeds of a day against a target.
+type Intake struct {
+	Target *Target
+	// Kcal is the calories of the feeds, TreatKcal the part of it from treats.
+	Kcal      float64
+	TreatKcal float64
+	// Remaining is what is left of the target, negative when overfed.
+	Remaining float64
+	// Percent is Kcal relative to the target.
+	Percent float64
+	// TooManyTreats is set when treats are over MaxTreatShare of the target.
+	TooManyTreats bool
+	// Uncounted is the number of meals and treats without calories, from no
+	// food of the catalog or one with unknown calories, so Kcal falls short.
+	Uncounted int
+}
+
+func NewIntake(target *Target, feeds []*feed.Feed) *Intake {
+	in := &Intake{Target: target}
+	for _, f := range feeds {
+		t := f.TypeOrMeal()
+		if t != feed.TypeMeal && t != feed.TypeTreat {
+			continue
+		}
+		if f.Kcal == 0 {
+			in.Uncounted++
+			continue
+		}
+		in.Kcal += f.Kcal
+		if t == feed.TypeTreat {
+			in.TreatKcal += f.Kcal
+		}
+	}
+	in.Remaining = target.Kcal - in.Kcal
+	in.Percent = in.Kcal / target.Kcal * 100
+	in.TooManyTreats = in.TreatKcal > target.Kcal*MaxTreatShare
+	return in
+}
@@ -0,0 +1,68 @@
+package nutrition
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"ohmnyom/domain/feed"
+)
+
+func TestLifeStageOf(t *testing.T) {
+	now := time.Date(2022, 3, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		family string
+		born   time.Time
+		want   LifeStage
+	}{
+		{"unknown birth", FamilyDog, time.Time{}, LifeStageAdult},
+		{"puppy", FamilyDog, now.AddDate(0, -2, 0), LifeStageYoung},
+		{"growing dog", FamilyDog, now.AddDate(0, -6, 0), LifeStageGrowing},
+		{"kitten", FamilyCat, now.AddDate(0, -2, 0), LifeStageGrowing},
+		{"adult dog", FamilyDog, now.AddDate(-3, 0, 0), LifeStageAdult},
+		{"senior dog", FamilyDog, now.AddDate(-7, 0, 0), LifeStageSenior},
+		{"adult cat", FamilyCat, now.AddDate(-8, 0, 0), LifeStageAdult},
+		{"senior cat", FamilyCat, now.AddDate(-11, 0, 0), LifeStageSenior},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, LifeStageOf(tt.family, tt.born, now))
+		})
+	}
+}
+
+func TestNewTarget(t *testing.T) {
+	now := time.Now()
+	target, err := NewTarget(FamilyDog, 10, time.Time{}, true, now)
+	assert.NoError(t, err)
+	assert.InDelta(t, 393.6, target.RER, 0.1)
+	assert.Equal(t, 1.6, target.Multiplier)
+	assert.InDelta(t, 629.8, target.Kcal, 0.1)
+
+	target, err = NewTarget(FamilyCat, 4, now.AddDate(0, -3, 0), false, now)
+	assert.NoError(t, err)
+	assert.Equal(t, LifeStageGrowing, target.LifeStage)
+	assert.Equal(t, 2.5, target.Multiplier)
+
+	_, err = NewTarget("hamster", 0.1, time.Time{}, false, now)
+	assert.Error(t, err)
+	_, err = NewTarget(FamilyDog, 0, time.Time{}, false, now)
+	assert.Error(t, err)
+}
+
+func TestNewIntake(t *testing.T) {
+	target := &Target{Kcal: 500}
+	intake := NewIntake(target, []*feed.Feed{
+		{Kcal: 300},
+		{Type: feed.TypeTreat, Kcal: 60},
+		{Type: feed.TypeMeal},
+		{Type: feed.TypeWater, Amount: 100},
+	})
+	assert.Equal(t, 360.0, intake.Kcal)
+	assert.Equal(t, 60.0, intake.TreatKcal)
+	assert.Equal(t, 140.0, intake.Remaining)
+	assert.Equal(t, 72.0, intake.Percent)
+	assert.True(t, intake.TooManyTreats)
+	assert.Equal(t, 1, intake.Uncounted)
+}
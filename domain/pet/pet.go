@@ -8,6 +8,7 @@ import (
 
 	"github.com/aiceru/protonyom/gonyom"
 	"github.com/rs/xid"
+	"ohmnyom/internal/errors"
 )
 
 const (
@@ -16,10 +17,31 @@ const (
 	AdoptedField  = "adopted"
 	FamilyField   = "family"
 	SpeciesField  = "species"
+	// FeedWindowField holds FeedWindowMinutes.
+	FeedWindowField = "feedWindowMinutes"
+	// PreferredUnitField holds PreferredUnit.
+	PreferredUnitField = "preferredUnit"
+	BornField          = "born"
+	NeuteredField      = "neutered"
+	FeedersField       = "feeders"
+	// RolesField holds Roles, keyed by uid.
+	RolesField = "roles"
+	// GrantsField holds Grants, keyed by uid, and GrantsExpireField the
+	// earliest end among them.
+	GrantsField       = "grants"
+	GrantsExpireField = "grantsExpire"
+	HouseholdField    = "householdId"
+
+	// DefaultFeedWindow is the feed window of pets that did not set one.
+	DefaultFeedWindow = 30 * time.Minute
+	MaxFeedWindow     = 24 * time.Hour
+	// MaxGrant limits how long a pet sitter is granted access.
+	MaxGrant = 30 * 24 * time.Hour
 
 	storageSep         = "/"
 	storageDirPet      = "pets"
 	storageDirProfiles = "profiles"
+	storageDirHealth   = "health"
 	StorageRoot        = "ohmnyom"
 )
 
@@ -40,11 +62,275 @@ type Pet struct {
 	Adopted  time.Time `firestore:"adopted"`
 	Family   string    `firestore:"family,omitempty"`
 	Species  string    `firestore:"species,omitempty"`
-	Feeders  []string  `firestore:"feeders,omitempty"`
+	// Feeders lists every member of the pet, whatever the role.
+	Feeders []string `firestore:"feeders,omitempty"`
+	// Roles maps the uid of a member to the name of its role. Pets created
+	// before roles lack it, and RoleOf falls back to the order of Feeders.
+	Roles map[string]string `firestore:"roles,omitempty"`
+	// Grants limits the membership of pet sitters in time, keyed by uid.
+	Grants map[string]*Grant `firestore:"grants,omitempty"`
+	// GrantsExpire is the earliest end of Grants, for the sweep to find the
+	// pet by. Zero when the pet has no grants.
+	GrantsExpire time.Time `firestore:"grantsExpire,omitempty"`
+	// HouseholdId is the household the pet belongs to, whose members take
+	// care of it along with Feeders. Empty when it belongs to none.
+	HouseholdId string `firestore:"householdId,omitempty"`
+	// FeedWindowMinutes is how close two feeds may be before the second one is
+	// taken for a double feeding. Zero takes DefaultFeedWindow, and a negative
+	// value turns the check off.
+	FeedWindowMinutes int `firestore:"feedWindowMinutes,omitempty"`
+	// PreferredUnit is the unit feed stats report amounts in, where they
+	// convert. Empty keeps every feed in its own unit.
+	PreferredUnit string `firestore:"preferredUnit,omitempty"`
+	// Born and Neutered pick the life stage and neuter status of the daily
+	// calorie target. A pet without a birth date is taken for an adult.
+	Born     time.Time `firestore:"born,omitempty"`
+	Neutered bool      `firestore:"neutered,omitempty"`
+}
+
+// FeedWindow returns the window of FeedWindowMinutes, zero when turned off.
+func (p *Pet) FeedWindow() time.Duration {
+	switch {
+	case p.FeedWindowMinutes < 0:
+		return 0
+	case p.FeedWindowMinutes == 0:
+		return DefaultFeedWindow
+	}
+	return time.Duration(p.FeedWindowMinutes) * time.Minute
+}
+
+// Role is what a user is allowed to do with a pet. Higher roles include the
+// permissions of lower ones.
+type Role int
+
+const (
+	RoleNone Role = iota
+	// RoleViewer sees the pet and its records.
+	RoleViewer
+	// RoleCaregiver also logs feeds, weights and health records.
+	RoleCaregiver
+	// RoleOwner also changes, shares and deletes the pet.
+	RoleOwner
+)
+
+var roleNames = map[Role]string{
+	RoleViewer:    "viewer",
+	RoleCaregiver: "caregiver",
+	RoleOwner:     "owner",
+}
+
+func (r Role) String() string {
+	if name, ok := roleNames[r]; ok {
+		return name
+	}
+	return "none"
+}
+
+// ParseRole returns the role named s. RoleNone is not a role a member can
+// have, so it does not parse.
+func ParseRole(s string) (Role, error) {
+	for r, name := range roleNames {
+		if name == s {
+			return r, nil
+		}
+	}
+	return RoleNone, errors.NewInvalidParamError("role: %v", s)
+}
+
+// RoleOf returns the role of uid now.
+func (p *Pet) RoleOf(uid string) Role {
+	return p.RoleAt(uid, time.Now())
+}
+
+// RoleAt returns the role of uid at now. A member missing from Roles has the
+// role it had before roles: the first feeder, who added the pet, owns it and
+// the others are caregivers. A pet sitter only views the pet before its grant
+// starts, and has no role once it ends.
+func (p *Pet) RoleAt(uid string, now time.Time) Role {
+	role := p.memberRole(uid)
+	if g, ok := p.Grants[uid]; ok && role != RoleNone {
+		switch {
+		case g.Expired(now):
+			return RoleNone
+		case now.Before(g.Start) && role > RoleViewer:
+			return RoleViewer
+		}
+	}
+	return role
+}
+
+func (p *Pet) memberRole(uid string) Role {
+	for i, feeder := range p.Feeders {
+		if feeder != uid {
+			continue
+		}
+		if name, ok := p.Roles[uid]; ok {
+			role, _ := ParseRole(name)
+			return role
+		}
+		if i == 0 {
+			return RoleOwner
+		}
+		return RoleCaregiver
+	}
+	return RoleNone
 }
 
+// IsMember tells whether uid is listed among the feeders, whatever its role
+// or grant.
+func (p *Pet) IsMember(uid string) bool {
+	for _, member := range p.Feeders {
+		if member == uid {
+			return true
+		}
+	}
+	return false
+}
+
+// Owners returns the members who own the pet.
+func (p *Pet) Owners() []string {
+	ret := make([]string, 0, 1)
+	for _, uid := range p.Feeders {
+		if p.RoleOf(uid) == RoleOwner {
+			ret = append(ret, uid)
+		}
+	}
+	return ret
+}
+
+// IsLastOwner tells whether uid is the only owner of the pet.
+func (p *Pet) IsLastOwner(uid string) bool {
+	owners := p.Owners()
+	return len(owners) == 1 && owners[0] == uid
+}
+
+// Successor returns the member to hand the pet to when uid leaves, the first
+// caregiver or else the first viewer, or "" when uid is the only member.
+func (p *Pet) Successor(uid string) string {
+	ret := ""
+	for _, member := range p.Feeders {
+		if member == uid {
+			continue
+		}
+		switch p.RoleOf(member) {
+		case RoleOwner, RoleCaregiver:
+			if p.RoleOf(ret) < RoleCaregiver {
+				ret = member
+			}
+		case RoleViewer:
+			if ret == "" {
+				ret = member
+			}
+		}
+	}
+	return ret
+}
+
+// MigrateRoles fills Roles with the role every member has by the order of
+// Feeders, and tells whether it changed anything.
+func (p *Pet) MigrateRoles() bool {
+	changed := false
+	for _, uid := range p.Feeders {
+		if _, ok := p.Roles[uid]; ok {
+			continue
+		}
+		if p.Roles == nil {
+			p.Roles = make(map[string]string, len(p.Feeders))
+		}
+		p.Roles[uid] = p.memberRole(uid).String()
+		changed = true
+	}
+	return changed
+}
+
+// IsUpdatableField tells whether Store.Update may set field. Membership goes
+// through AddMember and DeleteMember instead.
 func IsUpdatableField(field string) bool {
-	return field != "Id"
+	switch field {
+	case "Id", FeedersField, RolesField, GrantsField, GrantsExpireField, HouseholdField:
+		return false
+	}
+	return true
+}
+
+// Grant lets a pet sitter take care of a pet from Start until End.
+type Grant struct {
+	Start     time.Time `firestore:"start"`
+	End       time.Time `firestore:"end"`
+	GrantedBy string    `firestore:"grantedBy"`
+}
+
+// NewGrant returns a grant of at most MaxGrant that has not ended at now.
+func NewGrant(start, end time.Time, grantedBy string, now time.Time) (*Grant, error) {
+	if grantedBy == "" || !start.Before(end) || !now.Before(end) || end.Sub(start) > MaxGrant {
+		return nil, errors.NewInvalidParamError("grant from [%v] until [%v] by [%v]", start, end, grantedBy)
+	}
+	return &Grant{Start: start.UTC(), End: end.UTC(), GrantedBy: grantedBy}, nil
+}
+
+func (g *Grant) Expired(now time.Time) bool {
+	return !now.Before(g.End)
+}
+
+// WithMember returns a copy of the pet with uid a member of it with role.
+func (p *Pet) WithMember(uid string, role Role) *Pet {
+	c := *p
+	c.Feeders = make([]string, 0, len(p.Feeders)+1)
+	c.Feeders = append(c.Feeders, p.Feeders...)
+	if !p.IsMember(uid) {
+		c.Feeders = append(c.Feeders, uid)
+	}
+	c.Roles = make(map[string]string, len(p.Roles)+1)
+	for member, r := range p.Roles {
+		c.Roles[member] = r
+	}
+	c.Roles[uid] = role.String()
+	return &c
+}
+
+// WithoutMember returns a copy of the pet with uid removed from its members.
+func (p *Pet) WithoutMember(uid string) *Pet {
+	c := *p
+	c.Feeders = make([]string, 0, len(p.Feeders))
+	for _, member := range p.Feeders {
+		if member != uid {
+			c.Feeders = append(c.Feeders, member)
+		}
+	}
+	if p.Roles != nil {
+		c.Roles = make(map[string]string, len(p.Roles))
+		for member, role := range p.Roles {
+			if member != uid {
+				c.Roles[member] = role
+			}
+		}
+	}
+	if p.Grants != nil {
+		c.Grants = p.GrantsWithout(uid)
+	}
+	return &c
+}
+
+// GrantsWithout returns the grants of the pet but the one of uid.
+func (p *Pet) GrantsWithout(uid string) map[string]*Grant {
+	ret := make(map[string]*Grant, len(p.Grants))
+	for member, g := range p.Grants {
+		if member != uid {
+			ret[member] = g
+		}
+	}
+	return ret
+}
+
+// GrantsExpire returns the earliest end of grants, zero when there are none.
+func GrantsExpire(grants map[string]*Grant) time.Time {
+	var ret time.Time
+	for _, g := range grants {
+		if ret.IsZero() || g.End.Before(ret) {
+			ret = g.End
+		}
+	}
+	return ret
 }
 
 func NewPetId() string {
@@ -71,14 +357,40 @@ func (p *Pet) ToProto() *gonyom.Pet {
 		Adopted:  p.Adopted.Unix(),
 		Family:   p.Family,
 		Species:  p.Species,
-		Feeders:  p.Feeders,
+		Feeders:  p.Members(time.Now()),
 	}
 }
 
+// Members returns the feeders with a role at now, leaving out the pet sitters
+// whose grant ended but were not swept yet.
+func (p *Pet) Members(now time.Time) []string {
+	if len(p.Grants) == 0 {
+		return p.Feeders
+	}
+	ret := make([]string, 0, len(p.Feeders))
+	for _, uid := range p.Feeders {
+		if p.RoleAt(uid, now) != RoleNone {
+			ret = append(ret, uid)
+		}
+	}
+	return ret
+}
+
+// StorageDir is the prefix of every object stored for the pet.
+func (p *Pet) StorageDir() string {
+	return strings.Join([]string{storageDirPet, p.Id, ""}, storageSep)
+}
+
 func (p *Pet) ProfileDir() string {
 	return strings.Join([]string{storageDirPet, p.Id, storageDirProfiles}, storageSep)
 }
 
+// HealthRecordDir is the prefix of the attachments of a health record of the
+// pet, under StorageDir so they go with the pet.
+func HealthRecordDir(petId, recordId string) string {
+	return strings.Join([]string{storageDirPet, petId, storageDirHealth, recordId, ""}, storageSep)
+}
+
 func (p *Pet) NewProfilePath() string {
 	timeStr := strconv.FormatInt(time.Now().UTC().UnixNano(), 16)
 	return strings.Join([]string{storageDirPet, p.Id, storageDirProfiles, timeStr}, storageSep)
@@ -90,6 +402,12 @@ type Store interface {
 	Put(ctx context.Context, pet *Pet) error
 	Update(ctx context.Context, id string, pathValues map[string]interface{}) error
 	Delete(ctx context.Context, id string) error
-	AddFeeder(ctx context.Context, id, uid string) error
-	DeleteFeeder(ctx context.Context, id, uid string) error
+	// AddMember adds uid to the members of the pet with role, or changes the
+	// role of uid if it is a member already.
+	AddMember(ctx context.Context, id, uid string, role Role) error
+	// DeleteMember removes uid from the members of the pet, and its grant.
+	DeleteMember(ctx context.Context, id, uid string) error
+	GetListOfHousehold(ctx context.Context, householdId string) (List, error)
+	// GetListOfExpiredGrants returns the pets with a grant that ended at now.
+	GetListOfExpiredGrants(ctx context.Context, now time.Time) (List, error)
 }
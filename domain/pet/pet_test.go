@@ -0,0 +1,86 @@
+package pet
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPet_RoleOf(t *testing.T) {
+	legacy := &Pet{Feeders: []string{"user1", "user2"}}
+	assert.Equal(t, RoleOwner, legacy.RoleOf("user1"))
+	assert.Equal(t, RoleCaregiver, legacy.RoleOf("user2"))
+	assert.Equal(t, RoleNone, legacy.RoleOf("user3"))
+
+	p := &Pet{
+		Feeders: []string{"user1", "user2", "user3"},
+		Roles:   map[string]string{"user1": "viewer", "user2": "owner", "user3": "caregiver", "user4": "owner"},
+	}
+	assert.Equal(t, RoleViewer, p.RoleOf("user1"))
+	assert.Equal(t, RoleOwner, p.RoleOf("user2"))
+	assert.Equal(t, RoleCaregiver, p.RoleOf("user3"))
+	// a role without membership is nothing
+	assert.Equal(t, RoleNone, p.RoleOf("user4"))
+	assert.Equal(t, []string{"user2"}, p.Owners())
+	assert.True(t, p.IsLastOwner("user2"))
+	assert.False(t, p.IsLastOwner("user3"))
+}
+
+func TestParseRole(t *testing.T) {
+	for _, r := range []Role{RoleViewer, RoleCaregiver, RoleOwner} {
+		parsed, err := ParseRole(r.String())
+		assert.NoError(t, err)
+		assert.Equal(t, r, parsed)
+	}
+	_, err := ParseRole("none")
+	assert.Error(t, err)
+	_, err = ParseRole("feeder")
+	assert.Error(t, err)
+}
+
+func TestPet_Successor(t *testing.T) {
+	p := &Pet{
+		Feeders: []string{"user1", "user2", "user3"},
+		Roles:   map[string]string{"user1": "owner", "user2": "viewer", "user3": "caregiver"},
+	}
+	assert.Equal(t, "user3", p.Successor("user1"))
+	p.Roles["user3"] = "viewer"
+	assert.Equal(t, "user2", p.Successor("user1"))
+	assert.Equal(t, "", (&Pet{Feeders: []string{"user1"}}).Successor("user1"))
+}
+
+func TestPet_MigrateRoles(t *testing.T) {
+	p := &Pet{Feeders: []string{"user1", "user2"}}
+	assert.True(t, p.MigrateRoles())
+	assert.Equal(t, map[string]string{"user1": "owner", "user2": "caregiver"}, p.Roles)
+	assert.False(t, p.MigrateRoles())
+
+	// a member added after roles keeps its role, the others get theirs
+	p = &Pet{Feeders: []string{"user1", "user2", "user3"}, Roles: map[string]string{"user3": "viewer"}}
+	assert.True(t, p.MigrateRoles())
+	assert.Equal(t, map[string]string{"user1": "owner", "user2": "caregiver", "user3": "viewer"}, p.Roles)
+}
+
+func TestPet_RoleAt(t *testing.T) {
+	now := time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)
+	g, err := NewGrant(now, now.Add(7*24*time.Hour), "user1", now)
+	assert.NoError(t, err)
+	p := &Pet{
+		Feeders: []string{"user1", "user2"},
+		Roles:   map[string]string{"user1": "owner", "user2": "caregiver"},
+		Grants:  map[string]*Grant{"user2": g},
+	}
+	assert.Equal(t, RoleViewer, p.RoleAt("user2", now.Add(-time.Hour)), "before the grant starts")
+	assert.Equal(t, RoleCaregiver, p.RoleAt("user2", now))
+	assert.Equal(t, RoleNone, p.RoleAt("user2", g.End))
+	assert.Equal(t, []string{"user1"}, p.Members(g.End))
+	assert.Equal(t, []string{"user1", "user2"}, p.Members(now))
+
+	_, err = NewGrant(now, now.Add(MaxGrant+time.Hour), "user1", now)
+	assert.Error(t, err)
+	_, err = NewGrant(now.Add(-2*time.Hour), now.Add(-time.Hour), "user1", now)
+	assert.Error(t, err, "ended already")
+	_, err = NewGrant(now, now, "user1", now)
+	assert.Error(t, err)
+}
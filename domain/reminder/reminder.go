@@ -0,0 +1,44 @@
+// Package reminder records the missed-feeding reminders that were sent. Every
+// server instance checks the schedules, and the one that claims a slot first
+// is the only one notifying about it.
+package reminder
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TTL is how long a claim is kept. Slots older than that are never checked
+// again, so the claims can be dropped, e.g. by a firestore TTL policy on Expires.
+const TTL = 7 * 24 * time.Hour
+
+type Reminder struct {
+	Id         string    `firestore:"id"`
+	PetId      string    `firestore:"petId"`
+	ScheduleId string    `firestore:"scheduleId"`
+	SlotAt     time.Time `firestore:"slotAt"`
+	Created    time.Time `firestore:"created"`
+	Expires    time.Time `firestore:"expires"`
+}
+
+// New creates the reminder for the slot of the schedule at slotAt. Reminders of
+// the same slot have the same id.
+func New(petId, scheduleId string, slotAt time.Time) *Reminder {
+	now := time.Now().UTC()
+	return &Reminder{
+		Id:         strings.Join([]string{petId, scheduleId, strconv.FormatInt(slotAt.Unix(), 10)}, "-"),
+		PetId:      petId,
+		ScheduleId: scheduleId,
+		SlotAt:     slotAt,
+		Created:    now,
+		Expires:    now.Add(TTL),
+	}
+}
+
+type Store interface {
+	// Claim stores the reminder and returns true, or returns false when a
+	// reminder with the id was already claimed.
+	Claim(ctx context.Context, reminder *Reminder) (bool, error)
+}
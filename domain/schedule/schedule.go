@@ -0,0 +1,207 @@
+// Package schedule describes when a pet is supposed to eat, and compares that
+// with the feeds that were recorded.
+package schedule
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/rs/xid"
+	"ohmnyom/domain/feed"
+	"ohmnyom/internal/errors"
+)
+
+const (
+	// DefaultToleranceMinutes is how far a feed may be from a slot and still
+	// count for it.
+	DefaultToleranceMinutes = 60
+	MaxToleranceMinutes     = 6 * 60
+	MaxSlots                = 24
+)
+
+// Slot is a time of day, in the time zone of the schedule.
+type Slot struct {
+	Hour   int     `firestore:"hour"`
+	Minute int     `firestore:"minute"`
+	Amount float64 `firestore:"amount,omitempty"`
+	Unit   string  `firestore:"unit,omitempty"`
+}
+
+func (s Slot) minutes() int {
+	return s.Hour*60 + s.Minute
+}
+
+// Schedule repeats its slots on Weekdays, or every day when Weekdays is empty.
+type Schedule struct {
+	Id               string         `firestore:"id"`
+	PetId            string         `firestore:"petId"`
+	Name             string         `firestore:"name,omitempty"`
+	TimeZone         string         `firestore:"timeZone"`
+	Slots            []Slot         `firestore:"slots"`
+	Weekdays         []time.Weekday `firestore:"weekdays,omitempty"`
+	ToleranceMinutes int            `firestore:"toleranceMinutes"`
+	CreatedBy        string         `firestore:"createdBy"`
+	Created          time.Time      `firestore:"created"`
+}
+
+func NewScheduleId() string {
+	return xid.New().String()
+}
+
+// Validate checks the schedule and sorts its slots. A zero ToleranceMinutes
+// takes DefaultToleranceMinutes.
+func (s *Schedule) Validate() error {
+	if s.PetId == "" {
+		return errors.NewInvalidParamError("petId: %v", s.PetId)
+	}
+	if _, err := time.LoadLocation(s.TimeZone); err != nil {
+		return errors.NewInvalidParamError("timeZone: %v", s.TimeZone)
+	}
+	if len(s.Slots) == 0 || len(s.Slots) > MaxSlots {
+		return errors.NewInvalidParamError("%v slots, want 1 to %v", len(s.Slots), MaxSlots)
+	}
+	sort.Slice(s.Slots, func(i, j int) bool {
+		return s.Slots[i].minutes() < s.Slots[j].minutes()
+	})
+	for i, slot := range s.Slots {
+		if slot.Hour < 0 || slot.Hour > 23 || slot.Minute < 0 || slot.Minute > 59 || slot.Amount < 0 {
+			return errors.NewInvalidParamError("slot: %+v", slot)
+		}
+		if i > 0 && slot.minutes() == s.Slots[i-1].minutes() {
+			return errors.NewInvalidParamError("duplicate slot at %02d:%02d", slot.Hour, slot.Minute)
+		}
+	}
+	for _, d := range s.Weekdays {
+		if d < time.Sunday || d > time.Saturday {
+			return errors.NewInvalidParamError("weekday: %v", d)
+		}
+	}
+	if s.ToleranceMinutes == 0 {
+		s.ToleranceMinutes = DefaultToleranceMinutes
+	}
+	if s.ToleranceMinutes < 0 || s.ToleranceMinutes > MaxToleranceMinutes {
+		return errors.NewInvalidParamError("toleranceMinutes: %v", s.ToleranceMinutes)
+	}
+	return nil
+}
+
+func (s *Schedule) Tolerance() time.Duration {
+	return time.Duration(s.ToleranceMinutes) * time.Minute
+}
+
+func (s *Schedule) onWeekday(d time.Weekday) bool {
+	if len(s.Weekdays) == 0 {
+		return true
+	}
+	for _, w := range s.Weekdays {
+		if w == d {
+			return true
+		}
+	}
+	return false
+}
+
+type Occurrence struct {
+	At   time.Time
+	Slot Slot
+}
+
+// Occurrences returns the slots in [from, to), in order.
+func (s *Schedule) Occurrences(from, to time.Time) ([]Occurrence, error) {
+	loc, err := time.LoadLocation(s.TimeZone)
+	if err != nil {
+		return nil, errors.NewInvalidParamError("timeZone: %v", s.TimeZone)
+	}
+	ret := make([]Occurrence, 0)
+	y, m, d := from.In(loc).Date()
+	for day := time.Date(y, m, d, 0, 0, 0, 0, loc); day.Before(to); day = day.AddDate(0, 0, 1) {
+		if !s.onWeekday(day.Weekday()) {
+			continue
+		}
+		for _, slot := range s.Slots {
+			at := time.Date(day.Year(), day.Month(), day.Day(), slot.Hour, slot.Minute, 0, 0, loc)
+			if !at.Before(from) && at.Before(to) {
+				ret = append(ret, Occurrence{At: at, Slot: slot})
+			}
+		}
+	}
+	return ret, nil
+}
+
+type Status string
+
+const (
+	StatusFed      Status = "fed"
+	StatusMissed   Status = "missed"
+	StatusUpcoming Status = "upcoming"
+)
+
+type SlotStatus struct {
+	Occurrence
+	Status Status
+	// Feed is the feed that counted for the slot, if it was fed.
+	Feed *feed.Feed
+}
+
+// Check reports every slot in [from, to) as of now. A slot is fed by the
+// closest unused meal within the tolerance, missed once the tolerance passed
+// without one, and upcoming otherwise. Treats, water and medication feed no
+// slot. Pass feeds from at least one tolerance
+// before from until one tolerance after to.
+func (s *Schedule) Check(feeds []*feed.Feed, from, to, now time.Time) ([]*SlotStatus, error) {
+	occurrences, err := s.Occurrences(from, to)
+	if err != nil {
+		return nil, err
+	}
+	tolerance := s.Tolerance()
+	used := make(map[string]bool)
+	ret := make([]*SlotStatus, len(occurrences))
+	for i, o := range occurrences {
+		at := o.At
+		var best *feed.Feed
+		for _, f := range feeds {
+			if used[f.Id] || f.TypeOrMeal() != feed.TypeMeal || absDuration(f.Timestamp.Sub(at)) > tolerance {
+				continue
+			}
+			if best == nil || absDuration(f.Timestamp.Sub(at)) < absDuration(best.Timestamp.Sub(at)) {
+				best = f
+			}
+		}
+
+		status := &SlotStatus{Occurrence: o}
+		switch {
+		case best != nil:
+			used[best.Id] = true
+			status.Status = StatusFed
+			status.Feed = best
+		case now.After(at.Add(tolerance)):
+			status.Status = StatusMissed
+		default:
+			status.Status = StatusUpcoming
+		}
+		ret[i] = status
+	}
+	return ret, nil
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
+
+type Store interface {
+	Get(ctx context.Context, petId, id string) (*Schedule, error)
+	GetListOfPet(ctx context.Context, petId string) ([]*Schedule, error)
+	// GetAll returns the schedules of every pet.
+	GetAll(ctx context.Context) ([]*Schedule, error)
+	Put(ctx context.Context, schedule *Schedule) error
+	// Update replaces the stored schedule with the same pet and id.
+	Update(ctx context.Context, schedule *Schedule) error
+	Delete(ctx context.Context, petId, id string) error
+	// DeleteAllOfPet deletes every schedule of the pet and returns how many
+	// there were.
+	DeleteAllOfPet(ctx context.Context, petId string) (int, error)
+}
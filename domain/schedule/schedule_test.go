@@ -0,0 +1,85 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"ohmnyom/domain/feed"
+)
+
+func TestSchedule_Validate(t *testing.T) {
+	valid := func() *Schedule {
+		return &Schedule{PetId: "pet1", TimeZone: "UTC", Slots: []Slot{{Hour: 19}, {Hour: 8}}}
+	}
+	s := valid()
+	assert.NoError(t, s.Validate())
+	assert.Equal(t, 8, s.Slots[0].Hour)
+	assert.Equal(t, DefaultToleranceMinutes, s.ToleranceMinutes)
+
+	tests := []struct {
+		name   string
+		modify func(s *Schedule)
+	}{
+		{"no pet", func(s *Schedule) { s.PetId = "" }},
+		{"unknown time zone", func(s *Schedule) { s.TimeZone = "Nowhere/City" }},
+		{"no slots", func(s *Schedule) { s.Slots = nil }},
+		{"hour out of range", func(s *Schedule) { s.Slots[0].Hour = 24 }},
+		{"minute out of range", func(s *Schedule) { s.Slots[0].Minute = -1 }},
+		{"duplicate slot", func(s *Schedule) { s.Slots[1].Hour = 19 }},
+		{"negative amount", func(s *Schedule) { s.Slots[0].Amount = -1 }},
+		{"bad weekday", func(s *Schedule) { s.Weekdays = []time.Weekday{7} }},
+		{"tolerance too long", func(s *Schedule) { s.ToleranceMinutes = MaxToleranceMinutes + 1 }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := valid()
+			tt.modify(s)
+			assert.Error(t, s.Validate())
+		})
+	}
+}
+
+func TestSchedule_Check(t *testing.T) {
+	s := &Schedule{
+		PetId:    "pet1",
+		TimeZone: "Asia/Seoul",
+		Slots:    []Slot{{Hour: 8, Amount: 50, Unit: "g"}, {Hour: 19, Amount: 50, Unit: "g"}},
+		// Tuesday and Wednesday only
+		Weekdays: []time.Weekday{time.Tuesday, time.Wednesday},
+	}
+	assert.NoError(t, s.Validate())
+	seoul, _ := time.LoadLocation("Asia/Seoul")
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2022, 3, day, hour, minute, 0, 0, seoul)
+	}
+
+	feeds := []*feed.Feed{
+		{Id: "early", Timestamp: at(1, 7, 20)},
+		{Id: "close", Timestamp: at(1, 7, 50)},
+		// closer still, but not a meal
+		{Id: "treat", Timestamp: at(1, 8, 0), Type: feed.TypeTreat},
+		{Id: "water", Timestamp: at(1, 19, 0), Type: feed.TypeWater},
+		{Id: "late", Timestamp: at(1, 20, 30)},
+		{Id: "wednesday", Timestamp: at(2, 8, 10)},
+	}
+	// from Monday Feb 28 to Thursday Mar 3, only Tue 1st and Wed 2nd have slots
+	from := time.Date(2022, 2, 28, 0, 0, 0, 0, seoul)
+	statuses, err := s.Check(feeds, from, at(4, 0, 0), at(2, 19, 30))
+	assert.NoError(t, err)
+	if !assert.Len(t, statuses, 4) {
+		return
+	}
+
+	assert.Equal(t, StatusFed, statuses[0].Status)
+	assert.Equal(t, "close", statuses[0].Feed.Id)
+	assert.Equal(t, 50.0, statuses[0].Slot.Amount)
+	// 20:30 is further than the hour of tolerance from 19:00
+	assert.Equal(t, StatusMissed, statuses[1].Status)
+	assert.Nil(t, statuses[1].Feed)
+	assert.Equal(t, StatusFed, statuses[2].Status)
+	assert.Equal(t, "wednesday", statuses[2].Feed.Id)
+	// 19:00 on the 2nd is within the tolerance at 19:30
+	assert.Equal(t, StatusUpcoming, statuses[3].Status)
+	assert.Equal(t, at(2, 19, 0), statuses[3].At)
+}
@@ -0,0 +1,62 @@
+package token
+
+import (
+	"context"
+	"time"
+
+	"github.com/rs/xid"
+)
+
+// Family tracks the refresh tokens rotated from one sign in. Only Current may
+// be exchanged; presenting an older token means it leaked, and the whole family
+// is revoked.
+type Family struct {
+	Id      string    `firestore:"id"`
+	Uid     string    `firestore:"uid"`
+	Current string    `firestore:"current"`
+	Revoked bool      `firestore:"revoked"`
+	Created time.Time `firestore:"created"`
+	Expires time.Time `firestore:"expires"`
+}
+
+func NewFamily(uid, current string, ttl time.Duration) *Family {
+	now := time.Now().UTC()
+	return &Family{
+		Id:      xid.New().String(),
+		Uid:     uid,
+		Current: current,
+		Created: now,
+		Expires: now.Add(ttl),
+	}
+}
+
+// Revocation is an entry of the revocation list. It can be dropped after
+// Expires, when the token is rejected for being expired anyway.
+type Revocation struct {
+	Id      string    `firestore:"id"`
+	Uid     string    `firestore:"uid"`
+	Expires time.Time `firestore:"expires"`
+}
+
+type Generation struct {
+	Uid        string `firestore:"uid"`
+	Generation int64  `firestore:"generation"`
+}
+
+type Store interface {
+	Get(ctx context.Context, id string) (*Family, error)
+	Put(ctx context.Context, family *Family) error
+	// Rotate replaces presented with next as the current token of the family.
+	// If presented is not current, the family is revoked and an
+	// AuthenticationError is returned.
+	Rotate(ctx context.Context, id, presented, next string, expires time.Time) error
+	Revoke(ctx context.Context, id string) error
+
+	// RevokeToken puts the token id on the revocation list until expires.
+	RevokeToken(ctx context.Context, id, uid string, expires time.Time) error
+	IsTokenRevoked(ctx context.Context, id string) (bool, error)
+	// GetGeneration returns the token generation of uid, zero if never bumped.
+	GetGeneration(ctx context.Context, uid string) (int64, error)
+	// BumpGeneration increments the token generation of uid and returns the new one.
+	BumpGeneration(ctx context.Context, uid string) (int64, error)
+}
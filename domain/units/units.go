@@ -0,0 +1,133 @@
+// Package units is the registry of the units feed amounts are measured in.
+// Every unit has a canonical symbol and a few aliases clients send, so "gram",
+// "grams" and "그램" all end up as "g".
+package units
+
+import (
+	"strings"
+
+	"ohmnyom/internal/errors"
+)
+
+// Dimension tells what a unit measures. Units convert within a dimension, and
+// between mass and volume given a density.
+type Dimension string
+
+const (
+	DimensionMass   Dimension = "mass"
+	DimensionVolume Dimension = "volume"
+	DimensionCount  Dimension = "count"
+)
+
+const (
+	Gram       = "g"
+	Kilogram   = "kg"
+	Milligram  = "mg"
+	Ounce      = "oz"
+	Pound      = "lb"
+	Milliliter = "ml"
+	Liter      = "l"
+	Cup        = "cup"
+	Tablespoon = "tbsp"
+	Teaspoon   = "tsp"
+	Piece      = "piece"
+	Can        = "can"
+	Pouch      = "pouch"
+	Scoop      = "scoop"
+)
+
+type Unit struct {
+	Symbol    string
+	Dimension Dimension
+	// factor is the unit in grams for mass and in millilitres for volume.
+	// Count units do not convert into each other.
+	factor float64
+}
+
+var registry = map[string]*Unit{
+	Gram:       {Gram, DimensionMass, 1},
+	Kilogram:   {Kilogram, DimensionMass, 1000},
+	Milligram:  {Milligram, DimensionMass, 0.001},
+	Ounce:      {Ounce, DimensionMass, 28.349523125},
+	Pound:      {Pound, DimensionMass, 453.59237},
+	Milliliter: {Milliliter, DimensionVolume, 1},
+	Liter:      {Liter, DimensionVolume, 1000},
+	// the US customary cup pet food labels measure in
+	Cup:        {Cup, DimensionVolume, 236.5882365},
+	Tablespoon: {Tablespoon, DimensionVolume, 14.78676478125},
+	Teaspoon:   {Teaspoon, DimensionVolume, 4.92892159375},
+	Piece:      {Piece, DimensionCount, 1},
+	Can:        {Can, DimensionCount, 1},
+	Pouch:      {Pouch, DimensionCount, 1},
+	Scoop:      {Scoop, DimensionCount, 1},
+}
+
+var aliases = map[string]string{
+	"gram": Gram, "grams": Gram, "gr": Gram, "그램": Gram,
+	"kilogram": Kilogram, "kilograms": Kilogram, "킬로그램": Kilogram,
+	"milligram": Milligram, "milligrams": Milligram, "밀리그램": Milligram,
+	"ounce": Ounce, "ounces": Ounce, "온스": Ounce,
+	"lbs": Pound, "pound": Pound, "pounds": Pound, "파운드": Pound,
+	"milliliter": Milliliter, "milliliters": Milliliter, "millilitre": Milliliter, "millilitres": Milliliter,
+	"cc": Milliliter, "밀리리터": Milliliter,
+	"liter": Liter, "liters": Liter, "litre": Liter, "litres": Liter, "리터": Liter,
+	"cups": Cup, "컵": Cup,
+	"tablespoon": Tablespoon, "tablespoons": Tablespoon, "큰술": Tablespoon,
+	"teaspoon": Teaspoon, "teaspoons": Teaspoon, "작은술": Teaspoon,
+	"pieces": Piece, "pc": Piece, "pcs": Piece, "ea": Piece, "개": Piece,
+	"cans": Can, "캔": Can,
+	"pouches": Pouch, "파우치": Pouch,
+	"scoops": Scoop, "스쿱": Scoop,
+}
+
+// Lookup returns the unit named by s, a symbol or an alias in any case.
+func Lookup(s string) (*Unit, error) {
+	name := strings.ToLower(strings.TrimSpace(s))
+	if symbol, ok := aliases[name]; ok {
+		name = symbol
+	}
+	u, ok := registry[name]
+	if !ok {
+		return nil, errors.NewInvalidParamError("unknown unit [%v]", s)
+	}
+	return u, nil
+}
+
+// Normalize returns the symbol of the unit named by s. An empty s stays empty,
+// for feeds without an amount.
+func Normalize(s string) (string, error) {
+	if strings.TrimSpace(s) == "" {
+		return "", nil
+	}
+	u, err := Lookup(s)
+	if err != nil {
+		return "", err
+	}
+	return u.Symbol, nil
+}
+
+// Convert returns amount in from as an amount in to. Mass and volume convert
+// with density, in grams per millilitre; a density of zero leaves them apart.
+func Convert(amount float64, from, to string, density float64) (float64, error) {
+	src, err := Lookup(from)
+	if err != nil {
+		return 0, err
+	}
+	dst, err := Lookup(to)
+	if err != nil {
+		return 0, err
+	}
+	if src == dst {
+		return amount, nil
+	}
+	switch {
+	case src.Dimension == DimensionCount || dst.Dimension == DimensionCount:
+	case src.Dimension == dst.Dimension:
+		return amount * src.factor / dst.factor, nil
+	case density > 0 && src.Dimension == DimensionVolume:
+		return amount * src.factor * density / dst.factor, nil
+	case density > 0 && src.Dimension == DimensionMass:
+		return amount * src.factor / density / dst.factor, nil
+	}
+	return 0, errors.NewInvalidParamError("cannot convert %v to %v", src.Symbol, dst.Symbol)
+}
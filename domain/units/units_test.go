@@ -0,0 +1,63 @@
+package units
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"g", Gram},
+		{" Grams ", Gram},
+		{"그램", Gram},
+		{"CUP", Cup},
+		{"컵", Cup},
+		{"pcs", Piece},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := Normalize(tt.in)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+	_, err := Normalize("handful")
+	assert.Error(t, err)
+}
+
+func TestConvert(t *testing.T) {
+	tests := []struct {
+		name     string
+		amount   float64
+		from, to string
+		density  float64
+		want     float64
+	}{
+		{"same unit", 3, Cup, "cups", 0, 3},
+		{"mass", 1.5, Kilogram, Gram, 0, 1500},
+		{"volume", 2, Liter, Milliliter, 0, 2000},
+		{"cups to grams", 1, Cup, Gram, 0.5, 118.29411825},
+		{"grams to cups", 118.29411825, Gram, Cup, 0.5, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Convert(tt.amount, tt.from, tt.to, tt.density)
+			assert.NoError(t, err)
+			assert.InDelta(t, tt.want, got, 1e-9)
+		})
+	}
+
+	_, err := Convert(1, Cup, Gram, 0)
+	assert.Error(t, err)
+	_, err = Convert(1, Can, Piece, 0)
+	assert.Error(t, err)
+	_, err = Convert(1, Piece, Gram, 1)
+	assert.Error(t, err)
+	_, err = Convert(1, "handful", Gram, 1)
+	assert.Error(t, err)
+}
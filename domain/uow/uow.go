@@ -0,0 +1,105 @@
+// Package uow lets a server change several user, pet, household and invite
+// documents atomically, along with the audit events of the change.
+package uow
+
+import (
+	"context"
+
+	"ohmnyom/domain/audit"
+	"ohmnyom/domain/deletion"
+	"ohmnyom/domain/household"
+	"ohmnyom/domain/invite"
+	"ohmnyom/domain/pet"
+	"ohmnyom/domain/user"
+)
+
+// Tx reads and writes documents in one transaction. As in firestore, every
+// read has to come before the first write.
+type Tx interface {
+	GetUser(id string) (*user.User, error)
+	GetPet(id string) (*pet.Pet, error)
+	GetInvite(code string) (*invite.Invite, error)
+	GetHousehold(id string) (*household.Household, error)
+
+	DeleteUser(id string) error
+	AddUserPet(id, petId string) error
+	DeleteUserPet(id, petId string) error
+	CreatePet(p *pet.Pet) error
+	DeletePet(id string) error
+	// AddMember adds uid to the members of the pet with role, or changes the
+	// role of uid if it is a member already.
+	AddMember(petId, uid string, role pet.Role) error
+	// DeleteMember removes uid from the members of the pet, and its grant.
+	DeleteMember(petId, uid string) error
+	// SetGrants replaces the grants of the pet sitters of the pet.
+	SetGrants(petId string, grants map[string]*pet.Grant) error
+	// SetPetHousehold moves the pet to the household, or out of any with an
+	// empty householdId.
+	SetPetHousehold(petId, householdId string) error
+	CreateHousehold(h *household.Household) error
+	DeleteHousehold(id string) error
+	AddHouseholdMember(id, uid string) error
+	DeleteHouseholdMember(id, uid string) error
+	// UseInvite records uid as a use of the invite. Check it with
+	// invite.CheckRedeemable first.
+	UseInvite(code, uid string) error
+	// SaveDeletionJob records the cleanup that has to follow a DeleteUser or a
+	// DeletePet, so it is not lost if the server stops before running it.
+	SaveDeletionJob(job *deletion.Job) error
+	// SaveAuditEvent records the change the transaction makes, so the log has
+	// exactly the changes that were committed.
+	SaveAuditEvent(event *audit.Event) error
+}
+
+type UnitOfWork interface {
+	// Run runs fn in a transaction and commits its writes if fn returns nil.
+	// fn may be run more than once on contention, so it must not have other side
+	// effects.
+	Run(ctx context.Context, fn func(ctx context.Context, tx Tx) error) error
+}
+
+// Join makes uid a member of petId with role on both documents.
+func Join(tx Tx, uid, petId string, role pet.Role) error {
+	if err := tx.AddUserPet(uid, petId); err != nil {
+		return err
+	}
+	return tx.AddMember(petId, uid, role)
+}
+
+// MakeOwner makes uid an owner of p, read in the transaction. An owner stays
+// for good, so a pet sitter made one loses its grant, which would otherwise
+// leave the pet without an owner when it ends.
+func MakeOwner(tx Tx, p *pet.Pet, uid string) error {
+	if err := tx.AddMember(p.Id, uid, pet.RoleOwner); err != nil {
+		return err
+	}
+	if _, ok := p.Grants[uid]; !ok {
+		return nil
+	}
+	return tx.SetGrants(p.Id, p.GrantsWithout(uid))
+}
+
+// Leave removes uid from the members of petId on both documents.
+func Leave(tx Tx, uid, petId string) error {
+	if err := tx.DeleteUserPet(uid, petId); err != nil {
+		return err
+	}
+	return tx.DeleteMember(petId, uid)
+}
+
+// LeaveHousehold removes uid from the household. The last member to leave
+// deletes it, and its pets, read in the transaction, belong to none then.
+func LeaveHousehold(tx Tx, h *household.Household, uid string, pets []*pet.Pet) error {
+	if len(h.Members) > 1 || !h.IsMember(uid) {
+		return tx.DeleteHouseholdMember(h.Id, uid)
+	}
+	for _, p := range pets {
+		if p.HouseholdId != h.Id {
+			continue
+		}
+		if err := tx.SetPetHousehold(p.Id, ""); err != nil {
+			return err
+		}
+	}
+	return tx.DeleteHousehold(h.Id)
+}
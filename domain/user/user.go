@@ -98,6 +98,11 @@ func (u *User) HasPet(petId string) bool {
 	return false
 }
 
+// StorageDir is the prefix of every object stored for the user.
+func (u *User) StorageDir() string {
+	return strings.Join([]string{storageDirUser, u.Id, ""}, storageSep)
+}
+
 func (u *User) ProfileDir() string {
 	return strings.Join([]string{storageDirUser, u.Id, storageDirProfiles}, storageSep)
 }
@@ -0,0 +1,78 @@
+package weight
+
+import (
+	"sort"
+	"time"
+
+	"ohmnyom/internal/errors"
+)
+
+// Point is a measurement with the moving average of the window ending at it.
+type Point struct {
+	Timestamp     time.Time
+	Value         float64
+	MovingAverage float64
+}
+
+// Trend is how the weight of a pet moved over [From, To), in Unit.
+type Trend struct {
+	From   time.Time
+	To     time.Time
+	Unit   string
+	Window time.Duration
+	Points []*Point
+	// Change is the difference of the last moving average from the first, and
+	// ChangePercent the same relative to the first. Both are zero with fewer
+	// than two points.
+	Change        float64
+	ChangePercent float64
+}
+
+// NewTrend works out the trend of the weights in [from, to) in unit. The moving
+// average of a point takes the measurements in the window ending at it, so
+// weights recorded before from should be passed to start the average.
+func NewTrend(weights []*Weight, from, to time.Time, unit string, window time.Duration) (*Trend, error) {
+	if !from.Before(to) {
+		return nil, errors.NewInvalidParamError("from: %v, to: %v", from, to)
+	}
+	if window <= 0 {
+		window = DefaultWindow
+	}
+	sorted := make([]*Weight, len(weights))
+	copy(sorted, weights)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Timestamp.Before(sorted[j].Timestamp)
+	})
+	values := make([]float64, len(sorted))
+	for i, w := range sorted {
+		v, err := w.In(unit)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = v
+	}
+
+	trend := &Trend{From: from, To: to, Unit: unit, Window: window, Points: make([]*Point, 0)}
+	first, sum := 0, 0.0
+	for i, w := range sorted {
+		sum += values[i]
+		for !sorted[first].Timestamp.After(w.Timestamp.Add(-window)) {
+			sum -= values[first]
+			first++
+		}
+		if w.Timestamp.Before(from) || !w.Timestamp.Before(to) {
+			continue
+		}
+		trend.Points = append(trend.Points, &Point{
+			Timestamp:     w.Timestamp,
+			Value:         values[i],
+			MovingAverage: sum / float64(i-first+1),
+		})
+	}
+	if n := len(trend.Points); n > 1 {
+		start, end := trend.Points[0].MovingAverage, trend.Points[n-1].MovingAverage
+		trend.Change = end - start
+		trend.ChangePercent = trend.Change / start * 100
+	}
+	return trend, nil
+}
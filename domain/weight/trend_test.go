@@ -0,0 +1,53 @@
+package weight
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewTrend(t *testing.T) {
+	day := time.Date(2022, 3, 1, 9, 0, 0, 0, time.UTC)
+	at := func(days int) time.Time {
+		return day.AddDate(0, 0, days)
+	}
+	weights := []*Weight{
+		{Timestamp: at(6), Value: 4600, Unit: "g"},
+		{Timestamp: at(-2), Value: 4, Unit: "kg"},
+		{Timestamp: at(0), Value: 4.2, Unit: "kg"},
+		{Timestamp: at(2), Value: 4.4, Unit: "kg"},
+	}
+
+	trend, err := NewTrend(weights, day, at(7), "kg", 3*24*time.Hour)
+	assert.NoError(t, err)
+	if assert.Len(t, trend.Points, 3) {
+		// the weight before from still counts for the first average
+		assert.InDelta(t, 4.1, trend.Points[0].MovingAverage, 1e-9)
+		assert.InDelta(t, 4.3, trend.Points[1].MovingAverage, 1e-9)
+		assert.InDelta(t, 4.6, trend.Points[2].Value, 1e-9)
+		assert.InDelta(t, 4.6, trend.Points[2].MovingAverage, 1e-9)
+	}
+	assert.InDelta(t, 0.5, trend.Change, 1e-9)
+	assert.InDelta(t, 0.5/4.1*100, trend.ChangePercent, 1e-9)
+
+	trend, err = NewTrend(weights[:1], day, at(7), "kg", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultWindow, trend.Window)
+	assert.Zero(t, trend.ChangePercent)
+
+	_, err = NewTrend(weights, at(7), day, "kg", 0)
+	assert.Error(t, err)
+	_, err = NewTrend([]*Weight{{Timestamp: day, Value: 1, Unit: "cup"}}, day, at(1), "kg", 0)
+	assert.Error(t, err)
+}
+
+func TestWeight_Validate(t *testing.T) {
+	w := &Weight{PetId: "pet1", Timestamp: time.Now(), Value: 9.5, Unit: "Pounds"}
+	assert.NoError(t, w.Validate())
+	assert.Equal(t, "lb", w.Unit)
+
+	assert.Error(t, (&Weight{PetId: "pet1", Timestamp: time.Now(), Value: 1, Unit: "cup"}).Validate())
+	assert.Error(t, (&Weight{PetId: "pet1", Timestamp: time.Now(), Value: 0, Unit: "kg"}).Validate())
+	assert.Error(t, (&Weight{PetId: "pet1", Value: 1, Unit: "kg"}).Validate())
+}
@@ -0,0 +1,71 @@
+// Package weight records the body weight of pets and works out its trend.
+package weight
+
+import (
+	"context"
+	"time"
+
+	"github.com/rs/xid"
+	"ohmnyom/domain/units"
+	"ohmnyom/internal/errors"
+)
+
+// DefaultWindow is the moving average window of a trend that did not ask for
+// one. A week smooths out a meal or a drink before weighing.
+const DefaultWindow = 7 * 24 * time.Hour
+
+// Weight is a measurement of a pet, in any mass unit.
+type Weight struct {
+	Id         string    `firestore:"id"`
+	PetId      string    `firestore:"petId"`
+	Timestamp  time.Time `firestore:"timestamp"`
+	Value      float64   `firestore:"value"`
+	Unit       string    `firestore:"unit"`
+	Note       string    `firestore:"note,omitempty"`
+	RecordedBy string    `firestore:"recordedBy"`
+	Created    time.Time `firestore:"created"`
+}
+
+func NewWeightId() string {
+	return xid.NewWithTime(time.Now().UTC()).String()
+}
+
+// Validate checks the weight and normalizes its unit, which has to be a mass.
+func (w *Weight) Validate() error {
+	if w.PetId == "" || w.Timestamp.IsZero() {
+		return errors.NewInvalidParamError("petId [%v], timestamp [%v]", w.PetId, w.Timestamp)
+	}
+	if w.Value <= 0 {
+		return errors.NewInvalidParamError("value [%v]", w.Value)
+	}
+	u, err := units.Lookup(w.Unit)
+	if err != nil {
+		return err
+	}
+	if u.Dimension != units.DimensionMass {
+		return errors.NewInvalidParamError("unit [%v] is not a mass", w.Unit)
+	}
+	w.Unit = u.Symbol
+	return nil
+}
+
+// In returns the weight in unit.
+func (w *Weight) In(unit string) (float64, error) {
+	return units.Convert(w.Value, w.Unit, unit, 0)
+}
+
+type Store interface {
+	Get(ctx context.Context, petId, id string) (*Weight, error)
+	// GetListOfPet returns the weights in [from, to), oldest first.
+	GetListOfPet(ctx context.Context, petId string, from, to time.Time) ([]*Weight, error)
+	// GetLatest returns the weight measured last, NotFoundError if the pet has
+	// none.
+	GetLatest(ctx context.Context, petId string) (*Weight, error)
+	Put(ctx context.Context, weight *Weight) error
+	// Update replaces the stored weight with the same pet and id.
+	Update(ctx context.Context, weight *Weight) error
+	Delete(ctx context.Context, petId, id string) error
+	// DeleteBatchOfPet deletes up to limit weights of the pet and returns how
+	// many it deleted. Fewer than limit means none are left.
+	DeleteBatchOfPet(ctx context.Context, petId string, limit int) (int, error)
+}
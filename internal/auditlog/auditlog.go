@@ -0,0 +1,79 @@
+// Package auditlog writes the audit log of the changes RPCs make to users,
+// pets and feeds. Its decorators wrap the stores and the unit of work the
+// servers change them through, so that no server logs its own changes.
+package auditlog
+
+import (
+	"context"
+	"log"
+
+	"google.golang.org/grpc"
+	"ohmnyom/domain/audit"
+	"ohmnyom/domain/pet"
+	"ohmnyom/domain/user"
+)
+
+// redacted replaces secrets in the snapshots of users.
+const redacted = "[redacted]"
+
+// newEvent returns the event of the change ctx makes with op, nil when nobody
+// is signed in to ctx and the change is not to an account. Changes to an
+// account made signed out, as a sign up is, are made by the account itself.
+// The method of an RPC that is not served over grpc falls back to op.
+func newEvent(ctx context.Context, op, petId, userId string, before, after interface{}) *audit.Event {
+	actor, _ := ctx.Value(user.CtxKeyUid).(string)
+	if actor == "" {
+		actor = userId
+	}
+	if actor == "" {
+		return nil
+	}
+	method, ok := grpc.Method(ctx)
+	if !ok || method == "" {
+		method = op
+	}
+	event, err := audit.NewEvent(actor, method, before, after)
+	if err != nil {
+		log.Printf("cannot audit %v: %v", method, err)
+		return nil
+	}
+	event.PetId = petId
+	event.UserId = userId
+	return event
+}
+
+// userSnapshot returns a copy of u fit for the log, nil for nil.
+func userSnapshot(u *user.User) *user.User {
+	if u == nil {
+		return nil
+	}
+	c := *u
+	if c.Password != "" {
+		c.Password = redacted
+	}
+	c.OAuthInfo = nil
+	c.Pets = append([]string(nil), u.Pets...)
+	return &c
+}
+
+// petSnapshot returns a copy of p, nil for nil.
+func petSnapshot(p *pet.Pet) *pet.Pet {
+	if p == nil {
+		return nil
+	}
+	c := *p
+	c.Feeders = append([]string(nil), p.Feeders...)
+	if p.Roles != nil {
+		c.Roles = make(map[string]string, len(p.Roles))
+		for uid, role := range p.Roles {
+			c.Roles[uid] = role
+		}
+	}
+	if p.Grants != nil {
+		c.Grants = make(map[string]*pet.Grant, len(p.Grants))
+		for uid, g := range p.Grants {
+			c.Grants[uid] = g
+		}
+	}
+	return &c
+}
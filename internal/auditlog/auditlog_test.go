@@ -0,0 +1,113 @@
+package auditlog
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"ohmnyom/domain/audit"
+	"ohmnyom/domain/feed"
+	"ohmnyom/domain/pet"
+	"ohmnyom/domain/uow"
+	"ohmnyom/domain/user"
+	"ohmnyom/internal/errors"
+	"ohmnyom/internal/memstore"
+)
+
+func TestUnitOfWork(t *testing.T) {
+	ctx := context.TODO()
+	users := memstore.NewUserStore()
+	pets := memstore.NewPetStore()
+	audits := memstore.NewAuditStore()
+	assert.NoError(t, users.Put(ctx, &user.User{Id: "owner", Pets: []string{"pet1"}}))
+	assert.NoError(t, users.Put(ctx, &user.User{Id: "sitter"}))
+	assert.NoError(t, pets.Put(ctx, &pet.Pet{Id: "pet1", Feeders: []string{"owner"}}))
+	u := UnitOfWork(memstore.NewUnitOfWork(users, pets, memstore.NewInviteStore(), memstore.NewDeletionStore(),
+		audits, memstore.NewHouseholdStore()))
+
+	// without anybody signed in, only changes to accounts are logged
+	assert.NoError(t, u.Run(ctx, func(ctx context.Context, tx uow.Tx) error {
+		return tx.DeleteMember("pet1", "nobody")
+	}))
+	events, err := audits.GetListOfPet(ctx, "pet1", audit.Cursor{}, 0)
+	assert.NoError(t, err)
+	assert.Empty(t, events)
+
+	actor := context.WithValue(ctx, user.CtxKeyUid, "owner")
+	assert.NoError(t, u.Run(actor, func(ctx context.Context, tx uow.Tx) error {
+		if _, err := tx.GetPet("pet1"); err != nil {
+			return err
+		}
+		// the user is not read, so its change has no snapshots
+		return uow.Join(tx, "sitter", "pet1", pet.RoleViewer)
+	}))
+	events, err = audits.GetListOfPet(ctx, "pet1", audit.Cursor{}, 0)
+	assert.NoError(t, err)
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, "owner", events[0].Actor)
+		assert.Equal(t, "pet.AddMember", events[0].Method)
+		assert.NotContains(t, events[0].Before, "sitter")
+		assert.Contains(t, events[0].After, `"sitter":"viewer"`)
+	}
+	events, err = audits.GetListOfUser(ctx, "sitter", audit.Cursor{}, 0)
+	assert.NoError(t, err)
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, "user.AddPet", events[0].Method)
+		assert.Empty(t, events[0].Before)
+		assert.Empty(t, events[0].After)
+	}
+}
+
+func TestUserStore(t *testing.T) {
+	ctx := context.TODO()
+	audits := memstore.NewAuditStore()
+	users := UserStore(memstore.NewUserStore(), audits)
+
+	// a sign up is made by the account itself
+	assert.NoError(t, users.Put(ctx, &user.User{Id: "new", Password: "hashed"}))
+	events, err := audits.GetListOfUser(ctx, "new", audit.Cursor{}, 0)
+	assert.NoError(t, err)
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, "new", events[0].Actor)
+		assert.Equal(t, "user.Put", events[0].Method)
+		assert.NotContains(t, events[0].After, "hashed")
+	}
+}
+
+// failingAudits cannot write events while fail is set.
+type failingAudits struct {
+	audit.Store
+	fail bool
+}
+
+func (a *failingAudits) Put(ctx context.Context, event *audit.Event) error {
+	if a.fail {
+		return errors.NewInternalError("audit store down")
+	}
+	return a.Store.Put(ctx, event)
+}
+
+func TestFeedStore_Delete(t *testing.T) {
+	ctx := context.WithValue(context.TODO(), user.CtxKeyUid, "owner")
+	audits := &failingAudits{Store: memstore.NewAuditStore()}
+	feeds := FeedStore(memstore.NewFeedStore(), audits)
+	assert.NoError(t, feeds.Put(ctx, &feed.Feed{Id: "feed1", PetId: "pet1", Timestamp: time.Now()}))
+
+	// a delete that cannot be logged is not made
+	audits.fail = true
+	assert.Error(t, feeds.Delete(ctx, "pet1", "feed1"))
+	_, err := feeds.Get(ctx, "pet1", "feed1")
+	assert.NoError(t, err)
+
+	audits.fail = false
+	assert.NoError(t, feeds.Delete(ctx, "pet1", "feed1"))
+	_, err = feeds.Get(ctx, "pet1", "feed1")
+	assert.Error(t, err)
+	events, err := audits.GetListOfPet(ctx, "pet1", audit.Cursor{}, 0)
+	assert.NoError(t, err)
+	if assert.Len(t, events, 2) {
+		assert.Equal(t, "feed.Delete", events[0].Method)
+		assert.Contains(t, events[0].Before, "feed1")
+	}
+}
@@ -0,0 +1,262 @@
+package auditlog
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"ohmnyom/domain/audit"
+	"ohmnyom/domain/feed"
+	"ohmnyom/domain/pet"
+	"ohmnyom/domain/user"
+	"ohmnyom/internal/errors"
+)
+
+// The store decorators read a document before and after they change it, and
+// log the change once it is made. An event that cannot be written then is
+// logged and lost, the change stays. Deletes, which leave nothing behind to
+// tell what was there, are logged before they are made instead, and are not
+// made when their event cannot be written.
+
+type feedStore struct {
+	feed.Store
+	audits audit.Store
+}
+
+// FeedStore logs the feeds put, updated and deleted through store to the log
+// of their pet.
+func FeedStore(store feed.Store, audits audit.Store) feed.Store {
+	return &feedStore{
+		Store:  store,
+		audits: audits,
+	}
+}
+
+func (s *feedStore) Put(ctx context.Context, f *feed.Feed) error {
+	if err := s.Store.Put(ctx, f); err != nil {
+		return err
+	}
+	put(ctx, s.audits, newEvent(ctx, "feed.Put", f.PetId, "", nil, f))
+	return nil
+}
+
+func (s *feedStore) PutUnlessConflict(ctx context.Context, f *feed.Feed, window time.Duration) error {
+	if err := s.Store.PutUnlessConflict(ctx, f, window); err != nil {
+		return err
+	}
+	put(ctx, s.audits, newEvent(ctx, "feed.Put", f.PetId, "", nil, f))
+	return nil
+}
+
+func (s *feedStore) Update(ctx context.Context, petId, feedId string, pathValues map[string]interface{}) error {
+	return s.update(ctx, petId, feedId, func() error {
+		return s.Store.Update(ctx, petId, feedId, pathValues)
+	})
+}
+
+func (s *feedStore) UpdateUnlessConflict(ctx context.Context, petId, feedId string, pathValues map[string]interface{},
+	window time.Duration) error {
+	return s.update(ctx, petId, feedId, func() error {
+		return s.Store.UpdateUnlessConflict(ctx, petId, feedId, pathValues, window)
+	})
+}
+
+// update logs the feed before and after write.
+func (s *feedStore) update(ctx context.Context, petId, feedId string, write func() error) error {
+	before, err := s.Store.Get(ctx, petId, feedId)
+	if err != nil {
+		return err
+	}
+	if err := write(); err != nil {
+		return err
+	}
+	after, err := s.Store.Get(ctx, petId, feedId)
+	if err != nil {
+		log.Printf("cannot audit feed.Update of Feed{Id: %v}: %v", feedId, err)
+		return nil
+	}
+	put(ctx, s.audits, newEvent(ctx, "feed.Update", petId, "", before, after))
+	return nil
+}
+
+func (s *feedStore) Delete(ctx context.Context, petId, feedId string) error {
+	before, err := s.Store.Get(ctx, petId, feedId)
+	if err != nil && !isNotFound(err) {
+		return err
+	}
+	if before != nil {
+		if err := putDelete(ctx, s.audits, newEvent(ctx, "feed.Delete", petId, "", before, nil)); err != nil {
+			return err
+		}
+	}
+	return s.Store.Delete(ctx, petId, feedId)
+}
+
+type petStore struct {
+	pet.Store
+	audits audit.Store
+}
+
+// PetStore logs the changes of pets made through store to their log.
+func PetStore(store pet.Store, audits audit.Store) pet.Store {
+	return &petStore{
+		Store:  store,
+		audits: audits,
+	}
+}
+
+func (s *petStore) Put(ctx context.Context, p *pet.Pet) error {
+	if err := s.Store.Put(ctx, p); err != nil {
+		return err
+	}
+	put(ctx, s.audits, newEvent(ctx, "pet.Put", p.Id, "", nil, p))
+	return nil
+}
+
+func (s *petStore) Update(ctx context.Context, id string, pathValues map[string]interface{}) error {
+	return s.change(ctx, "pet.Update", id, func() error {
+		return s.Store.Update(ctx, id, pathValues)
+	})
+}
+
+func (s *petStore) Delete(ctx context.Context, id string) error {
+	before, err := s.Store.Get(ctx, id)
+	if err != nil && !isNotFound(err) {
+		return err
+	}
+	if before != nil {
+		if err := putDelete(ctx, s.audits, newEvent(ctx, "pet.Delete", id, "", before, nil)); err != nil {
+			return err
+		}
+	}
+	return s.Store.Delete(ctx, id)
+}
+
+func (s *petStore) AddMember(ctx context.Context, id, uid string, role pet.Role) error {
+	return s.change(ctx, "pet.AddMember", id, func() error {
+		return s.Store.AddMember(ctx, id, uid, role)
+	})
+}
+
+func (s *petStore) DeleteMember(ctx context.Context, id, uid string) error {
+	return s.change(ctx, "pet.DeleteMember", id, func() error {
+		return s.Store.DeleteMember(ctx, id, uid)
+	})
+}
+
+// change logs the pet before and after write.
+func (s *petStore) change(ctx context.Context, op, id string, write func() error) error {
+	before, err := s.Store.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := write(); err != nil {
+		return err
+	}
+	after, err := s.Store.Get(ctx, id)
+	if err != nil {
+		log.Printf("cannot audit %v of Pet{Id: %v}: %v", op, id, err)
+		return nil
+	}
+	put(ctx, s.audits, newEvent(ctx, op, id, "", before, after))
+	return nil
+}
+
+type userStore struct {
+	user.Store
+	audits audit.Store
+}
+
+// UserStore logs the changes of users made through store to the log of their
+// account. Passwords and the accounts linked by OAuth are left out of it.
+func UserStore(store user.Store, audits audit.Store) user.Store {
+	return &userStore{
+		Store:  store,
+		audits: audits,
+	}
+}
+
+func (s *userStore) Put(ctx context.Context, u *user.User) error {
+	if err := s.Store.Put(ctx, u); err != nil {
+		return err
+	}
+	put(ctx, s.audits, newEvent(ctx, "user.Put", "", u.Id, nil, userSnapshot(u)))
+	return nil
+}
+
+func (s *userStore) Update(ctx context.Context, u *user.User, path, value string) error {
+	if u == nil {
+		return s.Store.Update(ctx, u, path, value)
+	}
+	return s.change(ctx, "user.Update", u.Id, func() error {
+		return s.Store.Update(ctx, u, path, value)
+	})
+}
+
+func (s *userStore) Delete(ctx context.Context, id string) error {
+	before, err := s.Store.Get(ctx, id)
+	if err != nil && !isNotFound(err) {
+		return err
+	}
+	if before != nil {
+		if err := putDelete(ctx, s.audits, newEvent(ctx, "user.Delete", "", id, userSnapshot(before), nil)); err != nil {
+			return err
+		}
+	}
+	return s.Store.Delete(ctx, id)
+}
+
+func (s *userStore) AddPet(ctx context.Context, id, petId string) error {
+	return s.change(ctx, "user.AddPet", id, func() error {
+		return s.Store.AddPet(ctx, id, petId)
+	})
+}
+
+func (s *userStore) DeletePet(ctx context.Context, id, petId string) error {
+	return s.change(ctx, "user.DeletePet", id, func() error {
+		return s.Store.DeletePet(ctx, id, petId)
+	})
+}
+
+// change logs the user before and after write.
+func (s *userStore) change(ctx context.Context, op, id string, write func() error) error {
+	before, err := s.Store.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := write(); err != nil {
+		return err
+	}
+	after, err := s.Store.Get(ctx, id)
+	if err != nil {
+		log.Printf("cannot audit %v of user %v: %v", op, id, err)
+		return nil
+	}
+	put(ctx, s.audits, newEvent(ctx, op, "", id, userSnapshot(before), userSnapshot(after)))
+	return nil
+}
+
+func put(ctx context.Context, audits audit.Store, event *audit.Event) {
+	if event == nil {
+		return
+	}
+	if err := audits.Put(ctx, event); err != nil {
+		log.Printf("cannot audit %v: %v", event.Method, err)
+	}
+}
+
+// putDelete writes the event of a delete about to be made.
+func putDelete(ctx context.Context, audits audit.Store, event *audit.Event) error {
+	if event == nil {
+		return nil
+	}
+	if err := audits.Put(ctx, event); err != nil {
+		return errors.NewInternalError("cannot audit %v: %v", event.Method, err)
+	}
+	return nil
+}
+
+func isNotFound(err error) bool {
+	var notfound *errors.NotFoundError
+	return errors.As(err, &notfound)
+}
@@ -0,0 +1,189 @@
+package auditlog
+
+import (
+	"context"
+
+	"ohmnyom/domain/audit"
+	"ohmnyom/domain/pet"
+	"ohmnyom/domain/uow"
+	"ohmnyom/domain/user"
+)
+
+type unitOfWork struct {
+	uow.UnitOfWork
+}
+
+// UnitOfWork logs the changes transactions make to users and pets in the same
+// transactions, so the log has exactly the changes that were committed. As no
+// read may follow a write, the snapshots are of the documents as the
+// transaction read them, and a change to one it did not read is logged
+// without them.
+func UnitOfWork(u uow.UnitOfWork) uow.UnitOfWork {
+	return &unitOfWork{
+		UnitOfWork: u,
+	}
+}
+
+func (u *unitOfWork) Run(ctx context.Context, fn func(ctx context.Context, tx uow.Tx) error) error {
+	return u.UnitOfWork.Run(ctx, func(ctx context.Context, t uow.Tx) error {
+		return fn(ctx, &tx{
+			Tx:    t,
+			ctx:   ctx,
+			users: make(map[string]*user.User),
+			pets:  make(map[string]*pet.Pet),
+		})
+	})
+}
+
+// tx keeps the users and the pets as they are after the writes so far.
+type tx struct {
+	uow.Tx
+	ctx   context.Context
+	users map[string]*user.User
+	pets  map[string]*pet.Pet
+}
+
+func (x *tx) GetUser(id string) (*user.User, error) {
+	u, err := x.Tx.GetUser(id)
+	if err != nil {
+		return nil, err
+	}
+	x.users[id] = userSnapshot(u)
+	return u, nil
+}
+
+func (x *tx) GetPet(id string) (*pet.Pet, error) {
+	p, err := x.Tx.GetPet(id)
+	if err != nil {
+		return nil, err
+	}
+	x.pets[id] = petSnapshot(p)
+	return p, nil
+}
+
+func (x *tx) DeleteUser(id string) error {
+	if err := x.Tx.DeleteUser(id); err != nil {
+		return err
+	}
+	return x.changeUser("user.Delete", id, func(u *user.User) *user.User { return nil })
+}
+
+func (x *tx) AddUserPet(id, petId string) error {
+	if err := x.Tx.AddUserPet(id, petId); err != nil {
+		return err
+	}
+	return x.changeUser("user.AddPet", id, func(u *user.User) *user.User {
+		if !u.HasPet(petId) {
+			u.Pets = append(u.Pets, petId)
+		}
+		return u
+	})
+}
+
+func (x *tx) DeleteUserPet(id, petId string) error {
+	if err := x.Tx.DeleteUserPet(id, petId); err != nil {
+		return err
+	}
+	return x.changeUser("user.DeletePet", id, func(u *user.User) *user.User {
+		pets := make([]string, 0, len(u.Pets))
+		for _, p := range u.Pets {
+			if p != petId {
+				pets = append(pets, p)
+			}
+		}
+		u.Pets = pets
+		return u
+	})
+}
+
+func (x *tx) CreatePet(p *pet.Pet) error {
+	if err := x.Tx.CreatePet(p); err != nil {
+		return err
+	}
+	after := petSnapshot(p)
+	x.pets[p.Id] = after
+	return x.save(newEvent(x.ctx, "pet.Create", p.Id, "", nil, after))
+}
+
+func (x *tx) DeletePet(id string) error {
+	if err := x.Tx.DeletePet(id); err != nil {
+		return err
+	}
+	return x.changePet("pet.Delete", id, func(p *pet.Pet) *pet.Pet { return nil })
+}
+
+func (x *tx) AddMember(petId, uid string, role pet.Role) error {
+	if err := x.Tx.AddMember(petId, uid, role); err != nil {
+		return err
+	}
+	return x.changePet("pet.AddMember", petId, func(p *pet.Pet) *pet.Pet {
+		return p.WithMember(uid, role)
+	})
+}
+
+func (x *tx) DeleteMember(petId, uid string) error {
+	if err := x.Tx.DeleteMember(petId, uid); err != nil {
+		return err
+	}
+	return x.changePet("pet.DeleteMember", petId, func(p *pet.Pet) *pet.Pet {
+		return p.WithoutMember(uid)
+	})
+}
+
+func (x *tx) SetGrants(petId string, grants map[string]*pet.Grant) error {
+	if err := x.Tx.SetGrants(petId, grants); err != nil {
+		return err
+	}
+	return x.changePet("pet.SetGrants", petId, func(p *pet.Pet) *pet.Pet {
+		p.Grants = grants
+		p.GrantsExpire = pet.GrantsExpire(grants)
+		return p
+	})
+}
+
+func (x *tx) SetPetHousehold(petId, householdId string) error {
+	if err := x.Tx.SetPetHousehold(petId, householdId); err != nil {
+		return err
+	}
+	return x.changePet("pet.SetHousehold", petId, func(p *pet.Pet) *pet.Pet {
+		p.HouseholdId = householdId
+		return p
+	})
+}
+
+// changeUser logs a write of the user, change returning the user after it
+// from a copy of the one before.
+func (x *tx) changeUser(op, id string, change func(u *user.User) *user.User) error {
+	before, ok := x.users[id]
+	if !ok {
+		return x.save(newEvent(x.ctx, op, "", id, nil, nil))
+	}
+	var after *user.User
+	if before != nil {
+		after = change(userSnapshot(before))
+	}
+	x.users[id] = after
+	return x.save(newEvent(x.ctx, op, "", id, before, after))
+}
+
+// changePet logs a write of the pet, change returning the pet after it from a
+// copy of the one before.
+func (x *tx) changePet(op, id string, change func(p *pet.Pet) *pet.Pet) error {
+	before, ok := x.pets[id]
+	if !ok {
+		return x.save(newEvent(x.ctx, op, id, "", nil, nil))
+	}
+	var after *pet.Pet
+	if before != nil {
+		after = change(petSnapshot(before))
+	}
+	x.pets[id] = after
+	return x.save(newEvent(x.ctx, op, id, "", before, after))
+}
+
+func (x *tx) save(event *audit.Event) error {
+	if event == nil {
+		return nil
+	}
+	return x.Tx.SaveAuditEvent(event)
+}
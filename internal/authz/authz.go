@@ -0,0 +1,141 @@
+// Package authz decides whether the caller of an RPC may access a pet and its
+// feeds and records.
+package authz
+
+import (
+	"context"
+
+	"ohmnyom/domain/feed"
+	"ohmnyom/domain/household"
+	"ohmnyom/domain/pet"
+	"ohmnyom/domain/user"
+	"ohmnyom/internal/errors"
+)
+
+type Authorizer struct {
+	petStore       pet.Store
+	householdStore household.Store
+}
+
+func New(petStore pet.Store, householdStore household.Store) *Authorizer {
+	return &Authorizer{petStore: petStore, householdStore: householdStore}
+}
+
+// Uid returns the uid the auth interceptor put into ctx.
+func Uid(ctx context.Context) (string, error) {
+	uid, _ := ctx.Value(user.CtxKeyUid).(string)
+	if uid == "" {
+		return "", errors.NewAuthenticationError("UID not provided")
+	}
+	return uid, nil
+}
+
+// Pet returns the pet if the caller has at least the given role on it.
+func (a *Authorizer) Pet(ctx context.Context, petId string, role pet.Role) (*pet.Pet, error) {
+	uid, err := Uid(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if petId == "" {
+		return nil, errors.NewInvalidParamError("petId: %v", petId)
+	}
+	p, err := a.petStore.Get(ctx, petId)
+	if err != nil {
+		return nil, err
+	}
+	has, err := a.RoleOf(ctx, p, uid)
+	if err != nil {
+		return nil, err
+	}
+	if has < role {
+		return nil, errors.NewPermissionDeniedError("user %v on Pet{Id: %v}", uid, petId)
+	}
+	return p, nil
+}
+
+// RoleOf returns the role of uid on the pet. A member of the household of the
+// pet takes care of it, unless uid has a higher role of its own.
+func (a *Authorizer) RoleOf(ctx context.Context, p *pet.Pet, uid string) (pet.Role, error) {
+	role := p.RoleOf(uid)
+	if role >= pet.RoleCaregiver || p.HouseholdId == "" {
+		return role, nil
+	}
+	h, err := a.householdStore.Get(ctx, p.HouseholdId)
+	var notfound *errors.NotFoundError
+	if errors.As(err, &notfound) {
+		return role, nil
+	}
+	if err != nil {
+		return pet.RoleNone, err
+	}
+	if h.IsMember(uid) {
+		return pet.RoleCaregiver, nil
+	}
+	return role, nil
+}
+
+// Pets returns the pets in pets the caller has a role on. The others are left
+// out rather than failing the list, as the pet of a sitter whose grant has
+// ended stays in the account of the sitter until the sweep removes it.
+func (a *Authorizer) Pets(ctx context.Context, pets pet.List) (pet.List, error) {
+	uid, err := Uid(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ret := make(pet.List, 0, len(pets))
+	for _, p := range pets {
+		role, err := a.RoleOf(ctx, p, uid)
+		if err != nil {
+			return nil, err
+		}
+		if role >= pet.RoleViewer {
+			ret = append(ret, p)
+		}
+	}
+	return ret, nil
+}
+
+// Feed checks the caller takes care of the feed's pet and is the feeder of f,
+// unless the caller owns the pet. The feeder need not be a member any more, so
+// that owners keep control of the feeds of members who left.
+func (a *Authorizer) Feed(ctx context.Context, f *feed.Feed) (*pet.Pet, error) {
+	p, err := a.Pet(ctx, f.PetId, pet.RoleCaregiver)
+	if err != nil {
+		return nil, err
+	}
+	uid, _ := Uid(ctx)
+	if f.FeederId != uid && p.RoleOf(uid) < pet.RoleOwner {
+		return nil, errors.NewPermissionDeniedError("user %v on Feed{Id: %v, FeederId: %v}", uid, f.Id, f.FeederId)
+	}
+	return p, nil
+}
+
+// Author checks the caller takes care of the pet and recorded what authorId
+// recorded, unless the caller owns the pet.
+func (a *Authorizer) Author(ctx context.Context, petId, authorId string) (*pet.Pet, error) {
+	p, err := a.Pet(ctx, petId, pet.RoleCaregiver)
+	if err != nil {
+		return nil, err
+	}
+	uid, _ := Uid(ctx)
+	if authorId != uid && p.RoleOf(uid) < pet.RoleOwner {
+		return nil, errors.NewPermissionDeniedError("user %v on a record of %v in Pet{Id: %v}", uid, authorId, petId)
+	}
+	return p, nil
+}
+
+// Household returns the household if the caller is a member of it.
+func (a *Authorizer) Household(ctx context.Context, id string) (*household.Household, error) {
+	uid, err := Uid(ctx)
+	if err != nil {
+		return nil, err
+	}
+	h, err := a.householdStore.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !h.IsMember(uid) {
+		return nil, errors.NewPermissionDeniedError("user %v on Household{Id: %v}", uid, id)
+	}
+	return h, nil
+}
@@ -0,0 +1,327 @@
+// Package cascade runs the deletion jobs written when a pet or a user is
+// deleted: it removes the feeds and weights of the pet in batches, its
+// schedules, its food catalog, its health records, its outstanding invites,
+// the devices of the user, the audit log of either and the storage
+// directories of the job. Progress is saved after every step, so a job that is
+// interrupted continues where it stopped.
+package cascade
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"ohmnyom/domain/audit"
+	"ohmnyom/domain/deletion"
+	"ohmnyom/domain/device"
+	"ohmnyom/domain/feed"
+	"ohmnyom/domain/food"
+	"ohmnyom/domain/health"
+	"ohmnyom/domain/invite"
+	"ohmnyom/domain/schedule"
+	"ohmnyom/domain/weight"
+	"ohmnyom/internal/storage"
+)
+
+const (
+	// DefaultBatchSize stays under the firestore limit of 500 writes per batch.
+	DefaultBatchSize = 300
+	// DefaultResumeInterval is how often jobs left pending are resumed.
+	DefaultResumeInterval = 10 * time.Minute
+)
+
+type Deleter struct {
+	jobs          deletion.Store
+	feedStore     feed.Store
+	scheduleStore schedule.Store
+	foodStore     food.Store
+	weightStore   weight.Store
+	healthStore   health.Store
+	inviteStore   invite.Store
+	deviceStore   device.Store
+	auditStore    audit.Store
+	storage       storage.Storage
+	storageRoot   string
+	batchSize     int
+
+	// running has the jobs started in this process and not finished yet.
+	mu      sync.Mutex
+	running map[string]struct{}
+	wg      sync.WaitGroup
+}
+
+func New(jobs deletion.Store, feedStore feed.Store, scheduleStore schedule.Store, foodStore food.Store,
+	weightStore weight.Store, healthStore health.Store, inviteStore invite.Store, deviceStore device.Store,
+	auditStore audit.Store, storage storage.Storage, storageRoot string, batchSize int) *Deleter {
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+	return &Deleter{
+		jobs:          jobs,
+		feedStore:     feedStore,
+		scheduleStore: scheduleStore,
+		foodStore:     foodStore,
+		weightStore:   weightStore,
+		healthStore:   healthStore,
+		inviteStore:   inviteStore,
+		deviceStore:   deviceStore,
+		auditStore:    auditStore,
+		storage:       storage,
+		storageRoot:   storageRoot,
+		batchSize:     batchSize,
+		running:       make(map[string]struct{}),
+	}
+}
+
+// Run continues the job from its last saved step and returns what the job
+// deleted in total, including earlier runs.
+func (d *Deleter) Run(ctx context.Context, job *deletion.Job) (*deletion.Report, error) {
+	if !job.FeedsDone {
+		if err := d.deleteFeeds(ctx, job); err != nil {
+			return &job.Report, err
+		}
+	}
+	if !job.SchedulesDone {
+		n, err := d.scheduleStore.DeleteAllOfPet(ctx, job.TargetId)
+		if err != nil {
+			return &job.Report, err
+		}
+		job.Report.Schedules += n
+		job.SchedulesDone = true
+		if err := d.jobs.Save(ctx, job); err != nil {
+			return &job.Report, err
+		}
+	}
+	if !job.FoodsDone {
+		n, err := d.foodStore.DeleteAllOfPet(ctx, job.TargetId)
+		if err != nil {
+			return &job.Report, err
+		}
+		job.Report.Foods += n
+		job.FoodsDone = true
+		if err := d.jobs.Save(ctx, job); err != nil {
+			return &job.Report, err
+		}
+	}
+	if !job.WeightsDone {
+		if err := d.deleteWeights(ctx, job); err != nil {
+			return &job.Report, err
+		}
+	}
+	if !job.HealthDone {
+		n, err := d.healthStore.DeleteAllOfPet(ctx, job.TargetId)
+		if err != nil {
+			return &job.Report, err
+		}
+		job.Report.HealthRecords += n
+		job.HealthDone = true
+		if err := d.jobs.Save(ctx, job); err != nil {
+			return &job.Report, err
+		}
+	}
+	if !job.InvitesDone {
+		if err := d.deleteInvites(ctx, job); err != nil {
+			return &job.Report, err
+		}
+	}
+	if !job.DevicesDone {
+		n, err := d.deviceStore.DeleteAllOfUser(ctx, job.TargetId)
+		if err != nil {
+			return &job.Report, err
+		}
+		job.Report.Devices += n
+		job.DevicesDone = true
+		if err := d.jobs.Save(ctx, job); err != nil {
+			return &job.Report, err
+		}
+	}
+	if !job.AuditDone {
+		if err := d.deleteAudit(ctx, job); err != nil {
+			return &job.Report, err
+		}
+	}
+	for _, dir := range job.StorageDirs {
+		if job.IsDirDeleted(dir) {
+			continue
+		}
+		if err := d.storage.DeleteDir(ctx, d.storageRoot, dir); err != nil {
+			return &job.Report, err
+		}
+		job.Report.StorageDirs = append(job.Report.StorageDirs, dir)
+		if err := d.jobs.Save(ctx, job); err != nil {
+			return &job.Report, err
+		}
+	}
+
+	job.Done = true
+	if err := d.jobs.Save(ctx, job); err != nil {
+		return &job.Report, err
+	}
+	return &job.Report, nil
+}
+
+func (d *Deleter) deleteFeeds(ctx context.Context, job *deletion.Job) error {
+	for {
+		n, err := d.feedStore.DeleteBatchOfPet(ctx, job.TargetId, d.batchSize)
+		if err != nil {
+			return err
+		}
+		job.Report.Feeds += n
+		job.FeedsDone = n < d.batchSize
+		if err := d.jobs.Save(ctx, job); err != nil {
+			return err
+		}
+		if job.FeedsDone {
+			return nil
+		}
+	}
+}
+
+func (d *Deleter) deleteWeights(ctx context.Context, job *deletion.Job) error {
+	for {
+		n, err := d.weightStore.DeleteBatchOfPet(ctx, job.TargetId, d.batchSize)
+		if err != nil {
+			return err
+		}
+		job.Report.Weights += n
+		job.WeightsDone = n < d.batchSize
+		if err := d.jobs.Save(ctx, job); err != nil {
+			return err
+		}
+		if job.WeightsDone {
+			return nil
+		}
+	}
+}
+
+// deleteAudit deletes the audit log of the pet or the user, the event of its
+// deletion included, so that no trail nobody can read is left behind, nor the
+// personal data of a deleted account.
+func (d *Deleter) deleteAudit(ctx context.Context, job *deletion.Job) error {
+	deleteBatch := d.auditStore.DeleteBatchOfPet
+	if job.Kind == deletion.KindUser {
+		deleteBatch = d.auditStore.DeleteBatchOfUser
+	}
+	for {
+		n, err := deleteBatch(ctx, job.TargetId, d.batchSize)
+		if err != nil {
+			return err
+		}
+		job.Report.AuditEvents += n
+		job.AuditDone = n < d.batchSize
+		if err := d.jobs.Save(ctx, job); err != nil {
+			return err
+		}
+		if job.AuditDone {
+			return nil
+		}
+	}
+}
+
+func (d *Deleter) deleteInvites(ctx context.Context, job *deletion.Job) error {
+	invites, err := d.inviteStore.GetListOfPet(ctx, job.TargetId)
+	if err != nil {
+		return err
+	}
+	for _, i := range invites {
+		if err := d.inviteStore.Delete(ctx, i.Code); err != nil {
+			return err
+		}
+		job.Report.Invites++
+	}
+	job.InvitesDone = true
+	return d.jobs.Save(ctx, job)
+}
+
+// RunById runs the saved job with the id.
+func (d *Deleter) RunById(ctx context.Context, id string) (*deletion.Report, error) {
+	job, err := d.jobs.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return d.Run(ctx, job)
+}
+
+// Start runs the job in the background. It does not run with the context of
+// the request that deleted the documents, so a client that goes away does not
+// stop it. A job that fails is left pending for ResumeEvery.
+func (d *Deleter) Start(job *deletion.Job) {
+	if !d.claim(job.Id) {
+		return
+	}
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+		defer d.release(job.Id)
+		d.runLogged(context.Background(), job)
+	}()
+}
+
+// Wait waits for the jobs started so far.
+func (d *Deleter) Wait() {
+	d.wg.Wait()
+}
+
+// claim marks the job running, false if it runs already.
+func (d *Deleter) claim(id string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, ok := d.running[id]; ok {
+		return false
+	}
+	d.running[id] = struct{}{}
+	return true
+}
+
+func (d *Deleter) release(id string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.running, id)
+}
+
+func (d *Deleter) runLogged(ctx context.Context, job *deletion.Job) {
+	report, err := d.Run(ctx, job)
+	if err != nil {
+		log.Printf("deletion %v stopped: %v, deleted so far: %+v", job.Id, err, report)
+		return
+	}
+	log.Printf("deletion %v done: %+v", job.Id, report)
+}
+
+// Resume runs every pending job but those running already. A failing job is
+// logged and left pending for the next call.
+func (d *Deleter) Resume(ctx context.Context) error {
+	jobs, err := d.jobs.GetPending(ctx)
+	if err != nil {
+		return err
+	}
+	for _, job := range jobs {
+		if !d.claim(job.Id) {
+			continue
+		}
+		d.runLogged(ctx, job)
+		d.release(job.Id)
+	}
+	return nil
+}
+
+// ResumeEvery resumes the pending jobs now and then every interval until ctx
+// is done.
+func (d *Deleter) ResumeEvery(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultResumeInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := d.Resume(ctx); err != nil {
+			log.Printf("cannot resume deletions: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package cascade
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"ohmnyom/domain/audit"
+	"ohmnyom/domain/deletion"
+	"ohmnyom/domain/feed"
+	"ohmnyom/domain/food"
+	"ohmnyom/domain/health"
+	"ohmnyom/domain/invite"
+	"ohmnyom/domain/schedule"
+	"ohmnyom/domain/weight"
+	"ohmnyom/internal/errors"
+	"ohmnyom/internal/memstore"
+	"ohmnyom/internal/storage"
+)
+
+// flakyStorage fails DeleteDir until fail is cleared.
+type flakyStorage struct {
+	fail    bool
+	deleted []string
+}
+
+func (s *flakyStorage) Upload(ctx context.Context, object *storage.Object) (string, error) {
+	return "", nil
+}
+
+func (s *flakyStorage) Delete(ctx context.Context, root, path string) error {
+	return nil
+}
+
+func (s *flakyStorage) DeleteDir(ctx context.Context, root, dir string) error {
+	if s.fail {
+		return errors.NewInternalError("storage unavailable")
+	}
+	s.deleted = append(s.deleted, dir)
+	return nil
+}
+
+func TestDeleter_Resume(t *testing.T) {
+	ctx := context.TODO()
+	jobs := memstore.NewDeletionStore()
+	feeds := memstore.NewFeedStore()
+	invites := memstore.NewInviteStore()
+	store := &flakyStorage{fail: true}
+	schedules := memstore.NewScheduleStore()
+	foods := memstore.NewFoodStore()
+	weights := memstore.NewWeightStore()
+	records := memstore.NewHealthStore()
+	audits := memstore.NewAuditStore()
+	d := New(jobs, feeds, schedules, foods, weights, records, invites, memstore.NewDeviceStore(), audits, store, "root", 2)
+
+	for i := 0; i < 5; i++ {
+		f := &feed.Feed{Id: fmt.Sprint("feed", i), PetId: "pet1", Timestamp: time.Now()}
+		assert.NoError(t, feeds.Put(ctx, f))
+	}
+	assert.NoError(t, feeds.Put(ctx, &feed.Feed{Id: "other", PetId: "pet2", Timestamp: time.Now()}))
+	assert.NoError(t, invites.Put(ctx, &invite.Invite{Code: "code", PetId: "pet1"}))
+	assert.NoError(t, schedules.Put(ctx, &schedule.Schedule{Id: "schedule", PetId: "pet1"}))
+	assert.NoError(t, foods.Put(ctx, &food.Food{Id: "food", PetId: "pet1"}))
+	assert.NoError(t, records.Put(ctx, &health.Record{Id: "record", PetId: "pet1"}))
+	for i := 0; i < 3; i++ {
+		w := &weight.Weight{Id: fmt.Sprint("weight", i), PetId: "pet1", Timestamp: time.Now()}
+		assert.NoError(t, weights.Put(ctx, w))
+	}
+	for i := 0; i < 3; i++ {
+		e := &audit.Event{Id: fmt.Sprint("event", i), PetId: "pet1", Timestamp: time.Now()}
+		assert.NoError(t, audits.Put(ctx, e))
+	}
+	assert.NoError(t, audits.Put(ctx, &audit.Event{Id: "feed", PetId: "pet1", UserId: "owner", Timestamp: time.Now()}))
+	assert.NoError(t, audits.Put(ctx, &audit.Event{Id: "other", PetId: "pet2", Timestamp: time.Now()}))
+
+	job := deletion.NewPetJob("pet1", "pet/pet1/")
+	assert.NoError(t, jobs.Save(ctx, job))
+	report, err := d.RunById(ctx, job.Id)
+	assert.Error(t, err)
+	assert.Equal(t, &deletion.Report{Feeds: 5, Schedules: 1, Foods: 1, Weights: 3, HealthRecords: 1, Invites: 1,
+		AuditEvents: 4}, report)
+
+	pending, err := jobs.GetPending(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, pending, 1)
+	assert.True(t, pending[0].FeedsDone)
+	assert.True(t, pending[0].InvitesDone)
+
+	store.fail = false
+	assert.NoError(t, d.Resume(ctx))
+	saved, err := jobs.Get(ctx, job.Id)
+	assert.NoError(t, err)
+	assert.True(t, saved.Done)
+	assert.Equal(t, deletion.Report{Feeds: 5, Schedules: 1, Foods: 1, Weights: 3, HealthRecords: 1, Invites: 1,
+		AuditEvents: 4, StorageDirs: []string{"pet/pet1/"}}, saved.Report)
+	assert.Equal(t, []string{"pet/pet1/"}, store.deleted)
+
+	left, err := feeds.GetFeedsOfPet(ctx, "pet2", time.Now().Add(time.Hour), 0)
+	assert.NoError(t, err)
+	assert.Len(t, left, 1)
+	events, err := audits.GetListOfPet(ctx, "pet1", audit.Cursor{}, 0)
+	assert.NoError(t, err)
+	assert.Empty(t, events)
+	// the event of the owner's account stays in its log
+	events, err = audits.GetListOfUser(ctx, "owner", audit.Cursor{}, 0)
+	assert.NoError(t, err)
+	assert.Len(t, events, 1)
+	events, err = audits.GetListOfPet(ctx, "pet2", audit.Cursor{}, 0)
+	assert.NoError(t, err)
+	assert.Len(t, events, 1)
+
+	pending, err = jobs.GetPending(ctx)
+	assert.NoError(t, err)
+	assert.Empty(t, pending)
+}
+
+func TestDeleter_Start(t *testing.T) {
+	ctx := context.TODO()
+	jobs := memstore.NewDeletionStore()
+	feeds := memstore.NewFeedStore()
+	d := New(jobs, feeds, memstore.NewScheduleStore(), memstore.NewFoodStore(), memstore.NewWeightStore(),
+		memstore.NewHealthStore(), memstore.NewInviteStore(), memstore.NewDeviceStore(),
+		memstore.NewAuditStore(), &flakyStorage{}, "root", 2)
+	for i := 0; i < 5; i++ {
+		f := &feed.Feed{Id: fmt.Sprint("feed", i), PetId: "pet1", Timestamp: time.Now()}
+		assert.NoError(t, feeds.Put(ctx, f))
+	}
+	job := deletion.NewPetJob("pet1", "pet/pet1/")
+	assert.NoError(t, jobs.Save(ctx, job))
+
+	d.Start(job)
+	d.Wait()
+	pending, err := jobs.GetPending(ctx)
+	assert.NoError(t, err)
+	assert.Empty(t, pending)
+	left, err := feeds.GetFeedsOfPet(ctx, "pet1", time.Now().Add(time.Hour), 0)
+	assert.NoError(t, err)
+	assert.Empty(t, left)
+}
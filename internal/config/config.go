@@ -0,0 +1,307 @@
+// Package config loads the server configuration. Every option can be set in a
+// JSON file, an environment variable and a flag, in increasing precedence.
+// The environment variable of flag "jwt-secret" is OHMNYOM_JWT_SECRET.
+//
+// Secrets have no defaults outside development; they are read from the value
+// or from the file its "-file" option names.
+package config
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"strings"
+
+	"ohmnyom/internal/errors"
+	"ohmnyom/internal/oauth"
+)
+
+const (
+	EnvProduction  = "production"
+	EnvDevelopment = "development"
+
+	StorageGoogle = "google"
+	StorageLocal  = "local"
+
+	NotifyLog = "log"
+	NotifyFCM = "fcm"
+
+	envPrefix         = "OHMNYOM_"
+	configFileFlag    = "config"
+	developmentSecret = "ohmnyom-development-secret"
+)
+
+type Config struct {
+	Env  string `json:"env"`
+	Port string `json:"port"`
+	// NetworkInterface is the interface whose address is logged at start up.
+	NetworkInterface string `json:"networkInterface"`
+
+	Firestore Firestore `json:"firestore"`
+	Jwt       Jwt       `json:"jwt"`
+	Storage   Storage   `json:"storage"`
+	OAuth     OAuth     `json:"oauth"`
+	Notify    Notify    `json:"notify"`
+}
+
+type Firestore struct {
+	ProjectId string `json:"projectId"`
+	// CredentialFile is a service account key. Application default credentials
+	// are used when empty.
+	CredentialFile string `json:"credentialFile"`
+}
+
+type Jwt struct {
+	Secret     string `json:"secret"`
+	SecretFile string `json:"secretFile"`
+}
+
+type Storage struct {
+	Backend        string `json:"backend"`
+	CredentialFile string `json:"credentialFile"`
+	// OwnerEntity is granted ownership of uploaded objects, e.g. "user-someone@gmail.com".
+	OwnerEntity string `json:"ownerEntity"`
+	LocalDir    string `json:"localDir"`
+	LocalAddr   string `json:"localAddr"`
+	LocalUrl    string `json:"localUrl"`
+}
+
+type OAuth struct {
+	GoogleClientIds   []string `json:"googleClientIds"`
+	GoogleCertsUrl    string   `json:"googleCertsUrl"`
+	KakaoAppId        int64    `json:"kakaoAppId"`
+	KakaoTokenInfoUrl string   `json:"kakaoTokenInfoUrl"`
+	KakaoUserUrl      string   `json:"kakaoUserUrl"`
+}
+
+type Notify struct {
+	Backend      string `json:"backend"`
+	FcmProjectId string `json:"fcmProjectId"`
+	// CredentialFile is a service account key allowed to send through FCM.
+	// Application default credentials are used when empty.
+	CredentialFile string `json:"credentialFile"`
+	// ReminderIntervalSeconds is how often missed feedings are checked, zero
+	// disables the reminders.
+	ReminderIntervalSeconds int `json:"reminderIntervalSeconds"`
+}
+
+func defaults() *Config {
+	return &Config{
+		Env:  EnvProduction,
+		Port: "8080",
+		Storage: Storage{
+			Backend:  StorageGoogle,
+			LocalDir: "local-storage",
+		},
+		OAuth: OAuth{
+			GoogleCertsUrl:    oauth.GoogleCertsUrl,
+			KakaoTokenInfoUrl: oauth.KakaoTokenInfoUrl,
+			KakaoUserUrl:      oauth.KakaoUserUrl,
+		},
+		Notify: Notify{
+			Backend:                 NotifyLog,
+			ReminderIntervalSeconds: 60,
+		},
+	}
+}
+
+type stringList struct{ list *[]string }
+
+func (s stringList) String() string {
+	if s.list == nil {
+		return ""
+	}
+	return strings.Join(*s.list, ",")
+}
+
+func (s stringList) Set(value string) error {
+	*s.list = nil
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			*s.list = append(*s.list, v)
+		}
+	}
+	return nil
+}
+
+func (c *Config) flagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.String(configFileFlag, "", "JSON config file")
+	fs.StringVar(&c.Env, "env", c.Env, "production or development")
+	fs.StringVar(&c.Port, "port", c.Port, "gRPC listen port")
+	fs.StringVar(&c.NetworkInterface, "network-interface", c.NetworkInterface, "interface to log the address of")
+	fs.StringVar(&c.Firestore.ProjectId, "firestore-project-id", c.Firestore.ProjectId, "firestore project")
+	fs.StringVar(&c.Firestore.CredentialFile, "firestore-credential-file", c.Firestore.CredentialFile,
+		"firestore service account key")
+	fs.StringVar(&c.Jwt.Secret, "jwt-secret", c.Jwt.Secret, "jwt signing secret")
+	fs.StringVar(&c.Jwt.SecretFile, "jwt-secret-file", c.Jwt.SecretFile, "file holding the jwt signing secret")
+	fs.StringVar(&c.Storage.Backend, "storage-backend", c.Storage.Backend, "google or local")
+	fs.StringVar(&c.Storage.CredentialFile, "storage-credential-file", c.Storage.CredentialFile,
+		"google storage service account key")
+	fs.StringVar(&c.Storage.OwnerEntity, "storage-owner-entity", c.Storage.OwnerEntity,
+		"ACL entity owning uploaded objects")
+	fs.StringVar(&c.Storage.LocalDir, "storage-local-dir", c.Storage.LocalDir, "directory of local storage")
+	fs.StringVar(&c.Storage.LocalAddr, "storage-local-addr", c.Storage.LocalAddr, "address serving local storage")
+	fs.StringVar(&c.Storage.LocalUrl, "storage-local-url", c.Storage.LocalUrl, "base url of local storage links")
+	fs.Var(stringList{&c.OAuth.GoogleClientIds}, "oauth-google-client-ids", "comma separated google client ids")
+	fs.StringVar(&c.OAuth.GoogleCertsUrl, "oauth-google-certs-url", c.OAuth.GoogleCertsUrl, "google JWKS url")
+	fs.Int64Var(&c.OAuth.KakaoAppId, "oauth-kakao-app-id", c.OAuth.KakaoAppId, "kakao app id")
+	fs.StringVar(&c.OAuth.KakaoTokenInfoUrl, "oauth-kakao-token-info-url", c.OAuth.KakaoTokenInfoUrl,
+		"kakao token info url")
+	fs.StringVar(&c.OAuth.KakaoUserUrl, "oauth-kakao-user-url", c.OAuth.KakaoUserUrl, "kakao user url")
+	fs.StringVar(&c.Notify.Backend, "notify-backend", c.Notify.Backend, "log or fcm")
+	fs.StringVar(&c.Notify.FcmProjectId, "notify-fcm-project-id", c.Notify.FcmProjectId, "firebase project to send with")
+	fs.StringVar(&c.Notify.CredentialFile, "notify-credential-file", c.Notify.CredentialFile,
+		"service account key sending through fcm")
+	fs.IntVar(&c.Notify.ReminderIntervalSeconds, "notify-reminder-interval-seconds", c.Notify.ReminderIntervalSeconds,
+		"seconds between missed feeding checks, 0 to disable")
+	return fs
+}
+
+func envName(flagName string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// findConfigFile looks for the config file in args, then in the environment.
+func findConfigFile(args []string) string {
+	for i, arg := range args {
+		name := strings.TrimLeft(arg, "-")
+		if name == arg {
+			continue
+		}
+		if name == configFileFlag && i+1 < len(args) {
+			return args[i+1]
+		}
+		if strings.HasPrefix(name, configFileFlag+"=") {
+			return strings.TrimPrefix(name, configFileFlag+"=")
+		}
+	}
+	return os.Getenv(envName(configFileFlag))
+}
+
+// Load reads the configuration for the command line args, without the program name.
+func Load(args []string) (*Config, error) {
+	c := defaults()
+
+	if file := findConfigFile(args); file != "" {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return nil, errors.NewInvalidParamError("config file: %v", err)
+		}
+		if err := json.Unmarshal(b, c); err != nil {
+			return nil, errors.NewInvalidFormatError("config file %v: %v", file, err)
+		}
+	}
+
+	fs := c.flagSet("ohmnyom")
+	if err := fs.Parse(args); err != nil {
+		return nil, errors.NewInvalidParamError("%v", err)
+	}
+	set := make(map[string]struct{})
+	fs.Visit(func(f *flag.Flag) {
+		set[f.Name] = struct{}{}
+	})
+	var envErr error
+	fs.VisitAll(func(f *flag.Flag) {
+		if _, ok := set[f.Name]; ok || envErr != nil {
+			return
+		}
+		if v, ok := os.LookupEnv(envName(f.Name)); ok {
+			if err := fs.Set(f.Name, v); err != nil {
+				envErr = errors.NewInvalidParamError("%v: %v", envName(f.Name), err)
+			}
+		}
+	})
+	if envErr != nil {
+		return nil, envErr
+	}
+	// Cloud Run tells the port to listen on in PORT.
+	if _, ok := set["port"]; !ok {
+		if port, ok := os.LookupEnv("PORT"); ok {
+			if _, ok := os.LookupEnv(envName("port")); !ok {
+				c.Port = port
+			}
+		}
+	}
+
+	if err := c.resolveSecrets(); err != nil {
+		return nil, err
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func readSecret(value, file string) (string, error) {
+	if value != "" || file == "" {
+		return value, nil
+	}
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return "", errors.NewInvalidParamError("secret file: %v", err)
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+func (c *Config) resolveSecrets() error {
+	secret, err := readSecret(c.Jwt.Secret, c.Jwt.SecretFile)
+	if err != nil {
+		return err
+	}
+	if secret == "" && c.Env == EnvDevelopment {
+		secret = developmentSecret
+	}
+	c.Jwt.Secret = secret
+	return nil
+}
+
+func (c *Config) IsProduction() bool {
+	return c.Env == EnvProduction
+}
+
+func (c *Config) Validate() error {
+	if c.Env != EnvProduction && c.Env != EnvDevelopment {
+		return errors.NewInvalidParamError("env [%v]", c.Env)
+	}
+	if c.Port == "" {
+		return errors.NewInvalidParamError("port [%v]", c.Port)
+	}
+	if c.Firestore.ProjectId == "" {
+		return errors.NewInvalidParamError("firestore project id is not set")
+	}
+	if c.Jwt.Secret == "" {
+		return errors.NewInvalidParamError("jwt secret is not set")
+	}
+	if c.IsProduction() && (c.Jwt.Secret == developmentSecret || len(c.Jwt.Secret) < 32) {
+		return errors.NewInvalidParamError("jwt secret is too weak for production")
+	}
+	switch c.Storage.Backend {
+	case StorageGoogle:
+	case StorageLocal:
+		if c.IsProduction() {
+			return errors.NewInvalidParamError("local storage in production")
+		}
+		if c.Storage.LocalDir == "" {
+			return errors.NewInvalidParamError("storage local dir is not set")
+		}
+	default:
+		return errors.NewInvalidParamError("storage backend [%v]", c.Storage.Backend)
+	}
+	switch c.Notify.Backend {
+	case NotifyLog:
+	case NotifyFCM:
+		if c.Notify.FcmProjectId == "" {
+			return errors.NewInvalidParamError("notify fcm project id is not set")
+		}
+	default:
+		return errors.NewInvalidParamError("notify backend [%v]", c.Notify.Backend)
+	}
+	if c.Notify.ReminderIntervalSeconds < 0 {
+		return errors.NewInvalidParamError("reminder interval [%v]", c.Notify.ReminderIntervalSeconds)
+	}
+	if c.IsProduction() && len(c.OAuth.GoogleClientIds) == 0 && c.OAuth.KakaoAppId == 0 {
+		return errors.NewInvalidParamError("no oauth provider is set in production")
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const strongSecret = "0123456789abcdef0123456789abcdef"
+
+func writeFile(t *testing.T, name, content string) string {
+	p := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(p, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestLoad_Precedence(t *testing.T) {
+	file := writeFile(t, "config.json", `{
+		"port": "9000",
+		"firestore": {"projectId": "from-file"},
+		"storage": {"ownerEntity": "user-file@test.com"},
+		"oauth": {"googleClientIds": ["file-client"]}
+	}`)
+	secretFile := writeFile(t, "secret", strongSecret+"\n")
+	t.Setenv("OHMNYOM_CONFIG", file)
+	t.Setenv("OHMNYOM_FIRESTORE_PROJECT_ID", "from-env")
+	t.Setenv("OHMNYOM_JWT_SECRET_FILE", secretFile)
+	t.Setenv("OHMNYOM_OAUTH_KAKAO_APP_ID", "42")
+	// PORT would override the file; Setenv restores it after the test
+	t.Setenv("PORT", "")
+	os.Unsetenv("PORT")
+
+	c, err := Load([]string{"-storage-owner-entity", "user-flag@test.com"})
+	assert.NoError(t, err)
+	assert.Equal(t, "9000", c.Port)
+	assert.Equal(t, "from-env", c.Firestore.ProjectId)
+	assert.Equal(t, "user-flag@test.com", c.Storage.OwnerEntity)
+	assert.Equal(t, []string{"file-client"}, c.OAuth.GoogleClientIds)
+	assert.Equal(t, int64(42), c.OAuth.KakaoAppId)
+	assert.Equal(t, strongSecret, c.Jwt.Secret)
+	assert.True(t, c.IsProduction())
+}
+
+func TestLoad_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{"no project", []string{"-jwt-secret", strongSecret}, "project"},
+		{"no secret in production", []string{"-firestore-project-id", "p"}, "jwt secret"},
+		{"weak secret in production", []string{"-firestore-project-id", "p", "-jwt-secret", "short"}, "weak"},
+		{"local storage in production",
+			[]string{"-firestore-project-id", "p", "-jwt-secret", strongSecret, "-storage-backend", "local"},
+			"local storage"},
+		{"fcm without project",
+			[]string{"-firestore-project-id", "p", "-jwt-secret", strongSecret, "-notify-backend", "fcm"},
+			"fcm project"},
+		{"no oauth in production", []string{"-firestore-project-id", "p", "-jwt-secret", strongSecret}, "oauth"},
+		{"kakao only in production", []string{"-firestore-project-id", "p", "-jwt-secret", strongSecret,
+			"-oauth-kakao-app-id", "42"}, ""},
+		{"unknown env", []string{"-env", "staging", "-firestore-project-id", "p", "-jwt-secret", strongSecret}, "env"},
+		{"development defaults", []string{"-env", "development", "-firestore-project-id", "p",
+			"-storage-backend", "local"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(tt.args)
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			if assert.Error(t, err) {
+				assert.True(t, strings.Contains(err.Error(), tt.wantErr), err.Error())
+			}
+		})
+	}
+}
@@ -36,6 +36,12 @@ func GrpcError(err error) error {
 		return status.Error(codes.AlreadyExists, err.Error())
 	case *InternalError:
 		return status.Error(codes.Internal, err.Error())
+	case *PermissionDeniedError:
+		return status.Error(codes.PermissionDenied, err.Error())
+	case *FailedPreconditionError:
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case *AbortedError:
+		return status.Error(codes.Aborted, err.Error())
 	}
 	return status.Error(codes.Unknown, err.Error())
 }
@@ -48,6 +54,9 @@ type UnimplementedError struct{ Err error }
 type AlreadyExistsError struct{ Err error }
 type InternalError struct{ Err error }
 type NotSupportedError struct{ Err error }
+type PermissionDeniedError struct{ Err error }
+type FailedPreconditionError struct{ Err error }
+type AbortedError struct{ Err error }
 
 func (e *InvalidParamError) Unwrap() error { return e.Err }
 func (e *InvalidParamError) Error() string { return e.Err.Error() }
@@ -90,3 +99,21 @@ func (e *InternalError) Error() string { return e.Err.Error() }
 func NewInternalError(format string, a ...interface{}) *InternalError {
 	return &InternalError{Err: fmt.Errorf("internal error: "+format, a...)}
 }
+
+func (e *PermissionDeniedError) Unwrap() error { return e.Err }
+func (e *PermissionDeniedError) Error() string { return e.Err.Error() }
+func NewPermissionDeniedError(format string, a ...interface{}) *PermissionDeniedError {
+	return &PermissionDeniedError{Err: fmt.Errorf("permission denied: "+format, a...)}
+}
+
+func (e *FailedPreconditionError) Unwrap() error { return e.Err }
+func (e *FailedPreconditionError) Error() string { return e.Err.Error() }
+func NewFailedPreconditionError(format string, a ...interface{}) *FailedPreconditionError {
+	return &FailedPreconditionError{Err: fmt.Errorf("failed precondition: "+format, a...)}
+}
+
+func (e *AbortedError) Unwrap() error { return e.Err }
+func (e *AbortedError) Error() string { return e.Err.Error() }
+func NewAbortedError(format string, a ...interface{}) *AbortedError {
+	return &AbortedError{Err: fmt.Errorf("aborted: "+format, a...)}
+}
@@ -0,0 +1,126 @@
+package audit
+
+import (
+	"context"
+
+	"cloud.google.com/go/firestore"
+	"ohmnyom/domain/audit"
+	"ohmnyom/internal/errors"
+)
+
+const (
+	petCollection   = "pets"
+	userCollection  = "users"
+	auditCollection = "audit"
+)
+
+// Store keeps the events of a pet under the pet, and those of an account under
+// the user, where they outlive the documents they are about until the deletion
+// job of the pet or the user deletes them.
+type Store struct {
+	client *firestore.Client
+}
+
+func New(ctx context.Context, client *firestore.Client) audit.Store {
+	return &Store{
+		client: client,
+	}
+}
+
+// Refs returns the documents event is written to.
+func Refs(client *firestore.Client, event *audit.Event) []*firestore.DocumentRef {
+	ret := make([]*firestore.DocumentRef, 0, 2)
+	if event.PetId != "" {
+		ret = append(ret, client.Collection(petCollection).Doc(event.PetId).Collection(auditCollection).Doc(event.Id))
+	}
+	if event.UserId != "" {
+		ret = append(ret, client.Collection(userCollection).Doc(event.UserId).Collection(auditCollection).Doc(event.Id))
+	}
+	return ret
+}
+
+func (s *Store) Put(ctx context.Context, event *audit.Event) error {
+	if event == nil || event.Id == "" || (event.PetId == "" && event.UserId == "") {
+		return errors.NewInvalidParamError("event: %v", event)
+	}
+	batch := s.client.Batch()
+	for _, ref := range Refs(s.client, event) {
+		batch.Create(ref, event)
+	}
+	if _, err := batch.Commit(ctx); err != nil {
+		return errors.New("%v", err)
+	}
+	return nil
+}
+
+func (s *Store) GetListOfPet(ctx context.Context, petId string, startAfter audit.Cursor, limit int) ([]*audit.Event, error) {
+	if petId == "" {
+		return nil, errors.NewInvalidParamError("petId: %v", petId)
+	}
+	return s.getList(ctx, s.client.Collection(petCollection).Doc(petId).Collection(auditCollection), startAfter, limit)
+}
+
+func (s *Store) GetListOfUser(ctx context.Context, userId string, startAfter audit.Cursor, limit int) ([]*audit.Event, error) {
+	if userId == "" {
+		return nil, errors.NewInvalidParamError("userId: %v", userId)
+	}
+	return s.getList(ctx, s.client.Collection(userCollection).Doc(userId).Collection(auditCollection), startAfter, limit)
+}
+
+func (s *Store) getList(ctx context.Context, events *firestore.CollectionRef, startAfter audit.Cursor,
+	limit int) ([]*audit.Event, error) {
+	q := events.OrderBy("timestamp", firestore.Desc).OrderBy("id", firestore.Desc).Limit(limit)
+	switch {
+	case startAfter.Timestamp.IsZero():
+	case startAfter.Id == "":
+		q = q.StartAfter(startAfter.Timestamp)
+	default:
+		q = q.StartAfter(startAfter.Timestamp, startAfter.Id)
+	}
+	docs, err := q.Documents(ctx).GetAll()
+	if err != nil {
+		return nil, errors.New("%v", err)
+	}
+	ret := make([]*audit.Event, len(docs))
+	for i, doc := range docs {
+		e := &audit.Event{}
+		if err := doc.DataTo(e); err != nil {
+			return nil, errors.NewInvalidFormatError("%v", err)
+		}
+		ret[i] = e
+	}
+	return ret, nil
+}
+
+func (s *Store) DeleteBatchOfPet(ctx context.Context, petId string, limit int) (int, error) {
+	if petId == "" || limit <= 0 {
+		return 0, errors.NewInvalidParamError("petId: %v, limit: %v", petId, limit)
+	}
+	return s.deleteBatch(ctx, s.client.Collection(petCollection).Doc(petId).Collection(auditCollection), limit)
+}
+
+func (s *Store) DeleteBatchOfUser(ctx context.Context, userId string, limit int) (int, error) {
+	if userId == "" || limit <= 0 {
+		return 0, errors.NewInvalidParamError("userId: %v, limit: %v", userId, limit)
+	}
+	return s.deleteBatch(ctx, s.client.Collection(userCollection).Doc(userId).Collection(auditCollection), limit)
+}
+
+func (s *Store) deleteBatch(ctx context.Context, events *firestore.CollectionRef, limit int) (int, error) {
+	docs, err := events.Select().Limit(limit).Documents(ctx).GetAll()
+	if err != nil {
+		return 0, errors.New("%v", err)
+	}
+	if len(docs) == 0 {
+		return 0, nil
+	}
+
+	batch := s.client.Batch()
+	for _, doc := range docs {
+		batch.Delete(doc.Ref)
+	}
+	if _, err := batch.Commit(ctx); err != nil {
+		return 0, errors.New("%v", err)
+	}
+	return len(docs), nil
+}
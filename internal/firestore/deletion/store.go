@@ -0,0 +1,80 @@
+package deletion
+
+import (
+	"context"
+	"sort"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"ohmnyom/domain/deletion"
+	"ohmnyom/internal/errors"
+)
+
+const (
+	deletionCollection = "deletions"
+	operatorIs         = "=="
+)
+
+type Store struct {
+	client *firestore.Client
+}
+
+func New(ctx context.Context, client *firestore.Client) deletion.Store {
+	return &Store{
+		client: client,
+	}
+}
+
+func (s *Store) Get(ctx context.Context, id string) (*deletion.Job, error) {
+	if id == "" {
+		return nil, errors.NewInvalidParamError("id: %v", id)
+	}
+	snapshot, err := s.client.Collection(deletionCollection).Doc(id).Get(ctx)
+	switch status.Code(err) {
+	case codes.OK:
+		j := &deletion.Job{}
+		if suberr := snapshot.DataTo(j); suberr != nil {
+			return nil, errors.NewInvalidFormatError("%v", suberr)
+		}
+		return j, nil
+	case codes.NotFound:
+		return nil, errors.NewNotFoundError("Job{Id: %v}", id)
+	}
+	return nil, errors.New("%v", err)
+}
+
+func (s *Store) Save(ctx context.Context, job *deletion.Job) error {
+	if job == nil || job.Id == "" {
+		return errors.NewInvalidParamError("job: %v", job)
+	}
+	if _, err := s.client.Collection(deletionCollection).Doc(job.Id).Set(ctx, job); err != nil {
+		return errors.New("%v", err)
+	}
+	return nil
+}
+
+func (s *Store) GetPending(ctx context.Context) ([]*deletion.Job, error) {
+	// sorted here rather than in the query, which would need a composite index
+	iter := s.client.Collection(deletionCollection).Where("done", operatorIs, false).Documents(ctx)
+	ret := make([]*deletion.Job, 0)
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, errors.New("%v", err)
+		}
+		j := &deletion.Job{}
+		if suberr := doc.DataTo(j); suberr != nil {
+			return nil, errors.NewInvalidFormatError("%v", suberr)
+		}
+		ret = append(ret, j)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Created.Before(ret[j].Created)
+	})
+	return ret, nil
+}
@@ -0,0 +1,106 @@
+package device
+
+import (
+	"context"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"ohmnyom/domain/device"
+	"ohmnyom/internal/errors"
+)
+
+const (
+	deviceCollection = "devices"
+	operatorIs       = "=="
+)
+
+type Store struct {
+	client *firestore.Client
+}
+
+func New(ctx context.Context, client *firestore.Client) device.Store {
+	return &Store{
+		client: client,
+	}
+}
+
+func (s *Store) Get(ctx context.Context, token string) (*device.Device, error) {
+	if token == "" {
+		return nil, errors.NewInvalidParamError("token: %v", token)
+	}
+	snapshot, err := s.client.Collection(deviceCollection).Doc(device.Id(token)).Get(ctx)
+	switch status.Code(err) {
+	case codes.OK:
+		d := &device.Device{}
+		if suberr := snapshot.DataTo(d); suberr != nil {
+			return nil, errors.NewInvalidFormatError("%v", suberr)
+		}
+		return d, nil
+	case codes.NotFound:
+		return nil, errors.NewNotFoundError("Device{Id: %v}", device.Id(token))
+	}
+	return nil, errors.New("%v", err)
+}
+
+func (s *Store) GetListOfUser(ctx context.Context, uid string) ([]*device.Device, error) {
+	if uid == "" {
+		return nil, errors.NewInvalidParamError("uid: %v", uid)
+	}
+	docs, err := s.client.Collection(deviceCollection).Where("uid", operatorIs, uid).Documents(ctx).GetAll()
+	if err != nil {
+		return nil, errors.New("%v", err)
+	}
+	ret := make([]*device.Device, len(docs))
+	for i, doc := range docs {
+		d := &device.Device{}
+		if err := doc.DataTo(d); err != nil {
+			return nil, errors.NewInvalidFormatError("%v", err)
+		}
+		ret[i] = d
+	}
+	return ret, nil
+}
+
+func (s *Store) Put(ctx context.Context, d *device.Device) error {
+	if d == nil || d.Token == "" || d.Uid == "" {
+		return errors.NewInvalidParamError("device: %v", d)
+	}
+	if _, err := s.client.Collection(deviceCollection).Doc(device.Id(d.Token)).Set(ctx, d); err != nil {
+		return errors.New("%v", err)
+	}
+	return nil
+}
+
+func (s *Store) Delete(ctx context.Context, token string) error {
+	if token == "" {
+		return errors.NewInvalidParamError("token: %v", token)
+	}
+	if _, err := s.client.Collection(deviceCollection).Doc(device.Id(token)).Delete(ctx); err != nil {
+		return errors.New("%v", err)
+	}
+	return nil
+}
+
+func (s *Store) DeleteAllOfUser(ctx context.Context, uid string) (int, error) {
+	if uid == "" {
+		return 0, errors.NewInvalidParamError("uid: %v", uid)
+	}
+	docs, err := s.client.Collection(deviceCollection).Where("uid", operatorIs, uid).
+		Select().Documents(ctx).GetAll()
+	if err != nil {
+		return 0, errors.New("%v", err)
+	}
+	if len(docs) == 0 {
+		return 0, nil
+	}
+	// a user signs in on a handful of devices, far below the batch limit
+	batch := s.client.Batch()
+	for _, doc := range docs {
+		batch.Delete(doc.Ref)
+	}
+	if _, err := batch.Commit(ctx); err != nil {
+		return 0, errors.New("%v", err)
+	}
+	return len(docs), nil
+}
@@ -12,6 +12,7 @@ import (
 	"syscall"
 
 	"cloud.google.com/go/firestore"
+	"ohmnyom/internal/errors"
 )
 
 const firestoreEmulatorHostStr = "FIRESTORE_EMULATOR_HOST"
@@ -44,19 +45,25 @@ func KillEmulator() {
 	}
 }
 
-func RunEmulator() {
-	cmd := exec.Command("/Users/wooseok/google-cloud-sdk/bin/gcloud", "beta", "emulators", "firestore", "start",
+// RunEmulator starts the firestore emulator with the gcloud found in PATH.
+// It returns an error without starting anything if gcloud is not installed.
+func RunEmulator() error {
+	gcloud, err := exec.LookPath("gcloud")
+	if err != nil {
+		return errors.New("%v", err)
+	}
+	cmd := exec.Command(gcloud, "beta", "emulators", "firestore", "start",
 		"--host-port=localhost")
 	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
 
 	stderr, err := cmd.StderrPipe()
 	if err != nil {
-		log.Fatal(err)
+		return errors.New("%v", err)
 	}
 	defer stderr.Close()
 
 	if err := cmd.Start(); err != nil {
-		log.Fatal(err)
+		return errors.New("%v", err)
 	}
 
 	var wg sync.WaitGroup
@@ -90,6 +97,7 @@ func RunEmulator() {
 	}()
 
 	wg.Wait()
+	return nil
 }
 
 func NewEmulatorClient(ctx context.Context) *firestore.Client {
@@ -5,6 +5,8 @@ import (
 	"time"
 
 	"cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	"ohmnyom/domain/feed"
 	"ohmnyom/internal/errors"
 )
@@ -12,6 +14,10 @@ import (
 const (
 	petCollection  = "pets"
 	feedCollection = "feeds"
+	// feedsWrittenField of a pet is written with every feed checked for
+	// conflicts, so that two transactions adding feeds to the pet conflict even
+	// when neither reads the feed of the other.
+	feedsWrittenField = "feedsWritten"
 )
 
 type Store struct {
@@ -59,6 +65,28 @@ func (s *Store) GetFeedsOfPet(ctx context.Context, petId string, startAfter time
 	return ret, nil
 }
 
+func (s *Store) GetFeedsOfPetInRange(ctx context.Context, petId string, from, to time.Time) ([]*feed.Feed, error) {
+	if petId == "" {
+		return nil, errors.NewInvalidParamError("petId: %v", petId)
+	}
+	docs, err := s.client.Collection(petCollection).Doc(petId).Collection(feedCollection).
+		Where("timestamp", ">=", from).Where("timestamp", "<", to).
+		OrderBy("timestamp", firestore.Asc).Documents(ctx).GetAll()
+	if err != nil {
+		return nil, errors.New("%v", err)
+	}
+
+	ret := make([]*feed.Feed, len(docs))
+	for i, doc := range docs {
+		f := &feed.Feed{}
+		if err := doc.DataTo(f); err != nil {
+			return nil, errors.NewInvalidFormatError("%v", err)
+		}
+		ret[i] = f
+	}
+	return ret, nil
+}
+
 func (s *Store) Put(ctx context.Context, feed *feed.Feed) error {
 	if feed == nil || feed.Id == "" {
 		return errors.NewInvalidParamError("feed: %v", feed)
@@ -71,23 +99,31 @@ func (s *Store) Put(ctx context.Context, feed *feed.Feed) error {
 	return nil
 }
 
+func (s *Store) PutUnlessConflict(ctx context.Context, f *feed.Feed, window time.Duration) error {
+	if f == nil || f.Id == "" {
+		return errors.NewInvalidParamError("feed: %v", f)
+	}
+	petRef := s.client.Collection(petCollection).Doc(f.PetId)
+	return s.runChecked(ctx, petRef, func(tx *firestore.Transaction) (func() error, error) {
+		if err := s.checkConflict(tx, petRef, f, window); err != nil {
+			return nil, err
+		}
+		return func() error {
+			return tx.Create(petRef.Collection(feedCollection).Doc(f.Id), f)
+		}, nil
+	})
+}
+
 func (s *Store) Update(ctx context.Context, petId, feedId string, pathValues map[string]interface{}) error {
 	if petId == "" || feedId == "" {
 		return errors.NewInvalidParamError("petId: %v, feedId: %v", petId, feedId)
 	}
-
-	updates := make([]firestore.Update, len(pathValues))
-	i := 0
-	for path, value := range pathValues {
-		if !feed.IsUpdatableField(path) {
-			return errors.NewInvalidParamError("path %v is not updatable", path)
-		}
-		updates[i].Path = path
-		updates[i].Value = value
-		i++
+	updates, err := updatesOf(pathValues)
+	if err != nil {
+		return err
 	}
 
-	_, err := s.client.Collection(petCollection).Doc(petId).
+	_, err = s.client.Collection(petCollection).Doc(petId).
 		Collection(feedCollection).Doc(feedId).Update(ctx, updates)
 	if err != nil {
 		return errors.New("%v", err)
@@ -95,6 +131,100 @@ func (s *Store) Update(ctx context.Context, petId, feedId string, pathValues map
 	return nil
 }
 
+func (s *Store) UpdateUnlessConflict(ctx context.Context, petId, feedId string, pathValues map[string]interface{},
+	window time.Duration) error {
+	if petId == "" || feedId == "" {
+		return errors.NewInvalidParamError("petId: %v, feedId: %v", petId, feedId)
+	}
+	updates, err := updatesOf(pathValues)
+	if err != nil {
+		return err
+	}
+	petRef := s.client.Collection(petCollection).Doc(petId)
+	feedRef := petRef.Collection(feedCollection).Doc(feedId)
+	return s.runChecked(ctx, petRef, func(tx *firestore.Transaction) (func() error, error) {
+		doc, err := tx.Get(feedRef)
+		if status.Code(err) == codes.NotFound {
+			return nil, errors.NewNotFoundError("Feed{PetId: %v, Id: %v}", petId, feedId)
+		}
+		if err != nil {
+			return nil, errors.New("%v", err)
+		}
+		f := &feed.Feed{}
+		if err := doc.DataTo(f); err != nil {
+			return nil, errors.NewInvalidFormatError("%v", err)
+		}
+		if ts, ok := pathValues["timestamp"].(time.Time); ok {
+			f.Timestamp = ts
+		}
+		if err := s.checkConflict(tx, petRef, f, window); err != nil {
+			return nil, err
+		}
+		return func() error {
+			return tx.Update(feedRef, updates)
+		}, nil
+	})
+}
+
+// runChecked runs check and then the write it returns in a transaction that
+// also writes feedsWrittenField of the pet. check may only read, as no read
+// may follow a write.
+func (s *Store) runChecked(ctx context.Context, petRef *firestore.DocumentRef,
+	check func(tx *firestore.Transaction) (func() error, error)) error {
+	return s.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		write, err := check(tx)
+		if err != nil {
+			return err
+		}
+		if err := write(); err != nil {
+			return errors.New("%v", err)
+		}
+		if err := tx.Update(petRef, []firestore.Update{
+			{Path: feedsWrittenField, Value: firestore.ServerTimestamp},
+		}); err != nil {
+			return errors.New("%v", err)
+		}
+		return nil
+	})
+}
+
+// checkConflict reads the pet, so that the transaction takes it, and the feeds
+// within window of f, and returns the ConflictError of CheckConflict.
+func (s *Store) checkConflict(tx *firestore.Transaction, petRef *firestore.DocumentRef, f *feed.Feed,
+	window time.Duration) error {
+	if _, err := tx.Get(petRef); err != nil {
+		if status.Code(err) == codes.NotFound {
+			return errors.NewNotFoundError("Pet{Id: %v}", petRef.ID)
+		}
+		return errors.New("%v", err)
+	}
+	docs, err := tx.Documents(petRef.Collection(feedCollection).
+		Where("timestamp", ">", f.Timestamp.Add(-window)).
+		Where("timestamp", "<", f.Timestamp.Add(window))).GetAll()
+	if err != nil {
+		return errors.New("%v", err)
+	}
+	others := make([]*feed.Feed, len(docs))
+	for i, doc := range docs {
+		others[i] = &feed.Feed{}
+		if err := doc.DataTo(others[i]); err != nil {
+			return errors.NewInvalidFormatError("%v", err)
+		}
+	}
+	return feed.CheckConflict(others, f, window)
+}
+
+func updatesOf(pathValues map[string]interface{}) ([]firestore.Update, error) {
+	updates := make([]firestore.Update, 0, len(pathValues))
+	for path, value := range pathValues {
+		if !feed.IsUpdatableField(path) {
+			return nil, errors.NewInvalidParamError("path %v is not updatable", path)
+		}
+		updates = append(updates, firestore.Update{Path: path, Value: value})
+	}
+	return updates, nil
+}
+
 func (s *Store) Delete(ctx context.Context, petId, feedId string) error {
 	if _, err := s.client.Collection(petCollection).Doc(petId).
 		Collection(feedCollection).Doc(feedId).Delete(ctx); err != nil {
@@ -102,3 +232,26 @@ func (s *Store) Delete(ctx context.Context, petId, feedId string) error {
 	}
 	return nil
 }
+
+func (s *Store) DeleteBatchOfPet(ctx context.Context, petId string, limit int) (int, error) {
+	if petId == "" || limit <= 0 {
+		return 0, errors.NewInvalidParamError("petId: %v, limit: %v", petId, limit)
+	}
+	docs, err := s.client.Collection(petCollection).Doc(petId).Collection(feedCollection).
+		Select().Limit(limit).Documents(ctx).GetAll()
+	if err != nil {
+		return 0, errors.New("%v", err)
+	}
+	if len(docs) == 0 {
+		return 0, nil
+	}
+
+	batch := s.client.Batch()
+	for _, doc := range docs {
+		batch.Delete(doc.Ref)
+	}
+	if _, err := batch.Commit(ctx); err != nil {
+		return 0, errors.New("%v", err)
+	}
+	return len(docs), nil
+}
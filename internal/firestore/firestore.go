@@ -9,7 +9,16 @@ import (
 	"ohmnyom/internal/errors"
 )
 
+// NewClient connects to projectId with the service account key in credfile, or
+// with application default credentials if credfile is empty.
 func NewClient(ctx context.Context, projectId, credfile string) (*firestore.Client, error) {
+	if credfile == "" {
+		client, err := firestore.NewClient(ctx, projectId)
+		if err != nil {
+			return nil, errors.New("%v", err)
+		}
+		return client, nil
+	}
 	cred, err := os.ReadFile(credfile)
 	if err != nil {
 		return nil, errors.New("%v", err)
@@ -0,0 +1,129 @@
+package food
+
+import (
+	"context"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"ohmnyom/domain/food"
+	"ohmnyom/internal/errors"
+)
+
+const (
+	petCollection  = "pets"
+	foodCollection = "foods"
+)
+
+type Store struct {
+	client *firestore.Client
+}
+
+func New(ctx context.Context, client *firestore.Client) food.Store {
+	return &Store{
+		client: client,
+	}
+}
+
+func (s *Store) collection(petId string) *firestore.CollectionRef {
+	return s.client.Collection(petCollection).Doc(petId).Collection(foodCollection)
+}
+
+func (s *Store) Get(ctx context.Context, petId, id string) (*food.Food, error) {
+	if petId == "" || id == "" {
+		return nil, errors.NewInvalidParamError("petId: %v, id: %v", petId, id)
+	}
+	snapshot, err := s.collection(petId).Doc(id).Get(ctx)
+	switch status.Code(err) {
+	case codes.OK:
+		f := &food.Food{}
+		if suberr := snapshot.DataTo(f); suberr != nil {
+			return nil, errors.NewInvalidFormatError("%v", suberr)
+		}
+		return f, nil
+	case codes.NotFound:
+		return nil, errors.NewNotFoundError("Food{PetId: %v, Id: %v}", petId, id)
+	}
+	return nil, errors.New("%v", err)
+}
+
+func (s *Store) GetListOfPet(ctx context.Context, petId string) ([]*food.Food, error) {
+	if petId == "" {
+		return nil, errors.NewInvalidParamError("petId: %v", petId)
+	}
+	docs, err := s.collection(petId).OrderBy("created", firestore.Asc).Documents(ctx).GetAll()
+	if err != nil {
+		return nil, errors.New("%v", err)
+	}
+	ret := make([]*food.Food, len(docs))
+	for i, doc := range docs {
+		f := &food.Food{}
+		if err := doc.DataTo(f); err != nil {
+			return nil, errors.NewInvalidFormatError("%v", err)
+		}
+		ret[i] = f
+	}
+	return ret, nil
+}
+
+func (s *Store) Put(ctx context.Context, f *food.Food) error {
+	if f == nil || f.Id == "" || f.PetId == "" {
+		return errors.NewInvalidParamError("food: %v", f)
+	}
+	if _, err := s.collection(f.PetId).Doc(f.Id).Create(ctx, f); err != nil {
+		return errors.New("%v", err)
+	}
+	return nil
+}
+
+func (s *Store) Update(ctx context.Context, f *food.Food) error {
+	if f == nil || f.Id == "" || f.PetId == "" {
+		return errors.NewInvalidParamError("food: %v", f)
+	}
+	err := s.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		ref := s.collection(f.PetId).Doc(f.Id)
+		if _, err := tx.Get(ref); err != nil {
+			if status.Code(err) == codes.NotFound {
+				return errors.NewNotFoundError("Food{PetId: %v, Id: %v}", f.PetId, f.Id)
+			}
+			return errors.New("%v", err)
+		}
+		return tx.Set(ref, f)
+	})
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *Store) Delete(ctx context.Context, petId, id string) error {
+	if petId == "" || id == "" {
+		return errors.NewInvalidParamError("petId: %v, id: %v", petId, id)
+	}
+	if _, err := s.collection(petId).Doc(id).Delete(ctx); err != nil {
+		return errors.New("%v", err)
+	}
+	return nil
+}
+
+func (s *Store) DeleteAllOfPet(ctx context.Context, petId string) (int, error) {
+	if petId == "" {
+		return 0, errors.NewInvalidParamError("petId: %v", petId)
+	}
+	docs, err := s.collection(petId).Select().Documents(ctx).GetAll()
+	if err != nil {
+		return 0, errors.New("%v", err)
+	}
+	if len(docs) == 0 {
+		return 0, nil
+	}
+	// a pet has a handful of foods, far below the batch limit
+	batch := s.client.Batch()
+	for _, doc := range docs {
+		batch.Delete(doc.Ref)
+	}
+	if _, err := batch.Commit(ctx); err != nil {
+		return 0, errors.New("%v", err)
+	}
+	return len(docs), nil
+}
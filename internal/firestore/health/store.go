@@ -0,0 +1,139 @@
+package health
+
+import (
+	"context"
+	"sort"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"ohmnyom/domain/health"
+	"ohmnyom/internal/errors"
+)
+
+const (
+	petCollection    = "pets"
+	healthCollection = "foods"
+)
+
+type Store struct {
+	client *firestore.Client
+}
+
+func New(ctx context.Context, client *firestore.Client) health.Store {
+	return &Store{
+		client: client,
+	}
+}
+
+func (s *Store) collection(petId string) *firestore.CollectionRef {
+	return s.client.Collection(petCollection).Doc(petId).Collection(healthCollection)
+}
+
+func (s *Store) Get(ctx context.Context, petId, id string) (*health.Record, error) {
+	if petId == "" || id == "" {
+		return nil, errors.NewInvalidParamError("petId: %v, id: %v", petId, id)
+	}
+	snapshot, err := s.collection(petId).Doc(id).Get(ctx)
+	switch status.Code(err) {
+	case codes.OK:
+		r := &health.Record{}
+		if suberr := snapshot.DataTo(r); suberr != nil {
+			return nil, errors.NewInvalidFormatError("%v", suberr)
+		}
+		return r, nil
+	case codes.NotFound:
+		return nil, errors.NewNotFoundError("Record{PetId: %v, Id: %v}", petId, id)
+	}
+	return nil, errors.New("%v", err)
+}
+
+func (s *Store) GetListOfPet(ctx context.Context, petId string, kind health.Kind) ([]*health.Record, error) {
+	if petId == "" {
+		return nil, errors.NewInvalidParamError("petId: %v", petId)
+	}
+	query := s.collection(petId).Query
+	if kind != "" {
+		query = query.Where("kind", "==", kind)
+	}
+	docs, err := query.Documents(ctx).GetAll()
+	if err != nil {
+		return nil, errors.New("%v", err)
+	}
+	ret := make([]*health.Record, len(docs))
+	for i, doc := range docs {
+		r := &health.Record{}
+		if err := doc.DataTo(r); err != nil {
+			return nil, errors.NewInvalidFormatError("%v", err)
+		}
+		ret[i] = r
+	}
+	// sorted here, ordering by date after filtering by kind needs a composite
+	// index for a few dozen records
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Date.After(ret[j].Date)
+	})
+	return ret, nil
+}
+
+func (s *Store) Put(ctx context.Context, r *health.Record) error {
+	if r == nil || r.Id == "" || r.PetId == "" {
+		return errors.NewInvalidParamError("record: %v", r)
+	}
+	if _, err := s.collection(r.PetId).Doc(r.Id).Create(ctx, r); err != nil {
+		return errors.New("%v", err)
+	}
+	return nil
+}
+
+func (s *Store) Update(ctx context.Context, r *health.Record) error {
+	if r == nil || r.Id == "" || r.PetId == "" {
+		return errors.NewInvalidParamError("record: %v", r)
+	}
+	err := s.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		ref := s.collection(r.PetId).Doc(r.Id)
+		if _, err := tx.Get(ref); err != nil {
+			if status.Code(err) == codes.NotFound {
+				return errors.NewNotFoundError("Record{PetId: %v, Id: %v}", r.PetId, r.Id)
+			}
+			return errors.New("%v", err)
+		}
+		return tx.Set(ref, r)
+	})
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *Store) Delete(ctx context.Context, petId, id string) error {
+	if petId == "" || id == "" {
+		return errors.NewInvalidParamError("petId: %v, id: %v", petId, id)
+	}
+	if _, err := s.collection(petId).Doc(id).Delete(ctx); err != nil {
+		return errors.New("%v", err)
+	}
+	return nil
+}
+
+func (s *Store) DeleteAllOfPet(ctx context.Context, petId string) (int, error) {
+	if petId == "" {
+		return 0, errors.NewInvalidParamError("petId: %v", petId)
+	}
+	docs, err := s.collection(petId).Select().Documents(ctx).GetAll()
+	if err != nil {
+		return 0, errors.New("%v", err)
+	}
+	if len(docs) == 0 {
+		return 0, nil
+	}
+	// a pet has a few dozen records, far below the batch limit
+	batch := s.client.Batch()
+	for _, doc := range docs {
+		batch.Delete(doc.Ref)
+	}
+	if _, err := batch.Commit(ctx); err != nil {
+		return 0, errors.New("%v", err)
+	}
+	return len(docs), nil
+}
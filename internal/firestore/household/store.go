@@ -0,0 +1,84 @@
+package household
+
+import (
+	"context"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"ohmnyom/domain/household"
+	"ohmnyom/internal/errors"
+)
+
+const (
+	householdCollection = "households"
+	operatorContains    = "array-contains"
+)
+
+type Store struct {
+	client *firestore.Client
+}
+
+func New(ctx context.Context, client *firestore.Client) household.Store {
+	return &Store{
+		client: client,
+	}
+}
+
+func (s *Store) Get(ctx context.Context, id string) (*household.Household, error) {
+	if id == "" {
+		return nil, errors.NewInvalidParamError("id: %v", id)
+	}
+	snapshot, err := s.client.Collection(householdCollection).Doc(id).Get(ctx)
+	switch status.Code(err) {
+	case codes.OK:
+		h := &household.Household{}
+		if suberr := snapshot.DataTo(h); suberr != nil {
+			return nil, errors.NewInvalidFormatError("%v", suberr)
+		}
+		return h, nil
+	case codes.NotFound:
+		return nil, errors.NewNotFoundError("Household{Id: %v}", id)
+	}
+	return nil, errors.New("%v", err)
+}
+
+func (s *Store) GetListOfUser(ctx context.Context, uid string) ([]*household.Household, error) {
+	if uid == "" {
+		return nil, errors.NewInvalidParamError("uid: %v", uid)
+	}
+	docs, err := s.client.Collection(householdCollection).Where("members", operatorContains, uid).
+		Documents(ctx).GetAll()
+	if err != nil {
+		return nil, errors.New("%v", err)
+	}
+	ret := make([]*household.Household, len(docs))
+	for i, doc := range docs {
+		h := &household.Household{}
+		if err := doc.DataTo(h); err != nil {
+			return nil, errors.NewInvalidFormatError("%v", err)
+		}
+		ret[i] = h
+	}
+	return ret, nil
+}
+
+func (s *Store) Put(ctx context.Context, h *household.Household) error {
+	if h == nil || h.Id == "" {
+		return errors.NewInvalidParamError("household: %v", h)
+	}
+	if _, err := s.client.Collection(householdCollection).Doc(h.Id).Create(ctx, h); err != nil {
+		return errors.New("%v", err)
+	}
+	return nil
+}
+
+func (s *Store) Delete(ctx context.Context, id string) error {
+	if id == "" {
+		return errors.NewInvalidParamError("id: %v", id)
+	}
+	if _, err := s.client.Collection(householdCollection).Doc(id).Delete(ctx); err != nil {
+		return errors.New("%v", err)
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package idempotency
+
+import (
+	"context"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"ohmnyom/domain/idempotency"
+	"ohmnyom/internal/errors"
+)
+
+const idempotencyCollection = "idempotencyKeys"
+
+type Store struct {
+	client *firestore.Client
+}
+
+func New(ctx context.Context, client *firestore.Client) idempotency.Store {
+	return &Store{
+		client: client,
+	}
+}
+
+func (s *Store) Begin(ctx context.Context, record *idempotency.Record) (*idempotency.Record, error) {
+	if record == nil || record.Id == "" {
+		return nil, errors.NewInvalidParamError("record: %v", record)
+	}
+	ref := s.client.Collection(idempotencyCollection).Doc(record.Id)
+	var existing *idempotency.Record
+	err := s.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		existing = nil
+		snapshot, err := tx.Get(ref)
+		switch status.Code(err) {
+		case codes.OK:
+			stored := &idempotency.Record{}
+			if err := snapshot.DataTo(stored); err != nil {
+				return errors.NewInvalidFormatError("%v", err)
+			}
+			if stored.Expires.After(time.Now()) {
+				existing = stored
+				return nil
+			}
+		case codes.NotFound:
+		default:
+			return errors.New("%v", err)
+		}
+		return tx.Set(ref, record)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return existing, nil
+}
+
+func (s *Store) Complete(ctx context.Context, id string, response []byte, expires time.Time) error {
+	if id == "" {
+		return errors.NewInvalidParamError("id: %v", id)
+	}
+	_, err := s.client.Collection(idempotencyCollection).Doc(id).Update(ctx, []firestore.Update{
+		{Path: "state", Value: idempotency.StateDone},
+		{Path: "response", Value: response},
+		{Path: "expires", Value: expires},
+	})
+	if err != nil {
+		return errors.New("%v", err)
+	}
+	return nil
+}
+
+func (s *Store) Delete(ctx context.Context, id string) error {
+	if id == "" {
+		return errors.NewInvalidParamError("id: %v", id)
+	}
+	if _, err := s.client.Collection(idempotencyCollection).Doc(id).Delete(ctx); err != nil {
+		return errors.New("%v", err)
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package invite
+
+import (
+	"context"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"ohmnyom/domain/invite"
+	"ohmnyom/internal/errors"
+)
+
+const (
+	inviteCollection = "invites"
+	operatorIs       = "=="
+)
+
+type Store struct {
+	client *firestore.Client
+}
+
+func New(ctx context.Context, client *firestore.Client) invite.Store {
+	return &Store{
+		client: client,
+	}
+}
+
+func (s *Store) Get(ctx context.Context, code string) (*invite.Invite, error) {
+	if code == "" {
+		return nil, errors.NewInvalidParamError("code: %v", code)
+	}
+	snapshot, err := s.client.Collection(inviteCollection).Doc(code).Get(ctx)
+	switch status.Code(err) {
+	case codes.OK:
+		i := &invite.Invite{}
+		if suberr := snapshot.DataTo(i); suberr != nil {
+			return nil, errors.NewInvalidFormatError("%v", suberr)
+		}
+		return i, nil
+	case codes.NotFound:
+		return nil, errors.NewNotFoundError("Invite{Code: %v}", code)
+	}
+	return nil, errors.New("%v", err)
+}
+
+func (s *Store) GetListOfPet(ctx context.Context, petId string) ([]*invite.Invite, error) {
+	iter := s.client.Collection(inviteCollection).Where("petId", operatorIs, petId).Documents(ctx)
+	ret := make([]*invite.Invite, 0)
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, errors.New("%v", err)
+		}
+		i := &invite.Invite{}
+		if suberr := doc.DataTo(i); suberr != nil {
+			return nil, errors.NewInvalidFormatError("%v", suberr)
+		}
+		ret = append(ret, i)
+	}
+	return ret, nil
+}
+
+func (s *Store) Put(ctx context.Context, i *invite.Invite) error {
+	if i == nil || i.Code == "" {
+		return errors.NewInvalidParamError("invite: %v", i)
+	}
+	_, err := s.client.Collection(inviteCollection).Doc(i.Code).Create(ctx, i)
+	if err != nil {
+		return errors.New("%v", err)
+	}
+	return nil
+}
+
+func (s *Store) Delete(ctx context.Context, code string) error {
+	if code == "" {
+		return errors.NewInvalidParamError("code: %v", code)
+	}
+	if _, err := s.client.Collection(inviteCollection).Doc(code).Delete(ctx); err != nil {
+		return errors.New("%v", err)
+	}
+	return nil
+}
@@ -2,6 +2,7 @@ package pet
 
 import (
 	"context"
+	"time"
 
 	"cloud.google.com/go/firestore"
 	"google.golang.org/api/iterator"
@@ -14,6 +15,12 @@ import (
 const (
 	petCollection = "pets"
 	operatorIn    = "in"
+	operatorIs    = "=="
+	// operatorLessOrEqual finds pets by the end of their grants.
+	operatorLessOrEqual = "<="
+	// migrationCollection has a document for every migration that finished.
+	migrationCollection = "migrations"
+	rolesMigration      = "petRoles"
 )
 
 type Store struct {
@@ -102,13 +109,14 @@ func (s *Store) Delete(ctx context.Context, id string) error {
 	return nil
 }
 
-func (s *Store) AddFeeder(ctx context.Context, id, uid string) error {
-	if id == "" || uid == "" {
-		return errors.NewInvalidParamError("id: %v, uid: %v", id, uid)
+func (s *Store) AddMember(ctx context.Context, id, uid string, role pet.Role) error {
+	if id == "" || uid == "" || role == pet.RoleNone {
+		return errors.NewInvalidParamError("id: %v, uid: %v, role: %v", id, uid, role)
 	}
 	_, err := s.client.Collection(petCollection).Doc(id).Update(ctx,
 		[]firestore.Update{
-			{Path: "feeders", Value: firestore.ArrayUnion(uid)},
+			{Path: pet.FeedersField, Value: firestore.ArrayUnion(uid)},
+			{FieldPath: firestore.FieldPath{pet.RolesField, uid}, Value: role.String()},
 		})
 	if err != nil {
 		return errors.New("%v", err)
@@ -116,16 +124,109 @@ func (s *Store) AddFeeder(ctx context.Context, id, uid string) error {
 	return nil
 }
 
-func (s *Store) DeleteFeeder(ctx context.Context, id, uid string) error {
+func (s *Store) DeleteMember(ctx context.Context, id, uid string) error {
 	if id == "" || uid == "" {
 		return errors.NewInvalidParamError("id: %v, uid: %v", id, uid)
 	}
 	_, err := s.client.Collection(petCollection).Doc(id).Update(ctx,
 		[]firestore.Update{
-			{Path: "feeders", Value: firestore.ArrayRemove(uid)},
+			{Path: pet.FeedersField, Value: firestore.ArrayRemove(uid)},
+			{FieldPath: firestore.FieldPath{pet.RolesField, uid}, Value: firestore.Delete},
+			{FieldPath: firestore.FieldPath{pet.GrantsField, uid}, Value: firestore.Delete},
 		})
 	if err != nil {
 		return errors.New("%v", err)
 	}
 	return nil
 }
+
+func (s *Store) GetListOfHousehold(ctx context.Context, householdId string) (pet.List, error) {
+	if householdId == "" {
+		return nil, errors.NewInvalidParamError("householdId: %v", householdId)
+	}
+	return s.getList(ctx, s.client.Collection(petCollection).Where(pet.HouseholdField, operatorIs, householdId))
+}
+
+func (s *Store) GetListOfExpiredGrants(ctx context.Context, now time.Time) (pet.List, error) {
+	return s.getList(ctx, s.client.Collection(petCollection).Where(pet.GrantsExpireField, operatorLessOrEqual, now))
+}
+
+func (s *Store) getList(ctx context.Context, q firestore.Query) (pet.List, error) {
+	query := q.Documents(ctx)
+	defer query.Stop()
+	ret := make([]*pet.Pet, 0)
+	for {
+		doc, err := query.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, errors.New("%v", err)
+		}
+		p := &pet.Pet{}
+		if suberr := doc.DataTo(p); suberr != nil {
+			return nil, errors.NewInvalidFormatError("%v", suberr)
+		}
+		ret = append(ret, p)
+	}
+	return ret, nil
+}
+
+// MigrateRoles gives the roles of the order of their feeders to the members
+// of pets stored before roles, and returns how many pets it changed. Pets work
+// unmigrated as well, so it may run while serving. Once it went through every
+// pet it records so in the migrations collection, and later calls return
+// right away, as every pet stored since has roles.
+func MigrateRoles(ctx context.Context, client *firestore.Client) (int, error) {
+	marker := client.Collection(migrationCollection).Doc(rolesMigration)
+	_, err := marker.Get(ctx)
+	switch status.Code(err) {
+	case codes.OK:
+		return 0, nil
+	case codes.NotFound:
+	default:
+		return 0, errors.New("%v", err)
+	}
+
+	docs := client.Collection(petCollection).Documents(ctx)
+	defer docs.Stop()
+	migrated := 0
+	for {
+		doc, err := docs.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return migrated, errors.New("%v", err)
+		}
+		changed := false
+		err = client.RunTransaction(ctx, func(ctx context.Context, t *firestore.Transaction) error {
+			changed = false
+			snapshot, err := t.Get(doc.Ref)
+			if err != nil {
+				return err
+			}
+			p := &pet.Pet{}
+			if err := snapshot.DataTo(p); err != nil {
+				return errors.NewInvalidFormatError("%v", err)
+			}
+			if changed = p.MigrateRoles(); !changed {
+				return nil
+			}
+			return t.Update(doc.Ref, []firestore.Update{{Path: pet.RolesField, Value: p.Roles}})
+		})
+		if status.Code(err) == codes.NotFound {
+			continue
+		}
+		if err != nil {
+			return migrated, errors.New("%v", err)
+		}
+		if changed {
+			migrated++
+		}
+	}
+	if _, err := marker.Set(ctx, map[string]interface{}{"finished": time.Now().UTC()}); err != nil {
+		return migrated, errors.New("%v", err)
+	}
+	return migrated, nil
+}
@@ -0,0 +1,37 @@
+package reminder
+
+import (
+	"context"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"ohmnyom/domain/reminder"
+	"ohmnyom/internal/errors"
+)
+
+const reminderCollection = "reminders"
+
+type Store struct {
+	client *firestore.Client
+}
+
+func New(ctx context.Context, client *firestore.Client) reminder.Store {
+	return &Store{
+		client: client,
+	}
+}
+
+func (s *Store) Claim(ctx context.Context, r *reminder.Reminder) (bool, error) {
+	if r == nil || r.Id == "" {
+		return false, errors.NewInvalidParamError("reminder: %v", r)
+	}
+	_, err := s.client.Collection(reminderCollection).Doc(r.Id).Create(ctx, r)
+	switch status.Code(err) {
+	case codes.OK:
+		return true, nil
+	case codes.AlreadyExists:
+		return false, nil
+	}
+	return false, errors.New("%v", err)
+}
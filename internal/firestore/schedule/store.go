@@ -0,0 +1,150 @@
+package schedule
+
+import (
+	"context"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"ohmnyom/domain/schedule"
+	"ohmnyom/internal/errors"
+)
+
+const (
+	petCollection      = "pets"
+	scheduleCollection = "schedules"
+)
+
+type Store struct {
+	client *firestore.Client
+}
+
+func New(ctx context.Context, client *firestore.Client) schedule.Store {
+	return &Store{
+		client: client,
+	}
+}
+
+func (s *Store) collection(petId string) *firestore.CollectionRef {
+	return s.client.Collection(petCollection).Doc(petId).Collection(scheduleCollection)
+}
+
+func (s *Store) Get(ctx context.Context, petId, id string) (*schedule.Schedule, error) {
+	if petId == "" || id == "" {
+		return nil, errors.NewInvalidParamError("petId: %v, id: %v", petId, id)
+	}
+	snapshot, err := s.collection(petId).Doc(id).Get(ctx)
+	switch status.Code(err) {
+	case codes.OK:
+		sc := &schedule.Schedule{}
+		if suberr := snapshot.DataTo(sc); suberr != nil {
+			return nil, errors.NewInvalidFormatError("%v", suberr)
+		}
+		return sc, nil
+	case codes.NotFound:
+		return nil, errors.NewNotFoundError("Schedule{PetId: %v, Id: %v}", petId, id)
+	}
+	return nil, errors.New("%v", err)
+}
+
+func (s *Store) GetListOfPet(ctx context.Context, petId string) ([]*schedule.Schedule, error) {
+	if petId == "" {
+		return nil, errors.NewInvalidParamError("petId: %v", petId)
+	}
+	docs, err := s.collection(petId).OrderBy("created", firestore.Asc).Documents(ctx).GetAll()
+	if err != nil {
+		return nil, errors.New("%v", err)
+	}
+	ret := make([]*schedule.Schedule, len(docs))
+	for i, doc := range docs {
+		sc := &schedule.Schedule{}
+		if err := doc.DataTo(sc); err != nil {
+			return nil, errors.NewInvalidFormatError("%v", err)
+		}
+		ret[i] = sc
+	}
+	return ret, nil
+}
+
+func (s *Store) GetAll(ctx context.Context) ([]*schedule.Schedule, error) {
+	iter := s.client.CollectionGroup(scheduleCollection).Documents(ctx)
+	ret := make([]*schedule.Schedule, 0)
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, errors.New("%v", err)
+		}
+		sc := &schedule.Schedule{}
+		if suberr := doc.DataTo(sc); suberr != nil {
+			return nil, errors.NewInvalidFormatError("%v", suberr)
+		}
+		ret = append(ret, sc)
+	}
+	return ret, nil
+}
+
+func (s *Store) Put(ctx context.Context, sc *schedule.Schedule) error {
+	if sc == nil || sc.Id == "" || sc.PetId == "" {
+		return errors.NewInvalidParamError("schedule: %v", sc)
+	}
+	if _, err := s.collection(sc.PetId).Doc(sc.Id).Create(ctx, sc); err != nil {
+		return errors.New("%v", err)
+	}
+	return nil
+}
+
+func (s *Store) Update(ctx context.Context, sc *schedule.Schedule) error {
+	if sc == nil || sc.Id == "" || sc.PetId == "" {
+		return errors.NewInvalidParamError("schedule: %v", sc)
+	}
+	err := s.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		ref := s.collection(sc.PetId).Doc(sc.Id)
+		if _, err := tx.Get(ref); err != nil {
+			if status.Code(err) == codes.NotFound {
+				return errors.NewNotFoundError("Schedule{PetId: %v, Id: %v}", sc.PetId, sc.Id)
+			}
+			return errors.New("%v", err)
+		}
+		return tx.Set(ref, sc)
+	})
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *Store) Delete(ctx context.Context, petId, id string) error {
+	if petId == "" || id == "" {
+		return errors.NewInvalidParamError("petId: %v, id: %v", petId, id)
+	}
+	if _, err := s.collection(petId).Doc(id).Delete(ctx); err != nil {
+		return errors.New("%v", err)
+	}
+	return nil
+}
+
+func (s *Store) DeleteAllOfPet(ctx context.Context, petId string) (int, error) {
+	if petId == "" {
+		return 0, errors.NewInvalidParamError("petId: %v", petId)
+	}
+	docs, err := s.collection(petId).Select().Documents(ctx).GetAll()
+	if err != nil {
+		return 0, errors.New("%v", err)
+	}
+	if len(docs) == 0 {
+		return 0, nil
+	}
+	// a pet has a handful of schedules, far below the batch limit
+	batch := s.client.Batch()
+	for _, doc := range docs {
+		batch.Delete(doc.Ref)
+	}
+	if _, err := batch.Commit(ctx); err != nil {
+		return 0, errors.New("%v", err)
+	}
+	return len(docs), nil
+}
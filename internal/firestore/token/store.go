@@ -0,0 +1,173 @@
+package token
+
+import (
+	"context"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"ohmnyom/domain/token"
+	"ohmnyom/internal/errors"
+)
+
+const (
+	familyCollection     = "tokenFamilies"
+	revocationCollection = "revokedTokens"
+	generationCollection = "tokenGenerations"
+)
+
+type Store struct {
+	client *firestore.Client
+}
+
+func New(ctx context.Context, client *firestore.Client) token.Store {
+	return &Store{
+		client: client,
+	}
+}
+
+func (s *Store) Get(ctx context.Context, id string) (*token.Family, error) {
+	if id == "" {
+		return nil, errors.NewInvalidParamError("id: %v", id)
+	}
+	snapshot, err := s.client.Collection(familyCollection).Doc(id).Get(ctx)
+	switch status.Code(err) {
+	case codes.OK:
+		f := &token.Family{}
+		if suberr := snapshot.DataTo(f); suberr != nil {
+			return nil, errors.NewInvalidFormatError("%v", suberr)
+		}
+		return f, nil
+	case codes.NotFound:
+		return nil, errors.NewNotFoundError("Family{Id: %v}", id)
+	}
+	return nil, errors.New("%v", err)
+}
+
+func (s *Store) Put(ctx context.Context, f *token.Family) error {
+	if f == nil || f.Id == "" {
+		return errors.NewInvalidParamError("family: %v", f)
+	}
+	_, err := s.client.Collection(familyCollection).Doc(f.Id).Create(ctx, f)
+	if err != nil {
+		return errors.New("%v", err)
+	}
+	return nil
+}
+
+func (s *Store) Rotate(ctx context.Context, id, presented, next string, expires time.Time) error {
+	if id == "" || presented == "" || next == "" {
+		return errors.NewInvalidParamError("id: %v, presented: %v, next: %v", id, presented, next)
+	}
+	ref := s.client.Collection(familyCollection).Doc(id)
+	reused := false
+	err := s.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		reused = false
+		snapshot, err := tx.Get(ref)
+		switch status.Code(err) {
+		case codes.OK:
+		case codes.NotFound:
+			return errors.NewAuthenticationError("unknown token family %v", id)
+		default:
+			return errors.New("%v", err)
+		}
+		f := &token.Family{}
+		if err := snapshot.DataTo(f); err != nil {
+			return errors.NewInvalidFormatError("%v", err)
+		}
+		if f.Revoked {
+			return errors.NewAuthenticationError("token family %v revoked", id)
+		}
+		if f.Current != presented {
+			// keep the revocation, the error is reported after commit
+			reused = true
+			return tx.Update(ref, []firestore.Update{{Path: "revoked", Value: true}})
+		}
+		return tx.Update(ref, []firestore.Update{
+			{Path: "current", Value: next},
+			{Path: "expires", Value: expires},
+		})
+	})
+	if err != nil {
+		return err
+	}
+	if reused {
+		return errors.NewAuthenticationError("refresh token reused, token family %v revoked", id)
+	}
+	return nil
+}
+
+func (s *Store) Revoke(ctx context.Context, id string) error {
+	if id == "" {
+		return errors.NewInvalidParamError("id: %v", id)
+	}
+	_, err := s.client.Collection(familyCollection).Doc(id).Update(ctx, []firestore.Update{
+		{Path: "revoked", Value: true},
+	})
+	if err != nil {
+		return errors.New("%v", err)
+	}
+	return nil
+}
+
+func (s *Store) RevokeToken(ctx context.Context, id, uid string, expires time.Time) error {
+	if id == "" {
+		return errors.NewInvalidParamError("id: %v", id)
+	}
+	_, err := s.client.Collection(revocationCollection).Doc(id).Set(ctx, &token.Revocation{
+		Id:      id,
+		Uid:     uid,
+		Expires: expires,
+	})
+	if err != nil {
+		return errors.New("%v", err)
+	}
+	return nil
+}
+
+func (s *Store) IsTokenRevoked(ctx context.Context, id string) (bool, error) {
+	if id == "" {
+		return false, errors.NewInvalidParamError("id: %v", id)
+	}
+	_, err := s.client.Collection(revocationCollection).Doc(id).Get(ctx)
+	switch status.Code(err) {
+	case codes.OK:
+		return true, nil
+	case codes.NotFound:
+		return false, nil
+	}
+	return false, errors.New("%v", err)
+}
+
+func (s *Store) GetGeneration(ctx context.Context, uid string) (int64, error) {
+	if uid == "" {
+		return 0, errors.NewInvalidParamError("uid: %v", uid)
+	}
+	snapshot, err := s.client.Collection(generationCollection).Doc(uid).Get(ctx)
+	switch status.Code(err) {
+	case codes.OK:
+		g := &token.Generation{}
+		if suberr := snapshot.DataTo(g); suberr != nil {
+			return 0, errors.NewInvalidFormatError("%v", suberr)
+		}
+		return g.Generation, nil
+	case codes.NotFound:
+		return 0, nil
+	}
+	return 0, errors.New("%v", err)
+}
+
+func (s *Store) BumpGeneration(ctx context.Context, uid string) (int64, error) {
+	if uid == "" {
+		return 0, errors.NewInvalidParamError("uid: %v", uid)
+	}
+	_, err := s.client.Collection(generationCollection).Doc(uid).Set(ctx, map[string]interface{}{
+		"uid":        uid,
+		"generation": firestore.Increment(1),
+	}, firestore.MergeAll)
+	if err != nil {
+		return 0, errors.New("%v", err)
+	}
+	return s.GetGeneration(ctx, uid)
+}
@@ -0,0 +1,279 @@
+package uow
+
+import (
+	"context"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"ohmnyom/domain/audit"
+	"ohmnyom/domain/deletion"
+	"ohmnyom/domain/household"
+	"ohmnyom/domain/invite"
+	"ohmnyom/domain/pet"
+	"ohmnyom/domain/uow"
+	"ohmnyom/domain/user"
+	"ohmnyom/internal/errors"
+	auditstore "ohmnyom/internal/firestore/audit"
+)
+
+const (
+	userCollection      = "users"
+	petCollection       = "pets"
+	inviteCollection    = "invites"
+	deletionCollection  = "deletions"
+	householdCollection = "households"
+)
+
+type UnitOfWork struct {
+	client *firestore.Client
+}
+
+func New(ctx context.Context, client *firestore.Client) uow.UnitOfWork {
+	return &UnitOfWork{
+		client: client,
+	}
+}
+
+func (u *UnitOfWork) Run(ctx context.Context, fn func(ctx context.Context, tx uow.Tx) error) error {
+	return u.client.RunTransaction(ctx, func(ctx context.Context, t *firestore.Transaction) error {
+		return fn(ctx, &tx{client: u.client, t: t})
+	})
+}
+
+type tx struct {
+	client *firestore.Client
+	t      *firestore.Transaction
+}
+
+// get reads the doc into v, or returns a NotFoundError described by name.
+func (x *tx) get(ref *firestore.DocumentRef, v interface{}, name string) error {
+	snapshot, err := x.t.Get(ref)
+	switch status.Code(err) {
+	case codes.OK:
+		if suberr := snapshot.DataTo(v); suberr != nil {
+			return errors.NewInvalidFormatError("%v", suberr)
+		}
+		return nil
+	case codes.NotFound:
+		return errors.NewNotFoundError("%v", name)
+	}
+	return errors.New("%v", err)
+}
+
+func (x *tx) update(ref *firestore.DocumentRef, updates []firestore.Update) error {
+	if err := x.t.Update(ref, updates); err != nil {
+		return errors.New("%v", err)
+	}
+	return nil
+}
+
+func (x *tx) GetUser(id string) (*user.User, error) {
+	if id == "" {
+		return nil, errors.NewInvalidParamError("id: %v", id)
+	}
+	u := &user.User{}
+	if err := x.get(x.client.Collection(userCollection).Doc(id), u, "User{Id: "+id+"}"); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+func (x *tx) GetPet(id string) (*pet.Pet, error) {
+	if id == "" {
+		return nil, errors.NewInvalidParamError("id: %v", id)
+	}
+	p := &pet.Pet{}
+	if err := x.get(x.client.Collection(petCollection).Doc(id), p, "Pet{Id: "+id+"}"); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (x *tx) GetInvite(code string) (*invite.Invite, error) {
+	if code == "" {
+		return nil, errors.NewInvalidParamError("code: %v", code)
+	}
+	i := &invite.Invite{}
+	if err := x.get(x.client.Collection(inviteCollection).Doc(code), i, "Invite{Code: "+code+"}"); err != nil {
+		return nil, err
+	}
+	return i, nil
+}
+
+func (x *tx) GetHousehold(id string) (*household.Household, error) {
+	if id == "" {
+		return nil, errors.NewInvalidParamError("id: %v", id)
+	}
+	h := &household.Household{}
+	if err := x.get(x.client.Collection(householdCollection).Doc(id), h, "Household{Id: "+id+"}"); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+func (x *tx) DeleteUser(id string) error {
+	if id == "" {
+		return errors.NewInvalidParamError("id: %v", id)
+	}
+	if err := x.t.Delete(x.client.Collection(userCollection).Doc(id)); err != nil {
+		return errors.New("%v", err)
+	}
+	return nil
+}
+
+func (x *tx) AddUserPet(id, petId string) error {
+	if id == "" || petId == "" {
+		return errors.NewInvalidParamError("id: %v, petId: %v", id, petId)
+	}
+	return x.update(x.client.Collection(userCollection).Doc(id), []firestore.Update{
+		{Path: "pets", Value: firestore.ArrayUnion(petId)},
+	})
+}
+
+func (x *tx) DeleteUserPet(id, petId string) error {
+	if id == "" || petId == "" {
+		return errors.NewInvalidParamError("id: %v, petId: %v", id, petId)
+	}
+	return x.update(x.client.Collection(userCollection).Doc(id), []firestore.Update{
+		{Path: "pets", Value: firestore.ArrayRemove(petId)},
+	})
+}
+
+func (x *tx) CreatePet(p *pet.Pet) error {
+	if p == nil || p.Id == "" {
+		return errors.NewInvalidParamError("p: %v", p)
+	}
+	if err := x.t.Create(x.client.Collection(petCollection).Doc(p.Id), p); err != nil {
+		return errors.New("%v", err)
+	}
+	return nil
+}
+
+func (x *tx) DeletePet(id string) error {
+	if id == "" {
+		return errors.NewInvalidParamError("id: %v", id)
+	}
+	if err := x.t.Delete(x.client.Collection(petCollection).Doc(id)); err != nil {
+		return errors.New("%v", err)
+	}
+	return nil
+}
+
+func (x *tx) AddMember(petId, uid string, role pet.Role) error {
+	if petId == "" || uid == "" || role == pet.RoleNone {
+		return errors.NewInvalidParamError("petId: %v, uid: %v, role: %v", petId, uid, role)
+	}
+	return x.update(x.client.Collection(petCollection).Doc(petId), []firestore.Update{
+		{Path: pet.FeedersField, Value: firestore.ArrayUnion(uid)},
+		{FieldPath: firestore.FieldPath{pet.RolesField, uid}, Value: role.String()},
+	})
+}
+
+func (x *tx) DeleteMember(petId, uid string) error {
+	if petId == "" || uid == "" {
+		return errors.NewInvalidParamError("petId: %v, uid: %v", petId, uid)
+	}
+	return x.update(x.client.Collection(petCollection).Doc(petId), []firestore.Update{
+		{Path: pet.FeedersField, Value: firestore.ArrayRemove(uid)},
+		{FieldPath: firestore.FieldPath{pet.RolesField, uid}, Value: firestore.Delete},
+		{FieldPath: firestore.FieldPath{pet.GrantsField, uid}, Value: firestore.Delete},
+	})
+}
+
+func (x *tx) SetGrants(petId string, grants map[string]*pet.Grant) error {
+	if petId == "" {
+		return errors.NewInvalidParamError("petId: %v", petId)
+	}
+	var grantsValue, expireValue interface{} = grants, pet.GrantsExpire(grants)
+	if len(grants) == 0 {
+		grantsValue, expireValue = firestore.Delete, firestore.Delete
+	}
+	return x.update(x.client.Collection(petCollection).Doc(petId), []firestore.Update{
+		{Path: pet.GrantsField, Value: grantsValue},
+		{Path: pet.GrantsExpireField, Value: expireValue},
+	})
+}
+
+func (x *tx) SetPetHousehold(petId, householdId string) error {
+	if petId == "" {
+		return errors.NewInvalidParamError("petId: %v", petId)
+	}
+	var value interface{} = householdId
+	if householdId == "" {
+		value = firestore.Delete
+	}
+	return x.update(x.client.Collection(petCollection).Doc(petId), []firestore.Update{
+		{Path: pet.HouseholdField, Value: value},
+	})
+}
+
+func (x *tx) CreateHousehold(h *household.Household) error {
+	if h == nil || h.Id == "" {
+		return errors.NewInvalidParamError("h: %v", h)
+	}
+	if err := x.t.Create(x.client.Collection(householdCollection).Doc(h.Id), h); err != nil {
+		return errors.New("%v", err)
+	}
+	return nil
+}
+
+func (x *tx) DeleteHousehold(id string) error {
+	if id == "" {
+		return errors.NewInvalidParamError("id: %v", id)
+	}
+	if err := x.t.Delete(x.client.Collection(householdCollection).Doc(id)); err != nil {
+		return errors.New("%v", err)
+	}
+	return nil
+}
+
+func (x *tx) AddHouseholdMember(id, uid string) error {
+	if id == "" || uid == "" {
+		return errors.NewInvalidParamError("id: %v, uid: %v", id, uid)
+	}
+	return x.update(x.client.Collection(householdCollection).Doc(id), []firestore.Update{
+		{Path: "members", Value: firestore.ArrayUnion(uid)},
+	})
+}
+
+func (x *tx) DeleteHouseholdMember(id, uid string) error {
+	if id == "" || uid == "" {
+		return errors.NewInvalidParamError("id: %v, uid: %v", id, uid)
+	}
+	return x.update(x.client.Collection(householdCollection).Doc(id), []firestore.Update{
+		{Path: "members", Value: firestore.ArrayRemove(uid)},
+	})
+}
+
+func (x *tx) UseInvite(code, uid string) error {
+	if code == "" || uid == "" {
+		return errors.NewInvalidParamError("code: %v, uid: %v", code, uid)
+	}
+	return x.update(x.client.Collection(inviteCollection).Doc(code), []firestore.Update{
+		{Path: "uses", Value: firestore.Increment(1)},
+		{Path: "usedBy", Value: firestore.ArrayUnion(uid)},
+	})
+}
+
+func (x *tx) SaveDeletionJob(job *deletion.Job) error {
+	if job == nil || job.Id == "" {
+		return errors.NewInvalidParamError("job: %v", job)
+	}
+	if err := x.t.Set(x.client.Collection(deletionCollection).Doc(job.Id), job); err != nil {
+		return errors.New("%v", err)
+	}
+	return nil
+}
+
+func (x *tx) SaveAuditEvent(event *audit.Event) error {
+	if event == nil || event.Id == "" || (event.PetId == "" && event.UserId == "") {
+		return errors.NewInvalidParamError("event: %v", event)
+	}
+	for _, ref := range auditstore.Refs(x.client, event) {
+		if err := x.t.Create(ref, event); err != nil {
+			return errors.New("%v", err)
+		}
+	}
+	return nil
+}
@@ -1,8 +1,9 @@
 package user
 
 import (
+	"time"
+
 	"ohmnyom/domain/user"
-	"ohmnyom/internal/time"
 )
 
 var users = []*user.User{
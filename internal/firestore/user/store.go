@@ -61,9 +61,14 @@ func (s *Store) GetByEmail(ctx context.Context, email string) (*user.User, error
 	return nil, errors.NewNotFoundError("User{Email: %v}", email)
 }
 
+// GetByOAuth finds the user by the account id of the provider. The email of
+// the account may have changed since sign up.
 func (s *Store) GetByOAuth(ctx context.Context, info *user.OAuthInfo, provider string) (*user.User, error) {
+	if info == nil || info.Id == "" {
+		return nil, errors.NewInvalidParamError("info: %v", info)
+	}
 	iter := s.client.Collection(userCollection).
-		Where("oauthinfo."+provider, operatorIs, info).Documents(ctx)
+		Where("oauthinfo."+provider+".id", operatorIs, info.Id).Documents(ctx)
 	for {
 		doc, err := iter.Next()
 		if err == iterator.Done {
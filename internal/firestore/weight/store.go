@@ -0,0 +1,150 @@
+package weight
+
+import (
+	"context"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"ohmnyom/domain/weight"
+	"ohmnyom/internal/errors"
+)
+
+const (
+	petCollection    = "pets"
+	weightCollection = "weights"
+)
+
+type Store struct {
+	client *firestore.Client
+}
+
+func New(ctx context.Context, client *firestore.Client) weight.Store {
+	return &Store{
+		client: client,
+	}
+}
+
+func (s *Store) collection(petId string) *firestore.CollectionRef {
+	return s.client.Collection(petCollection).Doc(petId).Collection(weightCollection)
+}
+
+func (s *Store) Get(ctx context.Context, petId, id string) (*weight.Weight, error) {
+	if petId == "" || id == "" {
+		return nil, errors.NewInvalidParamError("petId: %v, id: %v", petId, id)
+	}
+	snapshot, err := s.collection(petId).Doc(id).Get(ctx)
+	switch status.Code(err) {
+	case codes.OK:
+		w := &weight.Weight{}
+		if suberr := snapshot.DataTo(w); suberr != nil {
+			return nil, errors.NewInvalidFormatError("%v", suberr)
+		}
+		return w, nil
+	case codes.NotFound:
+		return nil, errors.NewNotFoundError("Weight{PetId: %v, Id: %v}", petId, id)
+	}
+	return nil, errors.New("%v", err)
+}
+
+func (s *Store) GetListOfPet(ctx context.Context, petId string, from, to time.Time) ([]*weight.Weight, error) {
+	if petId == "" {
+		return nil, errors.NewInvalidParamError("petId: %v", petId)
+	}
+	docs, err := s.collection(petId).
+		Where("timestamp", ">=", from).Where("timestamp", "<", to).
+		OrderBy("timestamp", firestore.Asc).Documents(ctx).GetAll()
+	if err != nil {
+		return nil, errors.New("%v", err)
+	}
+	ret := make([]*weight.Weight, len(docs))
+	for i, doc := range docs {
+		w := &weight.Weight{}
+		if err := doc.DataTo(w); err != nil {
+			return nil, errors.NewInvalidFormatError("%v", err)
+		}
+		ret[i] = w
+	}
+	return ret, nil
+}
+
+func (s *Store) GetLatest(ctx context.Context, petId string) (*weight.Weight, error) {
+	if petId == "" {
+		return nil, errors.NewInvalidParamError("petId: %v", petId)
+	}
+	docs, err := s.collection(petId).OrderBy("timestamp", firestore.Desc).Limit(1).Documents(ctx).GetAll()
+	if err != nil {
+		return nil, errors.New("%v", err)
+	}
+	if len(docs) == 0 {
+		return nil, errors.NewNotFoundError("weight of Pet{Id: %v}", petId)
+	}
+	w := &weight.Weight{}
+	if err := docs[0].DataTo(w); err != nil {
+		return nil, errors.NewInvalidFormatError("%v", err)
+	}
+	return w, nil
+}
+
+func (s *Store) Put(ctx context.Context, w *weight.Weight) error {
+	if w == nil || w.Id == "" || w.PetId == "" {
+		return errors.NewInvalidParamError("weight: %v", w)
+	}
+	if _, err := s.collection(w.PetId).Doc(w.Id).Create(ctx, w); err != nil {
+		return errors.New("%v", err)
+	}
+	return nil
+}
+
+func (s *Store) Update(ctx context.Context, w *weight.Weight) error {
+	if w == nil || w.Id == "" || w.PetId == "" {
+		return errors.NewInvalidParamError("weight: %v", w)
+	}
+	err := s.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		ref := s.collection(w.PetId).Doc(w.Id)
+		if _, err := tx.Get(ref); err != nil {
+			if status.Code(err) == codes.NotFound {
+				return errors.NewNotFoundError("Weight{PetId: %v, Id: %v}", w.PetId, w.Id)
+			}
+			return errors.New("%v", err)
+		}
+		return tx.Set(ref, w)
+	})
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *Store) Delete(ctx context.Context, petId, id string) error {
+	if petId == "" || id == "" {
+		return errors.NewInvalidParamError("petId: %v, id: %v", petId, id)
+	}
+	if _, err := s.collection(petId).Doc(id).Delete(ctx); err != nil {
+		return errors.New("%v", err)
+	}
+	return nil
+}
+
+func (s *Store) DeleteBatchOfPet(ctx context.Context, petId string, limit int) (int, error) {
+	if petId == "" || limit <= 0 {
+		return 0, errors.NewInvalidParamError("petId: %v, limit: %v", petId, limit)
+	}
+	docs, err := s.collection(petId).Select().Limit(limit).Documents(ctx).GetAll()
+	if err != nil {
+		return 0, errors.New("%v", err)
+	}
+	if len(docs) == 0 {
+		return 0, nil
+	}
+
+	batch := s.client.Batch()
+	for _, doc := range docs {
+		batch.Delete(doc.Ref)
+	}
+	if _, err := batch.Commit(ctx); err != nil {
+		return 0, errors.New("%v", err)
+	}
+	return len(docs), nil
+}
@@ -9,15 +9,17 @@ import (
 	"ohmnyom/domain/user"
 	"ohmnyom/internal/errors"
 	"ohmnyom/internal/jwt"
+	"ohmnyom/internal/revocation"
 )
 
 type AuthInterceptor struct {
 	jwtManager *jwt.Manager
+	checker    *revocation.Checker
 	bypass     map[string]struct{}
 }
 
-func NewAuthInterceptor(jwtManager *jwt.Manager, bypassMethods ...string) *AuthInterceptor {
-	if jwtManager == nil {
+func NewAuthInterceptor(jwtManager *jwt.Manager, checker *revocation.Checker, bypassMethods ...string) *AuthInterceptor {
+	if jwtManager == nil || checker == nil {
 		return nil
 	}
 	bypass := make(map[string]struct{})
@@ -26,28 +28,32 @@ func NewAuthInterceptor(jwtManager *jwt.Manager, bypassMethods ...string) *AuthI
 	}
 	return &AuthInterceptor{
 		jwtManager: jwtManager,
+		checker:    checker,
 		bypass:     bypass,
 	}
 }
 
-func (i *AuthInterceptor) authorize(ctx context.Context) (string, error) {
+func (i *AuthInterceptor) authorize(ctx context.Context) (*jwt.Claims, error) {
 	md, ok := metadata.FromIncomingContext(ctx)
 	if !ok {
-		return "", errors.NewAuthenticationError("metadata is not provided")
+		return nil, errors.NewAuthenticationError("metadata is not provided")
 	}
 
 	values := md["user-auth"]
 	if len(values) == 0 {
-		return "", errors.NewAuthenticationError("authorization token is not provided")
+		return nil, errors.NewAuthenticationError("authorization token is not provided")
 	}
 
 	accessToken := values[0]
-	uid, err := i.jwtManager.Verify(accessToken)
+	claims, err := i.jwtManager.VerifyAuth(accessToken)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+	if err := i.checker.Check(ctx, claims); err != nil {
+		return nil, err
 	}
 
-	return uid, nil
+	return claims, nil
 }
 
 func (i *AuthInterceptor) Unary() grpc.UnaryServerInterceptor {
@@ -62,12 +68,13 @@ func (i *AuthInterceptor) Unary() grpc.UnaryServerInterceptor {
 			return handler(ctx, req)
 		}
 
-		uid, err := i.authorize(ctx)
+		claims, err := i.authorize(ctx)
 		if err != nil {
 			return nil, errors.GrpcError(err)
 		}
 
-		c := context.WithValue(ctx, user.CtxKeyUid, uid)
+		c := context.WithValue(ctx, user.CtxKeyUid, claims.Uid)
+		c = context.WithValue(c, jwt.CtxKeyClaims, claims)
 		return handler(c, req)
 	}
 }
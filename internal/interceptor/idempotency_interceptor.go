@@ -0,0 +1,161 @@
+package interceptor
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+	"ohmnyom/domain/idempotency"
+	"ohmnyom/internal/authz"
+	"ohmnyom/internal/errors"
+)
+
+// IdempotencyKey is the request metadata holding the idempotency key.
+const IdempotencyKey = "idempotency-key"
+
+// IdempotencyInterceptor replays the reply to a request when it is retried
+// with the same idempotency key. It has to run after the AuthInterceptor.
+// Replies are kept for ttl, a request still running holds its key for
+// idempotency.PendingLease.
+type IdempotencyInterceptor struct {
+	store   idempotency.Store
+	ttl     time.Duration
+	methods map[string]struct{}
+}
+
+// NewIdempotencyInterceptor handles the keys of methods, the other methods run
+// as if no key was sent.
+func NewIdempotencyInterceptor(store idempotency.Store, ttl time.Duration, methods ...string) *IdempotencyInterceptor {
+	if ttl <= 0 {
+		ttl = idempotency.DefaultTTL
+	}
+	m := make(map[string]struct{})
+	for _, method := range methods {
+		m[method] = struct{}{}
+	}
+	return &IdempotencyInterceptor{
+		store:   store,
+		ttl:     ttl,
+		methods: m,
+	}
+}
+
+func idempotencyKey(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get(IdempotencyKey)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+func hashRequest(req interface{}) (string, error) {
+	msg, ok := req.(proto.Message)
+	if !ok {
+		return "", errors.NewInternalError("request %T is not a proto message", req)
+	}
+	b, err := proto.MarshalOptions{Deterministic: true}.Marshal(msg)
+	if err != nil {
+		return "", errors.NewInternalError("%v", err)
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// replay returns the reply of a completed record.
+func replay(record *idempotency.Record) (interface{}, error) {
+	if record.State != idempotency.StateDone {
+		return nil, errors.NewAbortedError("request with the idempotency key is in progress")
+	}
+	a := &anypb.Any{}
+	if err := proto.Unmarshal(record.Response, a); err != nil {
+		return nil, errors.NewInvalidFormatError("%v", err)
+	}
+	resp, err := a.UnmarshalNew()
+	if err != nil {
+		return nil, errors.NewInvalidFormatError("%v", err)
+	}
+	return resp, nil
+}
+
+func (i *IdempotencyInterceptor) intercept(ctx context.Context, req interface{}, method string,
+	handler grpc.UnaryHandler) (interface{}, error) {
+	key := idempotencyKey(ctx)
+	if key == "" {
+		return handler(ctx, req)
+	}
+	if len(key) > idempotency.MaxKeyLength {
+		return nil, errors.NewInvalidParamError("idempotency key is longer than %v", idempotency.MaxKeyLength)
+	}
+	uid, err := authz.Uid(ctx)
+	if err != nil {
+		return nil, err
+	}
+	hash, err := hashRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	record := idempotency.NewPending(uid, key, method, hash)
+	existing, err := i.store.Begin(ctx, record)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		if !existing.Matches(record) {
+			return nil, errors.NewFailedPreconditionError("idempotency key was used for another request")
+		}
+		return replay(existing)
+	}
+
+	resp, err := handler(ctx, req)
+	if err != nil {
+		// let the client retry a request that failed
+		if delErr := i.store.Delete(ctx, record.Id); delErr != nil {
+			log.Printf("cannot forget idempotency key of %v: %v", method, delErr)
+		}
+		return nil, err
+	}
+	msg, ok := resp.(proto.Message)
+	if !ok {
+		return resp, nil
+	}
+	a, err := anypb.New(msg)
+	if err == nil {
+		var b []byte
+		if b, err = proto.Marshal(a); err == nil {
+			err = i.store.Complete(ctx, record.Id, b, time.Now().UTC().Add(i.ttl))
+		}
+	}
+	if err != nil {
+		// the request succeeded, only a retry of it would run again
+		log.Printf("cannot store reply of %v: %v", method, err)
+	}
+	return resp, nil
+}
+
+func (i *IdempotencyInterceptor) Unary() grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req interface{},
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (interface{}, error) {
+		if _, ok := i.methods[info.FullMethod]; !ok {
+			return handler(ctx, req)
+		}
+		resp, err := i.intercept(ctx, req, info.FullMethod, handler)
+		if _, ok := status.FromError(err); !ok {
+			// errors of the interceptor itself, handler errors are statuses already
+			return nil, errors.GrpcError(err)
+		}
+		return resp, err
+	}
+}
@@ -0,0 +1,107 @@
+package interceptor
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aiceru/protonyom/gonyom"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"ohmnyom/domain/idempotency"
+	"ohmnyom/domain/user"
+	"ohmnyom/internal/memstore"
+)
+
+const addFeedMethod = "/protonyom.FeedApi/AddFeed"
+
+func TestIdempotencyInterceptor(t *testing.T) {
+	store := memstore.NewIdempotencyStore()
+	unary := NewIdempotencyInterceptor(store, time.Hour, addFeedMethod).Unary()
+
+	calls := 0
+	fail := false
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		calls++
+		if fail {
+			return nil, status.Error(codes.Unavailable, "try again")
+		}
+		f := proto.Clone(req.(*gonyom.AddFeedRequest).Feed).(*gonyom.Feed)
+		f.Id = fmt.Sprint("feed", calls)
+		return &gonyom.AddFeedReply{Feed: f}, nil
+	}
+	call := func(uid, key, method string, amount float64) (*gonyom.AddFeedReply, error) {
+		ctx := context.WithValue(context.TODO(), user.CtxKeyUid, uid)
+		if key != "" {
+			ctx = metadata.NewIncomingContext(ctx, metadata.Pairs(IdempotencyKey, key))
+		}
+		req := &gonyom.AddFeedRequest{Feed: &gonyom.Feed{PetId: "pet1", Amount: amount}}
+		resp, err := unary(ctx, req, &grpc.UnaryServerInfo{FullMethod: method}, handler)
+		if err != nil {
+			return nil, err
+		}
+		return resp.(*gonyom.AddFeedReply), nil
+	}
+
+	first, err := call("user1", "key1", addFeedMethod, 10)
+	assert.NoError(t, err)
+	retry, err := call("user1", "key1", addFeedMethod, 10)
+	assert.NoError(t, err)
+	assert.True(t, proto.Equal(first, retry))
+	assert.Equal(t, 1, calls)
+
+	_, err = call("user1", "key1", addFeedMethod, 20)
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+	_, err = call("user1", "key1", "/protonyom.PetApi/AddPet", 10)
+	assert.NoError(t, err, "methods without keys ignore them")
+
+	// keys are per user
+	other, err := call("user2", "key1", addFeedMethod, 10)
+	assert.NoError(t, err)
+	assert.NotEqual(t, first.Feed.Id, other.Feed.Id)
+
+	// no key, no replay
+	calls = 0
+	_, err = call("user1", "", addFeedMethod, 10)
+	assert.NoError(t, err)
+	_, err = call("user1", "", addFeedMethod, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, calls)
+
+	// a failed request can be retried with the same key
+	fail = true
+	_, err = call("user1", "key2", addFeedMethod, 10)
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	fail = false
+	_, err = call("user1", "key2", addFeedMethod, 10)
+	assert.NoError(t, err)
+
+	// a retry while the first request runs
+	hash, err := hashRequest(&gonyom.AddFeedRequest{Feed: &gonyom.Feed{PetId: "pet1", Amount: 10}})
+	assert.NoError(t, err)
+	_, err = store.Begin(context.TODO(), idempotency.NewPending("user1", "key3", addFeedMethod, hash))
+	assert.NoError(t, err)
+	_, err = call("user1", "key3", addFeedMethod, 10)
+	assert.Equal(t, codes.Aborted, status.Code(err))
+
+	// a retry after the lease of a request that never replied runs it
+	stale := idempotency.NewPending("user1", "key4", addFeedMethod, hash)
+	stale.Expires = time.Now().Add(-time.Second)
+	_, err = store.Begin(context.TODO(), stale)
+	assert.NoError(t, err)
+	_, err = call("user1", "key4", addFeedMethod, 10)
+	assert.NoError(t, err)
+
+	// the reply is kept for the ttl, not the lease
+	done, err := store.Begin(context.TODO(), idempotency.NewPending("user1", "key4", addFeedMethod, hash))
+	assert.NoError(t, err)
+	if assert.NotNil(t, done) {
+		assert.Equal(t, idempotency.StateDone, done.State)
+		assert.True(t, done.Expires.After(time.Now().Add(idempotency.PendingLease)))
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"time"
 
 	"github.com/golang-jwt/jwt"
+	"github.com/rs/xid"
+	"ohmnyom/internal"
 	"ohmnyom/internal/errors"
 )
 
@@ -19,11 +21,28 @@ type Manager struct {
 	secret []byte
 }
 
+const CtxKeyClaims = internal.ContextKey("claims")
+
 type claim struct {
-	Uid string `json:"uid"`
+	Uid        string `json:"uid"`
+	Family     string `json:"fam,omitempty"`
+	Generation int64  `json:"gen,omitempty"`
 	jwt.StandardClaims
 }
 
+// Claims is what a verified token tells about its holder.
+type Claims struct {
+	Uid string
+	// Id is the unique id (jti) of the token.
+	Id string
+	// Family groups the refresh tokens rotated from one sign in.
+	Family string
+	// Generation is the token generation of the user when the token was issued.
+	// Signing out everywhere bumps the generation and invalidates older tokens.
+	Generation int64
+	ExpiresAt  time.Time
+}
+
 func NewManager(secret []byte) *Manager {
 	if len(secret) < 1 {
 		return nil
@@ -31,10 +50,17 @@ func NewManager(secret []byte) *Manager {
 	return &Manager{secret: secret}
 }
 
-func (m *Manager) newToken(uid string, expDuration time.Duration, subject string) (string, error) {
+func NewTokenId() string {
+	return xid.New().String()
+}
+
+func (m *Manager) newToken(uid, id, family string, generation int64, expDuration time.Duration, subject string) (string, error) {
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claim{
-		Uid: uid,
+		Uid:        uid,
+		Family:     family,
+		Generation: generation,
 		StandardClaims: jwt.StandardClaims{
+			Id:        id,
 			ExpiresAt: time.Now().Add(expDuration).UTC().Unix(),
 			IssuedAt:  time.Now().UTC().Unix(),
 			Issuer:    tokenIssuer,
@@ -48,15 +74,20 @@ func (m *Manager) newToken(uid string, expDuration time.Duration, subject string
 	return tokenString, nil
 }
 
-func (m *Manager) NewAuthToken(uid string) (string, error) {
-	return m.newToken(uid, expAuth, subjectAuth)
+func (m *Manager) NewAuthToken(uid string, generation int64) (string, error) {
+	return m.newToken(uid, NewTokenId(), "", generation, expAuth, subjectAuth)
 }
 
-func (m *Manager) NewRefreshToken(uid string) (string, error) {
-	return m.newToken(uid, expRefresh, subjectRefresh)
+// NewRefreshToken issues a refresh token with the given id in family.
+func (m *Manager) NewRefreshToken(uid, id, family string, generation int64) (string, error) {
+	return m.newToken(uid, id, family, generation, expRefresh, subjectRefresh)
 }
 
-func (m *Manager) Verify(tokenStr string) (string, error) {
+func RefreshTokenTTL() time.Duration {
+	return expRefresh
+}
+
+func (m *Manager) parse(tokenStr, subject string) (*Claims, error) {
 	token, err := jwt.ParseWithClaims(
 		tokenStr,
 		&claim{},
@@ -69,13 +100,53 @@ func (m *Manager) Verify(tokenStr string) (string, error) {
 		},
 	)
 	if err != nil {
-		return "", errors.NewAuthenticationError(err.Error())
+		return nil, errors.NewAuthenticationError(err.Error())
 	}
 
 	c, ok := token.Claims.(*claim)
 	if !ok {
-		return "", errors.NewAuthenticationError("invalid claim")
+		return nil, errors.NewAuthenticationError("invalid claim")
+	}
+	if c.Subject != subject || c.Issuer != tokenIssuer {
+		return nil, errors.NewAuthenticationError("invalid subject %v", c.Subject)
 	}
 
+	return &Claims{
+		Uid:        c.Uid,
+		Id:         c.Id,
+		Family:     c.Family,
+		Generation: c.Generation,
+		ExpiresAt:  time.Unix(c.ExpiresAt, 0),
+	}, nil
+}
+
+// Verify verifies an auth token and returns its uid. Refresh tokens are rejected.
+func (m *Manager) Verify(tokenStr string) (string, error) {
+	c, err := m.VerifyAuth(tokenStr)
+	if err != nil {
+		return "", err
+	}
 	return c.Uid, nil
 }
+
+func (m *Manager) VerifyAuth(tokenStr string) (*Claims, error) {
+	c, err := m.parse(tokenStr, subjectAuth)
+	if err != nil {
+		return nil, err
+	}
+	if c.Id == "" {
+		return nil, errors.NewAuthenticationError("auth token without id")
+	}
+	return c, nil
+}
+
+func (m *Manager) VerifyRefresh(tokenStr string) (*Claims, error) {
+	c, err := m.parse(tokenStr, subjectRefresh)
+	if err != nil {
+		return nil, err
+	}
+	if c.Id == "" || c.Family == "" {
+		return nil, errors.NewAuthenticationError("refresh token without id or family")
+	}
+	return c, nil
+}
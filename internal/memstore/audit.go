@@ -0,0 +1,108 @@
+package memstore
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"ohmnyom/domain/audit"
+	"ohmnyom/internal/errors"
+)
+
+type AuditStore struct {
+	mu     sync.RWMutex
+	events []*audit.Event
+}
+
+func NewAuditStore() audit.Store {
+	return &AuditStore{
+		events: make([]*audit.Event, 0),
+	}
+}
+
+func copyEvent(e *audit.Event) *audit.Event {
+	c := *e
+	return &c
+}
+
+func (s *AuditStore) Put(ctx context.Context, event *audit.Event) error {
+	if event == nil || event.Id == "" || (event.PetId == "" && event.UserId == "") {
+		return errors.NewInvalidParamError("event: %v", event)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, copyEvent(event))
+	return nil
+}
+
+// GetListOfPet returns events after startAfter. A non-positive limit returns
+// every matching event.
+func (s *AuditStore) GetListOfPet(ctx context.Context, petId string, startAfter audit.Cursor, limit int) ([]*audit.Event, error) {
+	if petId == "" {
+		return nil, errors.NewInvalidParamError("petId: %v", petId)
+	}
+	return s.getList(func(e *audit.Event) bool { return e.PetId == petId }, startAfter, limit), nil
+}
+
+func (s *AuditStore) GetListOfUser(ctx context.Context, userId string, startAfter audit.Cursor, limit int) ([]*audit.Event, error) {
+	if userId == "" {
+		return nil, errors.NewInvalidParamError("userId: %v", userId)
+	}
+	return s.getList(func(e *audit.Event) bool { return e.UserId == userId }, startAfter, limit), nil
+}
+
+func (s *AuditStore) getList(match func(e *audit.Event) bool, startAfter audit.Cursor, limit int) []*audit.Event {
+	s.mu.RLock()
+	ret := make([]*audit.Event, 0)
+	for _, e := range s.events {
+		if match(e) && startAfter.Before(e) {
+			ret = append(ret, copyEvent(e))
+		}
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Cursor().Before(ret[j])
+	})
+	if limit > 0 && len(ret) > limit {
+		ret = ret[:limit]
+	}
+	return ret
+}
+
+func (s *AuditStore) DeleteBatchOfPet(ctx context.Context, petId string, limit int) (int, error) {
+	if petId == "" || limit <= 0 {
+		return 0, errors.NewInvalidParamError("petId: %v, limit: %v", petId, limit)
+	}
+	return s.deleteBatch(func(e *audit.Event) *string { return &e.PetId }, petId, limit), nil
+}
+
+func (s *AuditStore) DeleteBatchOfUser(ctx context.Context, userId string, limit int) (int, error) {
+	if userId == "" || limit <= 0 {
+		return 0, errors.NewInvalidParamError("userId: %v, limit: %v", userId, limit)
+	}
+	return s.deleteBatch(func(e *audit.Event) *string { return &e.UserId }, userId, limit), nil
+}
+
+// deleteBatch deletes up to limit events whose id field is id. An event kept
+// for both a pet and an account, which firestore stores twice, stays for the
+// other one with the field cleared.
+func (s *AuditStore) deleteBatch(field func(e *audit.Event) *string, id string, limit int) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	kept := s.events[:0]
+	for _, e := range s.events {
+		if n == limit || *field(e) != id {
+			kept = append(kept, e)
+			continue
+		}
+		n++
+		*field(e) = ""
+		if e.PetId != "" || e.UserId != "" {
+			kept = append(kept, e)
+		}
+	}
+	s.events = kept
+	return n
+}
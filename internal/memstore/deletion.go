@@ -0,0 +1,67 @@
+package memstore
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"ohmnyom/domain/deletion"
+	"ohmnyom/internal/errors"
+)
+
+type DeletionStore struct {
+	mu   sync.Mutex
+	jobs map[string]*deletion.Job
+}
+
+func NewDeletionStore() deletion.Store {
+	return &DeletionStore{
+		jobs: make(map[string]*deletion.Job),
+	}
+}
+
+func copyJob(j *deletion.Job) *deletion.Job {
+	c := *j
+	c.StorageDirs = copyStrings(j.StorageDirs)
+	c.Report.StorageDirs = copyStrings(j.Report.StorageDirs)
+	return &c
+}
+
+func (s *DeletionStore) Get(ctx context.Context, id string) (*deletion.Job, error) {
+	if id == "" {
+		return nil, errors.NewInvalidParamError("id: %v", id)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j, ok := s.jobs[id]
+	if !ok {
+		return nil, errors.NewNotFoundError("Job{Id: %v}", id)
+	}
+	return copyJob(j), nil
+}
+
+func (s *DeletionStore) Save(ctx context.Context, job *deletion.Job) error {
+	if job == nil || job.Id == "" {
+		return errors.NewInvalidParamError("job: %v", job)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobs[job.Id] = copyJob(job)
+	return nil
+}
+
+func (s *DeletionStore) GetPending(ctx context.Context) ([]*deletion.Job, error) {
+	s.mu.Lock()
+	ret := make([]*deletion.Job, 0)
+	for _, j := range s.jobs {
+		if !j.Done {
+			ret = append(ret, copyJob(j))
+		}
+	}
+	s.mu.Unlock()
+
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Created.Before(ret[j].Created)
+	})
+	return ret, nil
+}
@@ -0,0 +1,87 @@
+package memstore
+
+import (
+	"context"
+	"sync"
+
+	"ohmnyom/domain/device"
+	"ohmnyom/internal/errors"
+)
+
+type DeviceStore struct {
+	mu      sync.Mutex
+	devices map[string]*device.Device // token -> device
+}
+
+func NewDeviceStore() device.Store {
+	return &DeviceStore{
+		devices: make(map[string]*device.Device),
+	}
+}
+
+func (s *DeviceStore) Get(ctx context.Context, token string) (*device.Device, error) {
+	if token == "" {
+		return nil, errors.NewInvalidParamError("token: %v", token)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d, ok := s.devices[token]
+	if !ok {
+		return nil, errors.NewNotFoundError("Device{Id: %v}", device.Id(token))
+	}
+	c := *d
+	return &c, nil
+}
+
+func (s *DeviceStore) GetListOfUser(ctx context.Context, uid string) ([]*device.Device, error) {
+	if uid == "" {
+		return nil, errors.NewInvalidParamError("uid: %v", uid)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ret := make([]*device.Device, 0)
+	for _, d := range s.devices {
+		if d.Uid == uid {
+			c := *d
+			ret = append(ret, &c)
+		}
+	}
+	return ret, nil
+}
+
+func (s *DeviceStore) Put(ctx context.Context, d *device.Device) error {
+	if d == nil || d.Token == "" || d.Uid == "" {
+		return errors.NewInvalidParamError("device: %v", d)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c := *d
+	s.devices[d.Token] = &c
+	return nil
+}
+
+func (s *DeviceStore) Delete(ctx context.Context, token string) error {
+	if token == "" {
+		return errors.NewInvalidParamError("token: %v", token)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.devices, token)
+	return nil
+}
+
+func (s *DeviceStore) DeleteAllOfUser(ctx context.Context, uid string) (int, error) {
+	if uid == "" {
+		return 0, errors.NewInvalidParamError("uid: %v", uid)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for token, d := range s.devices {
+		if d.Uid == uid {
+			delete(s.devices, token)
+			n++
+		}
+	}
+	return n, nil
+}
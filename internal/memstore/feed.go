@@ -0,0 +1,230 @@
+package memstore
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"ohmnyom/domain/feed"
+	"ohmnyom/internal/errors"
+)
+
+type FeedStore struct {
+	mu    sync.RWMutex
+	feeds map[string]map[string]*feed.Feed // petId -> feedId -> feed
+}
+
+func NewFeedStore() feed.Store {
+	return &FeedStore{
+		feeds: make(map[string]map[string]*feed.Feed),
+	}
+}
+
+func copyFeed(f *feed.Feed) *feed.Feed {
+	c := *f
+	return &c
+}
+
+func (s *FeedStore) Get(ctx context.Context, petId, feedId string) (*feed.Feed, error) {
+	if petId == "" || feedId == "" {
+		return nil, errors.NewInvalidParamError("petId: %v, feedId: %v", petId, feedId)
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	f, ok := s.feeds[petId][feedId]
+	if !ok {
+		return nil, errors.NewNotFoundError("Feed{PetId: %v, Id: %v}", petId, feedId)
+	}
+	return copyFeed(f), nil
+}
+
+// GetFeedsOfPet returns feeds older than startAfter, newest first.
+// A non-positive limit returns every matching feed.
+func (s *FeedStore) GetFeedsOfPet(ctx context.Context, petId string, startAfter time.Time, limit int) ([]*feed.Feed, error) {
+	s.mu.RLock()
+	ret := make([]*feed.Feed, 0)
+	for _, f := range s.feeds[petId] {
+		if f.Timestamp.Before(startAfter) {
+			ret = append(ret, copyFeed(f))
+		}
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Timestamp.After(ret[j].Timestamp)
+	})
+	if limit > 0 && len(ret) > limit {
+		ret = ret[:limit]
+	}
+	return ret, nil
+}
+
+func (s *FeedStore) GetFeedsOfPetInRange(ctx context.Context, petId string, from, to time.Time) ([]*feed.Feed, error) {
+	if petId == "" {
+		return nil, errors.NewInvalidParamError("petId: %v", petId)
+	}
+	s.mu.RLock()
+	ret := make([]*feed.Feed, 0)
+	for _, f := range s.feeds[petId] {
+		if !f.Timestamp.Before(from) && f.Timestamp.Before(to) {
+			ret = append(ret, copyFeed(f))
+		}
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Timestamp.Before(ret[j].Timestamp)
+	})
+	return ret, nil
+}
+
+func (s *FeedStore) Put(ctx context.Context, f *feed.Feed) error {
+	if f == nil || f.Id == "" {
+		return errors.NewInvalidParamError("feed: %v", f)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.put(f)
+}
+
+func (s *FeedStore) PutUnlessConflict(ctx context.Context, f *feed.Feed, window time.Duration) error {
+	if f == nil || f.Id == "" {
+		return errors.NewInvalidParamError("feed: %v", f)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := feed.CheckConflict(s.feedsOf(f.PetId), f, window); err != nil {
+		return err
+	}
+	return s.put(f)
+}
+
+func (s *FeedStore) put(f *feed.Feed) error {
+	feeds, ok := s.feeds[f.PetId]
+	if !ok {
+		feeds = make(map[string]*feed.Feed)
+		s.feeds[f.PetId] = feeds
+	}
+	if _, ok := feeds[f.Id]; ok {
+		return errors.NewAlreadyExistsError("Feed{PetId: %v, Id: %v}", f.PetId, f.Id)
+	}
+	feeds[f.Id] = copyFeed(f)
+	return nil
+}
+
+func (s *FeedStore) Update(ctx context.Context, petId, feedId string, pathValues map[string]interface{}) error {
+	if err := checkFeedUpdate(petId, feedId, pathValues); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	updated, err := s.updated(petId, feedId, pathValues)
+	if err != nil {
+		return err
+	}
+	s.feeds[petId][feedId] = updated
+	return nil
+}
+
+func (s *FeedStore) UpdateUnlessConflict(ctx context.Context, petId, feedId string, pathValues map[string]interface{},
+	window time.Duration) error {
+	if err := checkFeedUpdate(petId, feedId, pathValues); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	updated, err := s.updated(petId, feedId, pathValues)
+	if err != nil {
+		return err
+	}
+	if err := feed.CheckConflict(s.feedsOf(petId), updated, window); err != nil {
+		return err
+	}
+	s.feeds[petId][feedId] = updated
+	return nil
+}
+
+func checkFeedUpdate(petId, feedId string, pathValues map[string]interface{}) error {
+	if petId == "" || feedId == "" {
+		return errors.NewInvalidParamError("petId: %v, feedId: %v", petId, feedId)
+	}
+	for path := range pathValues {
+		if !feed.IsUpdatableField(path) {
+			return errors.NewInvalidParamError("path %v is not updatable", path)
+		}
+	}
+	return nil
+}
+
+// updated returns a copy of the stored feed with pathValues set.
+func (s *FeedStore) updated(petId, feedId string, pathValues map[string]interface{}) (*feed.Feed, error) {
+	stored, ok := s.feeds[petId][feedId]
+	if !ok {
+		return nil, errors.NewNotFoundError("Feed{PetId: %v, Id: %v}", petId, feedId)
+	}
+	updated := copyFeed(stored)
+	for path, value := range pathValues {
+		if err := setFeedField(updated, path, value); err != nil {
+			return nil, err
+		}
+	}
+	return updated, nil
+}
+
+// feedsOf returns copies of every feed of the pet.
+func (s *FeedStore) feedsOf(petId string) []*feed.Feed {
+	ret := make([]*feed.Feed, 0, len(s.feeds[petId]))
+	for _, f := range s.feeds[petId] {
+		ret = append(ret, copyFeed(f))
+	}
+	return ret
+}
+
+func setFeedField(f *feed.Feed, path string, value interface{}) error {
+	var ok bool
+	switch path {
+	case "timestamp":
+		f.Timestamp, ok = value.(time.Time)
+	case "amount":
+		f.Amount, ok = value.(float64)
+	case "unit":
+		f.Unit, ok = value.(string)
+	case "kcal":
+		f.Kcal, ok = value.(float64)
+	}
+	if !ok {
+		return errors.NewInvalidParamError("path %v, value %v", path, value)
+	}
+	return nil
+}
+
+func (s *FeedStore) Delete(ctx context.Context, petId, feedId string) error {
+	if petId == "" || feedId == "" {
+		return errors.NewInvalidParamError("petId: %v, feedId: %v", petId, feedId)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.feeds[petId], feedId)
+	return nil
+}
+
+func (s *FeedStore) DeleteBatchOfPet(ctx context.Context, petId string, limit int) (int, error) {
+	if petId == "" || limit <= 0 {
+		return 0, errors.NewInvalidParamError("petId: %v, limit: %v", petId, limit)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for id := range s.feeds[petId] {
+		if n == limit {
+			break
+		}
+		delete(s.feeds[petId], id)
+		n++
+	}
+	if len(s.feeds[petId]) == 0 {
+		delete(s.feeds, petId)
+	}
+	return n, nil
+}
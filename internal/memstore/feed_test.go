@@ -0,0 +1,88 @@
+package memstore
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"ohmnyom/domain/feed"
+)
+
+func newTestFeedStore(t *testing.T, feeds []*feed.Feed) feed.Store {
+	s := NewFeedStore()
+	for _, f := range feeds {
+		if err := s.Put(context.TODO(), f); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return s
+}
+
+func TestFeedStore_GetFeedsOfPet(t *testing.T) {
+	ctx := context.TODO()
+	base := time.Date(2022, 2, 1, 8, 0, 0, 0, time.UTC)
+	feeds := []*feed.Feed{
+		{Id: "feed1", PetId: "pet1", Timestamp: base, Amount: 10, Unit: "g"},
+		{Id: "feed2", PetId: "pet1", Timestamp: base.Add(time.Hour), Amount: 20, Unit: "g"},
+		{Id: "feed3", PetId: "pet1", Timestamp: base.Add(2 * time.Hour), Amount: 30, Unit: "g"},
+		{Id: "feed4", PetId: "pet2", Timestamp: base.Add(time.Hour), Amount: 40, Unit: "g"},
+	}
+	s := newTestFeedStore(t, feeds)
+
+	tests := []struct {
+		name       string
+		petId      string
+		startAfter time.Time
+		limit      int
+		want       []string
+	}{
+		{"all", "pet1", base.Add(24 * time.Hour), 10, []string{"feed3", "feed2", "feed1"}},
+		{"limit", "pet1", base.Add(24 * time.Hour), 2, []string{"feed3", "feed2"}},
+		{"next page", "pet1", base.Add(time.Hour), 2, []string{"feed1"}},
+		{"other pet", "pet2", base.Add(24 * time.Hour), 10, []string{"feed4"}},
+		{"no pet", "pet3", base.Add(24 * time.Hour), 10, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.GetFeedsOfPet(ctx, tt.petId, tt.startAfter, tt.limit)
+			assert.NoError(t, err)
+			ids := make([]string, len(got))
+			for i, f := range got {
+				ids[i] = f.Id
+			}
+			assert.Equalf(t, tt.want, ids, "GetFeedsOfPet(%v, %v, %v)", tt.petId, tt.startAfter, tt.limit)
+		})
+	}
+}
+
+func TestFeedStore_Update(t *testing.T) {
+	ctx := context.TODO()
+	s := newTestFeedStore(t, []*feed.Feed{
+		{Id: "feed1", PetId: "pet1", Timestamp: time.Unix(100, 0), Amount: 10, Unit: "g"},
+	})
+
+	tests := []struct {
+		name       string
+		feedId     string
+		pathValues map[string]interface{}
+		wantErr    assert.ErrorAssertionFunc
+	}{
+		{"not updatable", "feed1", map[string]interface{}{"feederId": "other"}, assert.Error},
+		{"not found", "feed2", map[string]interface{}{"amount": 20.0}, assert.Error},
+		{"wrong type", "feed1", map[string]interface{}{"amount": "20"}, assert.Error},
+		{"amount", "feed1", map[string]interface{}{"amount": 20.0, "unit": "ml"}, assert.NoError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.wantErr(t, s.Update(ctx, "pet1", tt.feedId, tt.pathValues),
+				fmt.Sprintf("Update(%v, %v)", tt.feedId, tt.pathValues))
+		})
+	}
+
+	got, err := s.Get(ctx, "pet1", "feed1")
+	assert.NoError(t, err)
+	assert.Equal(t, 20.0, got.Amount)
+	assert.Equal(t, "ml", got.Unit)
+}
@@ -0,0 +1,108 @@
+package memstore
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"ohmnyom/domain/food"
+	"ohmnyom/internal/errors"
+)
+
+type FoodStore struct {
+	mu    sync.Mutex
+	foods map[string]map[string]*food.Food // petId -> id -> food
+}
+
+func NewFoodStore() food.Store {
+	return &FoodStore{
+		foods: make(map[string]map[string]*food.Food),
+	}
+}
+
+func copyFood(f *food.Food) *food.Food {
+	c := *f
+	return &c
+}
+
+func (s *FoodStore) Get(ctx context.Context, petId, id string) (*food.Food, error) {
+	if petId == "" || id == "" {
+		return nil, errors.NewInvalidParamError("petId: %v, id: %v", petId, id)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, ok := s.foods[petId][id]
+	if !ok {
+		return nil, errors.NewNotFoundError("Food{PetId: %v, Id: %v}", petId, id)
+	}
+	return copyFood(f), nil
+}
+
+func (s *FoodStore) GetListOfPet(ctx context.Context, petId string) ([]*food.Food, error) {
+	if petId == "" {
+		return nil, errors.NewInvalidParamError("petId: %v", petId)
+	}
+	s.mu.Lock()
+	ret := make([]*food.Food, 0, len(s.foods[petId]))
+	for _, f := range s.foods[petId] {
+		ret = append(ret, copyFood(f))
+	}
+	s.mu.Unlock()
+
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Created.Before(ret[j].Created)
+	})
+	return ret, nil
+}
+
+func (s *FoodStore) Put(ctx context.Context, f *food.Food) error {
+	if f == nil || f.Id == "" || f.PetId == "" {
+		return errors.NewInvalidParamError("food: %v", f)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	foods, ok := s.foods[f.PetId]
+	if !ok {
+		foods = make(map[string]*food.Food)
+		s.foods[f.PetId] = foods
+	}
+	if _, ok := foods[f.Id]; ok {
+		return errors.NewAlreadyExistsError("Food{PetId: %v, Id: %v}", f.PetId, f.Id)
+	}
+	foods[f.Id] = copyFood(f)
+	return nil
+}
+
+func (s *FoodStore) Update(ctx context.Context, f *food.Food) error {
+	if f == nil || f.Id == "" || f.PetId == "" {
+		return errors.NewInvalidParamError("food: %v", f)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.foods[f.PetId][f.Id]; !ok {
+		return errors.NewNotFoundError("Food{PetId: %v, Id: %v}", f.PetId, f.Id)
+	}
+	s.foods[f.PetId][f.Id] = copyFood(f)
+	return nil
+}
+
+func (s *FoodStore) Delete(ctx context.Context, petId, id string) error {
+	if petId == "" || id == "" {
+		return errors.NewInvalidParamError("petId: %v, id: %v", petId, id)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.foods[petId], id)
+	return nil
+}
+
+func (s *FoodStore) DeleteAllOfPet(ctx context.Context, petId string) (int, error) {
+	if petId == "" {
+		return 0, errors.NewInvalidParamError("petId: %v", petId)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := len(s.foods[petId])
+	delete(s.foods, petId)
+	return n, nil
+}
@@ -0,0 +1,127 @@
+package memstore
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"ohmnyom/domain/health"
+	"ohmnyom/internal/errors"
+)
+
+type HealthStore struct {
+	mu      sync.Mutex
+	records map[string]map[string]*health.Record // petId -> id -> record
+}
+
+func NewHealthStore() health.Store {
+	return &HealthStore{
+		records: make(map[string]map[string]*health.Record),
+	}
+}
+
+func copyHealthRecord(r *health.Record) *health.Record {
+	c := *r
+	if r.Vaccination != nil {
+		v := *r.Vaccination
+		c.Vaccination = &v
+	}
+	if r.Visit != nil {
+		v := *r.Visit
+		v.Attachments = make([]*health.Attachment, len(r.Visit.Attachments))
+		for i, a := range r.Visit.Attachments {
+			copied := *a
+			v.Attachments[i] = &copied
+		}
+		c.Visit = &v
+	}
+	if r.Medication != nil {
+		m := *r.Medication
+		c.Medication = &m
+	}
+	return &c
+}
+
+func (s *HealthStore) Get(ctx context.Context, petId, id string) (*health.Record, error) {
+	if petId == "" || id == "" {
+		return nil, errors.NewInvalidParamError("petId: %v, id: %v", petId, id)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.records[petId][id]
+	if !ok {
+		return nil, errors.NewNotFoundError("Record{PetId: %v, Id: %v}", petId, id)
+	}
+	return copyHealthRecord(r), nil
+}
+
+func (s *HealthStore) GetListOfPet(ctx context.Context, petId string, kind health.Kind) ([]*health.Record, error) {
+	if petId == "" {
+		return nil, errors.NewInvalidParamError("petId: %v", petId)
+	}
+	s.mu.Lock()
+	ret := make([]*health.Record, 0, len(s.records[petId]))
+	for _, r := range s.records[petId] {
+		if kind == "" || r.Kind == kind {
+			ret = append(ret, copyHealthRecord(r))
+		}
+	}
+	s.mu.Unlock()
+
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Date.After(ret[j].Date)
+	})
+	return ret, nil
+}
+
+func (s *HealthStore) Put(ctx context.Context, r *health.Record) error {
+	if r == nil || r.Id == "" || r.PetId == "" {
+		return errors.NewInvalidParamError("record: %v", r)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	records, ok := s.records[r.PetId]
+	if !ok {
+		records = make(map[string]*health.Record)
+		s.records[r.PetId] = records
+	}
+	if _, ok := records[r.Id]; ok {
+		return errors.NewAlreadyExistsError("Record{PetId: %v, Id: %v}", r.PetId, r.Id)
+	}
+	records[r.Id] = copyHealthRecord(r)
+	return nil
+}
+
+func (s *HealthStore) Update(ctx context.Context, r *health.Record) error {
+	if r == nil || r.Id == "" || r.PetId == "" {
+		return errors.NewInvalidParamError("record: %v", r)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.records[r.PetId][r.Id]; !ok {
+		return errors.NewNotFoundError("Record{PetId: %v, Id: %v}", r.PetId, r.Id)
+	}
+	s.records[r.PetId][r.Id] = copyHealthRecord(r)
+	return nil
+}
+
+func (s *HealthStore) Delete(ctx context.Context, petId, id string) error {
+	if petId == "" || id == "" {
+		return errors.NewInvalidParamError("petId: %v, id: %v", petId, id)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.records[petId], id)
+	return nil
+}
+
+func (s *HealthStore) DeleteAllOfPet(ctx context.Context, petId string) (int, error) {
+	if petId == "" {
+		return 0, errors.NewInvalidParamError("petId: %v", petId)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := len(s.records[petId])
+	delete(s.records, petId)
+	return n, nil
+}
@@ -0,0 +1,81 @@
+package memstore
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"ohmnyom/domain/household"
+	"ohmnyom/internal/errors"
+)
+
+type HouseholdStore struct {
+	mu         sync.RWMutex
+	households map[string]*household.Household
+}
+
+func NewHouseholdStore() household.Store {
+	return &HouseholdStore{
+		households: make(map[string]*household.Household),
+	}
+}
+
+func copyHousehold(h *household.Household) *household.Household {
+	c := *h
+	c.Members = copyStrings(h.Members)
+	return &c
+}
+
+func (s *HouseholdStore) Get(ctx context.Context, id string) (*household.Household, error) {
+	if id == "" {
+		return nil, errors.NewInvalidParamError("id: %v", id)
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	h, ok := s.households[id]
+	if !ok {
+		return nil, errors.NewNotFoundError("Household{Id: %v}", id)
+	}
+	return copyHousehold(h), nil
+}
+
+func (s *HouseholdStore) GetListOfUser(ctx context.Context, uid string) ([]*household.Household, error) {
+	if uid == "" {
+		return nil, errors.NewInvalidParamError("uid: %v", uid)
+	}
+	s.mu.RLock()
+	ret := make([]*household.Household, 0)
+	for _, h := range s.households {
+		if h.IsMember(uid) {
+			ret = append(ret, copyHousehold(h))
+		}
+	}
+	s.mu.RUnlock()
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Id < ret[j].Id
+	})
+	return ret, nil
+}
+
+func (s *HouseholdStore) Put(ctx context.Context, h *household.Household) error {
+	if h == nil || h.Id == "" {
+		return errors.NewInvalidParamError("household: %v", h)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.households[h.Id]; ok {
+		return errors.NewAlreadyExistsError("Household{Id: %v}", h.Id)
+	}
+	s.households[h.Id] = copyHousehold(h)
+	return nil
+}
+
+func (s *HouseholdStore) Delete(ctx context.Context, id string) error {
+	if id == "" {
+		return errors.NewInvalidParamError("id: %v", id)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.households, id)
+	return nil
+}
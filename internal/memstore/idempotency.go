@@ -0,0 +1,66 @@
+package memstore
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"ohmnyom/domain/idempotency"
+	"ohmnyom/internal/errors"
+)
+
+type IdempotencyStore struct {
+	mu      sync.Mutex
+	records map[string]*idempotency.Record
+}
+
+func NewIdempotencyStore() idempotency.Store {
+	return &IdempotencyStore{
+		records: make(map[string]*idempotency.Record),
+	}
+}
+
+func copyRecord(r *idempotency.Record) *idempotency.Record {
+	c := *r
+	c.Response = append([]byte(nil), r.Response...)
+	return &c
+}
+
+func (s *IdempotencyStore) Begin(ctx context.Context, record *idempotency.Record) (*idempotency.Record, error) {
+	if record == nil || record.Id == "" {
+		return nil, errors.NewInvalidParamError("record: %v", record)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if stored, ok := s.records[record.Id]; ok && stored.Expires.After(time.Now()) {
+		return copyRecord(stored), nil
+	}
+	s.records[record.Id] = copyRecord(record)
+	return nil, nil
+}
+
+func (s *IdempotencyStore) Complete(ctx context.Context, id string, response []byte, expires time.Time) error {
+	if id == "" {
+		return errors.NewInvalidParamError("id: %v", id)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.records[id]
+	if !ok {
+		return errors.NewNotFoundError("Record{Id: %v}", id)
+	}
+	r.State = idempotency.StateDone
+	r.Response = append([]byte(nil), response...)
+	r.Expires = expires
+	return nil
+}
+
+func (s *IdempotencyStore) Delete(ctx context.Context, id string) error {
+	if id == "" {
+		return errors.NewInvalidParamError("id: %v", id)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.records, id)
+	return nil
+}
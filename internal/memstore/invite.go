@@ -0,0 +1,78 @@
+package memstore
+
+import (
+	"context"
+	"sync"
+
+	"ohmnyom/domain/invite"
+	"ohmnyom/internal/errors"
+)
+
+type InviteStore struct {
+	mu      sync.Mutex
+	invites map[string]*invite.Invite
+}
+
+func NewInviteStore() invite.Store {
+	return &InviteStore{
+		invites: make(map[string]*invite.Invite),
+	}
+}
+
+func copyInvite(i *invite.Invite) *invite.Invite {
+	c := *i
+	c.UsedBy = copyStrings(i.UsedBy)
+	if i.Grant != nil {
+		grant := *i.Grant
+		c.Grant = &grant
+	}
+	return &c
+}
+
+func (s *InviteStore) Get(ctx context.Context, code string) (*invite.Invite, error) {
+	if code == "" {
+		return nil, errors.NewInvalidParamError("code: %v", code)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i, ok := s.invites[code]
+	if !ok {
+		return nil, errors.NewNotFoundError("Invite{Code: %v}", code)
+	}
+	return copyInvite(i), nil
+}
+
+func (s *InviteStore) GetListOfPet(ctx context.Context, petId string) ([]*invite.Invite, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ret := make([]*invite.Invite, 0)
+	for _, i := range s.invites {
+		if i.PetId == petId {
+			ret = append(ret, copyInvite(i))
+		}
+	}
+	return ret, nil
+}
+
+func (s *InviteStore) Put(ctx context.Context, i *invite.Invite) error {
+	if i == nil || i.Code == "" {
+		return errors.NewInvalidParamError("invite: %v", i)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.invites[i.Code]; ok {
+		return errors.NewAlreadyExistsError("Invite{Code: %v}", i.Code)
+	}
+	s.invites[i.Code] = copyInvite(i)
+	return nil
+}
+
+func (s *InviteStore) Delete(ctx context.Context, code string) error {
+	if code == "" {
+		return errors.NewInvalidParamError("code: %v", code)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.invites, code)
+	return nil
+}
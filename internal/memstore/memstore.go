@@ -0,0 +1,33 @@
+// Package memstore implements the domain stores in memory. The semantics follow
+// the firestore stores so that servers can be tested without the emulator.
+package memstore
+
+func copyStrings(s []string) []string {
+	if s == nil {
+		return nil
+	}
+	ret := make([]string, len(s))
+	copy(ret, s)
+	return ret
+}
+
+// arrayUnion behaves like firestore.ArrayUnion, appending only missing values.
+func arrayUnion(s []string, value string) []string {
+	for _, v := range s {
+		if v == value {
+			return s
+		}
+	}
+	return append(s, value)
+}
+
+// arrayRemove behaves like firestore.ArrayRemove, removing every occurrence.
+func arrayRemove(s []string, value string) []string {
+	ret := make([]string, 0, len(s))
+	for _, v := range s {
+		if v != value {
+			ret = append(ret, v)
+		}
+	}
+	return ret
+}
@@ -0,0 +1,219 @@
+package memstore
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"ohmnyom/domain/pet"
+	"ohmnyom/internal/errors"
+)
+
+type PetStore struct {
+	mu   sync.RWMutex
+	pets map[string]*pet.Pet
+}
+
+func NewPetStore() pet.Store {
+	return &PetStore{
+		pets: make(map[string]*pet.Pet),
+	}
+}
+
+func copyPet(p *pet.Pet) *pet.Pet {
+	c := *p
+	c.Feeders = copyStrings(p.Feeders)
+	if p.Roles != nil {
+		c.Roles = make(map[string]string, len(p.Roles))
+		for uid, role := range p.Roles {
+			c.Roles[uid] = role
+		}
+	}
+	if p.Grants != nil {
+		c.Grants = make(map[string]*pet.Grant, len(p.Grants))
+		for uid, g := range p.Grants {
+			grant := *g
+			c.Grants[uid] = &grant
+		}
+	}
+	return &c
+}
+
+func (s *PetStore) Get(ctx context.Context, id string) (*pet.Pet, error) {
+	if id == "" {
+		return nil, errors.NewInvalidParamError("id: %v", id)
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	p, ok := s.pets[id]
+	if !ok {
+		return nil, errors.NewNotFoundError("Pet{Id: %v}", id)
+	}
+	return copyPet(p), nil
+}
+
+func (s *PetStore) GetList(ctx context.Context, ids []string) (pet.List, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	ret := make([]*pet.Pet, 0)
+	for _, id := range ids {
+		if p, ok := s.pets[id]; ok {
+			ret = append(ret, copyPet(p))
+		}
+	}
+	return ret, nil
+}
+
+func (s *PetStore) Put(ctx context.Context, p *pet.Pet) error {
+	if p == nil || p.Id == "" {
+		return errors.NewInvalidParamError("p: %v", p)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.pets[p.Id]; ok {
+		return errors.NewAlreadyExistsError("Pet{Id: %v}", p.Id)
+	}
+	s.pets[p.Id] = copyPet(p)
+	return nil
+}
+
+func (s *PetStore) Update(ctx context.Context, id string, pathValues map[string]interface{}) error {
+	if id == "" {
+		return errors.NewInvalidParamError("id: %v", id)
+	}
+	for path := range pathValues {
+		if !pet.IsUpdatableField(path) {
+			return errors.NewInvalidParamError("path %v is not updatable", path)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored, ok := s.pets[id]
+	if !ok {
+		return errors.NewNotFoundError("Pet{Id: %v}", id)
+	}
+
+	updated := copyPet(stored)
+	for path, value := range pathValues {
+		if err := setPetField(updated, path, value); err != nil {
+			return err
+		}
+	}
+	s.pets[id] = updated
+	return nil
+}
+
+func setPetField(p *pet.Pet, path string, value interface{}) error {
+	var ok bool
+	switch path {
+	case pet.NameField:
+		p.Name, ok = value.(string)
+	case pet.PhotourlField:
+		p.Photourl, ok = value.(string)
+	case pet.AdoptedField:
+		p.Adopted, ok = value.(time.Time)
+	case pet.FamilyField:
+		p.Family, ok = value.(string)
+	case pet.SpeciesField:
+		p.Species, ok = value.(string)
+	case pet.FeedWindowField:
+		p.FeedWindowMinutes, ok = value.(int)
+	case pet.PreferredUnitField:
+		p.PreferredUnit, ok = value.(string)
+	case pet.BornField:
+		p.Born, ok = value.(time.Time)
+	case pet.NeuteredField:
+		p.Neutered, ok = value.(bool)
+	default:
+		return errors.NewInvalidParamError("path %v is not supported", path)
+	}
+	if !ok {
+		return errors.NewInvalidParamError("path %v, value %v", path, value)
+	}
+	return nil
+}
+
+func (s *PetStore) Delete(ctx context.Context, id string) error {
+	if id == "" {
+		return errors.NewInvalidParamError("id: %v", id)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.pets, id)
+	return nil
+}
+
+func (s *PetStore) AddMember(ctx context.Context, id, uid string, role pet.Role) error {
+	if id == "" || uid == "" || role == pet.RoleNone {
+		return errors.NewInvalidParamError("id: %v, uid: %v, role: %v", id, uid, role)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.pets[id]
+	if !ok {
+		return errors.NewNotFoundError("Pet{Id: %v}", id)
+	}
+	addMember(p, uid, role)
+	return nil
+}
+
+func (s *PetStore) DeleteMember(ctx context.Context, id, uid string) error {
+	if id == "" || uid == "" {
+		return errors.NewInvalidParamError("id: %v, uid: %v", id, uid)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.pets[id]
+	if !ok {
+		return errors.NewNotFoundError("Pet{Id: %v}", id)
+	}
+	deleteMember(p, uid)
+	return nil
+}
+
+// addMember does what the firestore update of a membership does to the stored
+// document.
+func addMember(p *pet.Pet, uid string, role pet.Role) {
+	p.Feeders = arrayUnion(p.Feeders, uid)
+	if p.Roles == nil {
+		p.Roles = make(map[string]string)
+	}
+	p.Roles[uid] = role.String()
+}
+
+func deleteMember(p *pet.Pet, uid string) {
+	p.Feeders = arrayRemove(p.Feeders, uid)
+	delete(p.Roles, uid)
+	delete(p.Grants, uid)
+}
+
+func (s *PetStore) GetListOfHousehold(ctx context.Context, householdId string) (pet.List, error) {
+	if householdId == "" {
+		return nil, errors.NewInvalidParamError("householdId: %v", householdId)
+	}
+	return s.filter(func(p *pet.Pet) bool { return p.HouseholdId == householdId }), nil
+}
+
+func (s *PetStore) GetListOfExpiredGrants(ctx context.Context, now time.Time) (pet.List, error) {
+	return s.filter(func(p *pet.Pet) bool {
+		return !p.GrantsExpire.IsZero() && !p.GrantsExpire.After(now)
+	}), nil
+}
+
+// filter returns the pets that match, sorted by id.
+func (s *PetStore) filter(match func(p *pet.Pet) bool) pet.List {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	ret := make([]*pet.Pet, 0)
+	for _, p := range s.pets {
+		if match(p) {
+			ret = append(ret, copyPet(p))
+		}
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Id < ret[j].Id
+	})
+	return ret
+}
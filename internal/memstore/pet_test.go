@@ -0,0 +1,49 @@
+package memstore
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"ohmnyom/domain/pet"
+)
+
+func TestPetStore_Members(t *testing.T) {
+	ctx := context.TODO()
+	s := NewPetStore()
+	assert.NoError(t, s.Put(ctx, &pet.Pet{Id: "pet1", Name: "nyom", Feeders: []string{"user1"}}))
+	assert.Error(t, s.Put(ctx, &pet.Pet{Id: "pet1"}))
+
+	assert.NoError(t, s.AddMember(ctx, "pet1", "user2", pet.RoleCaregiver))
+	assert.NoError(t, s.AddMember(ctx, "pet1", "user2", pet.RoleViewer))
+	assert.NoError(t, s.AddMember(ctx, "pet1", "user3", pet.RoleOwner))
+	assert.NoError(t, s.DeleteMember(ctx, "pet1", "user3"))
+	assert.Error(t, s.AddMember(ctx, "pet-notfound", "user1", pet.RoleCaregiver))
+	assert.Error(t, s.AddMember(ctx, "pet1", "user4", pet.RoleNone))
+
+	got, err := s.Get(ctx, "pet1")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"user1", "user2"}, got.Feeders)
+	assert.Equal(t, map[string]string{"user2": "viewer"}, got.Roles)
+	// user1 is not migrated yet and owns the pet as its first feeder
+	assert.Equal(t, pet.RoleOwner, got.RoleOf("user1"))
+	assert.Equal(t, pet.RoleViewer, got.RoleOf("user2"))
+	assert.Equal(t, pet.RoleNone, got.RoleOf("user3"))
+
+	assert.Error(t, s.Update(ctx, "pet1", map[string]interface{}{pet.RolesField: map[string]string{}}))
+}
+
+func TestPetStore_Update(t *testing.T) {
+	ctx := context.TODO()
+	s := NewPetStore()
+	assert.NoError(t, s.Put(ctx, &pet.Pet{Id: "pet1", Name: "nyom"}))
+
+	assert.Error(t, s.Update(ctx, "pet1", map[string]interface{}{"Id": "pet2"}))
+	assert.Error(t, s.Update(ctx, "pet-notfound", map[string]interface{}{pet.NameField: "ohm"}))
+	assert.NoError(t, s.Update(ctx, "pet1", map[string]interface{}{pet.NameField: "ohm"}))
+
+	list, err := s.GetList(ctx, []string{"pet1", "pet-notfound"})
+	assert.NoError(t, err)
+	assert.Len(t, list, 1)
+	assert.Equal(t, "ohm", list[0].Name)
+}
@@ -0,0 +1,34 @@
+package memstore
+
+import (
+	"context"
+	"sync"
+
+	"ohmnyom/domain/reminder"
+	"ohmnyom/internal/errors"
+)
+
+type ReminderStore struct {
+	mu        sync.Mutex
+	reminders map[string]*reminder.Reminder
+}
+
+func NewReminderStore() reminder.Store {
+	return &ReminderStore{
+		reminders: make(map[string]*reminder.Reminder),
+	}
+}
+
+func (s *ReminderStore) Claim(ctx context.Context, r *reminder.Reminder) (bool, error) {
+	if r == nil || r.Id == "" {
+		return false, errors.NewInvalidParamError("reminder: %v", r)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.reminders[r.Id]; ok {
+		return false, nil
+	}
+	c := *r
+	s.reminders[r.Id] = &c
+	return true, nil
+}
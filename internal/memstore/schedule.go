@@ -0,0 +1,123 @@
+package memstore
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"ohmnyom/domain/schedule"
+	"ohmnyom/internal/errors"
+)
+
+type ScheduleStore struct {
+	mu        sync.Mutex
+	schedules map[string]map[string]*schedule.Schedule // petId -> id -> schedule
+}
+
+func NewScheduleStore() schedule.Store {
+	return &ScheduleStore{
+		schedules: make(map[string]map[string]*schedule.Schedule),
+	}
+}
+
+func copySchedule(s *schedule.Schedule) *schedule.Schedule {
+	c := *s
+	c.Slots = append([]schedule.Slot(nil), s.Slots...)
+	c.Weekdays = append([]time.Weekday(nil), s.Weekdays...)
+	return &c
+}
+
+func (s *ScheduleStore) Get(ctx context.Context, petId, id string) (*schedule.Schedule, error) {
+	if petId == "" || id == "" {
+		return nil, errors.NewInvalidParamError("petId: %v, id: %v", petId, id)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sc, ok := s.schedules[petId][id]
+	if !ok {
+		return nil, errors.NewNotFoundError("Schedule{PetId: %v, Id: %v}", petId, id)
+	}
+	return copySchedule(sc), nil
+}
+
+func (s *ScheduleStore) GetListOfPet(ctx context.Context, petId string) ([]*schedule.Schedule, error) {
+	if petId == "" {
+		return nil, errors.NewInvalidParamError("petId: %v", petId)
+	}
+	s.mu.Lock()
+	ret := make([]*schedule.Schedule, 0, len(s.schedules[petId]))
+	for _, sc := range s.schedules[petId] {
+		ret = append(ret, copySchedule(sc))
+	}
+	s.mu.Unlock()
+
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Created.Before(ret[j].Created)
+	})
+	return ret, nil
+}
+
+func (s *ScheduleStore) GetAll(ctx context.Context) ([]*schedule.Schedule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ret := make([]*schedule.Schedule, 0)
+	for _, schedules := range s.schedules {
+		for _, sc := range schedules {
+			ret = append(ret, copySchedule(sc))
+		}
+	}
+	return ret, nil
+}
+
+func (s *ScheduleStore) Put(ctx context.Context, sc *schedule.Schedule) error {
+	if sc == nil || sc.Id == "" || sc.PetId == "" {
+		return errors.NewInvalidParamError("schedule: %v", sc)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	schedules, ok := s.schedules[sc.PetId]
+	if !ok {
+		schedules = make(map[string]*schedule.Schedule)
+		s.schedules[sc.PetId] = schedules
+	}
+	if _, ok := schedules[sc.Id]; ok {
+		return errors.NewAlreadyExistsError("Schedule{PetId: %v, Id: %v}", sc.PetId, sc.Id)
+	}
+	schedules[sc.Id] = copySchedule(sc)
+	return nil
+}
+
+func (s *ScheduleStore) Update(ctx context.Context, sc *schedule.Schedule) error {
+	if sc == nil || sc.Id == "" || sc.PetId == "" {
+		return errors.NewInvalidParamError("schedule: %v", sc)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.schedules[sc.PetId][sc.Id]; !ok {
+		return errors.NewNotFoundError("Schedule{PetId: %v, Id: %v}", sc.PetId, sc.Id)
+	}
+	s.schedules[sc.PetId][sc.Id] = copySchedule(sc)
+	return nil
+}
+
+func (s *ScheduleStore) Delete(ctx context.Context, petId, id string) error {
+	if petId == "" || id == "" {
+		return errors.NewInvalidParamError("petId: %v, id: %v", petId, id)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.schedules[petId], id)
+	return nil
+}
+
+func (s *ScheduleStore) DeleteAllOfPet(ctx context.Context, petId string) (int, error) {
+	if petId == "" {
+		return 0, errors.NewInvalidParamError("petId: %v", petId)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := len(s.schedules[petId])
+	delete(s.schedules, petId)
+	return n, nil
+}
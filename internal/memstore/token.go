@@ -0,0 +1,128 @@
+package memstore
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"ohmnyom/domain/token"
+	"ohmnyom/internal/errors"
+)
+
+type TokenStore struct {
+	mu          sync.Mutex
+	families    map[string]*token.Family
+	revocations map[string]*token.Revocation
+	generations map[string]int64
+}
+
+func NewTokenStore() token.Store {
+	return &TokenStore{
+		families:    make(map[string]*token.Family),
+		revocations: make(map[string]*token.Revocation),
+		generations: make(map[string]int64),
+	}
+}
+
+func (s *TokenStore) Get(ctx context.Context, id string) (*token.Family, error) {
+	if id == "" {
+		return nil, errors.NewInvalidParamError("id: %v", id)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, ok := s.families[id]
+	if !ok {
+		return nil, errors.NewNotFoundError("Family{Id: %v}", id)
+	}
+	c := *f
+	return &c, nil
+}
+
+func (s *TokenStore) Put(ctx context.Context, f *token.Family) error {
+	if f == nil || f.Id == "" {
+		return errors.NewInvalidParamError("family: %v", f)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.families[f.Id]; ok {
+		return errors.NewAlreadyExistsError("Family{Id: %v}", f.Id)
+	}
+	c := *f
+	s.families[f.Id] = &c
+	return nil
+}
+
+func (s *TokenStore) Rotate(ctx context.Context, id, presented, next string, expires time.Time) error {
+	if id == "" || presented == "" || next == "" {
+		return errors.NewInvalidParamError("id: %v, presented: %v, next: %v", id, presented, next)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, ok := s.families[id]
+	if !ok {
+		return errors.NewAuthenticationError("unknown token family %v", id)
+	}
+	if f.Revoked {
+		return errors.NewAuthenticationError("token family %v revoked", id)
+	}
+	if f.Current != presented {
+		f.Revoked = true
+		return errors.NewAuthenticationError("refresh token reused, token family %v revoked", id)
+	}
+	f.Current = next
+	f.Expires = expires
+	return nil
+}
+
+func (s *TokenStore) Revoke(ctx context.Context, id string) error {
+	if id == "" {
+		return errors.NewInvalidParamError("id: %v", id)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, ok := s.families[id]
+	if !ok {
+		return errors.NewNotFoundError("Family{Id: %v}", id)
+	}
+	f.Revoked = true
+	return nil
+}
+
+func (s *TokenStore) RevokeToken(ctx context.Context, id, uid string, expires time.Time) error {
+	if id == "" {
+		return errors.NewInvalidParamError("id: %v", id)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.revocations[id] = &token.Revocation{Id: id, Uid: uid, Expires: expires}
+	return nil
+}
+
+func (s *TokenStore) IsTokenRevoked(ctx context.Context, id string) (bool, error) {
+	if id == "" {
+		return false, errors.NewInvalidParamError("id: %v", id)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.revocations[id]
+	return ok, nil
+}
+
+func (s *TokenStore) GetGeneration(ctx context.Context, uid string) (int64, error) {
+	if uid == "" {
+		return 0, errors.NewInvalidParamError("uid: %v", uid)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.generations[uid], nil
+}
+
+func (s *TokenStore) BumpGeneration(ctx context.Context, uid string) (int64, error) {
+	if uid == "" {
+		return 0, errors.NewInvalidParamError("uid: %v", uid)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.generations[uid]++
+	return s.generations[uid], nil
+}
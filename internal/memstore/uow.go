@@ -0,0 +1,306 @@
+package memstore
+
+import (
+	"context"
+
+	"ohmnyom/domain/audit"
+	"ohmnyom/domain/deletion"
+	"ohmnyom/domain/household"
+	"ohmnyom/domain/invite"
+	"ohmnyom/domain/pet"
+	"ohmnyom/domain/uow"
+	"ohmnyom/domain/user"
+	"ohmnyom/internal/errors"
+)
+
+// UnitOfWork runs transactions over the memstores it was created with. Writes
+// of a failed transaction are rolled back.
+type UnitOfWork struct {
+	users      *UserStore
+	pets       *PetStore
+	invites    *InviteStore
+	deletions  *DeletionStore
+	audits     *AuditStore
+	households *HouseholdStore
+}
+
+// NewUnitOfWork takes stores created by NewUserStore, NewPetStore,
+// NewInviteStore, NewDeletionStore, NewAuditStore and NewHouseholdStore.
+func NewUnitOfWork(users user.Store, pets pet.Store, invites invite.Store, deletions deletion.Store,
+	audits audit.Store, households household.Store) uow.UnitOfWork {
+	return &UnitOfWork{
+		users:      users.(*UserStore),
+		pets:       pets.(*PetStore),
+		invites:    invites.(*InviteStore),
+		deletions:  deletions.(*DeletionStore),
+		audits:     audits.(*AuditStore),
+		households: households.(*HouseholdStore),
+	}
+}
+
+func (u *UnitOfWork) Run(ctx context.Context, fn func(ctx context.Context, tx uow.Tx) error) error {
+	u.users.mu.Lock()
+	defer u.users.mu.Unlock()
+	u.pets.mu.Lock()
+	defer u.pets.mu.Unlock()
+	u.invites.mu.Lock()
+	defer u.invites.mu.Unlock()
+	u.deletions.mu.Lock()
+	defer u.deletions.mu.Unlock()
+	u.audits.mu.Lock()
+	defer u.audits.mu.Unlock()
+	u.households.mu.Lock()
+	defer u.households.mu.Unlock()
+
+	users := make(map[string]*user.User, len(u.users.users))
+	for id, v := range u.users.users {
+		users[id] = copyUser(v)
+	}
+	pets := make(map[string]*pet.Pet, len(u.pets.pets))
+	for id, v := range u.pets.pets {
+		pets[id] = copyPet(v)
+	}
+	invites := make(map[string]*invite.Invite, len(u.invites.invites))
+	for code, v := range u.invites.invites {
+		invites[code] = copyInvite(v)
+	}
+	jobs := make(map[string]*deletion.Job, len(u.deletions.jobs))
+	for id, v := range u.deletions.jobs {
+		jobs[id] = copyJob(v)
+	}
+
+	households := make(map[string]*household.Household, len(u.households.households))
+	for id, v := range u.households.households {
+		households[id] = copyHousehold(v)
+	}
+	x := &tx{users: users, pets: pets, invites: invites, jobs: jobs, households: households}
+	if err := fn(ctx, x); err != nil {
+		return err
+	}
+	u.users.users = users
+	u.pets.pets = pets
+	u.invites.invites = invites
+	u.deletions.jobs = jobs
+	u.households.households = households
+	u.audits.events = append(u.audits.events, x.events...)
+	return nil
+}
+
+// tx works on copies of the store maps, which replace the originals on commit.
+type tx struct {
+	users      map[string]*user.User
+	pets       map[string]*pet.Pet
+	invites    map[string]*invite.Invite
+	jobs       map[string]*deletion.Job
+	households map[string]*household.Household
+	// events are appended to the audit store on commit.
+	events  []*audit.Event
+	writing bool
+}
+
+func (x *tx) read() error {
+	if x.writing {
+		return errors.NewInternalError("read after write in transaction")
+	}
+	return nil
+}
+
+func (x *tx) GetUser(id string) (*user.User, error) {
+	if err := x.read(); err != nil {
+		return nil, err
+	}
+	u, ok := x.users[id]
+	if !ok {
+		return nil, errors.NewNotFoundError("User{Id: %v}", id)
+	}
+	return copyUser(u), nil
+}
+
+func (x *tx) GetPet(id string) (*pet.Pet, error) {
+	if err := x.read(); err != nil {
+		return nil, err
+	}
+	p, ok := x.pets[id]
+	if !ok {
+		return nil, errors.NewNotFoundError("Pet{Id: %v}", id)
+	}
+	return copyPet(p), nil
+}
+
+func (x *tx) GetInvite(code string) (*invite.Invite, error) {
+	if err := x.read(); err != nil {
+		return nil, err
+	}
+	i, ok := x.invites[code]
+	if !ok {
+		return nil, errors.NewNotFoundError("Invite{Code: %v}", code)
+	}
+	return copyInvite(i), nil
+}
+
+func (x *tx) GetHousehold(id string) (*household.Household, error) {
+	if err := x.read(); err != nil {
+		return nil, err
+	}
+	h, ok := x.households[id]
+	if !ok {
+		return nil, errors.NewNotFoundError("Household{Id: %v}", id)
+	}
+	return copyHousehold(h), nil
+}
+
+func (x *tx) DeleteUser(id string) error {
+	x.writing = true
+	delete(x.users, id)
+	return nil
+}
+
+func (x *tx) AddUserPet(id, petId string) error {
+	x.writing = true
+	u, ok := x.users[id]
+	if !ok {
+		return errors.NewNotFoundError("User{Id: %v}", id)
+	}
+	u.Pets = arrayUnion(u.Pets, petId)
+	return nil
+}
+
+func (x *tx) DeleteUserPet(id, petId string) error {
+	x.writing = true
+	u, ok := x.users[id]
+	if !ok {
+		return errors.NewNotFoundError("User{Id: %v}", id)
+	}
+	u.Pets = arrayRemove(u.Pets, petId)
+	return nil
+}
+
+func (x *tx) CreatePet(p *pet.Pet) error {
+	x.writing = true
+	if _, ok := x.pets[p.Id]; ok {
+		return errors.NewAlreadyExistsError("Pet{Id: %v}", p.Id)
+	}
+	x.pets[p.Id] = copyPet(p)
+	return nil
+}
+
+func (x *tx) DeletePet(id string) error {
+	x.writing = true
+	delete(x.pets, id)
+	return nil
+}
+
+func (x *tx) AddMember(petId, uid string, role pet.Role) error {
+	x.writing = true
+	if role == pet.RoleNone {
+		return errors.NewInvalidParamError("role: %v", role)
+	}
+	p, ok := x.pets[petId]
+	if !ok {
+		return errors.NewNotFoundError("Pet{Id: %v}", petId)
+	}
+	addMember(p, uid, role)
+	return nil
+}
+
+func (x *tx) DeleteMember(petId, uid string) error {
+	x.writing = true
+	p, ok := x.pets[petId]
+	if !ok {
+		return errors.NewNotFoundError("Pet{Id: %v}", petId)
+	}
+	deleteMember(p, uid)
+	return nil
+}
+
+func (x *tx) SetGrants(petId string, grants map[string]*pet.Grant) error {
+	x.writing = true
+	p, ok := x.pets[petId]
+	if !ok {
+		return errors.NewNotFoundError("Pet{Id: %v}", petId)
+	}
+	p.Grants = nil
+	for uid, g := range grants {
+		if p.Grants == nil {
+			p.Grants = make(map[string]*pet.Grant, len(grants))
+		}
+		grant := *g
+		p.Grants[uid] = &grant
+	}
+	p.GrantsExpire = pet.GrantsExpire(grants)
+	return nil
+}
+
+func (x *tx) SetPetHousehold(petId, householdId string) error {
+	x.writing = true
+	p, ok := x.pets[petId]
+	if !ok {
+		return errors.NewNotFoundError("Pet{Id: %v}", petId)
+	}
+	p.HouseholdId = householdId
+	return nil
+}
+
+func (x *tx) CreateHousehold(h *household.Household) error {
+	x.writing = true
+	if _, ok := x.households[h.Id]; ok {
+		return errors.NewAlreadyExistsError("Household{Id: %v}", h.Id)
+	}
+	x.households[h.Id] = copyHousehold(h)
+	return nil
+}
+
+func (x *tx) DeleteHousehold(id string) error {
+	x.writing = true
+	delete(x.households, id)
+	return nil
+}
+
+func (x *tx) AddHouseholdMember(id, uid string) error {
+	x.writing = true
+	h, ok := x.households[id]
+	if !ok {
+		return errors.NewNotFoundError("Household{Id: %v}", id)
+	}
+	h.Members = arrayUnion(h.Members, uid)
+	return nil
+}
+
+func (x *tx) DeleteHouseholdMember(id, uid string) error {
+	x.writing = true
+	h, ok := x.households[id]
+	if !ok {
+		return errors.NewNotFoundError("Household{Id: %v}", id)
+	}
+	h.Members = arrayRemove(h.Members, uid)
+	return nil
+}
+
+func (x *tx) UseInvite(code, uid string) error {
+	x.writing = true
+	i, ok := x.invites[code]
+	if !ok {
+		return errors.NewNotFoundError("Invite{Code: %v}", code)
+	}
+	i.Uses++
+	i.UsedBy = arrayUnion(i.UsedBy, uid)
+	return nil
+}
+
+func (x *tx) SaveDeletionJob(job *deletion.Job) error {
+	x.writing = true
+	if job == nil || job.Id == "" {
+		return errors.NewInvalidParamError("job: %v", job)
+	}
+	x.jobs[job.Id] = copyJob(job)
+	return nil
+}
+
+func (x *tx) SaveAuditEvent(event *audit.Event) error {
+	x.writing = true
+	if event == nil || event.Id == "" || (event.PetId == "" && event.UserId == "") {
+		return errors.NewInvalidParamError("event: %v", event)
+	}
+	x.events = append(x.events, copyEvent(event))
+	return nil
+}
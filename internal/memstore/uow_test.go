@@ -0,0 +1,57 @@
+package memstore
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"ohmnyom/domain/pet"
+	"ohmnyom/domain/uow"
+	"ohmnyom/domain/user"
+	"ohmnyom/internal/errors"
+)
+
+func TestUnitOfWork_Run(t *testing.T) {
+	ctx := context.TODO()
+	users := NewUserStore()
+	pets := NewPetStore()
+	u := NewUnitOfWork(users, pets, NewInviteStore(), NewDeletionStore(), NewAuditStore(), NewHouseholdStore())
+	assert.NoError(t, users.Put(ctx, &user.User{Id: "user1"}))
+
+	assert.NoError(t, u.Run(ctx, func(ctx context.Context, tx uow.Tx) error {
+		if err := tx.CreatePet(&pet.Pet{Id: "pet1"}); err != nil {
+			return err
+		}
+		return uow.Join(tx, "user1", "pet1", pet.RoleOwner)
+	}))
+	got, err := users.Get(ctx, "user1")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"pet1"}, got.Pets)
+
+	// a failing transaction leaves nothing behind
+	err = u.Run(ctx, func(ctx context.Context, tx uow.Tx) error {
+		if err := tx.CreatePet(&pet.Pet{Id: "pet2"}); err != nil {
+			return err
+		}
+		if err := tx.AddUserPet("user1", "pet2"); err != nil {
+			return err
+		}
+		return tx.AddMember("pet-notfound", "user1", pet.RoleCaregiver)
+	})
+	var notfound *errors.NotFoundError
+	assert.True(t, errors.As(err, &notfound))
+	_, err = pets.Get(ctx, "pet2")
+	assert.Error(t, err)
+	got, err = users.Get(ctx, "user1")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"pet1"}, got.Pets)
+
+	err = u.Run(ctx, func(ctx context.Context, tx uow.Tx) error {
+		if err := tx.DeletePet("pet1"); err != nil {
+			return err
+		}
+		_, err := tx.GetUser("user1")
+		return err
+	})
+	assert.Error(t, err, "read after write")
+}
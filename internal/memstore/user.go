@@ -0,0 +1,147 @@
+package memstore
+
+import (
+	"context"
+	"sync"
+
+	"ohmnyom/domain/user"
+	"ohmnyom/internal/errors"
+)
+
+type UserStore struct {
+	mu    sync.RWMutex
+	users map[string]*user.User
+}
+
+func NewUserStore() user.Store {
+	return &UserStore{
+		users: make(map[string]*user.User),
+	}
+}
+
+func copyUser(u *user.User) *user.User {
+	c := *u
+	if u.OAuthInfo != nil {
+		c.OAuthInfo = make(map[string]*user.OAuthInfo, len(u.OAuthInfo))
+		for provider, info := range u.OAuthInfo {
+			i := *info
+			c.OAuthInfo[provider] = &i
+		}
+	}
+	c.Pets = copyStrings(u.Pets)
+	return &c
+}
+
+func (s *UserStore) Get(ctx context.Context, id string) (*user.User, error) {
+	if id == "" {
+		return nil, errors.NewInvalidParamError("id: %v", id)
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	u, ok := s.users[id]
+	if !ok {
+		return nil, errors.NewNotFoundError("User{Id: %v}", id)
+	}
+	return copyUser(u), nil
+}
+
+func (s *UserStore) GetByEmail(ctx context.Context, email string) (*user.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, u := range s.users {
+		if u.Email == email {
+			return copyUser(u), nil
+		}
+	}
+	return nil, errors.NewNotFoundError("User{Email: %v}", email)
+}
+
+func (s *UserStore) GetByOAuth(ctx context.Context, info *user.OAuthInfo, provider string) (*user.User, error) {
+	if info == nil || info.Id == "" {
+		return nil, errors.NewInvalidParamError("info: %v", info)
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, u := range s.users {
+		if i, ok := u.OAuthInfo[provider]; ok && i.Id == info.Id {
+			return copyUser(u), nil
+		}
+	}
+	return nil, errors.NewNotFoundError("User{OAuthInfo: %v}", info)
+}
+
+func (s *UserStore) Put(ctx context.Context, u *user.User) error {
+	if u == nil || u.Id == "" {
+		return errors.NewInvalidParamError("user: %v", u)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.users[u.Id]; ok {
+		return errors.NewAlreadyExistsError("User{Id: %v}", u.Id)
+	}
+	s.users[u.Id] = copyUser(u)
+	return nil
+}
+
+func (s *UserStore) Update(ctx context.Context, u *user.User, path, value string) error {
+	if u == nil {
+		return errors.NewInvalidParamError("u: %v", u)
+	}
+	if !user.IsUpdatableField(path) {
+		return errors.NewInvalidParamError("path %v is not updatable", path)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored, ok := s.users[u.Id]
+	if !ok {
+		return errors.NewNotFoundError("User{Id: %v}", u.Id)
+	}
+	switch path {
+	case "name":
+		stored.Name = value
+	case "password":
+		stored.Password = value
+	case "photourl":
+		stored.Photourl = value
+	}
+	return nil
+}
+
+// Delete does nothing and returns no error if doc not exists.
+func (s *UserStore) Delete(ctx context.Context, id string) error {
+	if id == "" {
+		return errors.NewInvalidParamError("id: %v", id)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.users, id)
+	return nil
+}
+
+func (s *UserStore) AddPet(ctx context.Context, id, petId string) error {
+	if id == "" || petId == "" {
+		return errors.NewInvalidParamError("id: %v, petId: %v", id, petId)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, ok := s.users[id]
+	if !ok {
+		return errors.NewNotFoundError("User{Id: %v}", id)
+	}
+	u.Pets = arrayUnion(u.Pets, petId)
+	return nil
+}
+
+func (s *UserStore) DeletePet(ctx context.Context, id, petId string) error {
+	if id == "" || petId == "" {
+		return errors.NewInvalidParamError("id: %v, petId: %v", id, petId)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, ok := s.users[id]
+	if !ok {
+		return errors.NewNotFoundError("User{Id: %v}", id)
+	}
+	u.Pets = arrayRemove(u.Pets, petId)
+	return nil
+}
@@ -0,0 +1,137 @@
+package memstore
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"ohmnyom/domain/user"
+	"ohmnyom/internal/errors"
+)
+
+var users = []*user.User{
+	{
+		Id:       "id-testuser1",
+		Name:     "name-testuser1",
+		Email:    "email-testuser1@test.com",
+		Password: "password-testuser1",
+		SignedUp: time.Date(2006, 1, 2, 15, 4, 5, 987, time.UTC),
+		Pets:     []string{"pet1-1", "pet1-2"},
+	},
+	{
+		Id:    "id-testuser2",
+		Name:  "name-testuser2",
+		Email: "email-testuser2@test.com",
+		OAuthInfo: map[string]*user.OAuthInfo{
+			user.OAuthProviderGoogle: {
+				Id:    "id-google-testuser2",
+				Email: "email-google-testuser2",
+			},
+		},
+		SignedUp: time.Date(2007, 2, 3, 16, 5, 6, 876, time.UTC),
+	},
+}
+
+func newTestUserStore(t *testing.T) user.Store {
+	s := NewUserStore()
+	for _, u := range users {
+		if err := s.Put(context.TODO(), u); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return s
+}
+
+func TestUserStore_Get(t *testing.T) {
+	ctx := context.TODO()
+	s := newTestUserStore(t)
+
+	tests := []struct {
+		name    string
+		id      string
+		want    *user.User
+		wantErr assert.ErrorAssertionFunc
+	}{
+		{"empty param", "", nil, assert.Error},
+		{"not found", "id-notfound", nil, assert.Error},
+		{"user1", users[0].Id, users[0], assert.NoError},
+		{"user2", users[1].Id, users[1], assert.NoError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.Get(ctx, tt.id)
+			if !tt.wantErr(t, err, fmt.Sprintf("Get(%v)", tt.id)) {
+				return
+			}
+			assert.Equalf(t, tt.want, got, "Get(%v)", tt.id)
+		})
+	}
+
+	_, err := s.Get(ctx, "id-notfound")
+	var notfound *errors.NotFoundError
+	assert.True(t, errors.As(err, &notfound))
+}
+
+func TestUserStore_GetByOAuth(t *testing.T) {
+	ctx := context.TODO()
+	s := newTestUserStore(t)
+
+	tests := []struct {
+		name     string
+		info     *user.OAuthInfo
+		provider string
+		want     *user.User
+		wantErr  assert.ErrorAssertionFunc
+	}{
+		{"empty param", nil, user.OAuthProviderGoogle, nil, assert.Error},
+		{"not found provider", users[1].OAuthInfo[user.OAuthProviderGoogle], "noprovider", nil, assert.Error},
+		{"user2", users[1].OAuthInfo[user.OAuthProviderGoogle], user.OAuthProviderGoogle, users[1], assert.NoError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.GetByOAuth(ctx, tt.info, tt.provider)
+			if !tt.wantErr(t, err, fmt.Sprintf("GetByOAuth(%v, %v)", tt.info, tt.provider)) {
+				return
+			}
+			assert.Equalf(t, tt.want, got, "GetByOAuth(%v, %v)", tt.info, tt.provider)
+		})
+	}
+}
+
+func TestUserStore_Put(t *testing.T) {
+	s := newTestUserStore(t)
+
+	err := s.Put(context.TODO(), users[0])
+	var exists *errors.AlreadyExistsError
+	assert.True(t, errors.As(err, &exists))
+	assert.Error(t, s.Put(context.TODO(), nil))
+}
+
+func TestUserStore_Update(t *testing.T) {
+	ctx := context.TODO()
+	s := newTestUserStore(t)
+
+	assert.Error(t, s.Update(ctx, users[0], "email", "changed@test.com"))
+	assert.NoError(t, s.Update(ctx, users[0], "name", "changed"))
+
+	got, err := s.Get(ctx, users[0].Id)
+	assert.NoError(t, err)
+	assert.Equal(t, "changed", got.Name)
+	assert.Equal(t, "name-testuser1", users[0].Name)
+}
+
+func TestUserStore_Pets(t *testing.T) {
+	ctx := context.TODO()
+	s := newTestUserStore(t)
+
+	assert.NoError(t, s.AddPet(ctx, users[0].Id, "pet1-1"))
+	assert.NoError(t, s.AddPet(ctx, users[0].Id, "pet1-3"))
+	assert.NoError(t, s.DeletePet(ctx, users[0].Id, "pet1-2"))
+	assert.Error(t, s.AddPet(ctx, "id-notfound", "pet1-1"))
+
+	got, err := s.Get(ctx, users[0].Id)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"pet1-1", "pet1-3"}, got.Pets)
+}
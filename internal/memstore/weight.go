@@ -0,0 +1,138 @@
+package memstore
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"ohmnyom/domain/weight"
+	"ohmnyom/internal/errors"
+)
+
+type WeightStore struct {
+	mu      sync.Mutex
+	weights map[string]map[string]*weight.Weight // petId -> id -> weight
+}
+
+func NewWeightStore() weight.Store {
+	return &WeightStore{
+		weights: make(map[string]map[string]*weight.Weight),
+	}
+}
+
+func copyWeight(w *weight.Weight) *weight.Weight {
+	c := *w
+	return &c
+}
+
+func (s *WeightStore) Get(ctx context.Context, petId, id string) (*weight.Weight, error) {
+	if petId == "" || id == "" {
+		return nil, errors.NewInvalidParamError("petId: %v, id: %v", petId, id)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	w, ok := s.weights[petId][id]
+	if !ok {
+		return nil, errors.NewNotFoundError("Weight{PetId: %v, Id: %v}", petId, id)
+	}
+	return copyWeight(w), nil
+}
+
+func (s *WeightStore) GetListOfPet(ctx context.Context, petId string, from, to time.Time) ([]*weight.Weight, error) {
+	if petId == "" {
+		return nil, errors.NewInvalidParamError("petId: %v", petId)
+	}
+	s.mu.Lock()
+	ret := make([]*weight.Weight, 0)
+	for _, w := range s.weights[petId] {
+		if !w.Timestamp.Before(from) && w.Timestamp.Before(to) {
+			ret = append(ret, copyWeight(w))
+		}
+	}
+	s.mu.Unlock()
+
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Timestamp.Before(ret[j].Timestamp)
+	})
+	return ret, nil
+}
+
+func (s *WeightStore) GetLatest(ctx context.Context, petId string) (*weight.Weight, error) {
+	if petId == "" {
+		return nil, errors.NewInvalidParamError("petId: %v", petId)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var latest *weight.Weight
+	for _, w := range s.weights[petId] {
+		if latest == nil || w.Timestamp.After(latest.Timestamp) {
+			latest = w
+		}
+	}
+	if latest == nil {
+		return nil, errors.NewNotFoundError("weight of Pet{Id: %v}", petId)
+	}
+	return copyWeight(latest), nil
+}
+
+func (s *WeightStore) Put(ctx context.Context, w *weight.Weight) error {
+	if w == nil || w.Id == "" || w.PetId == "" {
+		return errors.NewInvalidParamError("weight: %v", w)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	weights, ok := s.weights[w.PetId]
+	if !ok {
+		weights = make(map[string]*weight.Weight)
+		s.weights[w.PetId] = weights
+	}
+	if _, ok := weights[w.Id]; ok {
+		return errors.NewAlreadyExistsError("Weight{PetId: %v, Id: %v}", w.PetId, w.Id)
+	}
+	weights[w.Id] = copyWeight(w)
+	return nil
+}
+
+func (s *WeightStore) Update(ctx context.Context, w *weight.Weight) error {
+	if w == nil || w.Id == "" || w.PetId == "" {
+		return errors.NewInvalidParamError("weight: %v", w)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.weights[w.PetId][w.Id]; !ok {
+		return errors.NewNotFoundError("Weight{PetId: %v, Id: %v}", w.PetId, w.Id)
+	}
+	s.weights[w.PetId][w.Id] = copyWeight(w)
+	return nil
+}
+
+func (s *WeightStore) Delete(ctx context.Context, petId, id string) error {
+	if petId == "" || id == "" {
+		return errors.NewInvalidParamError("petId: %v, id: %v", petId, id)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.weights[petId], id)
+	return nil
+}
+
+func (s *WeightStore) DeleteBatchOfPet(ctx context.Context, petId string, limit int) (int, error) {
+	if petId == "" || limit <= 0 {
+		return 0, errors.NewInvalidParamError("petId: %v, limit: %v", petId, limit)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for id := range s.weights[petId] {
+		if n == limit {
+			break
+		}
+		delete(s.weights[petId], id)
+		n++
+	}
+	if len(s.weights[petId]) == 0 {
+		delete(s.weights, petId)
+	}
+	return n, nil
+}
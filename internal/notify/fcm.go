@@ -0,0 +1,111 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"ohmnyom/internal/errors"
+)
+
+const (
+	// FCMEndpoint is the send endpoint of the FCM HTTP v1 API, formatted with
+	// the project id.
+	FCMEndpoint = "https://fcm.googleapis.com/v1/projects/%s/messages:send"
+	// FCMScope is the oauth scope the client of FCMNotifier needs.
+	FCMScope = "https://www.googleapis.com/auth/firebase.messaging"
+)
+
+// FCMNotifier sends through Firebase Cloud Messaging.
+type FCMNotifier struct {
+	url    string
+	client *http.Client
+}
+
+// NewFCMNotifier sends to endpoint, FCMEndpoint when empty, with client, which
+// has to authorize the requests for FCMScope.
+func NewFCMNotifier(endpoint, projectId string, client *http.Client) Notifier {
+	if endpoint == "" {
+		endpoint = FCMEndpoint
+	}
+	return &FCMNotifier{
+		url:    fmt.Sprintf(endpoint, projectId),
+		client: client,
+	}
+}
+
+type fcmNotification struct {
+	Title string `json:"title,omitempty"`
+	Body  string `json:"body,omitempty"`
+}
+
+type fcmMessage struct {
+	Token        string            `json:"token"`
+	Notification fcmNotification   `json:"notification"`
+	Data         map[string]string `json:"data,omitempty"`
+}
+
+type fcmRequest struct {
+	Message fcmMessage `json:"message"`
+}
+
+type fcmError struct {
+	Error struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+		Status  string `json:"status"`
+		Details []struct {
+			ErrorCode string `json:"errorCode"`
+		} `json:"details"`
+	} `json:"error"`
+}
+
+func (e *fcmError) isUnregistered() bool {
+	if e.Error.Status == "NOT_FOUND" {
+		return true
+	}
+	for _, d := range e.Error.Details {
+		if d.ErrorCode == "UNREGISTERED" {
+			return true
+		}
+	}
+	return false
+}
+
+func (n *FCMNotifier) Send(ctx context.Context, token string, msg *Message) error {
+	body, err := json.Marshal(&fcmRequest{Message: fcmMessage{
+		Token:        token,
+		Notification: fcmNotification{Title: msg.Title, Body: msg.Body},
+		Data:         msg.Data,
+	}})
+	if err != nil {
+		return errors.NewInternalError("%v", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return errors.NewInternalError("%v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return errors.NewInternalError("fcm: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+
+	b, _ := io.ReadAll(resp.Body)
+	fcmErr := &fcmError{}
+	if err := json.Unmarshal(b, fcmErr); err != nil {
+		return errors.NewInternalError("fcm: status %v", resp.StatusCode)
+	}
+	if fcmErr.isUnregistered() {
+		return errors.NewNotFoundError("fcm token is unregistered: %v", fcmErr.Error.Message)
+	}
+	return errors.NewInternalError("fcm: status %v, %v", resp.StatusCode, fcmErr.Error.Message)
+}
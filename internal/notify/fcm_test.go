@@ -0,0 +1,50 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"ohmnyom/internal/errors"
+)
+
+func TestFCMNotifier_Send(t *testing.T) {
+	var got fcmRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/projects/ohmnyom/messages:send", r.URL.Path)
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Error(err)
+		}
+		switch got.Message.Token {
+		case "unregistered":
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error": {"code": 404, "message": "Requested entity was not found.", "status": "NOT_FOUND",
+				"details": [{"errorCode": "UNREGISTERED"}]}}`))
+		case "broken":
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`{"error": {"code": 500, "message": "internal", "status": "INTERNAL"}}`))
+		default:
+			w.Write([]byte(`{"name": "projects/ohmnyom/messages/1"}`))
+		}
+	}))
+	defer server.Close()
+
+	n := NewFCMNotifier(server.URL+"/v1/projects/%s/messages:send", "ohmnyom", server.Client())
+	msg := &Message{Title: "title", Body: "body", Data: map[string]string{"petId": "pet1"}}
+
+	assert.NoError(t, n.Send(context.TODO(), "token", msg))
+	assert.Equal(t, "token", got.Message.Token)
+	assert.Equal(t, "title", got.Message.Notification.Title)
+	assert.Equal(t, "pet1", got.Message.Data["petId"])
+
+	err := n.Send(context.TODO(), "unregistered", msg)
+	var notfound *errors.NotFoundError
+	assert.True(t, errors.As(err, &notfound))
+
+	err = n.Send(context.TODO(), "broken", msg)
+	assert.Error(t, err)
+	assert.False(t, errors.As(err, &notfound))
+}
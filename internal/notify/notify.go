@@ -0,0 +1,69 @@
+// Package notify sends push notifications to devices.
+package notify
+
+import (
+	"context"
+	"log"
+	"sync"
+)
+
+type Message struct {
+	Title string
+	Body  string
+	// Data is delivered to the app along with the notification.
+	Data map[string]string
+}
+
+type Notifier interface {
+	// Send delivers msg to the device with token. It returns a NotFoundError
+	// when the token is no longer registered, and the device should be dropped.
+	Send(ctx context.Context, token string, msg *Message) error
+}
+
+// LogNotifier only logs the messages, for development.
+type LogNotifier struct{}
+
+func NewLogNotifier() Notifier {
+	return LogNotifier{}
+}
+
+func (LogNotifier) Send(ctx context.Context, token string, msg *Message) error {
+	log.Printf("notify %v: %v - %v %v", token, msg.Title, msg.Body, msg.Data)
+	return nil
+}
+
+type Sent struct {
+	Token   string
+	Message *Message
+}
+
+// FakeNotifier remembers the messages for tests. Send fails with Errs[token]
+// when it is set.
+type FakeNotifier struct {
+	mu   sync.Mutex
+	sent []Sent
+	Errs map[string]error
+}
+
+func NewFakeNotifier() *FakeNotifier {
+	return &FakeNotifier{
+		Errs: make(map[string]error),
+	}
+}
+
+func (n *FakeNotifier) Send(ctx context.Context, token string, msg *Message) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if err, ok := n.Errs[token]; ok {
+		return err
+	}
+	n.sent = append(n.sent, Sent{Token: token, Message: msg})
+	return nil
+}
+
+// Sent returns the messages sent so far.
+func (n *FakeNotifier) Sent() []Sent {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return append([]Sent(nil), n.sent...)
+}
@@ -0,0 +1,187 @@
+package oauth
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"ohmnyom/domain/user"
+	"ohmnyom/internal/errors"
+)
+
+const (
+	GoogleCertsUrl = "https://www.googleapis.com/oauth2/v3/certs"
+
+	jwksRefreshInterval = time.Hour
+	// jwksRefetchInterval is the least time between fetches of the key set, so
+	// tokens with made-up key ids cannot make every sign in wait on Google.
+	jwksRefetchInterval = time.Minute
+)
+
+var googleIssuers = map[string]struct{}{
+	"accounts.google.com":         {},
+	"https://accounts.google.com": {},
+}
+
+// GoogleVerifier validates Google ID tokens against the keys published at certsUrl.
+type GoogleVerifier struct {
+	certsUrl  string
+	audiences map[string]struct{}
+	client    *http.Client
+
+	mu      sync.Mutex
+	keys    map[string]*rsa.PublicKey
+	fetched time.Time
+	// attempted is when the key set was last fetched, successfully or not.
+	attempted time.Time
+	// fetching is closed when the fetch in flight ends, nil when none is.
+	fetching chan struct{}
+	fetchErr error
+}
+
+// NewGoogleVerifier accepts ID tokens issued to any of clientIds.
+func NewGoogleVerifier(certsUrl string, clientIds []string, client *http.Client) *GoogleVerifier {
+	audiences := make(map[string]struct{})
+	for _, id := range clientIds {
+		audiences[id] = struct{}{}
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &GoogleVerifier{
+		certsUrl:  certsUrl,
+		audiences: audiences,
+		client:    client,
+	}
+}
+
+type jwks struct {
+	Keys []struct {
+		Kid string `json:"kid"`
+		Kty string `json:"kty"`
+		N   string `json:"n"`
+		E   string `json:"e"`
+	} `json:"keys"`
+}
+
+func (v *GoogleVerifier) fetchKeys(ctx context.Context) (map[string]*rsa.PublicKey, error) {
+	set := &jwks{}
+	if err := getJson(ctx, v.client, v.certsUrl, "", set); err != nil {
+		return nil, err
+	}
+	keys := make(map[string]*rsa.PublicKey)
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, errors.NewInvalidFormatError("jwk n: %v", err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, errors.NewInvalidFormatError("jwk e: %v", err)
+		}
+		keys[k.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+	return keys, nil
+}
+
+// key returns the key with kid, refetching the key set when it is stale or
+// does not know kid, since Google rotates keys. The key set is fetched at most
+// once a jwksRefetchInterval, outside the lock, and callers arriving during a
+// fetch wait for it rather than starting their own.
+func (v *GoogleVerifier) key(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	v.mu.Lock()
+	key, ok := v.keys[kid]
+	if ok && time.Since(v.fetched) < jwksRefreshInterval {
+		v.mu.Unlock()
+		return key, nil
+	}
+	done := v.fetching
+	if done == nil && time.Since(v.attempted) < jwksRefetchInterval {
+		v.mu.Unlock()
+		if ok {
+			// stale, but fetched again too recently
+			return key, nil
+		}
+		return nil, errors.NewAuthenticationError("unknown google key %v", kid)
+	}
+	if done == nil {
+		done = make(chan struct{})
+		v.fetching = done
+		v.attempted = time.Now()
+		v.mu.Unlock()
+
+		keys, err := v.fetchKeys(ctx)
+		v.mu.Lock()
+		if err == nil {
+			v.keys = keys
+			v.fetched = time.Now()
+		}
+		v.fetchErr = err
+		v.fetching = nil
+		close(done)
+	} else {
+		v.mu.Unlock()
+		select {
+		case <-done:
+		case <-ctx.Done():
+			return nil, errors.NewAuthenticationError("google key %v: %v", kid, ctx.Err())
+		}
+		v.mu.Lock()
+	}
+	defer v.mu.Unlock()
+	if key, ok := v.keys[kid]; ok {
+		return key, nil
+	}
+	if v.fetchErr != nil {
+		return nil, v.fetchErr
+	}
+	return nil, errors.NewAuthenticationError("unknown google key %v", kid)
+}
+
+type googleClaims struct {
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	jwt.StandardClaims
+}
+
+func (v *GoogleVerifier) Verify(ctx context.Context, token string) (*user.OAuthInfo, error) {
+	if token == "" {
+		return nil, errors.NewAuthenticationError("google id token is not provided")
+	}
+	parsed, err := jwt.ParseWithClaims(token, &googleClaims{}, func(t *jwt.Token) (interface{}, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodRSA); !ok {
+			return nil, errors.NewAuthenticationError("google id token signing method %v", t.Header["alg"])
+		}
+		kid, _ := t.Header["kid"].(string)
+		return v.key(ctx, kid)
+	})
+	if err != nil {
+		return nil, errors.NewAuthenticationError("google id token: %v", err)
+	}
+
+	c := parsed.Claims.(*googleClaims)
+	if _, ok := googleIssuers[c.Issuer]; !ok {
+		return nil, errors.NewAuthenticationError("google id token issuer %v", c.Issuer)
+	}
+	if _, ok := v.audiences[c.Audience]; !ok {
+		return nil, errors.NewAuthenticationError("google id token audience %v", c.Audience)
+	}
+	if c.Subject == "" || !c.EmailVerified {
+		return nil, errors.NewAuthenticationError("google id token without verified account")
+	}
+	return &user.OAuthInfo{
+		Id:    c.Subject,
+		Email: c.Email,
+	}, nil
+}
@@ -0,0 +1,79 @@
+package oauth
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"ohmnyom/domain/user"
+	"ohmnyom/internal/errors"
+)
+
+const (
+	KakaoTokenInfoUrl = "https://kapi.kakao.com/v1/user/access_token_info"
+	KakaoUserUrl      = "https://kapi.kakao.com/v2/user/me"
+)
+
+// KakaoVerifier validates Kakao access tokens with the token info endpoint and
+// reads the account email from the user endpoint.
+type KakaoVerifier struct {
+	tokenInfoUrl string
+	userUrl      string
+	appId        int64
+	client       *http.Client
+}
+
+// NewKakaoVerifier accepts access tokens issued to appId.
+func NewKakaoVerifier(tokenInfoUrl, userUrl string, appId int64, client *http.Client) *KakaoVerifier {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &KakaoVerifier{
+		tokenInfoUrl: tokenInfoUrl,
+		userUrl:      userUrl,
+		appId:        appId,
+		client:       client,
+	}
+}
+
+type kakaoTokenInfo struct {
+	Id    int64 `json:"id"`
+	AppId int64 `json:"app_id"`
+}
+
+type kakaoUser struct {
+	Id      int64 `json:"id"`
+	Account struct {
+		Email           string `json:"email"`
+		IsEmailVerified bool   `json:"is_email_verified"`
+	} `json:"kakao_account"`
+}
+
+func (v *KakaoVerifier) Verify(ctx context.Context, token string) (*user.OAuthInfo, error) {
+	if token == "" {
+		return nil, errors.NewAuthenticationError("kakao access token is not provided")
+	}
+	info := &kakaoTokenInfo{}
+	if err := getJson(ctx, v.client, v.tokenInfoUrl, token, info); err != nil {
+		return nil, err
+	}
+	if info.AppId != v.appId {
+		return nil, errors.NewAuthenticationError("kakao access token app %v", info.AppId)
+	}
+
+	u := &kakaoUser{}
+	if err := getJson(ctx, v.client, v.userUrl, token, u); err != nil {
+		return nil, err
+	}
+	if u.Id != info.Id {
+		return nil, errors.NewAuthenticationError("kakao user %v, token of %v", u.Id, info.Id)
+	}
+	email := ""
+	if u.Account.IsEmailVerified {
+		email = u.Account.Email
+	}
+	return &user.OAuthInfo{
+		Id:    strconv.FormatInt(u.Id, 10),
+		Email: email,
+	}, nil
+}
@@ -0,0 +1,51 @@
+// Package oauth verifies the credentials an OAuth provider issued to a client,
+// so the server learns the account id from the provider instead of the client.
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"ohmnyom/domain/user"
+	"ohmnyom/internal/errors"
+)
+
+type Verifier interface {
+	// Verify checks token with the provider and returns the account it belongs to.
+	Verify(ctx context.Context, token string) (*user.OAuthInfo, error)
+}
+
+// getJson fetches url into v. A non 2xx response is reported as an
+// AuthenticationError when it is a 4xx, since providers answer invalid
+// tokens that way.
+func getJson(ctx context.Context, client *http.Client, url, bearer string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return errors.NewInternalError("%v", err)
+	}
+	if bearer != "" {
+		req.Header.Set("Authorization", "Bearer "+bearer)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return errors.NewInternalError("%v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return errors.NewInternalError("%v", err)
+	}
+	if resp.StatusCode >= 400 && resp.StatusCode < 500 {
+		return errors.NewAuthenticationError("%v: %v", url, resp.Status)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return errors.NewInternalError("%v: %v", url, resp.Status)
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return errors.NewInvalidFormatError("%v", err)
+	}
+	return nil
+}
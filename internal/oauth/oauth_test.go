@@ -0,0 +1,189 @@
+package oauth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/stretchr/testify/assert"
+	"ohmnyom/domain/user"
+)
+
+const testClientId = "test-client.apps.googleusercontent.com"
+
+func newGoogleFake(t *testing.T) (*httptest.Server, *rsa.PrivateKey) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kid": "test-kid",
+				"kty": "RSA",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	}))
+	t.Cleanup(server.Close)
+	return server, key
+}
+
+func signGoogle(t *testing.T, key *rsa.PrivateKey, kid string, c googleClaims) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, c)
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func TestGoogleVerifier_Verify(t *testing.T) {
+	server, key := newGoogleFake(t)
+	v := NewGoogleVerifier(server.URL, []string{testClientId}, server.Client())
+
+	valid := googleClaims{
+		Email:         "testuser@gmail.com",
+		EmailVerified: true,
+		StandardClaims: jwt.StandardClaims{
+			Audience:  testClientId,
+			ExpiresAt: time.Now().Add(time.Hour).Unix(),
+			Issuer:    "https://accounts.google.com",
+			Subject:   "google-sub",
+		},
+	}
+	otherAudience := valid
+	otherAudience.Audience = "other-client"
+	otherIssuer := valid
+	otherIssuer.Issuer = "https://evil.example.com"
+	expired := valid
+	expired.ExpiresAt = time.Now().Add(-time.Hour).Unix()
+	otherKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+
+	tests := []struct {
+		name    string
+		token   string
+		want    *user.OAuthInfo
+		wantErr assert.ErrorAssertionFunc
+	}{
+		{"empty", "", nil, assert.Error},
+		{"valid", signGoogle(t, key, "test-kid", valid),
+			&user.OAuthInfo{Id: "google-sub", Email: "testuser@gmail.com"}, assert.NoError},
+		{"other audience", signGoogle(t, key, "test-kid", otherAudience), nil, assert.Error},
+		{"other issuer", signGoogle(t, key, "test-kid", otherIssuer), nil, assert.Error},
+		{"expired", signGoogle(t, key, "test-kid", expired), nil, assert.Error},
+		{"unknown kid", signGoogle(t, key, "other-kid", valid), nil, assert.Error},
+		{"wrong key", signGoogle(t, otherKey, "test-kid", valid), nil, assert.Error},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := v.Verify(context.TODO(), tt.token)
+			if !tt.wantErr(t, err) {
+				return
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestGoogleVerifier_Refetch(t *testing.T) {
+	server, key := newGoogleFake(t)
+	var fetches int32
+	counting := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+		time.Sleep(10 * time.Millisecond)
+		resp, err := server.Client().Get(server.URL)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		defer resp.Body.Close()
+		_, _ = io.Copy(w, resp.Body)
+	}))
+	t.Cleanup(counting.Close)
+	v := NewGoogleVerifier(counting.URL, []string{testClientId}, counting.Client())
+	claims := googleClaims{
+		EmailVerified: true,
+		StandardClaims: jwt.StandardClaims{
+			Audience:  testClientId,
+			ExpiresAt: time.Now().Add(time.Hour).Unix(),
+			Issuer:    "accounts.google.com",
+			Subject:   "google-sub",
+		},
+	}
+
+	// callers arriving together share one fetch
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := v.Verify(context.TODO(), signGoogle(t, key, "test-kid", claims))
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&fetches))
+
+	// unknown key ids are rejected without fetching again so soon
+	for i := 0; i < 5; i++ {
+		_, err := v.Verify(context.TODO(), signGoogle(t, key, "made-up-kid", claims))
+		assert.Error(t, err)
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&fetches))
+}
+
+func TestKakaoVerifier_Verify(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/user/access_token_info", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Header.Get("Authorization") {
+		case "Bearer valid-token":
+			_, _ = w.Write([]byte(`{"id": 1234, "expires_in": 3600, "app_id": 42}`))
+		case "Bearer other-app-token":
+			_, _ = w.Write([]byte(`{"id": 1234, "expires_in": 3600, "app_id": 7}`))
+		default:
+			http.Error(w, `{"code": -401}`, http.StatusUnauthorized)
+		}
+	})
+	mux.HandleFunc("/v2/user/me", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"id": 1234, "kakao_account": {"email": "testuser@kakao.com", "is_email_verified": true}}`))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	v := NewKakaoVerifier(server.URL+"/v1/user/access_token_info", server.URL+"/v2/user/me", 42, server.Client())
+
+	tests := []struct {
+		name    string
+		token   string
+		want    *user.OAuthInfo
+		wantErr assert.ErrorAssertionFunc
+	}{
+		{"empty", "", nil, assert.Error},
+		{"valid", "valid-token", &user.OAuthInfo{Id: "1234", Email: "testuser@kakao.com"}, assert.NoError},
+		{"other app", "other-app-token", nil, assert.Error},
+		{"invalid", "invalid-token", nil, assert.Error},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := v.Verify(context.TODO(), tt.token)
+			if !tt.wantErr(t, err) {
+				return
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
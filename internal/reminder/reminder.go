@@ -0,0 +1,200 @@
+// Package reminder notifies the feeders of a pet when a scheduled slot passes
+// without a feed. Every server instance may run a Scheduler; the reminder
+// store lets only one of them notify about a slot.
+package reminder
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"ohmnyom/domain/device"
+	"ohmnyom/domain/feed"
+	"ohmnyom/domain/household"
+	"ohmnyom/domain/pet"
+	"ohmnyom/domain/reminder"
+	"ohmnyom/domain/schedule"
+	"ohmnyom/internal/errors"
+	"ohmnyom/internal/notify"
+)
+
+const (
+	DefaultInterval = time.Minute
+	// DefaultLookback is how long after its tolerance passed a missed slot is
+	// still reminded of, to cover ticks lost to restarts.
+	DefaultLookback = time.Hour
+)
+
+type Scheduler struct {
+	scheduleStore  schedule.Store
+	feedStore      feed.Store
+	petStore       pet.Store
+	householdStore household.Store
+	deviceStore    device.Store
+	reminderStore  reminder.Store
+	notifier       notify.Notifier
+	interval       time.Duration
+	lookback       time.Duration
+}
+
+func New(scheduleStore schedule.Store, feedStore feed.Store, petStore pet.Store, householdStore household.Store,
+	deviceStore device.Store, reminderStore reminder.Store, notifier notify.Notifier,
+	interval, lookback time.Duration) *Scheduler {
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	if lookback <= 0 {
+		lookback = DefaultLookback
+	}
+	return &Scheduler{
+		scheduleStore:  scheduleStore,
+		feedStore:      feedStore,
+		petStore:       petStore,
+		householdStore: householdStore,
+		deviceStore:    deviceStore,
+		reminderStore:  reminderStore,
+		notifier:       notifier,
+		interval:       interval,
+		lookback:       lookback,
+	}
+}
+
+// Run checks the schedules every interval until ctx is done.
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if _, err := s.Tick(ctx, now); err != nil {
+				log.Printf("reminder: %v", err)
+			}
+		}
+	}
+}
+
+// Tick sends reminders for the slots whose tolerance passed in the lookback
+// before now without a feed, and returns how many slots it reminded of.
+func (s *Scheduler) Tick(ctx context.Context, now time.Time) (int, error) {
+	schedules, err := s.scheduleStore.GetAll(ctx)
+	if err != nil {
+		return 0, err
+	}
+	reminded := 0
+	for _, sc := range schedules {
+		n, err := s.check(ctx, sc, now)
+		if err != nil {
+			// one broken schedule must not stop the others
+			log.Printf("reminder: schedule %v of pet %v: %v", sc.Id, sc.PetId, err)
+		}
+		reminded += n
+	}
+	return reminded, nil
+}
+
+func (s *Scheduler) check(ctx context.Context, sc *schedule.Schedule, now time.Time) (int, error) {
+	tolerance := sc.Tolerance()
+	to := now.Add(-tolerance)
+	from := to.Add(-s.lookback)
+	feeds, err := s.feedStore.GetFeedsOfPetInRange(ctx, sc.PetId, from.Add(-tolerance), now)
+	if err != nil {
+		return 0, err
+	}
+	statuses, err := sc.Check(feeds, from, to, now)
+	if err != nil {
+		return 0, err
+	}
+
+	reminded := 0
+	for _, st := range statuses {
+		if st.Status != schedule.StatusMissed {
+			continue
+		}
+		claimed, err := s.reminderStore.Claim(ctx, reminder.New(sc.PetId, sc.Id, st.At))
+		if err != nil {
+			return reminded, err
+		}
+		if !claimed {
+			continue
+		}
+		if err := s.notify(ctx, sc, st); err != nil {
+			return reminded, err
+		}
+		reminded++
+	}
+	return reminded, nil
+}
+
+// notify sends the reminder of st to every device of everyone who feeds the
+// pet.
+func (s *Scheduler) notify(ctx context.Context, sc *schedule.Schedule, st *schedule.SlotStatus) error {
+	p, err := s.petStore.Get(ctx, sc.PetId)
+	if err != nil {
+		return err
+	}
+	msg := &notify.Message{
+		Title: "Missed feeding",
+		Body:  fmt.Sprintf("%v has not been fed for %02d:%02d.", p.Name, st.Slot.Hour, st.Slot.Minute),
+		Data: map[string]string{
+			"petId":      sc.PetId,
+			"scheduleId": sc.Id,
+			"slotAt":     fmt.Sprint(st.At.Unix()),
+		},
+	}
+	uids, err := s.feedersOf(ctx, p)
+	if err != nil {
+		return err
+	}
+	for _, uid := range uids {
+		devices, err := s.deviceStore.GetListOfUser(ctx, uid)
+		if err != nil {
+			return err
+		}
+		for _, d := range devices {
+			err := s.notifier.Send(ctx, d.Token, msg)
+			var notfound *errors.NotFoundError
+			if errors.As(err, &notfound) {
+				if err := s.deviceStore.Delete(ctx, d.Token); err != nil {
+					log.Printf("reminder: cannot drop device of %v: %v", uid, err)
+				}
+				continue
+			}
+			if err != nil {
+				// the slot is claimed already, so keep notifying the others
+				log.Printf("reminder: cannot notify a device of %v: %v", uid, err)
+			}
+		}
+	}
+	return nil
+}
+
+// feedersOf returns the caregivers and owners of the pet, and the members of
+// its household, who take care of it as caregivers.
+func (s *Scheduler) feedersOf(ctx context.Context, p *pet.Pet) ([]string, error) {
+	ret := make([]string, 0, len(p.Feeders))
+	for _, uid := range p.Feeders {
+		if p.RoleOf(uid) >= pet.RoleCaregiver {
+			ret = append(ret, uid)
+		}
+	}
+	if p.HouseholdId == "" {
+		return ret, nil
+	}
+	h, err := s.householdStore.Get(ctx, p.HouseholdId)
+	var notfound *errors.NotFoundError
+	if errors.As(err, &notfound) {
+		return ret, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, uid := range h.Members {
+		if p.RoleOf(uid) < pet.RoleCaregiver {
+			ret = append(ret, uid)
+		}
+	}
+	return ret, nil
+}
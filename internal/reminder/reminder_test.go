@@ -0,0 +1,81 @@
+package reminder
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"ohmnyom/domain/device"
+	"ohmnyom/domain/feed"
+	"ohmnyom/domain/household"
+	"ohmnyom/domain/pet"
+	"ohmnyom/domain/schedule"
+	"ohmnyom/internal/errors"
+	"ohmnyom/internal/memstore"
+	"ohmnyom/internal/notify"
+)
+
+func TestScheduler_Tick(t *testing.T) {
+	ctx := context.TODO()
+	schedules := memstore.NewScheduleStore()
+	feeds := memstore.NewFeedStore()
+	pets := memstore.NewPetStore()
+	devices := memstore.NewDeviceStore()
+	households := memstore.NewHouseholdStore()
+	reminders := memstore.NewReminderStore()
+	notifier := notify.NewFakeNotifier()
+	notifier.Errs["stale"] = errors.NewNotFoundError("unregistered")
+
+	assert.NoError(t, pets.Put(ctx, &pet.Pet{Id: "pet1", Name: "nyom", Feeders: []string{"owner", "feeder", "viewer"},
+		Roles: map[string]string{"viewer": pet.RoleViewer.String()}, HouseholdId: "home"}))
+	// members of the household take care of its pets
+	assert.NoError(t, households.Put(ctx, &household.Household{Id: "home", Members: []string{"owner", "housemate"}}))
+	for token, uid := range map[string]string{"owner-phone": "owner", "feeder-phone": "feeder", "stale": "feeder",
+		"viewer-phone": "viewer", "housemate-phone": "housemate"} {
+		d, err := device.New(token, uid, device.PlatformAndroid)
+		assert.NoError(t, err)
+		assert.NoError(t, devices.Put(ctx, d))
+	}
+	sc := &schedule.Schedule{Id: "schedule1", PetId: "pet1", TimeZone: "UTC",
+		Slots: []schedule.Slot{{Hour: 8}, {Hour: 12}}, ToleranceMinutes: 30}
+	assert.NoError(t, sc.Validate())
+	assert.NoError(t, schedules.Put(ctx, sc))
+	day := time.Date(2022, 3, 1, 0, 0, 0, 0, time.UTC)
+	// 12:00 was fed, 08:00 was not
+	assert.NoError(t, feeds.Put(ctx, &feed.Feed{Id: "feed1", PetId: "pet1", Timestamp: day.Add(12 * time.Hour)}))
+
+	// two instances sharing the stores
+	a := New(schedules, feeds, pets, households, devices, reminders, notifier, time.Minute, time.Hour)
+	b := New(schedules, feeds, pets, households, devices, reminders, notifier, time.Minute, time.Hour)
+
+	// 08:20 is within the tolerance
+	n, err := a.Tick(ctx, day.Add(8*time.Hour+20*time.Minute))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+
+	now := day.Add(8*time.Hour + 40*time.Minute)
+	n, err = a.Tick(ctx, now)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+	n, err = b.Tick(ctx, now)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+	n, err = b.Tick(ctx, now.Add(time.Minute))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+
+	sent := notifier.Sent()
+	tokens := make([]string, len(sent))
+	for i, s := range sent {
+		tokens[i] = s.Token
+	}
+	assert.ElementsMatch(t, []string{"owner-phone", "feeder-phone", "housemate-phone"}, tokens)
+	assert.Equal(t, "pet1", sent[0].Message.Data["petId"])
+	_, err = devices.Get(ctx, "stale")
+	assert.Error(t, err, "unregistered token is dropped")
+
+	n, err = a.Tick(ctx, day.Add(13*time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+}
@@ -0,0 +1,147 @@
+// Package revocation decides whether a verified token has been signed out.
+// Lookups are cached, so a sign out on another server instance takes effect
+// here within the cache TTL.
+package revocation
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"ohmnyom/domain/token"
+	"ohmnyom/internal/errors"
+	"ohmnyom/internal/jwt"
+)
+
+const DefaultCacheTTL = time.Second * 30
+
+type cached struct {
+	revoked    bool
+	generation int64
+	expires    time.Time
+}
+
+type Checker struct {
+	store token.Store
+	ttl   time.Duration
+
+	mu          sync.Mutex
+	tokens      map[string]cached
+	generations map[string]cached
+	// nextEvict is when expired entries are dropped next, once a TTL.
+	nextEvict time.Time
+}
+
+func NewChecker(store token.Store, ttl time.Duration) *Checker {
+	return &Checker{
+		store:       store,
+		ttl:         ttl,
+		tokens:      make(map[string]cached),
+		generations: make(map[string]cached),
+	}
+}
+
+func (c *Checker) isRevoked(ctx context.Context, id string, now time.Time) (bool, error) {
+	c.mu.Lock()
+	entry, ok := c.tokens[id]
+	c.mu.Unlock()
+	if ok && now.Before(entry.expires) {
+		return entry.revoked, nil
+	}
+
+	revoked, err := c.store.IsTokenRevoked(ctx, id)
+	if err != nil {
+		return false, err
+	}
+	c.mu.Lock()
+	c.tokens[id] = cached{revoked: revoked, expires: now.Add(c.ttl)}
+	c.evict(now)
+	c.mu.Unlock()
+	return revoked, nil
+}
+
+// Generation returns the current token generation of uid.
+func (c *Checker) Generation(ctx context.Context, uid string) (int64, error) {
+	now := time.Now()
+	c.mu.Lock()
+	entry, ok := c.generations[uid]
+	c.mu.Unlock()
+	if ok && now.Before(entry.expires) {
+		return entry.generation, nil
+	}
+
+	generation, err := c.store.GetGeneration(ctx, uid)
+	if err != nil {
+		return 0, err
+	}
+	c.mu.Lock()
+	c.generations[uid] = cached{generation: generation, expires: now.Add(c.ttl)}
+	c.evict(now)
+	c.mu.Unlock()
+	return generation, nil
+}
+
+// evict drops expired entries at most once a TTL, so a cache miss does not
+// scan the cache every time; the caller holds c.mu.
+func (c *Checker) evict(now time.Time) {
+	if now.Before(c.nextEvict) {
+		return
+	}
+	c.nextEvict = now.Add(c.ttl)
+	for id, entry := range c.tokens {
+		if !now.Before(entry.expires) {
+			delete(c.tokens, id)
+		}
+	}
+	for uid, entry := range c.generations {
+		if !now.Before(entry.expires) {
+			delete(c.generations, uid)
+		}
+	}
+}
+
+// Check returns an AuthenticationError if the token was signed out, or was
+// issued before its user signed out everywhere.
+func (c *Checker) Check(ctx context.Context, claims *jwt.Claims) error {
+	generation, err := c.Generation(ctx, claims.Uid)
+	if err != nil {
+		return err
+	}
+	if claims.Generation < generation {
+		return errors.NewAuthenticationError("token generation %v is signed out", claims.Generation)
+	}
+	if claims.Id == "" {
+		return nil
+	}
+	revoked, err := c.isRevoked(ctx, claims.Id, time.Now())
+	if err != nil {
+		return err
+	}
+	if revoked {
+		return errors.NewAuthenticationError("token %v is signed out", claims.Id)
+	}
+	return nil
+}
+
+// Revoke signs out the token.
+func (c *Checker) Revoke(ctx context.Context, claims *jwt.Claims) error {
+	if err := c.store.RevokeToken(ctx, claims.Id, claims.Uid, claims.ExpiresAt); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	c.tokens[claims.Id] = cached{revoked: true, expires: claims.ExpiresAt}
+	c.mu.Unlock()
+	return nil
+}
+
+// RevokeAll signs out every token of uid issued so far.
+func (c *Checker) RevokeAll(ctx context.Context, uid string) error {
+	generation, err := c.store.BumpGeneration(ctx, uid)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	c.generations[uid] = cached{generation: generation, expires: time.Now().Add(c.ttl)}
+	c.mu.Unlock()
+	return nil
+}
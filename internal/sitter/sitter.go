@@ -0,0 +1,100 @@
+// Package sitter ends the membership of pet sitters whose grant expired. Pets
+// stop authorizing a sitter as soon as the grant ends, the sweep only tidies
+// up Pet.Feeders and User.Pets after them.
+package sitter
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"ohmnyom/domain/pet"
+	"ohmnyom/domain/uow"
+	"ohmnyom/internal/errors"
+)
+
+const DefaultInterval = 10 * time.Minute
+
+type Sweeper struct {
+	petStore   pet.Store
+	unitOfWork uow.UnitOfWork
+	interval   time.Duration
+}
+
+func New(petStore pet.Store, unitOfWork uow.UnitOfWork, interval time.Duration) *Sweeper {
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	return &Sweeper{
+		petStore:   petStore,
+		unitOfWork: unitOfWork,
+		interval:   interval,
+	}
+}
+
+// Run sweeps every interval until ctx is done.
+func (s *Sweeper) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if _, err := s.Sweep(ctx, now); err != nil {
+				log.Printf("sitter: %v", err)
+			}
+		}
+	}
+}
+
+// Sweep removes the sitters whose grant ended at now from their pets, and
+// returns how many it removed.
+func (s *Sweeper) Sweep(ctx context.Context, now time.Time) (int, error) {
+	pets, err := s.petStore.GetListOfExpiredGrants(ctx, now)
+	if err != nil {
+		return 0, err
+	}
+	removed := 0
+	for _, p := range pets {
+		n, err := s.sweepPet(ctx, p.Id, now)
+		if err != nil {
+			// one broken pet must not stop the others
+			log.Printf("sitter: pet %v: %v", p.Id, err)
+		}
+		removed += n
+	}
+	return removed, nil
+}
+
+func (s *Sweeper) sweepPet(ctx context.Context, petId string, now time.Time) (int, error) {
+	removed := 0
+	err := s.unitOfWork.Run(ctx, func(ctx context.Context, tx uow.Tx) error {
+		removed = 0
+		p, err := tx.GetPet(petId)
+		var notfound *errors.NotFoundError
+		if errors.As(err, &notfound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		kept := make(map[string]*pet.Grant, len(p.Grants))
+		expired := make([]string, 0)
+		for uid, g := range p.Grants {
+			if g.Expired(now) {
+				expired = append(expired, uid)
+			} else {
+				kept[uid] = g
+			}
+		}
+		for _, uid := range expired {
+			if err := uow.Leave(tx, uid, petId); err != nil {
+				return err
+			}
+		}
+		removed = len(expired)
+		return tx.SetGrants(petId, kept)
+	})
+	return removed, err
+}
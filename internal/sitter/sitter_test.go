@@ -0,0 +1,71 @@
+package sitter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"ohmnyom/domain/pet"
+	"ohmnyom/domain/user"
+	"ohmnyom/internal/memstore"
+)
+
+func TestSweeper_Sweep(t *testing.T) {
+	ctx := context.TODO()
+	users := memstore.NewUserStore()
+	pets := memstore.NewPetStore()
+	now := time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	grants := map[string]*pet.Grant{
+		"sitter1": {Start: now.Add(-48 * time.Hour), End: now.Add(-time.Hour), GrantedBy: "owner"},
+		"sitter2": {Start: now.Add(-time.Hour), End: now.Add(time.Hour), GrantedBy: "owner"},
+	}
+	assert.NoError(t, pets.Put(ctx, &pet.Pet{
+		Id:           "pet1",
+		Feeders:      []string{"owner", "sitter1", "sitter2"},
+		Roles:        map[string]string{"owner": "owner", "sitter1": "caregiver", "sitter2": "caregiver"},
+		Grants:       grants,
+		GrantsExpire: pet.GrantsExpire(grants),
+	}))
+	assert.NoError(t, pets.Put(ctx, &pet.Pet{Id: "pet2", Feeders: []string{"owner"}}))
+	for _, uid := range []string{"owner", "sitter1", "sitter2"} {
+		assert.NoError(t, users.Put(ctx, &user.User{Id: uid, Pets: []string{"pet1"}}))
+	}
+
+	s := New(pets, memstore.NewUnitOfWork(users, pets, memstore.NewInviteStore(), memstore.NewDeletionStore(),
+		memstore.NewAuditStore(), memstore.NewHouseholdStore()), 0)
+	removed, err := s.Sweep(ctx, now)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, removed)
+
+	p, err := pets.Get(ctx, "pet1")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"owner", "sitter2"}, p.Feeders)
+	assert.Equal(t, []string{"sitter2"}, keys(p.Grants))
+	assert.Equal(t, now.Add(time.Hour), p.GrantsExpire)
+	u, err := users.Get(ctx, "sitter1")
+	assert.NoError(t, err)
+	assert.Empty(t, u.Pets)
+
+	// nothing more is due until the other grant ends
+	removed, err = s.Sweep(ctx, now)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, removed)
+	removed, err = s.Sweep(ctx, now.Add(time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, removed)
+	p, err = pets.Get(ctx, "pet1")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"owner"}, p.Feeders)
+	assert.Empty(t, p.Grants)
+	assert.True(t, p.GrantsExpire.IsZero())
+}
+
+func keys(grants map[string]*pet.Grant) []string {
+	ret := make([]string, 0, len(grants))
+	for uid := range grants {
+		ret = append(ret, uid)
+	}
+	return ret
+}
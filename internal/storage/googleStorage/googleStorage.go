@@ -2,8 +2,6 @@ package googleStorage
 
 import (
 	"context"
-	"io"
-	"log"
 	"os"
 
 	gcs "cloud.google.com/go/storage"
@@ -14,23 +12,27 @@ import (
 )
 
 type Storage struct {
-	client *gcs.Client
+	client      *gcs.Client
+	ownerEntity gcs.ACLEntity
 }
 
-func New(ctx context.Context, credentialJsonPath string) storage.Storage {
-	credFile, err := os.Open(credentialJsonPath)
-	if err != nil {
-		log.Panic(err)
-	}
-	cred, err := io.ReadAll(credFile)
-	if err != nil {
-		log.Panic(err)
+// New connects with the service account key in credentialJsonPath, or with
+// application default credentials if it is empty. Uploaded objects are public,
+// and owned by ownerEntity if it is not empty.
+func New(ctx context.Context, credentialJsonPath, ownerEntity string) (storage.Storage, error) {
+	var opts []option.ClientOption
+	if credentialJsonPath != "" {
+		cred, err := os.ReadFile(credentialJsonPath)
+		if err != nil {
+			return nil, errors.New("%v", err)
+		}
+		opts = append(opts, option.WithCredentialsJSON(cred))
 	}
-	client, err := gcs.NewClient(ctx, option.WithCredentialsJSON(cred))
+	client, err := gcs.NewClient(ctx, opts...)
 	if err != nil {
-		log.Panic(err)
+		return nil, errors.New("%v", err)
 	}
-	return &Storage{client: client}
+	return &Storage{client: client, ownerEntity: gcs.ACLEntity(ownerEntity)}, nil
 }
 
 func (s *Storage) Upload(ctx context.Context, object *storage.Object) (string, error) {
@@ -39,7 +41,9 @@ func (s *Storage) Upload(ctx context.Context, object *storage.Object) (string, e
 	wc.ContentType = object.ContentType
 	wc.ACL = []gcs.ACLRule{
 		{Entity: gcs.AllUsers, Role: gcs.RoleReader},
-		{Entity: "user-aiceru@gmail.com", Role: gcs.RoleOwner},
+	}
+	if s.ownerEntity != "" {
+		wc.ACL = append(wc.ACL, gcs.ACLRule{Entity: s.ownerEntity, Role: gcs.RoleOwner})
 	}
 	if _, err := wc.Write(object.Bytes); err != nil {
 		return "", errors.NewInternalError("%v", err)
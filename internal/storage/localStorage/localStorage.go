@@ -0,0 +1,121 @@
+package localStorage
+
+import (
+	"context"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"ohmnyom/internal/errors"
+	"ohmnyom/internal/storage"
+)
+
+// Storage keeps objects as files under dir, laid out as dir/root/path.
+// Returned links are baseUrl/root/path, which Handler can serve.
+type Storage struct {
+	dir     string
+	baseUrl string
+}
+
+func New(dir, baseUrl string) (*Storage, error) {
+	if dir == "" {
+		return nil, errors.NewInvalidParamError("dir: %v", dir)
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, errors.New("%v", err)
+	}
+	if err := os.MkdirAll(abs, 0o755); err != nil {
+		return nil, errors.New("%v", err)
+	}
+	return &Storage{
+		dir:     abs,
+		baseUrl: strings.TrimSuffix(baseUrl, "/"),
+	}, nil
+}
+
+// objectPath maps root and path to a file under s.dir, rejecting anything
+// that would escape it.
+func (s *Storage) objectPath(root, path string) (string, error) {
+	name := filepath.Join(s.dir, filepath.FromSlash(root), filepath.FromSlash(path))
+	rel, err := filepath.Rel(s.dir, name)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return "", errors.NewInvalidParamError("root: %v, path: %v", root, path)
+	}
+	return name, nil
+}
+
+func (s *Storage) Upload(ctx context.Context, object *storage.Object) (string, error) {
+	if object == nil {
+		return "", errors.NewInvalidParamError("object: %v", object)
+	}
+	name, err := s.objectPath(object.Root, object.Path)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return "", errors.NewInternalError("%v", err)
+	}
+	if err := os.WriteFile(name, object.Bytes, 0o644); err != nil {
+		return "", errors.NewInternalError("%v", err)
+	}
+	return s.baseUrl + "/" + object.Root + "/" + object.Path, nil
+}
+
+func (s *Storage) Delete(ctx context.Context, root, path string) error {
+	name, err := s.objectPath(root, path)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(name); err != nil {
+		return errors.NewInternalError("%v", err)
+	}
+	return nil
+}
+
+// DeleteDir deletes every object in root whose path starts with dir, the same
+// way a prefix query on a bucket does. dir is not required to end at a
+// directory boundary.
+func (s *Storage) DeleteDir(ctx context.Context, root, dir string) error {
+	rootDir, err := s.objectPath(root, "")
+	if err != nil {
+		return err
+	}
+	err = filepath.WalkDir(rootDir, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(rootDir, name)
+		if err != nil {
+			return err
+		}
+		if strings.HasPrefix(filepath.ToSlash(rel), dir) {
+			return os.Remove(name)
+		}
+		return nil
+	})
+	if err != nil {
+		return errors.NewInternalError("%v", err)
+	}
+	return nil
+}
+
+// Handler serves uploaded objects. Mount it at the path of baseUrl.
+func (s *Storage) Handler() http.Handler {
+	files := http.FileServer(http.Dir(s.dir))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/") {
+			http.NotFound(w, r)
+			return
+		}
+		files.ServeHTTP(w, r)
+	})
+}
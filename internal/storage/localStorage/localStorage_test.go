@@ -0,0 +1,58 @@
+package localStorage
+
+import (
+	"context"
+	"io"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"ohmnyom/internal/storage"
+)
+
+func TestStorage_Upload(t *testing.T) {
+	ctx := context.TODO()
+	s, err := New(t.TempDir(), "http://localhost:8081/")
+	assert.NoError(t, err)
+
+	link, err := s.Upload(ctx, &storage.Object{
+		Root:        "ohmnyom",
+		Path:        "pets/pet1/profiles/1",
+		ContentType: "text/plain",
+		Bytes:       []byte("nyom"),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "http://localhost:8081/ohmnyom/pets/pet1/profiles/1", link)
+
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/ohmnyom/pets/pet1/profiles/1", nil))
+	body, _ := io.ReadAll(rec.Body)
+	assert.Equal(t, 200, rec.Code)
+	assert.Equal(t, "nyom", string(body))
+
+	_, err = s.Upload(ctx, &storage.Object{Root: "ohmnyom", Path: "../../escape", Bytes: []byte("x")})
+	assert.Error(t, err)
+}
+
+func TestStorage_DeleteDir(t *testing.T) {
+	ctx := context.TODO()
+	s, err := New(t.TempDir(), "")
+	assert.NoError(t, err)
+
+	paths := []string{
+		"pets/pet1/profiles/1",
+		"pets/pet1/profiles/2",
+		"pets/pet10/profiles/1",
+		"pets/pet2/profiles/1",
+	}
+	for _, p := range paths {
+		_, err := s.Upload(ctx, &storage.Object{Root: "ohmnyom", Path: p, Bytes: []byte(p)})
+		assert.NoError(t, err)
+	}
+
+	assert.NoError(t, s.DeleteDir(ctx, "ohmnyom", "pets/pet1"))
+	assert.Error(t, s.Delete(ctx, "ohmnyom", "pets/pet1/profiles/1"))
+	assert.Error(t, s.Delete(ctx, "ohmnyom", "pets/pet10/profiles/1"))
+	assert.NoError(t, s.Delete(ctx, "ohmnyom", "pets/pet2/profiles/1"))
+	assert.NoError(t, s.DeleteDir(ctx, "not-exist", "pets"))
+}